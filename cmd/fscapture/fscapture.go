@@ -0,0 +1,196 @@
+//fscapture subscribes to Freeswitch events and writes them to rotating capture
+//files, either as one JSON object per line (jsonl) or as length-prefixed binary
+//records with timestamps (framed), for collecting reproduction data.
+//
+//The framed format starts with an 8 byte file header: the magic number
+//0xf5ca7e01 followed by a uint16 major and minor version (1.0). Each record is
+//a uint32 seconds and uint32 microseconds timestamp, a uint32 payload length
+//and the payload, which is the event encoded as a JSON object. All integers are
+//big-endian.
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tomponline/fsclient/fsclient"
+)
+
+const framedMagic = 0xf5ca7e01
+
+//filterFlags collects repeated -filter flags.
+type filterFlags []string
+
+func (filters *filterFlags) String() string {
+	return strings.Join(*filters, ", ")
+}
+
+func (filters *filterFlags) Set(value string) error {
+	*filters = append(*filters, value)
+	return nil
+}
+
+//captureFile is an open capture file that is rotated by size and age.
+type captureFile struct {
+	dir     string
+	prefix  string
+	format  string
+	maxSize int64
+	maxAge  time.Duration
+	file    *os.File
+	w       *bufio.Writer
+	size    int64
+	opened  time.Time
+}
+
+func main() {
+	var filters filterFlags
+	addr := flag.String("addr", "127.0.0.1:8021", "Freeswitch event socket address")
+	password := flag.String("password", "ClueCon", "Freeswitch event socket password")
+	events := flag.String("events", "ALL", "Space separated events to capture")
+	flag.Var(&filters, "filter", "Event filter as \"<header> <value>\", may be repeated")
+	format := flag.String("format", "jsonl", "Output format: jsonl or framed")
+	dir := flag.String("dir", ".", "Output directory")
+	prefix := flag.String("prefix", "fscapture", "Output file name prefix")
+	maxSize := flag.Int64("max-size", 100*1024*1024, "Rotate files after this many bytes (0 to disable)")
+	maxAge := flag.Duration("max-age", time.Hour, "Rotate files after this long (0 to disable)")
+	bufSize := flag.Int("buffer", 1000, "Event buffer size")
+	flag.Parse()
+
+	if *format != "jsonl" && *format != "framed" {
+		log.Fatal("Invalid format: ", *format)
+	}
+
+	capture := &captureFile{
+		dir:     *dir,
+		prefix:  *prefix,
+		format:  *format,
+		maxSize: *maxSize,
+		maxAge:  *maxAge,
+	}
+
+	fs := fsclient.NewClient(*addr, *password, filters, []string{*events}, *bufSize, initFunc)
+
+	//Close the client on interrupt, which closes EventCh and ends the capture.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		fs.Close()
+	}()
+
+	count := 0
+	for event := range fs.EventCh {
+		if err := capture.write(time.Now(), event); err != nil {
+			log.Fatal("Write failed: ", err)
+		}
+		count++
+	}
+
+	if err := capture.close(); err != nil {
+		log.Fatal("Close failed: ", err)
+	}
+	fmt.Println("Captured", count, "events")
+}
+
+func initFunc(client *fsclient.Client) {
+	fmt.Println("Connected, capturing events...")
+}
+
+//write appends an event to the capture file, rotating it first if needed.
+func (capture *captureFile) write(received time.Time, event map[string]string) error {
+	if capture.file == nil || capture.due(received) {
+		if err := capture.rotate(received); err != nil {
+			return err
+		}
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if capture.format == "jsonl" {
+		payload = append(payload, '\n')
+	} else {
+		var header [12]byte
+		binary.BigEndian.PutUint32(header[0:], uint32(received.Unix()))
+		binary.BigEndian.PutUint32(header[4:], uint32(received.Nanosecond()/1000))
+		binary.BigEndian.PutUint32(header[8:], uint32(len(payload)))
+		if _, err = capture.w.Write(header[:]); err != nil {
+			return err
+		}
+		capture.size += int64(len(header))
+	}
+
+	n, err := capture.w.Write(payload)
+	capture.size += int64(n)
+	return err
+}
+
+//due returns true if the current file has reached its size or age limit.
+func (capture *captureFile) due(now time.Time) bool {
+	if capture.maxSize > 0 && capture.size >= capture.maxSize {
+		return true
+	}
+	return capture.maxAge > 0 && now.Sub(capture.opened) >= capture.maxAge
+}
+
+//rotate closes the current file (if any) and opens a new one.
+func (capture *captureFile) rotate(now time.Time) error {
+	if err := capture.close(); err != nil {
+		return err
+	}
+
+	ext := ".jsonl"
+	if capture.format == "framed" {
+		ext = ".fscap"
+	}
+	name := filepath.Join(capture.dir, capture.prefix+"-"+now.Format("20060102-150405.000000")+ext)
+
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	log.Print("Writing to ", name)
+
+	capture.file = file
+	capture.w = bufio.NewWriter(file)
+	capture.size = 0
+	capture.opened = now
+
+	if capture.format == "framed" {
+		var header [8]byte
+		binary.BigEndian.PutUint32(header[0:], framedMagic)
+		binary.BigEndian.PutUint16(header[4:], 1)
+		binary.BigEndian.PutUint16(header[6:], 0)
+		n, err := capture.w.Write(header[:])
+		capture.size += int64(n)
+		return err
+	}
+	return nil
+}
+
+//close flushes and closes the current file.
+func (capture *captureFile) close() error {
+	if capture.file == nil {
+		return nil
+	}
+
+	flushErr := capture.w.Flush()
+	closeErr := capture.file.Close()
+	capture.file = nil
+	capture.w = nil
+	return errors.Join(flushErr, closeErr)
+}
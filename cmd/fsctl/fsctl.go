@@ -0,0 +1,264 @@
+//fsctl is an interactive Freeswitch console, similar to fs_cli, built on the
+//fsclient package. Lines are sent as api commands (or bgapi commands when
+//prefixed with "bgapi") and subscribed events are tailed to the terminal.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/tomponline/fsclient/fsclient"
+)
+
+const colorReset = "\x1b[0m"
+
+//eventColors are the ANSI colors used for event names. Each event name is
+//always shown in the same color.
+var eventColors = []string{
+	"\x1b[31m", "\x1b[32m", "\x1b[33m", "\x1b[34m", "\x1b[35m", "\x1b[36m",
+	"\x1b[91m", "\x1b[92m", "\x1b[93m", "\x1b[94m", "\x1b[95m", "\x1b[96m",
+}
+
+var fs *fsclient.Client
+var serverAddr string
+var tailing int32 = 1
+var verbose int32
+var noColor bool
+var history []string
+
+func main() {
+	home, _ := os.UserHomeDir()
+	flag.StringVar(&serverAddr, "addr", "127.0.0.1:8021", "Freeswitch event socket address")
+	password := flag.String("password", "ClueCon", "Freeswitch event socket password")
+	events := flag.String("events", "", "Space separated events to subscribe to on startup")
+	historyFile := flag.String("history", filepath.Join(home, ".fsctl_history"), "Command history file")
+	flag.BoolVar(&noColor, "nocolor", false, "Disable colored output")
+	flag.Parse()
+
+	var subs []string
+	if *events != "" {
+		subs = append(subs, *events)
+	}
+
+	fs = fsclient.NewClient(serverAddr, *password, nil, subs, 100, initFunc)
+	go tailEvents()
+
+	loadHistory(*historyFile)
+	console(*historyFile)
+	fs.Close()
+}
+
+func initFunc(client *fsclient.Client) {
+	fmt.Println("\rConnected to", serverAddr)
+}
+
+//console reads commands from stdin until EOF or /quit.
+func console(historyFile string) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("fsctl> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		//Recall a previous command with !! or !N.
+		if strings.HasPrefix(line, "!") {
+			recalled, ok := recallHistory(line)
+			if !ok {
+				fmt.Println("No such history entry:", line)
+				continue
+			}
+			line = recalled
+			fmt.Println(line)
+		}
+
+		if line != "/history" {
+			addHistory(historyFile, line)
+		}
+
+		if !runCommand(line) {
+			return
+		}
+	}
+}
+
+//runCommand executes a console line. It returns false if the console should exit.
+func runCommand(line string) bool {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case "/quit", "/exit", "/bye":
+		return false
+	case "/help":
+		printHelp()
+	case "/history":
+		for i, cmd := range history {
+			fmt.Printf("%4d  %s\n", i+1, cmd)
+		}
+	case "/event":
+		if len(fields) < 2 {
+			fmt.Println("Usage: /event <name> [name...]")
+			break
+		}
+		printResult("", fs.Subscribe(strings.Join(fields[1:], " ")))
+	case "/filter":
+		if len(fields) < 3 {
+			fmt.Println("Usage: /filter <header> <value>")
+			break
+		}
+		printResult("", fs.AddFilter(fields[1]+" "+strings.Join(fields[2:], " ")))
+	case "/tail":
+		setToggle(&tailing, fields)
+	case "/verbose":
+		setToggle(&verbose, fields)
+	case "bgapi":
+		if len(fields) < 2 {
+			fmt.Println("Usage: bgapi <command>")
+			break
+		}
+		jobUUID, err := fs.BackgroundAPI(strings.TrimSpace(strings.TrimPrefix(line, "bgapi")))
+		printResult("Job-UUID: "+jobUUID, err)
+	default:
+		if strings.HasPrefix(line, "/") {
+			fmt.Println("Unknown command, type /help for help")
+			break
+		}
+		res, err := fs.API(line)
+		printResult(strings.TrimRight(res, "\n"), err)
+	}
+	return true
+}
+
+func printHelp() {
+	fmt.Println(`Commands:
+  <command>                 Send an api command, e.g. "status"
+  bgapi <command>           Send a bgapi command
+  /event <name> [name...]   Subscribe to events, e.g. "/event CHANNEL_CREATE"
+  /filter <header> <value>  Only receive events matching a header value
+  /tail on|off              Show or hide received events
+  /verbose on|off           Show all event headers
+  /history                  List command history, recall with !N or !!
+  /quit                     Exit`)
+}
+
+func printResult(res string, err error) {
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if res != "" {
+		fmt.Println(res)
+	}
+}
+
+//setToggle sets a flag from an on/off argument, or flips it if none is given.
+func setToggle(toggle *int32, fields []string) {
+	value := atomic.LoadInt32(toggle) ^ 1
+	if len(fields) > 1 {
+		value = 0
+		if fields[1] == "on" {
+			value = 1
+		}
+	}
+	atomic.StoreInt32(toggle, value)
+	fmt.Println("OK")
+}
+
+//tailEvents prints events as they arrive while tailing is enabled.
+func tailEvents() {
+	for event := range fs.EventCh {
+		if atomic.LoadInt32(&tailing) == 0 {
+			continue
+		}
+
+		name := event["Event-Name"]
+		if name == "CUSTOM" && event["Event-Subclass"] != "" {
+			name += " " + event["Event-Subclass"]
+		}
+
+		fmt.Print("\r", colorize(name, "["+name+"]"))
+		if uuid := event["Unique-ID"]; uuid != "" {
+			fmt.Print(" ", uuid)
+		}
+		fmt.Println()
+
+		if atomic.LoadInt32(&verbose) == 1 {
+			keys := make([]string, 0, len(event))
+			for key := range event {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Printf("  %s: %s\n", key, event[key])
+			}
+		} else if body := event["body-string"]; body != "" {
+			fmt.Println(strings.TrimRight(body, "\n"))
+		}
+	}
+}
+
+//colorize wraps text in the color assigned to an event name.
+func colorize(name string, text string) string {
+	if noColor {
+		return text
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+	return eventColors[hash.Sum32()%uint32(len(eventColors))] + text + colorReset
+}
+
+//loadHistory reads previous commands from the history file.
+func loadHistory(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			history = append(history, line)
+		}
+	}
+}
+
+//addHistory records a command in memory and appends it to the history file.
+func addHistory(path string, line string) {
+	history = append(history, line)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, line)
+}
+
+//recallHistory returns the history entry for "!!" (last command) or "!N".
+func recallHistory(line string) (string, bool) {
+	if len(history) == 0 {
+		return "", false
+	}
+	if line == "!!" {
+		return history[len(history)-1], true
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 || n > len(history) {
+		return "", false
+	}
+	return history[n-1], true
+}
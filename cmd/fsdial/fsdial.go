@@ -0,0 +1,240 @@
+//fsdial reads a CSV file of destinations and originates a call to each one
+//using bgapi, paced at a configurable rate. Results are correlated with the
+//BACKGROUND_JOB events by Job-UUID and reported as CSV on stdout.
+//
+//Each CSV row is "<dial string>[,<destination>]", e.g.
+//"sofia/gateway/carrier/15551234,&playback(welcome.wav)". If the destination
+//is omitted the -dest flag is used. Lines starting with "#" are ignored.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tomponline/fsclient/fsclient"
+)
+
+//dialResult is the outcome of a single originate.
+type dialResult struct {
+	row        int
+	dialString string
+	jobUUID    string
+	outcome    string
+	detail     string
+}
+
+//tracker correlates BACKGROUND_JOB events with the originates that caused
+//them. Job results can arrive before BackgroundAPI has returned the Job-UUID
+//to us, so unmatched results are kept until the originate is registered.
+type tracker struct {
+	mu        sync.Mutex
+	pending   map[string]*dialResult
+	unmatched map[string]string
+	results   []*dialResult
+	done      chan struct{}
+	remaining int
+}
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8021", "Freeswitch event socket address")
+	password := flag.String("password", "ClueCon", "Freeswitch event socket password")
+	file := flag.String("file", "", "CSV file of destinations (default stdin)")
+	dest := flag.String("dest", "&park()", "Destination when not given in the CSV")
+	vars := flag.String("vars", "", "Channel variables to add to each originate, e.g. \"origination_caller_id_number=1000\"")
+	rate := flag.Float64("rate", 1, "Calls per second")
+	wait := flag.Duration("wait", 2*time.Minute, "Maximum time to wait for results after the last call")
+	flag.Parse()
+
+	in := os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	rows, err := readRows(in)
+	if err != nil {
+		log.Fatal("Failed to read CSV: ", err)
+	}
+	if len(rows) == 0 {
+		log.Fatal("No destinations to dial")
+	}
+
+	//The job results are subscribed to from the init function rather than by
+	//passing filters to NewClient, as those are applied concurrently with it
+	//and the first originates could be sent before the results are received.
+	//Both are remembered and reapplied by the client after reconnecting.
+	ready := make(chan struct{})
+	var subscribe sync.Once
+	fs := fsclient.NewClient(*addr, *password, nil, nil, 1000, func(client *fsclient.Client) {
+		subscribe.Do(func() {
+			if err := client.AddFilter("Event-Name BACKGROUND_JOB"); err != nil {
+				log.Fatal("Failed to filter job results: ", err)
+			}
+			if err := client.Subscribe("BACKGROUND_JOB"); err != nil {
+				log.Fatal("Failed to subscribe to job results: ", err)
+			}
+			close(ready)
+		})
+	})
+	defer fs.Close()
+
+	//Pace the originates using the client's bgapi rate limiter.
+	fs.SetRateLimit(fsclient.ClassBGAPI, *rate, 1)
+
+	t := &tracker{
+		pending:   make(map[string]*dialResult),
+		unmatched: make(map[string]string),
+		done:      make(chan struct{}),
+		remaining: len(rows),
+	}
+	go t.readEvents(fs.EventCh)
+
+	<-ready
+	for i, row := range rows {
+		target := *dest
+		if len(row) > 1 && row[1] != "" {
+			target = row[1]
+		}
+
+		dialString := row[0]
+		if *vars != "" {
+			dialString = "{" + *vars + "}" + dialString
+		}
+
+		res := &dialResult{row: i + 1, dialString: row[0]}
+		jobUUID, err := fs.BackgroundAPI("originate " + dialString + " " + target)
+		if err != nil {
+			res.outcome = "FAILED"
+			res.detail = err.Error()
+			t.finish(res)
+			continue
+		}
+		res.jobUUID = jobUUID
+		t.register(res)
+		log.Print("Dialled row ", res.row, " ", row[0], " (Job-UUID ", jobUUID, ")")
+	}
+
+	select {
+	case <-t.done:
+	case <-time.After(*wait):
+		log.Print("Timed out waiting for results")
+	}
+	t.report(os.Stdout)
+}
+
+//readRows reads the non-empty, non-comment CSV rows.
+func readRows(in io.Reader) ([][]string, error) {
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	var rows [][]string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) > 0 && row[0] != "" {
+			rows = append(rows, row)
+		}
+	}
+}
+
+//readEvents processes BACKGROUND_JOB events.
+func (t *tracker) readEvents(eventCh chan map[string]string) {
+	for event := range eventCh {
+		if event["Event-Name"] != "BACKGROUND_JOB" {
+			continue
+		}
+
+		jobUUID := event["Job-UUID"]
+		body := strings.TrimSpace(event["body-string"])
+
+		t.mu.Lock()
+		res, ok := t.pending[jobUUID]
+		if ok {
+			delete(t.pending, jobUUID)
+		} else {
+			t.unmatched[jobUUID] = body
+		}
+		t.mu.Unlock()
+
+		if ok {
+			setOutcome(res, body)
+			t.finish(res)
+		}
+	}
+}
+
+//register records an originate that is waiting for its job result.
+func (t *tracker) register(res *dialResult) {
+	t.mu.Lock()
+	body, ok := t.unmatched[res.jobUUID]
+	if ok {
+		delete(t.unmatched, res.jobUUID)
+	} else {
+		t.pending[res.jobUUID] = res
+	}
+	t.mu.Unlock()
+
+	if ok {
+		setOutcome(res, body)
+		t.finish(res)
+	}
+}
+
+//finish records a completed result.
+func (t *tracker) finish(res *dialResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.results = append(t.results, res)
+	t.remaining--
+	if t.remaining == 0 {
+		close(t.done)
+	}
+}
+
+//report writes the results as CSV, including rows still waiting for a result.
+func (t *tracker) report(w io.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"row", "dial_string", "job_uuid", "outcome", "detail"})
+	for _, res := range t.results {
+		out.Write([]string{fmt.Sprint(res.row), res.dialString, res.jobUUID, res.outcome, res.detail})
+	}
+	for _, res := range t.pending {
+		out.Write([]string{fmt.Sprint(res.row), res.dialString, res.jobUUID, "NO_RESULT", ""})
+	}
+	out.Flush()
+}
+
+//setOutcome decodes an originate job result, which is "+OK <uuid>" on success
+//or "-ERR <cause>" on failure.
+func setOutcome(res *dialResult, body string) {
+	if strings.HasPrefix(body, "+OK") {
+		res.outcome = "ANSWERED"
+		res.detail = strings.TrimSpace(strings.TrimPrefix(body, "+OK"))
+		return
+	}
+
+	res.outcome = "FAILED"
+	res.detail = strings.TrimSpace(strings.TrimPrefix(body, "-ERR"))
+}
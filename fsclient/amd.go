@@ -0,0 +1,142 @@
+package fsclient
+
+import "strings"
+
+//AMDResult is the outcome of answering machine detection on a call.
+type AMDResult int
+
+const (
+	//AMDPending means no detection result has been received.
+	AMDPending AMDResult = iota
+
+	//AMDUnknown means detection finished without a decision.
+	AMDUnknown
+
+	//AMDHuman means the call was answered by a person.
+	AMDHuman
+
+	//AMDMachine means the call was answered by a machine or voicemail.
+	AMDMachine
+)
+
+func (result AMDResult) String() string {
+	switch result {
+	case AMDUnknown:
+		return "UNKNOWN"
+	case AMDHuman:
+		return "HUMAN"
+	case AMDMachine:
+		return "MACHINE"
+	}
+	return "PENDING"
+}
+
+//StartAMD runs answering machine detection on the channel uuid, using either
+//mod_amd ("amd") or mod_avmd ("avmd", with arg "start"). Results are decoded
+//by a CallManager, see CallManager.OnAMDResult.
+func (client *Client) StartAMD(uuid string, app string, arg string) error {
+	return startAMD(client, uuid, app, arg)
+}
+
+//startAMD runs answering machine detection on the channel uuid with client.
+func startAMD(client Commander, uuid string, app string, arg string) error {
+	_, err := client.Execute(app, arg, uuid, false)
+	return err
+}
+
+//decodeAMDEvent returns the detection result carried by an event, if it is an
+//AMD event. mod_amd reports its result in the amd_result channel variable,
+//which is sent with the amd application's CHANNEL_EXECUTE_COMPLETE and its
+//CUSTOM amd:: events. mod_avmd only detects voicemail beeps, so an avmd::beep
+//event means a machine and a timeout or stop without a beep is unknown.
+func decodeAMDEvent(event Event) (AMDResult, bool) {
+	switch event.Name() {
+	case "CUSTOM":
+		subclass := event["Event-Subclass"]
+		switch {
+		case subclass == "avmd::beep":
+			return AMDMachine, true
+		case subclass == "avmd::timeout":
+			return AMDUnknown, true
+		case subclass == "avmd::stop":
+			if event["Beep-Status"] == "DETECTED" {
+				return AMDMachine, true
+			}
+			return AMDUnknown, true
+		case strings.HasPrefix(subclass, "amd::"):
+			return parseAMDResult(event)
+		}
+	case "CHANNEL_EXECUTE_COMPLETE":
+		if event["Application"] == "amd" {
+			return parseAMDResult(event)
+		}
+	}
+	return AMDPending, false
+}
+
+//parseAMDResult decodes the amd_result variable of an event from mod_amd.
+func parseAMDResult(event Event) (AMDResult, bool) {
+	value := event["variable_amd_result"]
+	if value == "" {
+		value = event["AMD-Result"]
+	}
+
+	switch strings.ToUpper(value) {
+	case "":
+		return AMDPending, false
+	case "HUMAN", "PERSON":
+		return AMDHuman, true
+	case "MACHINE":
+		return AMDMachine, true
+	}
+	return AMDUnknown, true
+}
+
+//AMD returns the answering machine detection result for the call.
+func (call *Call) AMD() AMDResult {
+	call.mu.RLock()
+	defer call.mu.RUnlock()
+	return call.amd
+}
+
+//OnAMDResult registers a function to be called when answering machine
+//detection finishes on a call. Only the first result for a call is reported.
+//The client must be subscribed to CUSTOM avmd:: or amd:: events, or to
+//CHANNEL_EXECUTE_COMPLETE for mod_amd.
+func (manager *CallManager) OnAMDResult(fn func(call *Call, result AMDResult)) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.onAMD = append(manager.onAMD, fn)
+}
+
+//amdResult records the detection result of an AMD event on a tracked call
+//and runs the AMD callbacks.
+func (manager *CallManager) amdResult(event Event) {
+	result, ok := decodeAMDEvent(event)
+	if !ok {
+		return
+	}
+
+	manager.mu.RLock()
+	call := manager.calls[event.UUID()]
+	onAMD := manager.onAMD
+	manager.mu.RUnlock()
+
+	if call == nil {
+		return
+	}
+
+	call.mu.Lock()
+	first := call.amd == AMDPending
+	if first {
+		call.amd = result
+	}
+	call.mu.Unlock()
+
+	if !first {
+		return
+	}
+	for _, fn := range onAMD {
+		fn(call, result)
+	}
+}
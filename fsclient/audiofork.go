@@ -0,0 +1,125 @@
+package fsclient
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+//AudioForkModule is a Freeswitch module that streams channel audio over a
+//WebSocket.
+type AudioForkModule string
+
+const (
+	//AudioFork is mod_audio_fork (uuid_audio_fork).
+	AudioFork AudioForkModule = "audio_fork"
+
+	//AudioStream is mod_audio_stream (uuid_audio_stream).
+	AudioStream AudioForkModule = "audio_stream"
+)
+
+//AudioMix selects which audio is streamed.
+type AudioMix string
+
+const (
+	//MixMono streams the caller's audio only.
+	MixMono AudioMix = "mono"
+
+	//MixMixed streams both directions mixed into one channel.
+	MixMixed AudioMix = "mixed"
+
+	//MixStereo streams both directions as separate stereo channels.
+	MixStereo AudioMix = "stereo"
+)
+
+//AudioForkEvent is a decoded CUSTOM event from an audio fork module. Kind is
+//the part of the Event-Subclass after "::", e.g. "connect", "connect_failed",
+//"disconnect", "error" or "json". Data holds the event body, which for most
+//kinds is JSON sent by the module or by the remote service.
+type AudioForkEvent struct {
+	Module AudioForkModule
+	Kind   string
+	UUID   string
+	Data   json.RawMessage
+}
+
+//StartAudioFork starts streaming a channel's audio to a WebSocket url, e.g.
+//"wss://asr.example.com/stream", at sampleRate ("8k" or "16k"). metadata, if
+//not nil, is sent to the service as JSON when the stream connects, for
+//example to identify the call.
+func (client *Client) StartAudioFork(module AudioForkModule, uuid string, url string, mix AudioMix, sampleRate string, metadata interface{}) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+	cmd := "uuid_" + string(module) + " " + uuid + " start " + url + " " + string(mix) + " " + sampleRate
+	if metadata != nil {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		cmd += " " + string(data)
+	}
+	return client.apiOK(cmd)
+}
+
+//StopAudioFork stops streaming a channel's audio. metadata, if not nil, is
+//sent to the service as JSON before the stream is closed.
+func (client *Client) StopAudioFork(module AudioForkModule, uuid string, metadata interface{}) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+	cmd := "uuid_" + string(module) + " " + uuid + " stop"
+	if metadata != nil {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		cmd += " " + string(data)
+	}
+	return client.apiOK(cmd)
+}
+
+//SendAudioForkText sends a text message to the service on a channel's open
+//stream.
+func (client *Client) SendAudioForkText(module AudioForkModule, uuid string, text string) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+	return client.apiOK("uuid_" + string(module) + " " + uuid + " send_text " + text)
+}
+
+//DecodeAudioForkEvent decodes a CUSTOM event from mod_audio_fork or
+//mod_audio_stream. The bool result is false for any other event. The client
+//must be subscribed to the module's events, e.g.
+//"CUSTOM mod_audio_fork::connect mod_audio_fork::json".
+func DecodeAudioForkEvent(event Event) (AudioForkEvent, bool) {
+	if event.Name() != "CUSTOM" {
+		return AudioForkEvent{}, false
+	}
+
+	prefix, kind, ok := strings.Cut(event["Event-Subclass"], "::")
+	if !ok {
+		return AudioForkEvent{}, false
+	}
+
+	var module AudioForkModule
+	switch prefix {
+	case "mod_audio_fork":
+		module = AudioFork
+	case "mod_audio_stream":
+		module = AudioStream
+	default:
+		return AudioForkEvent{}, false
+	}
+
+	forkEvent := AudioForkEvent{Module: module, Kind: kind, UUID: event.UUID()}
+	if body := strings.TrimSpace(event.Body()); body != "" {
+		//Keep non-JSON bodies, such as plain error text, as a JSON string so
+		//Data is always valid JSON.
+		if json.Valid([]byte(body)) {
+			forkEvent.Data = json.RawMessage(body)
+		} else {
+			forkEvent.Data, _ = json.Marshal(body)
+		}
+	}
+	return forkEvent, true
+}
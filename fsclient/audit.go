@@ -0,0 +1,150 @@
+package fsclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+//maxAuditReply is the maximum length of the reply summary in an AuditRecord.
+const maxAuditReply = 200
+
+//callerKey is the context key for the caller identity set by WithCaller.
+type callerKey struct{}
+
+//WithCaller returns a context carrying the identity of the user or service
+//issuing commands, for authorizers and audit records.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+//CallerFromContext returns the caller identity set by WithCaller, or an empty
+//string.
+func CallerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+//AuditRecord describes a command sent by the application. Command is the
+//command as sent, after any rewriting by the authorizer. Reply is the first
+//line of the reply, or the Job-UUID for bgapi, truncated to a summary. Err is
+//set if the command failed or was denied.
+type AuditRecord struct {
+	Time     time.Time
+	Caller   string
+	Command  Command
+	Reply    string
+	Err      error
+	Duration time.Duration
+	Context  context.Context
+}
+
+//AuditSink receives an AuditRecord for every command sent. Audit is called
+//synchronously once the command completes, so it should not block for long.
+type AuditSink interface {
+	Audit(record AuditRecord)
+}
+
+//AuditFunc is an AuditSink implemented by a function.
+type AuditFunc func(record AuditRecord)
+
+//Audit calls the function.
+func (fn AuditFunc) Audit(record AuditRecord) {
+	fn(record)
+}
+
+//SetAuditSink sets the sink that receives an audit record for every command,
+//or removes it if sink is nil.
+func (client *Client) SetAuditSink(sink AuditSink) {
+	client.optMu.Lock()
+	defer client.optMu.Unlock()
+	client.auditSink = sink
+}
+
+//audit records the latency of a completed command and sends a record of it
+//to the audit sink, if any.
+func (client *Client) audit(ctx context.Context, cmd Command, start time.Time, reply string, err error) {
+	duration := client.now().Sub(start)
+	client.stats.record(cmd, duration, err)
+
+	client.optMu.RLock()
+	sink := client.auditSink
+	client.optMu.RUnlock()
+
+	if sink == nil {
+		return
+	}
+
+	reply, _, _ = strings.Cut(reply, "\n")
+	if len(reply) > maxAuditReply {
+		reply = reply[:maxAuditReply]
+	}
+
+	sink.Audit(AuditRecord{
+		Time:     start,
+		Caller:   CallerFromContext(ctx),
+		Command:  cmd,
+		Reply:    reply,
+		Err:      err,
+		Duration: duration,
+		Context:  ctx,
+	})
+}
+
+//JSONAuditSink writes audit records as JSON lines.
+type JSONAuditSink struct {
+	w  io.Writer
+	mu *sync.Mutex
+}
+
+//NewJSONAuditSink creates an AuditSink that writes one JSON object per record
+//to w.
+func NewJSONAuditSink(w io.Writer) *JSONAuditSink {
+	return &JSONAuditSink{w: w, mu: &sync.Mutex{}}
+}
+
+//jsonAuditRecord is the JSON form of an AuditRecord.
+type jsonAuditRecord struct {
+	Time     time.Time `json:"time"`
+	Caller   string    `json:"caller,omitempty"`
+	Class    string    `json:"class"`
+	Name     string    `json:"name"`
+	Args     string    `json:"args,omitempty"`
+	UUID     string    `json:"uuid,omitempty"`
+	Reply    string    `json:"reply,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Duration float64   `json:"duration_ms"`
+}
+
+//Audit writes a record.
+func (sink *JSONAuditSink) Audit(record AuditRecord) {
+	out := jsonAuditRecord{
+		Time:     record.Time,
+		Caller:   record.Caller,
+		Class:    record.Command.Class.String(),
+		Name:     record.Command.Name,
+		Args:     record.Command.Args,
+		UUID:     record.Command.UUID,
+		Reply:    record.Reply,
+		Duration: float64(record.Duration) / float64(time.Millisecond),
+	}
+	if record.Err != nil {
+		out.Error = record.Err.Error()
+	}
+
+	line, err := json.Marshal(out)
+	if err != nil {
+		log.Print(logPrefix, "Failed to encode audit record: ", err)
+		return
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if _, err := sink.w.Write(append(line, '\n')); err != nil {
+		log.Print(logPrefix, "Failed to write audit record: ", err)
+	}
+}
@@ -0,0 +1,60 @@
+package fsclient
+
+import (
+	"context"
+	"strings"
+)
+
+//Command is an outgoing command as seen by an Authorizer. For api and bgapi
+//commands Name is the command, e.g. "uuid_kill", and Args the rest of the
+//command line. For execute commands Name is the application, Args its
+//argument and UUID the channel. For sendevent Name is the event name. For
+//raw commands Name is the first word and Args the rest of the raw text.
+type Command struct {
+	Class CommandClass
+	Name  string
+	Args  string
+	UUID  string
+}
+
+//String returns the command line of an api or bgapi command.
+func (cmd Command) String() string {
+	if cmd.Args == "" {
+		return cmd.Name
+	}
+	return cmd.Name + " " + cmd.Args
+}
+
+//parseCommand splits an api or bgapi command line into a Command.
+func parseCommand(class CommandClass, line string) Command {
+	name, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+	return Command{Class: class, Name: name, Args: strings.TrimSpace(args)}
+}
+
+//Authorizer is called before every command the application sends, with the
+//context passed to the ...Context method (context.Background() otherwise).
+//It returns the command to send, which may be rewritten, or an error to deny
+//it, which is returned to the caller and nothing is sent. Commands the client
+//sends itself, such as event subscriptions, are not authorized.
+type Authorizer func(ctx context.Context, cmd Command) (Command, error)
+
+//SetAuthorizer sets the authorizer for outgoing commands, or removes it if
+//authorizer is nil. Callers can attach their identity to the context with
+//WithCaller for the authorizer to check.
+func (client *Client) SetAuthorizer(authorizer Authorizer) {
+	client.optMu.Lock()
+	defer client.optMu.Unlock()
+	client.authorize = authorizer
+}
+
+//authorizeCommand runs the authorizer, if any, on a command.
+func (client *Client) authorizeCommand(ctx context.Context, cmd Command) (Command, error) {
+	client.optMu.RLock()
+	authorizer := client.authorize
+	client.optMu.RUnlock()
+
+	if authorizer == nil {
+		return cmd, nil
+	}
+	return authorizer(ctx, cmd)
+}
@@ -0,0 +1,63 @@
+package fsclient
+
+import "errors"
+
+//AutoAnswerProfile is the way a make of phone is asked to answer a call by
+//itself, for intercom and paging calls. Phones are usually configured to
+//honour the header only from their own registrar.
+type AutoAnswerProfile string
+
+const (
+	//AutoAnswerGeneric sends both a Call-Info answer-after and an Alert-Info
+	//auto answer header, which most phones honour one of.
+	AutoAnswerGeneric AutoAnswerProfile = "generic"
+
+	//AutoAnswerPolycom sends "Alert-Info: Ring Answer", which needs an
+	//alertInfo class with that value set to auto answer on the phone.
+	AutoAnswerPolycom AutoAnswerProfile = "polycom"
+
+	//AutoAnswerYealink sends a Call-Info answer-after header.
+	AutoAnswerYealink AutoAnswerProfile = "yealink"
+
+	//AutoAnswerSnom sends a Call-Info answer-after header.
+	AutoAnswerSnom AutoAnswerProfile = "snom"
+
+	//AutoAnswerCisco sends a Call-Info answer-after header, for SPA and CP
+	//phones running SIP firmware.
+	AutoAnswerCisco AutoAnswerProfile = "cisco"
+
+	//AutoAnswerGrandstream sends "Alert-Info: info=alert-autoanswer" as well
+	//as a Call-Info answer-after header.
+	AutoAnswerGrandstream AutoAnswerProfile = "grandstream"
+)
+
+//Auto answer header values.
+const (
+	callInfoAnswerAfter = "<sip:127.0.0.1>;answer-after=0"
+	alertInfoAutoAnswer = "info=alert-autoanswer"
+	alertInfoRingAnswer = "Ring Answer"
+)
+
+//AutoAnswerVars returns the channel variables that add a profile's auto
+//answer SIP headers to an originated call, as set by OriginateRequest's
+//AutoAnswer.
+func AutoAnswerVars(profile AutoAnswerProfile) (map[string]string, error) {
+	switch profile {
+	case AutoAnswerGeneric:
+		return map[string]string{
+			"sip_auto_answer":  "true",
+			"sip_h_Call-Info":  callInfoAnswerAfter,
+			"sip_h_Alert-Info": alertInfoAutoAnswer,
+		}, nil
+	case AutoAnswerPolycom:
+		return map[string]string{"sip_h_Alert-Info": alertInfoRingAnswer}, nil
+	case AutoAnswerYealink, AutoAnswerSnom, AutoAnswerCisco:
+		return map[string]string{"sip_h_Call-Info": callInfoAnswerAfter}, nil
+	case AutoAnswerGrandstream:
+		return map[string]string{
+			"sip_h_Call-Info":  callInfoAnswerAfter,
+			"sip_h_Alert-Info": alertInfoAutoAnswer,
+		}, nil
+	}
+	return nil, errors.New("Unknown auto answer profile: " + string(profile))
+}
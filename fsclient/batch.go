@@ -0,0 +1,167 @@
+package fsclient
+
+import (
+	"context"
+	"errors"
+)
+
+//Batch is a sequence of commands that are written to the connection back to
+//back, without waiting for each reply before sending the next, and whose
+//replies are matched to them in order. It saves a round trip per command for
+//sequences such as setting several variables then transferring a call, which
+//matters over high latency links. Create one with Client.Batch.
+//
+//Commands in a batch aren't retried, and a command failing doesn't stop the
+//commands after it, which have already been sent.
+type Batch struct {
+	client   *Client
+	commands []batchCommand
+}
+
+//batchCommand is a command in a Batch, with the event lock flag of an
+//execute.
+type batchCommand struct {
+	command Command
+	lock    bool
+}
+
+//BatchResult is the result of a command in a Batch: the api result, the
+//Reply-Text of an execute, or the Job-UUID of a bgapi command.
+type BatchResult struct {
+	Command Command
+	Result  string
+	Err     error
+}
+
+//Batch returns an empty batch of commands to send with the client.
+func (client *Client) Batch() *Batch {
+	return &Batch{client: client}
+}
+
+//API adds an api command to the batch.
+func (batch *Batch) API(cmd string) *Batch {
+	batch.commands = append(batch.commands, batchCommand{command: parseCommand(ClassAPI, cmd)})
+	return batch
+}
+
+//BackgroundAPI adds a bgapi command to the batch.
+func (batch *Batch) BackgroundAPI(cmd string) *Batch {
+	batch.commands = append(batch.commands, batchCommand{command: parseCommand(ClassBGAPI, cmd)})
+	return batch
+}
+
+//Execute adds the execution of a dialplan application on a channel to the
+//batch.
+func (batch *Batch) Execute(app string, arg string, uuid string, lock bool) *Batch {
+	command := Command{Class: ClassExecute, Name: app, Args: arg, UUID: uuid}
+	batch.commands = append(batch.commands, batchCommand{command: command, lock: lock})
+	return batch
+}
+
+//Len returns the number of commands in the batch.
+func (batch *Batch) Len() int {
+	return len(batch.commands)
+}
+
+//Run sends the batch and returns a result for each command, in the order
+//they were added.
+func (batch *Batch) Run() ([]BatchResult, error) {
+	return batch.RunContext(context.Background())
+}
+
+//RunContext sends the batch like Run, passing ctx to the authorizer if one is
+//set. Every command is authorized before any is sent, so a command that
+//isn't allowed stops the whole batch.
+func (batch *Batch) RunContext(ctx context.Context) ([]BatchResult, error) {
+	client := batch.client
+	start := client.now()
+	if len(batch.commands) == 0 {
+		return nil, nil
+	}
+
+	commands := make([]Command, len(batch.commands))
+	frames := make([]string, len(batch.commands))
+	priority := PriorityLow
+	for i, entry := range batch.commands {
+		authorized, err := client.authorizeCommand(ctx, entry.command)
+		if err != nil {
+			return nil, err
+		}
+		commands[i] = authorized
+
+		frame, err := batchFrame(authorized, entry.lock)
+		if err != nil {
+			return nil, err
+		}
+		frames[i] = frame
+
+		if p := client.commandPriority(authorized.Class, authorized.String()); p > priority {
+			priority = p
+		}
+	}
+
+	results := make([]BatchResult, len(commands))
+	for i, command := range commands {
+		results[i].Command = command
+	}
+	defer func() {
+		for _, result := range results {
+			client.audit(ctx, result.Command, start, result.Result, result.Err)
+		}
+	}()
+
+	for _, command := range commands {
+		client.rateLimit(command.Class)
+	}
+
+	client.commands.acquire(priority)
+	defer client.commands.release()
+	client.connMu.Lock()
+	defer client.connMu.Unlock()
+
+	//If the command response channel is not intialised then it means we
+	//are not connected. So no point in sending a command.
+	resCh := client.cmdResCh
+	if resCh == nil {
+		for i := range results {
+			results[i].Err = errDisconnected
+		}
+		return results, errDisconnected
+	}
+
+	for _, frame := range frames {
+		client.eventConn.W.WriteString(frame)
+	}
+	if err := client.eventConn.W.Flush(); err != nil {
+		for i := range results {
+			results[i].Err = err
+		}
+		return results, err
+	}
+
+	//Freeswitch replies to the commands in the order they were sent. Once
+	//disconnected the closed channel gives every remaining command
+	//errDisconnected.
+	for i, command := range commands {
+		res := <-resCh
+		if command.Class == ClassBGAPI {
+			results[i].Result, results[i].Err = backgroundAPIResult(res)
+		} else {
+			results[i].Result, results[i].Err = cmdResult(res)
+		}
+	}
+	return results, nil
+}
+
+//batchFrame returns the text sent for a command in a batch.
+func batchFrame(command Command, lock bool) (string, error) {
+	switch command.Class {
+	case ClassAPI:
+		return "api " + command.String() + "\r\n\r\n", nil
+	case ClassBGAPI:
+		return "bgapi " + command.String() + "\r\n\r\n", nil
+	case ClassExecute:
+		return executeFrame(command.Name, command.Args, command.UUID, lock, ""), nil
+	}
+	return "", errors.New("Unsupported batch command: " + command.String())
+}
@@ -0,0 +1,180 @@
+package fsclient
+
+import (
+	"strings"
+	"sync"
+)
+
+//Leg selects which leg of a bridged call hears a broadcast.
+type Leg string
+
+const (
+	//LegA broadcasts to the channel itself.
+	LegA Leg = "aleg"
+
+	//LegB broadcasts to the channel it is bridged to.
+	LegB Leg = "bleg"
+
+	//LegBoth broadcasts to both legs.
+	LegBoth Leg = "both"
+)
+
+//ExecuteBroadcast runs a dialplan application on each of uuids, for the
+//selected leg, using uuid_broadcast. Unlike Execute it works on any channel,
+//not just those controlled by an outbound socket, and on the bridged leg. The
+//application's argument must not contain spaces. It returns the channels the
+//command failed for, or nil if it succeeded on all of them.
+func (client *Client) ExecuteBroadcast(uuids []string, app string, arg string, leg Leg) map[string]error {
+	path := app
+	if arg != "" {
+		path += "::" + arg
+	}
+
+	var failed map[string]error
+	for _, uuid := range uuids {
+		if err := client.broadcast(uuid, path, leg); err != nil {
+			if failed == nil {
+				failed = make(map[string]error)
+			}
+			failed[uuid] = err
+		}
+	}
+	return failed
+}
+
+//broadcast sends a uuid_broadcast command for a file or "app::arg" path.
+func (client *Client) broadcast(uuid string, path string, leg Leg) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+	cmd := []string{"uuid_broadcast", uuid, path}
+	if leg != "" {
+		cmd = append(cmd, string(leg))
+	}
+	return client.apiOK(strings.Join(cmd, " "))
+}
+
+//broadcastIDVar is the playback variable used to match PLAYBACK_STOP events to
+//a broadcast.
+const broadcastIDVar = "fsclient_broadcast_id"
+
+//BroadcastPlayback is a file being broadcast by a Broadcaster. Done is closed
+//once playback has stopped on every selected leg, or the channel has hung up.
+type BroadcastPlayback struct {
+	ID        string
+	UUID      string
+	done      chan struct{}
+	remaining int
+	status    string
+}
+
+//Done returns a channel that is closed once the broadcast has finished.
+func (playback *BroadcastPlayback) Done() <-chan struct{} {
+	return playback.done
+}
+
+//Status returns how the broadcast finished once Done is closed: "done" if it
+//played to the end, "break" if it was interrupted or "hangup" if the channel
+//hung up before playback stopped.
+func (playback *BroadcastPlayback) Status() string {
+	<-playback.done
+	return playback.status
+}
+
+//Broadcaster plays announcements into live calls with uuid_broadcast and
+//reports when they finish.
+//
+//Register the Broadcaster's HandleEvent method with a Dispatcher. The client
+//must be subscribed to PLAYBACK_STOP and CHANNEL_HANGUP_COMPLETE.
+type Broadcaster struct {
+	client    *Client
+	playbacks map[string]*BroadcastPlayback
+	mu        *sync.Mutex
+}
+
+//NewBroadcaster creates a Broadcaster using client.
+func NewBroadcaster(client *Client) *Broadcaster {
+	return &Broadcaster{
+		client:    client,
+		playbacks: make(map[string]*BroadcastPlayback),
+		mu:        &sync.Mutex{},
+	}
+}
+
+//Broadcast plays path, a sound file or stream, to the selected leg of the
+//channel uuid. The playback is tagged with a variable so its PLAYBACK_STOP
+//events can be recognised on whichever leg it plays.
+func (broadcaster *Broadcaster) Broadcast(uuid string, path string, leg Leg) (*BroadcastPlayback, error) {
+	playback := &BroadcastPlayback{
+		ID:        NewUUID(),
+		UUID:      uuid,
+		done:      make(chan struct{}),
+		remaining: 1,
+	}
+	if leg == LegBoth {
+		playback.remaining = 2
+	}
+
+	//Add the ID to any playback variables already given with the path.
+	tagged := "{" + broadcastIDVar + "=" + playback.ID + "}" + path
+	if strings.HasPrefix(path, "{") {
+		tagged = "{" + broadcastIDVar + "=" + playback.ID + "," + path[1:]
+	}
+
+	broadcaster.mu.Lock()
+	broadcaster.playbacks[playback.ID] = playback
+	broadcaster.mu.Unlock()
+
+	if err := broadcaster.client.broadcast(uuid, tagged, leg); err != nil {
+		broadcaster.mu.Lock()
+		delete(broadcaster.playbacks, playback.ID)
+		broadcaster.mu.Unlock()
+		return nil, err
+	}
+	return playback, nil
+}
+
+//HandleEvent tracks the completion of broadcasts.
+func (broadcaster *Broadcaster) HandleEvent(event Event) {
+	switch event.Name() {
+	case "PLAYBACK_STOP":
+		id := event[broadcastIDVar]
+		if id == "" {
+			id = event["variable_"+broadcastIDVar]
+		}
+
+		broadcaster.mu.Lock()
+		defer broadcaster.mu.Unlock()
+
+		playback, ok := broadcaster.playbacks[id]
+		if !ok {
+			return
+		}
+
+		//An interrupted leg makes the whole broadcast interrupted.
+		if playback.status != "break" {
+			playback.status = event["Playback-Status"]
+		}
+		playback.remaining--
+		if playback.remaining <= 0 {
+			broadcaster.finish(playback, playback.status)
+		}
+
+	case "CHANNEL_HANGUP_COMPLETE":
+		broadcaster.mu.Lock()
+		defer broadcaster.mu.Unlock()
+
+		for _, playback := range broadcaster.playbacks {
+			if playback.UUID == event.UUID() {
+				broadcaster.finish(playback, "hangup")
+			}
+		}
+	}
+}
+
+//finish completes a broadcast. The caller must hold the lock.
+func (broadcaster *Broadcaster) finish(playback *BroadcastPlayback, status string) {
+	playback.status = status
+	delete(broadcaster.playbacks, playback.ID)
+	close(playback.done)
+}
@@ -0,0 +1,281 @@
+package fsclient
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+//Call is a channel tracked by a CallManager. It holds the headers of the most
+//recent event received for the channel and any values the application has
+//attached to it, which are kept until the call has hung up.
+type Call struct {
+	UUID       string
+	event      Event
+	values     map[string]interface{}
+	state      ChannelState
+	callState  CallState
+	peer       string
+	aLeg       bool
+	amd        AMDResult
+	hungUp     bool
+	reserved   bool
+	earlyMedia bool
+	onProgress func(*Call, Progress)
+	manager    *CallManager
+	mu         *sync.RWMutex
+}
+
+//newCall creates a call for the channel UUID tracked by manager.
+func newCall(uuid string, manager *CallManager) *Call {
+	return &Call{
+		UUID:    uuid,
+		event:   Event{},
+		values:  make(map[string]interface{}),
+		manager: manager,
+		mu:      &sync.RWMutex{},
+	}
+}
+
+//Set attaches a value to the call under key, replacing any existing value.
+func (call *Call) Set(key string, value interface{}) {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	call.values[key] = value
+}
+
+//Value returns the value attached to the call under key. Use GetValue to get
+//the value as a specific type.
+func (call *Call) Value(key string) (interface{}, bool) {
+	call.mu.RLock()
+	defer call.mu.RUnlock()
+	value, ok := call.values[key]
+	return value, ok
+}
+
+//Delete removes the value attached to the call under key.
+func (call *Call) Delete(key string) {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	delete(call.values, key)
+}
+
+//GetValue returns the value attached to call under key as type T. The bool
+//result is false if there is no value or it is not of type T.
+func GetValue[T any](call *Call, key string) (T, bool) {
+	value, ok := call.Value(key)
+	typed, ok2 := value.(T)
+	return typed, ok && ok2
+}
+
+//Header returns a header from the most recent event received for the call.
+func (call *Call) Header(name string) string {
+	call.mu.RLock()
+	defer call.mu.RUnlock()
+	return call.event[name]
+}
+
+//Event returns a copy of the most recent event received for the call.
+func (call *Call) Event() Event {
+	call.mu.RLock()
+	defer call.mu.RUnlock()
+
+	event := make(Event, len(call.event))
+	for key, value := range call.event {
+		event[key] = value
+	}
+	return event
+}
+
+//HungUp returns true once the call has hung up.
+func (call *Call) HungUp() bool {
+	call.mu.RLock()
+	defer call.mu.RUnlock()
+	return call.hungUp
+}
+
+//HangupCause returns the hangup cause once the call has hung up.
+func (call *Call) HangupCause() string {
+	return call.Header("Hangup-Cause")
+}
+
+//update stores the latest event for the call.
+func (call *Call) update(event Event) {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	call.event = event
+}
+
+//CallManager tracks active calls from channel events. Register its
+//HandleEvent method with a Dispatcher, ideally in DispatchPerUUID or
+//DispatchSequential mode so events for a call are processed in order. The
+//client must be subscribed to at least CHANNEL_CREATE and
+//CHANNEL_HANGUP_COMPLETE.
+type CallManager struct {
+	calls        map[string]*Call
+	onCreate     []func(*Call)
+	onHangup     []func(*Call)
+	onTransition []func(*Call, Transition)
+	onBridge     []func(BridgedPair)
+	onUnbridge   []func(BridgedPair)
+	onAMD        []func(*Call, AMDResult)
+	onProgress   []func(*Call, Progress)
+	onResync     []func(ResyncReport)
+	mu           *sync.RWMutex
+}
+
+//NewCallManager creates a CallManager with no calls.
+func NewCallManager() *CallManager {
+	return &CallManager{
+		calls: make(map[string]*Call),
+		mu:    &sync.RWMutex{},
+	}
+}
+
+//OnCreate registers a function to be called when a new call is seen.
+func (manager *CallManager) OnCreate(fn func(call *Call)) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.onCreate = append(manager.onCreate, fn)
+}
+
+//OnHangup registers a function to be called when a call has hung up. The
+//call's attached values are still available to the function, after which the
+//call is no longer tracked.
+func (manager *CallManager) OnHangup(fn func(call *Call)) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.onHangup = append(manager.onHangup, fn)
+}
+
+//Call returns the active call with the given UUID, or nil if it isn't known.
+func (manager *CallManager) Call(uuid string) *Call {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	return manager.calls[uuid]
+}
+
+//Calls returns the active calls sorted by UUID.
+func (manager *CallManager) Calls() []*Call {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	calls := make([]*Call, 0, len(manager.calls))
+	for _, call := range manager.calls {
+		calls = append(calls, call)
+	}
+	sort.Slice(calls, func(i, j int) bool { return calls[i].UUID < calls[j].UUID })
+	return calls
+}
+
+//HandleEvent updates the tracked calls from a channel event.
+func (manager *CallManager) HandleEvent(event Event) {
+	if event.Name() == "BACKGROUND_JOB" {
+		manager.originateResult(event)
+		return
+	}
+
+	uuid := event.UUID()
+	if uuid == "" {
+		return
+	}
+
+	//CUSTOM events don't update the call's headers, but may carry answering
+	//machine detection results.
+	if event.Name() == "CUSTOM" {
+		manager.amdResult(event)
+		return
+	}
+	if !strings.HasPrefix(event.Name(), "CHANNEL_") {
+		return
+	}
+
+	switch event.Name() {
+	case "CHANNEL_HANGUP_COMPLETE", "CHANNEL_DESTROY":
+		manager.hangup(uuid, event)
+	case "CHANNEL_BRIDGE":
+		manager.track(uuid, event)
+		manager.bridge(event)
+	case "CHANNEL_UNBRIDGE":
+		manager.track(uuid, event)
+		manager.unbridge(event)
+	case "CHANNEL_EXECUTE_COMPLETE":
+		manager.track(uuid, event)
+		manager.amdResult(event)
+	case "CHANNEL_PROGRESS", "CHANNEL_PROGRESS_MEDIA":
+		manager.progress(manager.track(uuid, event), event)
+	default:
+		manager.track(uuid, event)
+	}
+}
+
+//track updates the call for uuid with the latest event, creating it if it
+//isn't already tracked, and returns it. Events for untracked channels that
+//have already hung up, such as the CHANNEL_STATE events that follow
+//CHANNEL_HANGUP_COMPLETE, don't create a call and nil is returned. A reserved
+//call is created by its first event.
+func (manager *CallManager) track(uuid string, event Event) *Call {
+	manager.mu.Lock()
+	call, ok := manager.calls[uuid]
+	if ok && call.reserved {
+		call.reserved = false
+		ok = false
+	} else if !ok {
+		switch ChannelState(event["Channel-State"]) {
+		case StateHangup, StateReporting, StateDestroy:
+			manager.mu.Unlock()
+			return nil
+		}
+		call = newCall(uuid, manager)
+		manager.calls[uuid] = call
+	}
+	onCreate := manager.onCreate
+	manager.mu.Unlock()
+
+	call.update(event)
+	manager.updateState(call, event, !ok)
+	if !ok {
+		for _, fn := range onCreate {
+			fn(call)
+		}
+	}
+	return call
+}
+
+//hangup marks a call as hung up, stops tracking it and runs the hangup
+//callbacks. A CHANNEL_HANGUP_COMPLETE for a call that isn't tracked, for
+//example one that started before the manager, still runs the callbacks, but
+//other events for untracked calls are ignored.
+func (manager *CallManager) hangup(uuid string, event Event) {
+	manager.mu.Lock()
+	call, ok := manager.calls[uuid]
+	delete(manager.calls, uuid)
+	onHangup := manager.onHangup
+	manager.mu.Unlock()
+
+	if !ok {
+		if event.Name() != "CHANNEL_HANGUP_COMPLETE" {
+			return
+		}
+		call = newCall(uuid, manager)
+	}
+
+	call.mu.Lock()
+	call.event = event
+	call.hungUp = true
+	if state := ChannelState(event["Channel-State"]); state != "" {
+		call.state = state
+	}
+	if callState := CallState(event["Channel-Call-State"]); callState != "" {
+		call.callState = callState
+	}
+	call.mu.Unlock()
+
+	for _, fn := range onHangup {
+		fn(call)
+	}
+
+	if ok {
+		manager.unlink(call)
+	}
+}
@@ -0,0 +1,479 @@
+package fsclient
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//CallbackIDVar is the channel variable set to the callback's ID on calls
+//originated by a CallbackScheduler, so the queue and CDRs can tell callbacks
+//from new calls.
+const CallbackIDVar = "fsclient_callback_id"
+
+//errNoCallbackNumber is returned by Offer when the caller accepts a callback
+//but no number is entered or known.
+var errNoCallbackNumber = errors.New("No callback number")
+
+//Callback is a caller's request to be called back on Number and connected to
+//Queue, a dialplan extension such as one running the callcenter app. CallUUID
+//is the UUID of the call the callback was requested on. Attempts is the
+//number of times the callback has been dialled.
+type Callback struct {
+	ID          string    `json:"id"`
+	Number      string    `json:"number"`
+	Queue       string    `json:"queue"`
+	CallUUID    string    `json:"call_uuid,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+	DueAt       time.Time `json:"due_at"`
+	Attempts    int       `json:"attempts"`
+}
+
+//CallbackResult is the outcome of one attempt to call back. Answered is true
+//if the callback was answered and connected to its queue, UUID is then the
+//new call's UUID. Cause is the originate failure otherwise. Retrying is true
+//if the callback will be dialled again.
+type CallbackResult struct {
+	Callback Callback
+	UUID     string
+	Answered bool
+	Cause    string
+	Err      error
+	Retrying bool
+}
+
+//CallbackStore persists pending callbacks, so that they survive a restart. A
+//CallbackScheduler calls its methods one at a time.
+type CallbackStore interface {
+	Load() ([]Callback, error)
+	Save(callback Callback) error
+	Delete(id string) error
+}
+
+//CallbackOffer configures CallbackScheduler.Offer. Prompt offers the caller a
+//callback, which is accepted by pressing AcceptDigit ("1" if empty) within
+//Timeout. NumberPrompt then asks for the number to call back, MinDigits to
+//MaxDigits long and ended with #. If DefaultNumber is set, such as the
+//caller's caller ID, pressing just # uses it. Confirmation is played once the
+//callback is scheduled, before the caller is hung up.
+type CallbackOffer struct {
+	Prompt        string
+	AcceptDigit   string
+	Timeout       time.Duration
+	NumberPrompt  string
+	MinDigits     int
+	MaxDigits     int
+	DefaultNumber string
+	Confirmation  string
+	Queue         string
+}
+
+//CallbackScheduler is a queue callback (virtual hold) service. Callers in a
+//queue are offered a callback with Offer instead of waiting, and once the
+//callback is due they are called back and connected to the queue they left.
+//Pending callbacks are kept in a CallbackStore and picked up again by Start
+//after a restart.
+//
+//Callbacks are originated with bgapi, so register the scheduler's HandleEvent
+//method with a Dispatcher and subscribe the client to BACKGROUND_JOB. The
+//exported fields must be set before Start is called.
+type CallbackScheduler struct {
+	//Endpoint is prefixed to the callback number to make the originate
+	//endpoint, e.g. "sofia/gateway/carrier/".
+	Endpoint string
+
+	//Context is the dialplan context the queue extensions are in, the
+	//default context if empty.
+	Context string
+
+	//CallerIDName and CallerIDNumber are presented on callbacks.
+	CallerIDName   string
+	CallerIDNumber string
+
+	//Delay is how long after being requested a callback is made.
+	Delay time.Duration
+
+	//Timeout is how long a callback rings before it is unanswered.
+	Timeout time.Duration
+
+	//MaxAttempts is the number of times a callback is dialled before giving
+	//up, and RetryDelay how long to wait between attempts.
+	MaxAttempts int
+	RetryDelay  time.Duration
+
+	//OnResult is called when each attempt finishes.
+	OnResult func(result CallbackResult)
+
+	client   Commander
+	store    CallbackStore
+	clock    Clock
+	pending  map[string]*Callback
+	timers   map[string]Timer
+	dialling map[string]string
+	mu       *sync.Mutex
+}
+
+//NewCallbackScheduler creates a CallbackScheduler that keeps pending
+//callbacks in store.
+func NewCallbackScheduler(client Commander, store CallbackStore) *CallbackScheduler {
+	return &CallbackScheduler{
+		Timeout:     30 * time.Second,
+		MaxAttempts: 3,
+		RetryDelay:  5 * time.Minute,
+		client:      client,
+		store:       store,
+		clock:       SystemClock,
+		pending:     make(map[string]*Callback),
+		timers:      make(map[string]Timer),
+		dialling:    make(map[string]string),
+		mu:          &sync.Mutex{},
+	}
+}
+
+//SetClock sets the clock callbacks are scheduled with. It must be called
+//before Start.
+func (scheduler *CallbackScheduler) SetClock(clock Clock) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	scheduler.clock = clock
+}
+
+//Start loads the pending callbacks from the store and schedules them.
+//Callbacks that fell due while the scheduler wasn't running are dialled
+//straight away.
+func (scheduler *CallbackScheduler) Start() error {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	callbacks, err := scheduler.store.Load()
+	if err != nil {
+		return err
+	}
+	for i := range callbacks {
+		callback := callbacks[i]
+		scheduler.pending[callback.ID] = &callback
+		scheduler.schedule(callback.ID, callback.DueAt)
+	}
+	return nil
+}
+
+//Stop cancels the scheduled callbacks without removing them from the store,
+//so that they are made after the next Start.
+func (scheduler *CallbackScheduler) Stop() {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	for id, timer := range scheduler.timers {
+		timer.Stop()
+		delete(scheduler.timers, id)
+	}
+}
+
+//Schedule adds a callback, saving it to the store. An empty ID is set to a
+//new UUID, and a zero RequestedAt or DueAt to now and Delay from now.
+func (scheduler *CallbackScheduler) Schedule(callback Callback) (Callback, error) {
+	if err := validateCallback(callback); err != nil {
+		return Callback{}, err
+	}
+	if callback.ID == "" {
+		callback.ID = NewUUID()
+	}
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	now := scheduler.clock.Now()
+	if callback.RequestedAt.IsZero() {
+		callback.RequestedAt = now
+	}
+	if callback.DueAt.IsZero() {
+		callback.DueAt = now.Add(scheduler.Delay)
+	}
+
+	if err := scheduler.store.Save(callback); err != nil {
+		return Callback{}, err
+	}
+	if timer, ok := scheduler.timers[callback.ID]; ok {
+		timer.Stop()
+	}
+	scheduler.pending[callback.ID] = &callback
+	scheduler.schedule(callback.ID, callback.DueAt)
+	return callback, nil
+}
+
+//Cancel removes a pending callback. A callback that is being dialled is not
+//hung up, but isn't retried.
+func (scheduler *CallbackScheduler) Cancel(id string) error {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	if timer, ok := scheduler.timers[id]; ok {
+		timer.Stop()
+		delete(scheduler.timers, id)
+	}
+	delete(scheduler.pending, id)
+	return scheduler.store.Delete(id)
+}
+
+//Pending returns the pending callbacks, soonest due first.
+func (scheduler *CallbackScheduler) Pending() []Callback {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	callbacks := make([]Callback, 0, len(scheduler.pending))
+	for _, callback := range scheduler.pending {
+		callbacks = append(callbacks, *callback)
+	}
+	sort.Slice(callbacks, func(i, j int) bool {
+		return callbacks[i].DueAt.Before(callbacks[j].DueAt)
+	})
+	return callbacks
+}
+
+//Offer offers the caller on session a callback to offer.Queue. If the caller
+//accepts and enters a number, the callback is scheduled, the confirmation
+//played and the caller hung up. The bool result is false if the caller
+//didn't accept.
+func (scheduler *CallbackScheduler) Offer(session *Session, offer CallbackOffer) (Callback, bool, error) {
+	accept := offer.AcceptDigit
+	if accept == "" {
+		accept = "1"
+	}
+	timeout := offer.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	if len(accept) != 1 || strings.Trim(accept, "0123456789*#") != "" {
+		return Callback{}, false, errors.New("Invalid callback accept digit: " + accept)
+	}
+
+	digit, err := session.PlayAndGetDigits(offer.Prompt, "", 1, 1, 1, timeout, timeout, "", "["+accept+"]")
+	if err != nil || digit != accept {
+		return Callback{}, false, err
+	}
+
+	number := offer.DefaultNumber
+	if offer.NumberPrompt != "" {
+		min, max := offer.MinDigits, offer.MaxDigits
+		if max <= 0 {
+			max = 15
+		}
+		if offer.DefaultNumber != "" {
+			min = 0
+		}
+		digits, err := session.PlayAndGetDigits(offer.NumberPrompt, "", min, max, 3, timeout, timeout, "#", "")
+		if err != nil {
+			return Callback{}, false, err
+		}
+		if digits != "" {
+			number = digits
+		}
+	}
+	if number == "" {
+		return Callback{}, false, errNoCallbackNumber
+	}
+
+	callback, err := scheduler.Schedule(Callback{Number: number, Queue: offer.Queue, CallUUID: session.UUID})
+	if err != nil {
+		return Callback{}, false, err
+	}
+
+	if offer.Confirmation != "" {
+		if err := session.Playback(offer.Confirmation); err != nil {
+			return callback, true, err
+		}
+	}
+	return callback, true, session.Hangup("NORMAL_CLEARING")
+}
+
+//HandleEvent reports the results of callback originates.
+func (scheduler *CallbackScheduler) HandleEvent(event Event) {
+	uuid, reply, ok := originateJob(event)
+	if !ok {
+		return
+	}
+
+	scheduler.mu.Lock()
+	id, ok := scheduler.dialling[uuid]
+	delete(scheduler.dialling, uuid)
+	scheduler.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	if reply.OK {
+		scheduler.finish(id, CallbackResult{UUID: uuid, Answered: true}, false)
+		return
+	}
+	scheduler.finish(id, CallbackResult{Cause: reply.Text}, true)
+}
+
+//schedule starts the timer for a pending callback. The caller must hold the
+//lock.
+func (scheduler *CallbackScheduler) schedule(id string, due time.Time) {
+	delay := due.Sub(scheduler.clock.Now())
+	if delay < 0 {
+		delay = 0
+	}
+	scheduler.timers[id] = scheduler.clock.AfterFunc(delay, func() { scheduler.dial(id) })
+}
+
+//dial originates a due callback.
+func (scheduler *CallbackScheduler) dial(id string) {
+	scheduler.mu.Lock()
+	pending, ok := scheduler.pending[id]
+	if !ok {
+		scheduler.mu.Unlock()
+		return
+	}
+	delete(scheduler.timers, id)
+	pending.Attempts++
+	callback := *pending
+	if err := scheduler.store.Save(callback); err != nil {
+		log.Print(logPrefix, "Failed saving callback ", id, ": ", err)
+	}
+
+	uuid := NewUUID()
+	scheduler.dialling[uuid] = id
+	req := OriginateRequest{
+		Endpoint:       scheduler.Endpoint + callback.Number,
+		Extension:      callback.Queue,
+		Context:        scheduler.Context,
+		CallerIDName:   scheduler.CallerIDName,
+		CallerIDNumber: scheduler.CallerIDNumber,
+		Timeout:        scheduler.Timeout,
+		Variables: map[string]string{
+			"origination_uuid": uuid,
+			CallbackIDVar:      id,
+		},
+	}
+	scheduler.mu.Unlock()
+
+	if _, err := req.BackgroundOriginate(scheduler.client); err != nil {
+		scheduler.mu.Lock()
+		_, ok := scheduler.dialling[uuid]
+		delete(scheduler.dialling, uuid)
+		scheduler.mu.Unlock()
+
+		if ok {
+			scheduler.finish(id, CallbackResult{Err: err}, true)
+		}
+	}
+}
+
+//finish records the result of an attempt, rescheduling the callback if it
+//failed and has attempts left or removing it otherwise, and reports the
+//result.
+func (scheduler *CallbackScheduler) finish(id string, result CallbackResult, failed bool) {
+	scheduler.mu.Lock()
+	pending, ok := scheduler.pending[id]
+	if !ok {
+		//Cancelled while being dialled.
+		scheduler.mu.Unlock()
+		return
+	}
+
+	if failed && pending.Attempts < scheduler.MaxAttempts {
+		pending.DueAt = scheduler.clock.Now().Add(scheduler.RetryDelay)
+		result.Retrying = true
+		if err := scheduler.store.Save(*pending); err != nil {
+			log.Print(logPrefix, "Failed saving callback ", id, ": ", err)
+		}
+		scheduler.schedule(id, pending.DueAt)
+	} else {
+		delete(scheduler.pending, id)
+		if err := scheduler.store.Delete(id); err != nil {
+			log.Print(logPrefix, "Failed deleting callback ", id, ": ", err)
+		}
+	}
+	result.Callback = *pending
+	onResult := scheduler.OnResult
+	scheduler.mu.Unlock()
+
+	if onResult != nil {
+		onResult(result)
+	}
+}
+
+//validateCallback checks a callback's number and queue can be originated.
+func validateCallback(callback Callback) error {
+	number := strings.TrimPrefix(callback.Number, "+")
+	if number == "" || strings.Trim(number, "0123456789") != "" {
+		return errors.New("Invalid callback number: " + callback.Number)
+	}
+	if callback.Queue == "" || strings.ContainsAny(callback.Queue, " '\n") {
+		return errors.New("Invalid callback queue: " + callback.Queue)
+	}
+	return nil
+}
+
+//FileCallbackStore stores pending callbacks as a JSON array in a file,
+//replacing it atomically on each change.
+type FileCallbackStore struct {
+	Path string
+}
+
+//Load reads the callbacks, returning none if the file doesn't exist yet.
+func (store *FileCallbackStore) Load() ([]Callback, error) {
+	data, err := os.ReadFile(store.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var callbacks []Callback
+	if err := json.Unmarshal(data, &callbacks); err != nil {
+		return nil, err
+	}
+	return callbacks, nil
+}
+
+//Save adds or replaces a callback.
+func (store *FileCallbackStore) Save(callback Callback) error {
+	callbacks, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	for i := range callbacks {
+		if callbacks[i].ID == callback.ID {
+			callbacks[i] = callback
+			return store.write(callbacks)
+		}
+	}
+	return store.write(append(callbacks, callback))
+}
+
+//Delete removes a callback if it is stored.
+func (store *FileCallbackStore) Delete(id string) error {
+	callbacks, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	for i := range callbacks {
+		if callbacks[i].ID == id {
+			return store.write(append(callbacks[:i], callbacks[i+1:]...))
+		}
+	}
+	return nil
+}
+
+//write replaces the file with callbacks.
+func (store *FileCallbackStore) write(callbacks []Callback) error {
+	if callbacks == nil {
+		callbacks = []Callback{}
+	}
+	data, err := json.Marshal(callbacks)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(store.Path, append(data, '\n'))
+}
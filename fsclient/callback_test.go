@@ -0,0 +1,260 @@
+package fsclient_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+)
+
+//newCallbackScheduler creates a CallbackScheduler on a fake client and clock,
+//keeping callbacks in a file in the test's temporary directory, and returns
+//the results it reports.
+func newCallbackScheduler(t *testing.T, client *fsclienttest.Client, clock *fsclienttest.Clock) (*fsclient.CallbackScheduler, *[]fsclient.CallbackResult) {
+	store := &fsclient.FileCallbackStore{Path: filepath.Join(t.TempDir(), "callbacks.json")}
+	scheduler := fsclient.NewCallbackScheduler(client, store)
+	scheduler.SetClock(clock)
+	scheduler.Endpoint = "sofia/gateway/carrier/"
+	scheduler.Delay = time.Minute
+	scheduler.RetryDelay = 5 * time.Minute
+	scheduler.MaxAttempts = 2
+
+	var results []fsclient.CallbackResult
+	scheduler.OnResult = func(result fsclient.CallbackResult) {
+		results = append(results, result)
+	}
+	client.OnEvent(scheduler.HandleEvent)
+	return scheduler, &results
+}
+
+//TestCallbackScheduler checks that due callbacks are dialled, and retried
+//until they are answered or run out of attempts.
+func TestCallbackScheduler(t *testing.T) {
+	type attempt struct {
+		reply       string
+		err         error
+		wantCause   string
+		wantRetried bool
+	}
+	tests := []struct {
+		name        string
+		attempts    []attempt
+		wantPending int
+	}{
+		{"answered", []attempt{{"+OK " + consultUUID, nil, "", false}}, 0},
+		{"retried", []attempt{{"-ERR NO_ANSWER", nil, "NO_ANSWER", true}, {"+OK " + consultUUID, nil, "", false}}, 0},
+		{"given up", []attempt{{"-ERR USER_BUSY", nil, "USER_BUSY", true}, {"-ERR USER_BUSY", nil, "USER_BUSY", false}}, 0},
+		{"not sent", []attempt{{"", errors.New("Not connected"), "", true}}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fsclienttest.NewClient()
+			sent := 0
+			client.Handle(fsclient.ClassBGAPI, "originate", func(call fsclienttest.Call) (string, error) {
+				attempt := test.attempts[sent]
+				sent++
+				return attempt.reply, attempt.err
+			})
+			clock := fsclienttest.NewClock(time.Unix(0, 0))
+			scheduler, results := newCallbackScheduler(t, client, clock)
+
+			callback, err := scheduler.Schedule(fsclient.Callback{Number: "+15551234", Queue: "support", CallUUID: callerUUID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !callback.DueAt.Equal(time.Unix(60, 0)) {
+				t.Errorf("Callback due at %v, want a minute from now", callback.DueAt)
+			}
+
+			clock.Advance(59 * time.Second)
+			if len(*results) != 0 {
+				t.Fatal("Callback made before it was due")
+			}
+			for i, attempt := range test.attempts {
+				if i == 0 {
+					clock.Advance(time.Second)
+				} else {
+					clock.Advance(scheduler.RetryDelay)
+				}
+				if len(*results) != i+1 {
+					t.Fatalf("Got %d results after %d attempts", len(*results), i+1)
+				}
+				result := (*results)[i]
+				answered := attempt.wantCause == "" && attempt.err == nil
+				if result.Answered != answered || result.Cause != attempt.wantCause || (result.Err != nil) != (attempt.err != nil) {
+					t.Errorf("Attempt %d got %+v", i+1, result)
+				}
+				if result.Retrying != attempt.wantRetried {
+					t.Errorf("Attempt %d retrying %v, want %v", i+1, result.Retrying, attempt.wantRetried)
+				}
+				if result.Callback.ID != callback.ID || result.Callback.Attempts != i+1 {
+					t.Errorf("Attempt %d reported callback %+v", i+1, result.Callback)
+				}
+			}
+
+			if pending := scheduler.Pending(); len(pending) != test.wantPending {
+				t.Errorf("Got %d pending callbacks, want %d", len(pending), test.wantPending)
+			}
+			for _, cmd := range sentCommands(client) {
+				if !strings.Contains(cmd, fsclient.CallbackIDVar+"="+callback.ID) || !strings.HasSuffix(cmd, "sofia/gateway/carrier/+15551234 support") {
+					t.Errorf("Sent %q", cmd)
+				}
+			}
+		})
+	}
+}
+
+//TestCallbackSchedulerRestart checks that pending callbacks are kept in the
+//store, and dialled straight away if they fell due while stopped.
+func TestCallbackSchedulerRestart(t *testing.T) {
+	client := fsclienttest.NewClient()
+	scriptCommands(client)
+	clock := fsclienttest.NewClock(time.Unix(0, 0))
+	store := &fsclient.FileCallbackStore{Path: filepath.Join(t.TempDir(), "callbacks.json")}
+
+	scheduler := fsclient.NewCallbackScheduler(client, store)
+	scheduler.SetClock(clock)
+	scheduler.Delay = time.Minute
+	first, err := scheduler.Schedule(fsclient.Callback{Number: "5551234", Queue: "support"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled, err := scheduler.Schedule(fsclient.Callback{Number: "5555678", Queue: "support"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.Cancel(cancelled.ID); err != nil {
+		t.Fatal(err)
+	}
+	scheduler.Stop()
+
+	clock.Advance(time.Hour)
+	if cmds := sentCommands(client); len(cmds) != 0 {
+		t.Fatalf("Sent %q while stopped", cmds)
+	}
+
+	restarted := fsclient.NewCallbackScheduler(client, store)
+	restarted.SetClock(clock)
+	var results []fsclient.CallbackResult
+	restarted.OnResult = func(result fsclient.CallbackResult) {
+		results = append(results, result)
+	}
+	client.OnEvent(restarted.HandleEvent)
+	if err := restarted.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if pending := restarted.Pending(); len(pending) != 1 || pending[0].ID != first.ID {
+		t.Fatalf("Loaded %+v", pending)
+	}
+
+	clock.Advance(0)
+	if len(results) != 1 || !results[0].Answered || results[0].Callback.ID != first.ID {
+		t.Errorf("Got results %+v", results)
+	}
+	if callbacks, err := store.Load(); err != nil || len(callbacks) != 0 {
+		t.Errorf("Stored %+v, %v after the callback was made", callbacks, err)
+	}
+}
+
+//TestCallbackSchedulerSchedule checks that callbacks that can't be dialled
+//are rejected.
+func TestCallbackSchedulerSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		callback fsclient.Callback
+		wantErr  bool
+	}{
+		{"valid", fsclient.Callback{Number: "5551234", Queue: "support"}, false},
+		{"international", fsclient.Callback{Number: "+445551234", Queue: "support"}, false},
+		{"no number", fsclient.Callback{Queue: "support"}, true},
+		{"invalid number", fsclient.Callback{Number: "555 1234", Queue: "support"}, true},
+		{"endpoint number", fsclient.Callback{Number: "1234@evil", Queue: "support"}, true},
+		{"no queue", fsclient.Callback{Number: "5551234"}, true},
+		{"invalid queue", fsclient.Callback{Number: "5551234", Queue: "support XML default"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scheduler, _ := newCallbackScheduler(t, fsclienttest.NewClient(), fsclienttest.NewClock(time.Unix(0, 0)))
+			_, err := scheduler.Schedule(test.callback)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Got error %v, want error %v", err, test.wantErr)
+			}
+			scheduled := len(scheduler.Pending()) == 1
+			if scheduled == test.wantErr {
+				t.Errorf("Callback scheduled %v", scheduled)
+			}
+		})
+	}
+}
+
+//TestCallbackSchedulerOffer checks the callbacks scheduled for a caller's
+//input when offered one.
+func TestCallbackSchedulerOffer(t *testing.T) {
+	tests := []struct {
+		name         string
+		digits       []string
+		defaultNum   string
+		wantErr      bool
+		wantAccepted bool
+		wantNumber   string
+	}{
+		{"accepted", []string{"1", "5551234"}, "", false, true, "5551234"},
+		{"default number", []string{"1", ""}, "5559999", false, true, "5559999"},
+		{"entered number", []string{"1", "5551234"}, "5559999", false, true, "5551234"},
+		{"declined", []string{"2"}, "", false, false, ""},
+		{"no input", []string{""}, "", false, false, ""},
+		{"no number", []string{"1", ""}, "", true, false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fsclienttest.NewClient()
+			for _, digits := range test.digits {
+				client.CompleteDigits(digits)
+			}
+			client.Complete("playback", nil)
+			client.Reply(fsclient.ClassExecute, "hangup", "+OK")
+			scheduler, _ := newCallbackScheduler(t, client, fsclienttest.NewClock(time.Unix(0, 0)))
+			session := fsclient.NewSession(client, callerUUID)
+			client.OnEvent(session.HandleEvent)
+
+			callback, accepted, err := scheduler.Offer(session, fsclient.CallbackOffer{
+				Prompt:        "ivr/callback.wav",
+				NumberPrompt:  "ivr/number.wav",
+				DefaultNumber: test.defaultNum,
+				Confirmation:  "ivr/confirm.wav",
+				Queue:         "support",
+			})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Got error %v, want error %v", err, test.wantErr)
+			}
+			if accepted != test.wantAccepted || callback.Number != test.wantNumber {
+				t.Fatalf("Got callback %+v accepted %v", callback, accepted)
+			}
+
+			pending := scheduler.Pending()
+			hungUp := false
+			for _, call := range client.Calls() {
+				hungUp = hungUp || call.Name == "hangup"
+			}
+			if !test.wantAccepted {
+				if len(pending) != 0 || hungUp {
+					t.Errorf("Scheduled %+v, hung up %v for a declined callback", pending, hungUp)
+				}
+				return
+			}
+			if len(pending) != 1 || pending[0].ID != callback.ID || pending[0].CallUUID != callerUUID {
+				t.Errorf("Scheduled %+v", pending)
+			}
+			if !hungUp {
+				t.Error("Caller not hung up")
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package fsclient
+
+//BridgedPair is two calls bridged together. A is the leg that initiated the
+//bridge, normally the inbound or originating leg, and B the leg it was
+//bridged to. B is nil if the B-leg isn't tracked by the CallManager.
+type BridgedPair struct {
+	A *Call
+	B *Call
+}
+
+//PeerUUID returns the UUID of the leg the call is currently bridged to, or an
+//empty string if it isn't bridged.
+func (call *Call) PeerUUID() string {
+	call.mu.RLock()
+	defer call.mu.RUnlock()
+	return call.peer
+}
+
+//Peer returns the call this call is currently bridged to, or nil if it isn't
+//bridged or the other leg isn't tracked.
+func (call *Call) Peer() *Call {
+	peer := call.PeerUUID()
+	if peer == "" || call.manager == nil {
+		return nil
+	}
+	return call.manager.Call(peer)
+}
+
+//OnBridge registers a function to be called when two calls are bridged,
+//including when a transfer rebridges a leg to a new peer. Bridges are only
+//tracked if the client is subscribed to CHANNEL_BRIDGE and CHANNEL_UNBRIDGE.
+func (manager *CallManager) OnBridge(fn func(pair BridgedPair)) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.onBridge = append(manager.onBridge, fn)
+}
+
+//OnUnbridge registers a function to be called when two bridged calls are
+//unbridged.
+func (manager *CallManager) OnUnbridge(fn func(pair BridgedPair)) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.onUnbridge = append(manager.onUnbridge, fn)
+}
+
+//BridgedPair returns the bridged pair the call with the given UUID belongs
+//to, whichever leg it is. The bool result is false if the call isn't tracked
+//or isn't bridged.
+func (manager *CallManager) BridgedPair(uuid string) (BridgedPair, bool) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	call, ok := manager.calls[uuid]
+	if !ok {
+		return BridgedPair{}, false
+	}
+
+	call.mu.RLock()
+	peer, aLeg := call.peer, call.aLeg
+	call.mu.RUnlock()
+
+	if peer == "" {
+		return BridgedPair{}, false
+	}
+	if aLeg {
+		return BridgedPair{A: call, B: manager.calls[peer]}, true
+	}
+	return BridgedPair{A: manager.calls[peer], B: call}, true
+}
+
+//BridgedPairs returns all bridged pairs whose A-leg is tracked, sorted by
+//A-leg UUID.
+func (manager *CallManager) BridgedPairs() []BridgedPair {
+	var pairs []BridgedPair
+	for _, call := range manager.Calls() {
+		call.mu.RLock()
+		aLeg := call.aLeg && call.peer != ""
+		call.mu.RUnlock()
+
+		if !aLeg {
+			continue
+		}
+		if pair, ok := manager.BridgedPair(call.UUID); ok {
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+//bridgeLegs returns the A-leg and B-leg UUIDs of a CHANNEL_BRIDGE or
+//CHANNEL_UNBRIDGE event.
+func bridgeLegs(event Event) (string, string) {
+	a := event["Bridge-A-Unique-ID"]
+	if a == "" {
+		a = event.UUID()
+	}
+
+	b := event["Bridge-B-Unique-ID"]
+	if b == "" {
+		b = event["Other-Leg-Unique-ID"]
+	}
+	if b == a {
+		b = event["Other-Leg-Unique-ID"]
+	}
+	return a, b
+}
+
+//bridge links the two legs of a CHANNEL_BRIDGE event, replacing any earlier
+//peer either leg had, and runs the bridge callbacks.
+func (manager *CallManager) bridge(event Event) {
+	a, b := bridgeLegs(event)
+	if a == "" || b == "" {
+		return
+	}
+
+	manager.mu.Lock()
+	aCall, bCall := manager.calls[a], manager.calls[b]
+	for _, call := range []*Call{aCall, bCall} {
+		if call != nil {
+			manager.clearPeer(call)
+		}
+	}
+	if aCall != nil {
+		aCall.mu.Lock()
+		aCall.peer, aCall.aLeg = b, true
+		aCall.mu.Unlock()
+	}
+	if bCall != nil {
+		bCall.mu.Lock()
+		bCall.peer, bCall.aLeg = a, false
+		bCall.mu.Unlock()
+	}
+	onBridge := manager.onBridge
+	manager.mu.Unlock()
+
+	pair := BridgedPair{A: aCall, B: bCall}
+	for _, fn := range onBridge {
+		fn(pair)
+	}
+}
+
+//unbridge unlinks the two legs of a CHANNEL_UNBRIDGE event and runs the
+//unbridge callbacks.
+func (manager *CallManager) unbridge(event Event) {
+	a, b := bridgeLegs(event)
+
+	manager.mu.Lock()
+	aCall, bCall := manager.calls[a], manager.calls[b]
+	for _, call := range []*Call{aCall, bCall} {
+		if call != nil {
+			manager.clearPeer(call)
+		}
+	}
+	onUnbridge := manager.onUnbridge
+	manager.mu.Unlock()
+
+	pair := BridgedPair{A: aCall, B: bCall}
+	for _, fn := range onUnbridge {
+		fn(pair)
+	}
+}
+
+//unlink removes a call that has hung up from its peer, if it was bridged.
+func (manager *CallManager) unlink(call *Call) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.clearPeer(call)
+}
+
+//clearPeer unlinks a call from its peer. The peer is only unlinked if it is
+//still linked back to the call. The caller must hold the manager lock.
+func (manager *CallManager) clearPeer(call *Call) {
+	call.mu.Lock()
+	peer := call.peer
+	call.peer, call.aLeg = "", false
+	call.mu.Unlock()
+
+	if peerCall, ok := manager.calls[peer]; ok {
+		peerCall.mu.Lock()
+		if peerCall.peer == call.UUID {
+			peerCall.peer, peerCall.aLeg = "", false
+		}
+		peerCall.mu.Unlock()
+	}
+}
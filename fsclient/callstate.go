@@ -0,0 +1,190 @@
+package fsclient
+
+//ChannelState is a Freeswitch channel state, from the Channel-State header.
+type ChannelState string
+
+//Channel states in the order a channel normally progresses through them.
+const (
+	StateNew           ChannelState = "CS_NEW"
+	StateInit          ChannelState = "CS_INIT"
+	StateRouting       ChannelState = "CS_ROUTING"
+	StateSoftExecute   ChannelState = "CS_SOFT_EXECUTE"
+	StateExecute       ChannelState = "CS_EXECUTE"
+	StateExchangeMedia ChannelState = "CS_EXCHANGE_MEDIA"
+	StatePark          ChannelState = "CS_PARK"
+	StateConsumeMedia  ChannelState = "CS_CONSUME_MEDIA"
+	StateHibernate     ChannelState = "CS_HIBERNATE"
+	StateReset         ChannelState = "CS_RESET"
+	StateHangup        ChannelState = "CS_HANGUP"
+	StateReporting     ChannelState = "CS_REPORTING"
+	StateDestroy       ChannelState = "CS_DESTROY"
+)
+
+//CallState is a Freeswitch call state, from the Channel-Call-State header.
+type CallState string
+
+//Call states.
+const (
+	CallStateDown     CallState = "DOWN"
+	CallStateDialing  CallState = "DIALING"
+	CallStateRinging  CallState = "RINGING"
+	CallStateRingWait CallState = "RING_WAIT"
+	CallStateEarly    CallState = "EARLY"
+	CallStateActive   CallState = "ACTIVE"
+	CallStateHeld     CallState = "HELD"
+	CallStateUnheld   CallState = "UNHELD"
+	CallStateHangup   CallState = "HANGUP"
+)
+
+//activeStates are the channel states a channel moves freely between while it
+//is up, once it has been initialised.
+var activeStates = []ChannelState{
+	StateRouting, StateSoftExecute, StateExecute, StateExchangeMedia,
+	StatePark, StateConsumeMedia, StateHibernate, StateReset,
+}
+
+//channelTransitions lists the valid next states for each channel state.
+var channelTransitions = map[ChannelState][]ChannelState{
+	StateNew:       {StateInit, StateHangup},
+	StateInit:      append([]ChannelState{StateHangup}, activeStates...),
+	StateHangup:    {StateReporting, StateDestroy},
+	StateReporting: {StateDestroy},
+	StateDestroy:   {},
+}
+
+//callTransitions lists the valid next states for each call state.
+var callTransitions = map[CallState][]CallState{
+	CallStateDown:     {CallStateDialing, CallStateRinging, CallStateRingWait, CallStateEarly, CallStateActive, CallStateHangup},
+	CallStateDialing:  {CallStateRinging, CallStateRingWait, CallStateEarly, CallStateActive, CallStateHangup},
+	CallStateRinging:  {CallStateEarly, CallStateActive, CallStateHangup},
+	CallStateRingWait: {CallStateRinging, CallStateEarly, CallStateActive, CallStateHangup},
+	CallStateEarly:    {CallStateRinging, CallStateActive, CallStateHangup},
+	CallStateActive:   {CallStateHeld, CallStateUnheld, CallStateHangup},
+	CallStateHeld:     {CallStateActive, CallStateUnheld, CallStateHangup},
+	CallStateUnheld:   {CallStateActive, CallStateHeld, CallStateHangup},
+	CallStateHangup:   {},
+}
+
+func init() {
+	for _, state := range activeStates {
+		channelTransitions[state] = append([]ChannelState{StateHangup}, activeStates...)
+	}
+}
+
+//Transition describes a change of a call's channel state or call state.
+//Header is "Channel-State" or "Channel-Call-State". Valid is false if the
+//change isn't one Freeswitch makes directly, which usually means events have
+//been missed.
+type Transition struct {
+	Header string
+	From   string
+	To     string
+	Valid  bool
+	Event  Event
+}
+
+//validChannelTransition returns true if a channel can move from one state to
+//another. Unknown states are assumed to be valid.
+func validChannelTransition(from ChannelState, to ChannelState) bool {
+	next, ok := channelTransitions[from]
+	if !ok {
+		return true
+	}
+	for _, state := range next {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+//validCallTransition returns true if a call can move from one call state to
+//another. Unknown states are assumed to be valid.
+func validCallTransition(from CallState, to CallState) bool {
+	next, ok := callTransitions[from]
+	if !ok {
+		return true
+	}
+	for _, state := range next {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+//State returns the call's current channel state.
+func (call *Call) State() ChannelState {
+	call.mu.RLock()
+	defer call.mu.RUnlock()
+	return call.state
+}
+
+//CallState returns the call's current call state.
+func (call *Call) CallState() CallState {
+	call.mu.RLock()
+	defer call.mu.RUnlock()
+	return call.callState
+}
+
+//OnTransition registers a function to be called when a call changes channel
+//state (from CHANNEL_STATE events) or call state (from CHANNEL_CALLSTATE
+//events). The initial states are taken from the first event seen for a call
+//and are not reported as transitions.
+func (manager *CallManager) OnTransition(fn func(call *Call, transition Transition)) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.onTransition = append(manager.onTransition, fn)
+}
+
+//updateState applies any state change in event to the call and runs the
+//transition callbacks.
+func (manager *CallManager) updateState(call *Call, event Event, created bool) {
+	var transitions []Transition
+
+	call.mu.Lock()
+	state := ChannelState(event["Channel-State"])
+	callState := CallState(event["Channel-Call-State"])
+
+	if created {
+		call.state = state
+		call.callState = callState
+	} else {
+		if event.Name() == "CHANNEL_STATE" && state != "" && state != call.state {
+			transitions = append(transitions, Transition{
+				Header: "Channel-State",
+				From:   string(call.state),
+				To:     string(state),
+				Valid:  validChannelTransition(call.state, state),
+				Event:  event,
+			})
+			call.state = state
+		}
+
+		if event.Name() == "CHANNEL_CALLSTATE" && callState != "" && callState != call.callState {
+			transitions = append(transitions, Transition{
+				Header: "Channel-Call-State",
+				From:   string(call.callState),
+				To:     string(callState),
+				Valid:  validCallTransition(call.callState, callState),
+				Event:  event,
+			})
+			call.callState = callState
+		}
+	}
+	call.mu.Unlock()
+
+	if len(transitions) == 0 {
+		return
+	}
+
+	manager.mu.RLock()
+	onTransition := manager.onTransition
+	manager.mu.RUnlock()
+
+	for _, transition := range transitions {
+		for _, fn := range onTransition {
+			fn(call, transition)
+		}
+	}
+}
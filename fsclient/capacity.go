@@ -0,0 +1,105 @@
+package fsclient
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//CapacityGuard refuses to originate calls on a node that is over its session
+//or sessions per second thresholds, using the node's heartbeats. As
+//heartbeats are only sent every 20 seconds by default, calls the guard admits
+//after the latest heartbeat are added to its session count (and to its
+//sessions per second when admitted within a second of each other).
+//
+//Calls are admitted until the first heartbeat from the node is received. A
+//threshold of zero is not checked.
+type CapacityGuard struct {
+	watcher           *HeartbeatWatcher
+	node              string
+	maxSessions       int64
+	maxSessionsPerSec float64
+	heartbeat         time.Time
+	admitted          int64
+	second            time.Time
+	secondAdmitted    int64
+	clock             Clock
+	mu                *sync.Mutex
+}
+
+//NewCapacityGuard creates a CapacityGuard for the node watched by watcher,
+//which is empty for a single Client.
+func NewCapacityGuard(watcher *HeartbeatWatcher, node string, maxSessions int64, maxSessionsPerSec float64) *CapacityGuard {
+	return &CapacityGuard{
+		watcher:           watcher,
+		node:              node,
+		maxSessions:       maxSessions,
+		maxSessionsPerSec: maxSessionsPerSec,
+		clock:             SystemClock,
+		mu:                &sync.Mutex{},
+	}
+}
+
+//SetClock sets the clock used to count the calls admitted each second.
+func (guard *CapacityGuard) SetClock(clock Clock) {
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	guard.clock = clock
+}
+
+//Admit returns an error if the node is over capacity, otherwise it counts a
+//new call against the node's capacity.
+func (guard *CapacityGuard) Admit() error {
+	heartbeat, ok := guard.watcher.Latest(guard.node)
+	if !ok {
+		return nil
+	}
+
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+
+	if !heartbeat.Time.Equal(guard.heartbeat) {
+		guard.heartbeat = heartbeat.Time
+		guard.admitted = 0
+	}
+
+	now := guard.clock.Now().Truncate(time.Second)
+	if !now.Equal(guard.second) {
+		guard.second = now
+		guard.secondAdmitted = 0
+	}
+
+	sessions := heartbeat.SessionCount + guard.admitted
+	if guard.maxSessions > 0 && sessions >= guard.maxSessions {
+		return errors.New("Over capacity: " + strconv.FormatInt(sessions, 10) + " sessions")
+	}
+
+	perSec := heartbeat.SessionsPerSec + float64(guard.secondAdmitted)
+	if guard.maxSessionsPerSec > 0 && perSec >= guard.maxSessionsPerSec {
+		return errors.New("Over capacity: " + strconv.FormatFloat(perSec, 'f', -1, 64) + " sessions per second")
+	}
+
+	guard.admitted++
+	guard.secondAdmitted++
+	return nil
+}
+
+//Authorizer returns an Authorizer that admits originate api and bgapi
+//commands with Admit, then runs next, if it isn't nil. Set it on the client
+//with SetAuthorizer to guard every originate, including the Dialer's.
+func (guard *CapacityGuard) Authorizer(next Authorizer) Authorizer {
+	return func(ctx context.Context, cmd Command) (Command, error) {
+		if (cmd.Class == ClassAPI || cmd.Class == ClassBGAPI) && cmd.Name == "originate" {
+			if err := guard.Admit(); err != nil {
+				return cmd, err
+			}
+		}
+
+		if next == nil {
+			return cmd, nil
+		}
+		return next(ctx, cmd)
+	}
+}
@@ -0,0 +1,203 @@
+package fsclient
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//captureValue matches the values CaptureTrigger substitutes into commands.
+//Anything else, such as a Call-ID with spaces or shell metacharacters, which
+//the remote party chooses, could change the meaning of the command.
+var captureValue = regexp.MustCompile(`^[A-Za-z0-9._@:+~-]*$`)
+
+//CaptureCriteria decide which calls a CaptureTrigger captures traces of: calls
+//that hung up with one of HangupCauses, calls whose audio MOS was below
+//MinMOS, if it isn't zero, or calls for which Match, if set, returns true.
+type CaptureCriteria struct {
+	HangupCauses []string
+	MinMOS       float64
+	Match        func(cdr CDR) bool
+}
+
+//Matches returns true if a call meets the criteria.
+func (criteria CaptureCriteria) Matches(cdr CDR) bool {
+	if containsString(criteria.HangupCauses, cdr.HangupCause) {
+		return true
+	}
+	if criteria.MinMOS > 0 && cdr.Quality.HasStats && cdr.Quality.MOS > 0 && cdr.Quality.MOS < criteria.MinMOS {
+		return true
+	}
+	return criteria.Match != nil && criteria.Match(cdr)
+}
+
+//CaptureTrigger runs api commands to capture a trace of problem calls, e.g.
+//asking a packet capture appliance to keep the call's packets or turning on
+//sofia tracing to catch the next occurrence, and tags the call's CDR with a
+//reference to the capture. Register its Tag method with
+//CDRCollector.AddTagger, and its HandleEvent method with a Dispatcher so that
+//commands that fail are logged. The client must be subscribed to
+//BACKGROUND_JOB.
+//
+//Commands are sent with bgapi, so slow ones don't hold up the CDR, and may
+//contain placeholders: {ref} for the capture reference, {uuid}, {call_id} for
+//the SIP Call-ID, and {cause}, e.g. "sofia profile external siptrace on". A
+//call is not captured if any of its values contains characters other than
+//letters, digits and "._@:+~-".
+type CaptureTrigger struct {
+	client   Commander
+	criteria CaptureCriteria
+	commands []string
+	cooldown time.Duration
+	last     time.Time
+	clock    Clock
+
+	jobs      map[string]string
+	unmatched map[string]string
+	sending   int
+	mu        *sync.Mutex
+}
+
+//NewCaptureTrigger creates a CaptureTrigger running commands for calls that
+//meet criteria.
+func NewCaptureTrigger(client Commander, criteria CaptureCriteria, commands ...string) (*CaptureTrigger, error) {
+	if len(commands) == 0 {
+		return nil, errors.New("Capture trigger has no commands")
+	}
+	for _, cmd := range commands {
+		if cmd == "" || strings.ContainsAny(cmd, "\r\n") {
+			return nil, errors.New("Invalid capture command: " + cmd)
+		}
+	}
+
+	return &CaptureTrigger{
+		client:   client,
+		criteria: criteria,
+		commands: commands,
+		clock:    SystemClock,
+
+		jobs:      make(map[string]string),
+		unmatched: make(map[string]string),
+		mu:        &sync.Mutex{},
+	}, nil
+}
+
+//SetClock sets the clock the cooldown is timed with.
+func (trigger *CaptureTrigger) SetClock(clock Clock) {
+	trigger.mu.Lock()
+	defer trigger.mu.Unlock()
+	trigger.clock = clock
+}
+
+//SetCooldown sets the minimum time between captures, so that a burst of
+//failing calls doesn't flood the capture system. Calls that match within it
+//aren't captured or tagged.
+func (trigger *CaptureTrigger) SetCooldown(cooldown time.Duration) {
+	trigger.mu.Lock()
+	defer trigger.mu.Unlock()
+	trigger.cooldown = cooldown
+}
+
+//Tag runs the capture commands for a call that meets the criteria and sets
+//its CDR's CaptureRef. The reference is set even if a command fails, as
+//others may have succeeded. Commands that can't be sent are logged straight
+//away, and those that fail once their results arrive in HandleEvent.
+func (trigger *CaptureTrigger) Tag(cdr *CDR) {
+	if !trigger.criteria.Matches(*cdr) {
+		return
+	}
+
+	values := map[string]string{
+		"{uuid}":    cdr.UUID,
+		"{call_id}": cdr.Event["variable_sip_call_id"],
+		"{cause}":   cdr.HangupCause,
+	}
+	for placeholder, value := range values {
+		if !captureValue.MatchString(value) {
+			log.Print(logPrefix, "Not capturing call ", cdr.UUID, ": unsafe ", placeholder, " value")
+			return
+		}
+	}
+
+	trigger.mu.Lock()
+	now := trigger.clock.Now()
+	if trigger.cooldown > 0 && !trigger.last.IsZero() && now.Sub(trigger.last) < trigger.cooldown {
+		trigger.mu.Unlock()
+		return
+	}
+	trigger.last = now
+	trigger.mu.Unlock()
+
+	ref := NewUUID()
+	replacer := strings.NewReplacer(
+		"{ref}", ref,
+		"{uuid}", values["{uuid}"],
+		"{call_id}", values["{call_id}"],
+		"{cause}", values["{cause}"],
+	)
+	for _, cmd := range trigger.commands {
+		trigger.send(cdr.UUID, replacer.Replace(cmd))
+	}
+	cdr.CaptureRef = ref
+}
+
+//send sends a capture command for the call uuid with bgapi, recording its
+//job so that its result can be checked.
+func (trigger *CaptureTrigger) send(uuid string, cmd string) {
+	trigger.mu.Lock()
+	trigger.sending++
+	trigger.mu.Unlock()
+
+	jobUUID, err := trigger.client.BackgroundAPI(cmd)
+
+	//The job result can arrive before BackgroundAPI returns, in which case it
+	//is waiting in unmatched. Unmatched results are only kept while a
+	//command is being sent so results of unrelated jobs don't build up.
+	trigger.mu.Lock()
+	body, done := trigger.unmatched[jobUUID]
+	if err == nil && !done {
+		trigger.jobs[jobUUID] = uuid
+	}
+	trigger.sending--
+	if trigger.sending == 0 {
+		trigger.unmatched = make(map[string]string)
+	}
+	trigger.mu.Unlock()
+
+	if err != nil {
+		log.Print(logPrefix, "Capture command failed for call ", uuid, ": ", err)
+	} else if done {
+		trigger.jobResult(uuid, body)
+	}
+}
+
+//HandleEvent checks the results of capture commands.
+func (trigger *CaptureTrigger) HandleEvent(event Event) {
+	if event.Name() != "BACKGROUND_JOB" {
+		return
+	}
+	jobUUID := event["Job-UUID"]
+
+	trigger.mu.Lock()
+	uuid, ok := trigger.jobs[jobUUID]
+	delete(trigger.jobs, jobUUID)
+	if !ok && trigger.sending > 0 {
+		trigger.unmatched[jobUUID] = event.Body()
+	}
+	trigger.mu.Unlock()
+
+	if ok {
+		trigger.jobResult(uuid, event.Body())
+	}
+}
+
+//jobResult logs the result of a capture command for the call uuid if it is
+//an error reply.
+func (trigger *CaptureTrigger) jobResult(uuid string, body string) {
+	if errorReply(body) {
+		log.Print(logPrefix, "Capture command failed for call ", uuid, ": ", strings.TrimSpace(body))
+	}
+}
@@ -0,0 +1,110 @@
+package fsclient_test
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+)
+
+//captureLog captures what is logged until the test ends.
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	output := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() {
+		log.SetOutput(output)
+	})
+	return &buf
+}
+
+//TestCaptureTrigger checks which calls are captured, the commands sent for
+//them and which failures are logged.
+func TestCaptureTrigger(t *testing.T) {
+	tests := []struct {
+		name    string
+		cause   string
+		callID  string
+		reply   string
+		sendErr error
+		wantCmd string
+		wantLog string
+		wantRef bool
+	}{
+		{"captured", "INCOMPATIBLE_DESTINATION", "abc@host", "+OK", nil, "pcap keep abc@host INCOMPATIBLE_DESTINATION", "", true},
+		{"not matched", "NORMAL_CLEARING", "abc@host", "+OK", nil, "", "", false},
+		{"unsafe call id", "INCOMPATIBLE_DESTINATION", "abc; rm -rf /", "+OK", nil, "", "unsafe {call_id}", false},
+		{"command failed", "INCOMPATIBLE_DESTINATION", "abc@host", "-ERR no such command", nil, "pcap keep abc@host INCOMPATIBLE_DESTINATION", "-ERR no such command", true},
+		{"not sent", "INCOMPATIBLE_DESTINATION", "abc@host", "", errors.New("Not connected"), "pcap keep abc@host INCOMPATIBLE_DESTINATION", "Not connected", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logged := captureLog(t)
+			client := fsclienttest.NewClient()
+			client.Handle(fsclient.ClassBGAPI, "pcap", func(call fsclienttest.Call) (string, error) {
+				return test.reply, test.sendErr
+			})
+
+			trigger, err := fsclient.NewCaptureTrigger(client, fsclient.CaptureCriteria{HangupCauses: []string{"INCOMPATIBLE_DESTINATION"}}, "pcap keep {call_id} {cause}")
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.OnEvent(trigger.HandleEvent)
+
+			cdr := fsclient.CDR{UUID: callerUUID, HangupCause: test.cause, Event: fsclient.Event{"variable_sip_call_id": test.callID}}
+			trigger.Tag(&cdr)
+
+			cmds := sentCommands(client)
+			if test.wantCmd == "" && len(cmds) != 0 || test.wantCmd != "" && (len(cmds) != 1 || cmds[0] != test.wantCmd) {
+				t.Errorf("Sent %q, want %q", cmds, test.wantCmd)
+			}
+			if (cdr.CaptureRef != "") != test.wantRef {
+				t.Errorf("Got capture reference %q", cdr.CaptureRef)
+			}
+			if test.wantLog == "" && logged.Len() != 0 || !strings.Contains(logged.String(), test.wantLog) {
+				t.Errorf("Logged %q, want %q", logged, test.wantLog)
+			}
+		})
+	}
+}
+
+//TestCaptureTriggerCooldown checks that calls aren't captured within the
+//cooldown of the last capture.
+func TestCaptureTriggerCooldown(t *testing.T) {
+	client := fsclienttest.NewClient()
+	client.Reply(fsclient.ClassBGAPI, "sofia", "+OK")
+	clock := fsclienttest.NewClock(time.Unix(0, 0))
+
+	trigger, err := fsclient.NewCaptureTrigger(client, fsclient.CaptureCriteria{MinMOS: 3.5}, "sofia global siptrace on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trigger.SetClock(clock)
+	trigger.SetCooldown(time.Minute)
+
+	tests := []struct {
+		advance time.Duration
+		want    bool
+	}{
+		{0, true},
+		{30 * time.Second, false},
+		{30 * time.Second, true},
+	}
+	for i, test := range tests {
+		clock.Advance(test.advance)
+		cdr := fsclient.CDR{UUID: callerUUID, Quality: fsclient.QualityReport{HasStats: true, MOS: 2.1}}
+		trigger.Tag(&cdr)
+		if (cdr.CaptureRef != "") != test.want {
+			t.Errorf("Call %d captured %v, want %v", i, cdr.CaptureRef != "", test.want)
+		}
+	}
+	if cmds := sentCommands(client); len(cmds) != 2 {
+		t.Errorf("Sent %d commands, want 2", len(cmds))
+	}
+}
@@ -0,0 +1,199 @@
+package fsclient
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+//CDR is a call detail record built from a CHANNEL_HANGUP_COMPLETE event.
+//Gateway is the SIP gateway the call was routed through, if any, so quality
+//can be tracked per route. Times are in the server's timezone and are zero
+//if they didn't happen, e.g. Answer for an unanswered call.
+//
+//Duration and Billsec are Freeswitch's whole seconds from the start and
+//answer of the call to its end. The derived durations are to the millisecond:
+//RingTime is from the start until the call was answered, or ended if it
+//wasn't, ProgressTime and ProgressMediaTime are from the start until ringing
+//and early media, and TalkTime is from answer to end. Leg is LegA for a call
+//that came in or was originated first, which is normally the one billed, and
+//LegB for a call it originated, e.g. with bridge. OtherLeg is the UUID of the
+//leg it was bridged with, if any. CaptureRef is the reference of a trace
+//captured for the call, see CaptureTrigger.
+type CDR struct {
+	UUID          string
+	Direction     string
+	CallerNumber  string
+	Destination   string
+	Gateway       string
+	Start         time.Time
+	Progress      time.Time
+	ProgressMedia time.Time
+	Answer        time.Time
+	End           time.Time
+	Duration      time.Duration
+	Billsec       time.Duration
+	HangupCause   string
+	Quality       QualityReport
+	Event         Event
+
+	RingTime          time.Duration
+	ProgressTime      time.Duration
+	ProgressMediaTime time.Duration
+	TalkTime          time.Duration
+	Leg               Leg
+	OtherLeg          string
+	CaptureRef        string
+}
+
+//ParseCDR builds a CDR from a CHANNEL_HANGUP_COMPLETE event.
+func ParseCDR(event Event) CDR {
+	cdr := CDR{
+		UUID:          event.UUID(),
+		Direction:     event["Call-Direction"],
+		CallerNumber:  event["Caller-Caller-ID-Number"],
+		Destination:   event["Caller-Destination-Number"],
+		Gateway:       event["variable_sip_gateway_name"],
+		Start:         epochVariable(event, "start"),
+		Progress:      epochVariable(event, "progress"),
+		ProgressMedia: epochVariable(event, "progress_media"),
+		Answer:        epochVariable(event, "answer"),
+		End:           epochVariable(event, "end"),
+		Duration:      time.Duration(int64Header(event, "variable_duration")) * time.Second,
+		Billsec:       time.Duration(int64Header(event, "variable_billsec")) * time.Second,
+		HangupCause:   event["Hangup-Cause"],
+		Quality:       ParseQualityReport(event),
+		Event:         event,
+		Leg:           LegA,
+		OtherLeg:      firstHeader(event, "variable_bridge_uuid", "variable_originator", "variable_signal_bond", "Other-Leg-Unique-ID"),
+	}
+
+	//The B leg of a bridge or originate records the leg that created it.
+	if event["variable_originator"] != "" || event["variable_originating_leg_uuid"] != "" {
+		cdr.Leg = LegB
+	}
+
+	cdr.ProgressTime = msecVariable(event, "progressmsec", cdr.Start, cdr.Progress)
+	cdr.ProgressMediaTime = msecVariable(event, "progress_mediamsec", cdr.Start, cdr.ProgressMedia)
+	cdr.TalkTime = msecVariable(event, "billmsec", cdr.Answer, cdr.End)
+	if cdr.Answer.IsZero() {
+		cdr.RingTime = msecVariable(event, "mduration", cdr.Start, cdr.End)
+	} else {
+		cdr.RingTime = msecVariable(event, "answermsec", cdr.Start, cdr.Answer)
+	}
+	return cdr
+}
+
+//msecVariable returns the duration in a milliseconds channel variable of a
+//hangup complete event, or if it isn't set, the time from start to end.
+//It returns zero when end didn't happen.
+func msecVariable(event Event, name string, start time.Time, end time.Time) time.Duration {
+	if _, ok := event["variable_"+name]; ok {
+		return time.Duration(int64Header(event, "variable_"+name)) * time.Millisecond
+	}
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start).Truncate(time.Millisecond)
+}
+
+//Billed returns the talk time rounded up for billing in increments, with a
+//minimum charge, e.g. Billed(time.Minute, 6*time.Second) for 60/6 billing.
+//Unanswered calls aren't billed.
+func (cdr CDR) Billed(minimum time.Duration, increment time.Duration) time.Duration {
+	if cdr.Answer.IsZero() || cdr.TalkTime <= 0 {
+		return 0
+	}
+
+	billed := cdr.TalkTime
+	if increment > 0 && billed%increment != 0 {
+		billed += increment - billed%increment
+	}
+	if billed < minimum {
+		billed = minimum
+	}
+	return billed
+}
+
+//epochVariable returns the time of a call milestone from its <name>_uepoch
+//channel variable in microseconds, falling back to <name>_epoch in seconds,
+//in the server's timezone. It returns the zero time if neither is set or
+//they are zero.
+func epochVariable(event Event, name string) time.Time {
+	if t := event.Timestamp("variable_" + name + "_uepoch"); !t.IsZero() {
+		return t
+	}
+
+	secs, err := strconv.ParseInt(event["variable_"+name+"_epoch"], 10, 64)
+	if err != nil || secs == 0 {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0).In(event.Location())
+}
+
+//CDRCollector builds a CDR for every call that hangs up.
+//
+//Register the collector's HandleEvent method with a Dispatcher. The client
+//must be subscribed to CHANNEL_HANGUP_COMPLETE.
+type CDRCollector struct {
+	taggers []func(*CDR)
+	onCDR   []func(CDR)
+	onPoor  []func(CDR)
+	mu      *sync.RWMutex
+}
+
+//NewCDRCollector creates a CDRCollector.
+func NewCDRCollector() *CDRCollector {
+	return &CDRCollector{mu: &sync.RWMutex{}}
+}
+
+//AddTagger registers a function that can add to the CDR of every call, such
+//as CaptureTrigger.Tag, before the CDR callbacks are run.
+func (collector *CDRCollector) AddTagger(fn func(cdr *CDR)) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.taggers = append(collector.taggers, fn)
+}
+
+//OnCDR registers a function to be called with the CDR of every call.
+func (collector *CDRCollector) OnCDR(fn func(cdr CDR)) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.onCDR = append(collector.onCDR, fn)
+}
+
+//OnPoorQuality registers a function to be called with the CDR of every call
+//whose QualityReport shows poor audio quality.
+func (collector *CDRCollector) OnPoorQuality(fn func(cdr CDR)) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.onPoor = append(collector.onPoor, fn)
+}
+
+//HandleEvent builds a CDR from a CHANNEL_HANGUP_COMPLETE event, tags it and
+//runs the callbacks.
+func (collector *CDRCollector) HandleEvent(event Event) {
+	if event.Name() != "CHANNEL_HANGUP_COMPLETE" {
+		return
+	}
+
+	cdr := ParseCDR(event)
+
+	collector.mu.RLock()
+	taggers := collector.taggers
+	onCDR := collector.onCDR
+	onPoor := collector.onPoor
+	collector.mu.RUnlock()
+
+	for _, fn := range taggers {
+		fn(&cdr)
+	}
+	for _, fn := range onCDR {
+		fn(cdr)
+	}
+	if cdr.Quality.Poor() {
+		for _, fn := range onPoor {
+			fn(cdr)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package fsclient
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+//ChannelGroup is a group of channels counted by a ChannelGauge. Profile is
+//the sofia profile of SIP channels and empty for other endpoints, and Gateway
+//is the gateway a channel goes through, if any.
+type ChannelGroup struct {
+	Profile   string
+	Gateway   string
+	Direction string
+}
+
+//ChannelCount is the number of active channels in a group.
+type ChannelCount struct {
+	ChannelGroup
+	Count int
+}
+
+//ChannelGauge counts the active channels of each sofia profile, gateway and
+//direction from channel events, so trunk capacity in use can be watched
+//without polling "show channels".
+//
+//Register the gauge's HandleEvent method with a Dispatcher. The client must
+//be subscribed to CHANNEL_CREATE, CHANNEL_ANSWER and CHANNEL_HANGUP_COMPLETE.
+type ChannelGauge struct {
+	channels map[string]ChannelGroup
+	counts   map[ChannelGroup]int
+	gauge    func(group ChannelGroup) Gauge
+	mu       *sync.Mutex
+}
+
+//NewChannelGauge creates a ChannelGauge with no channels.
+func NewChannelGauge() *ChannelGauge {
+	return &ChannelGauge{
+		channels: make(map[string]ChannelGroup),
+		counts:   make(map[ChannelGroup]int),
+		mu:       &sync.Mutex{},
+	}
+}
+
+//SetGauges sets a function returning the gauge of a group, which is set
+//whenever the group's count changes, e.g. a Prometheus GaugeVec's
+//WithLabelValues(group.Profile, group.Gateway, group.Direction).
+func (gauge *ChannelGauge) SetGauges(fn func(group ChannelGroup) Gauge) {
+	gauge.mu.Lock()
+	defer gauge.mu.Unlock()
+	gauge.gauge = fn
+}
+
+//Load adds the channels that already exist in Freeswitch, for when the gauge
+//starts after calls are up. Their gateways aren't known until their next
+//event.
+func (gauge *ChannelGauge) Load(client Commander) error {
+	channels, err := APIJSON[ShowResult[channelRow]](client, "show channels")
+	if err != nil {
+		return err
+	}
+
+	for _, row := range channels.Rows {
+		gauge.update(row.UUID, ChannelGroup{
+			Profile:   channelProfile(row.Name),
+			Direction: row.Direction,
+		})
+	}
+	return nil
+}
+
+//Count returns the number of active channels in a group.
+func (gauge *ChannelGauge) Count(group ChannelGroup) int {
+	gauge.mu.Lock()
+	defer gauge.mu.Unlock()
+	return gauge.counts[group]
+}
+
+//Stats returns the number of active channels in each group that has any,
+//sorted by profile, gateway and direction.
+func (gauge *ChannelGauge) Stats() []ChannelCount {
+	gauge.mu.Lock()
+	defer gauge.mu.Unlock()
+
+	stats := make([]ChannelCount, 0, len(gauge.counts))
+	for group, count := range gauge.counts {
+		stats = append(stats, ChannelCount{ChannelGroup: group, Count: count})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Profile != b.Profile {
+			return a.Profile < b.Profile
+		}
+		if a.Gateway != b.Gateway {
+			return a.Gateway < b.Gateway
+		}
+		return a.Direction < b.Direction
+	})
+	return stats
+}
+
+//HandleEvent counts new channels, moves channels to their gateway's group
+//once it is known and removes channels once they hang up.
+func (gauge *ChannelGauge) HandleEvent(event Event) {
+	uuid := event.UUID()
+	if uuid == "" {
+		return
+	}
+
+	switch event.Name() {
+	case "CHANNEL_CREATE", "CHANNEL_ANSWER":
+		profile := event["variable_sofia_profile_name"]
+		if profile == "" {
+			profile = channelProfile(event["Channel-Name"])
+		}
+		group := ChannelGroup{
+			Profile:   profile,
+			Gateway:   firstHeader(event, "variable_sip_gateway_name", "variable_sip_gateway"),
+			Direction: event["Call-Direction"],
+		}
+
+		gauge.mu.Lock()
+		current, tracked := gauge.channels[uuid]
+		gauge.mu.Unlock()
+
+		//Later events only fill in what the first didn't have.
+		if tracked {
+			if group.Profile == "" {
+				group.Profile = current.Profile
+			}
+			if group.Gateway == "" {
+				group.Gateway = current.Gateway
+			}
+			if group.Direction == "" {
+				group.Direction = current.Direction
+			}
+		}
+		gauge.update(uuid, group)
+	case "CHANNEL_HANGUP_COMPLETE":
+		gauge.remove(uuid)
+	}
+}
+
+//update moves a channel into a group, adding it if it isn't counted yet.
+func (gauge *ChannelGauge) update(uuid string, group ChannelGroup) {
+	gauge.mu.Lock()
+	current, tracked := gauge.channels[uuid]
+	if tracked && current == group {
+		gauge.mu.Unlock()
+		return
+	}
+	gauge.channels[uuid] = group
+	changed := []ChannelGroup{group}
+	gauge.counts[group]++
+	if tracked {
+		gauge.decrement(current)
+		changed = append(changed, current)
+	}
+	gauge.mu.Unlock()
+
+	gauge.setGauges(changed)
+}
+
+//remove stops counting a channel.
+func (gauge *ChannelGauge) remove(uuid string) {
+	gauge.mu.Lock()
+	group, tracked := gauge.channels[uuid]
+	if !tracked {
+		gauge.mu.Unlock()
+		return
+	}
+	delete(gauge.channels, uuid)
+	gauge.decrement(group)
+	gauge.mu.Unlock()
+
+	gauge.setGauges([]ChannelGroup{group})
+}
+
+//decrement removes a channel from a group's count. The lock must be held.
+func (gauge *ChannelGauge) decrement(group ChannelGroup) {
+	if gauge.counts[group]--; gauge.counts[group] <= 0 {
+		delete(gauge.counts, group)
+	}
+}
+
+//setGauges sets the gauges of groups to their current counts.
+func (gauge *ChannelGauge) setGauges(groups []ChannelGroup) {
+	gauge.mu.Lock()
+	fn := gauge.gauge
+	counts := make([]int, len(groups))
+	for i, group := range groups {
+		counts[i] = gauge.counts[group]
+	}
+	gauge.mu.Unlock()
+
+	if fn == nil {
+		return
+	}
+	for i, group := range groups {
+		setGauge(fn(group), float64(counts[i]))
+	}
+}
+
+//channelProfile returns the sofia profile of a channel name such as
+//"sofia/internal/1000@10.0.0.1", or an empty string for other endpoints.
+func channelProfile(name string) string {
+	endpoint, rest, ok := strings.Cut(name, "/")
+	if !ok || endpoint != "sofia" {
+		return ""
+	}
+	profile, _, _ := strings.Cut(rest, "/")
+	return profile
+}
@@ -0,0 +1,367 @@
+package fsclient
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//compactEvery is the number of acknowledgements between compactions of the
+//event log.
+const compactEvery = 1000
+
+//SequencedEvent is an event with the monotonic sequence number assigned to it
+//by a Checkpointer.
+type SequencedEvent struct {
+	Seq   uint64
+	Event map[string]string
+}
+
+//CheckpointStore persists the sequence number of the last event that has been
+//fully processed by the application.
+type CheckpointStore interface {
+	Load() (uint64, error)
+	Save(seq uint64) error
+}
+
+//EventLog is a durable buffer of sequenced events that have not yet been
+//acknowledged, used to replay them after a restart.
+type EventLog interface {
+	Append(seq uint64, event map[string]string) error
+	Replay(after uint64, fn func(seq uint64, event map[string]string) error) error
+	Compact(upTo uint64) error
+}
+
+//Checkpointer assigns monotonic sequence numbers to events read from a source
+//and delivers them on EventCh. Events are written to an optional EventLog
+//before delivery, and the application calls Ack once it has durably processed
+//an event. After a restart, logged events newer than the saved checkpoint are
+//replayed before any new events, giving at-least-once delivery.
+type Checkpointer struct {
+	EventCh      chan SequencedEvent
+	store        CheckpointStore
+	eventLog     EventLog
+	next         uint64
+	acked        uint64
+	sinceCompact int
+	ackMu        *sync.Mutex
+}
+
+//NewCheckpointer creates a Checkpointer that reads events from source, such as
+//a Client's EventCh, and resumes from the checkpoint in store. If eventLog is
+//nil events can't be replayed, so resync (if not nil) is called with the last
+//checkpoint before any events are delivered, allowing the application to
+//re-query channel state instead.
+func NewCheckpointer(source <-chan map[string]string, store CheckpointStore, eventLog EventLog, bufSize int, resync func(lastSeq uint64)) (*Checkpointer, error) {
+	last, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &Checkpointer{
+		EventCh:  make(chan SequencedEvent, bufSize),
+		store:    store,
+		eventLog: eventLog,
+		next:     last + 1,
+		acked:    last,
+		ackMu:    &sync.Mutex{},
+	}
+
+	go cp.run(source, last, resync)
+	return cp, nil
+}
+
+//Ack records that all events up to and including seq have been processed.
+//Acknowledging an older sequence number than a previous Ack has no effect.
+func (cp *Checkpointer) Ack(seq uint64) error {
+	cp.ackMu.Lock()
+	defer cp.ackMu.Unlock()
+
+	if seq <= cp.acked {
+		return nil
+	}
+
+	if err := cp.store.Save(seq); err != nil {
+		return err
+	}
+	cp.acked = seq
+
+	cp.sinceCompact++
+	if cp.eventLog != nil && cp.sinceCompact >= compactEvery {
+		cp.sinceCompact = 0
+		return cp.eventLog.Compact(seq)
+	}
+	return nil
+}
+
+//run replays any unacknowledged logged events and then sequences new events
+//from source, closing EventCh when the source is closed.
+func (cp *Checkpointer) run(source <-chan map[string]string, last uint64, resync func(uint64)) {
+	defer close(cp.EventCh)
+
+	if cp.eventLog != nil {
+		err := cp.eventLog.Replay(last, func(seq uint64, event map[string]string) error {
+			cp.EventCh <- SequencedEvent{Seq: seq, Event: event}
+			if seq >= cp.next {
+				cp.next = seq + 1
+			}
+			return nil
+		})
+		if err != nil {
+			log.Print(logPrefix, "Event log replay failed: ", err)
+		}
+	} else if resync != nil {
+		resync(last)
+	}
+
+	for event := range source {
+		seq := cp.next
+		cp.next++
+
+		if cp.eventLog != nil {
+			if err := cp.eventLog.Append(seq, event); err != nil {
+				log.Print(logPrefix, "Event log append failed: ", err)
+			}
+		}
+		cp.EventCh <- SequencedEvent{Seq: seq, Event: event}
+	}
+}
+
+//FileCheckpointStore stores the checkpoint as a decimal number in a file,
+//replacing it atomically on each save.
+type FileCheckpointStore struct {
+	Path string
+}
+
+//Load reads the checkpoint, returning zero if the file doesn't exist yet.
+func (store *FileCheckpointStore) Load() (uint64, error) {
+	data, err := os.ReadFile(store.Path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+//Save writes the checkpoint to a temporary file and renames it into place.
+func (store *FileCheckpointStore) Save(seq uint64) error {
+	return writeFileAtomic(store.Path, []byte(strconv.FormatUint(seq, 10)+"\n"))
+}
+
+//loggedEvent is the on-disk form of an event in a FileEventLog.
+type loggedEvent struct {
+	Seq   uint64            `json:"seq"`
+	Event map[string]string `json:"event"`
+}
+
+//FileEventLog is an EventLog stored in a single append-only file of
+//checksummed records. Each append is synced to disk. A partially written
+//record at the end of the file, left by a crash, is discarded on open.
+type FileEventLog struct {
+	path   string
+	file   *os.File
+	cipher RecordCipher
+	mu     *sync.Mutex
+}
+
+//OpenFileEventLog opens or creates the event log at path.
+func OpenFileEventLog(path string) (*FileEventLog, error) {
+	return OpenFileEventLogWithCipher(path, nil)
+}
+
+//OpenFileEventLogWithCipher opens or creates the event log at path like
+//OpenFileEventLog, encrypting its events with recordCipher. A log must always
+//be opened with the same cipher.
+func OpenFileEventLogWithCipher(path string, recordCipher RecordCipher) (*FileEventLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	//Find the end of the last complete record and discard anything after it.
+	var valid int64
+	reader := bufio.NewReader(file)
+	for {
+		payload, err := readRecord(reader)
+		if err != nil {
+			if err != io.EOF {
+				log.Print(logPrefix, "Discarding corrupt tail of event log ", path)
+			}
+			break
+		}
+		valid += int64(8 + len(payload))
+	}
+
+	if err := file.Truncate(valid); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &FileEventLog{path: path, file: file, cipher: recordCipher, mu: &sync.Mutex{}}, nil
+}
+
+//Append writes an event to the end of the log.
+func (eventLog *FileEventLog) Append(seq uint64, event map[string]string) error {
+	payload, err := json.Marshal(loggedEvent{Seq: seq, Event: event})
+	if err != nil {
+		return err
+	}
+	if payload, err = encryptRecord(eventLog.cipher, payload); err != nil {
+		return err
+	}
+
+	eventLog.mu.Lock()
+	defer eventLog.mu.Unlock()
+
+	if err := writeRecord(eventLog.file, payload); err != nil {
+		return err
+	}
+	return eventLog.file.Sync()
+}
+
+//Replay calls fn for each logged event with a sequence number after after.
+func (eventLog *FileEventLog) Replay(after uint64, fn func(seq uint64, event map[string]string) error) error {
+	events, err := eventLog.read(after)
+	if err != nil {
+		return err
+	}
+
+	for _, logged := range events {
+		if err := fn(logged.Seq, logged.Event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Compact rewrites the log without the events up to and including upTo.
+func (eventLog *FileEventLog) Compact(upTo uint64) error {
+	eventLog.mu.Lock()
+	defer eventLog.mu.Unlock()
+
+	events, err := eventLog.readLocked(upTo)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := eventLog.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(tmp)
+	for _, logged := range events {
+		payload, _ := json.Marshal(logged)
+		if payload, err = encryptRecord(eventLog.cipher, payload); err != nil {
+			break
+		}
+		if err = writeRecord(writer, payload); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, eventLog.path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	eventLog.file.Close()
+	eventLog.file = tmp
+	_, err = tmp.Seek(0, io.SeekEnd)
+	return err
+}
+
+//Close closes the log file.
+func (eventLog *FileEventLog) Close() error {
+	eventLog.mu.Lock()
+	defer eventLog.mu.Unlock()
+	return eventLog.file.Close()
+}
+
+//read returns the logged events with sequence numbers after after.
+func (eventLog *FileEventLog) read(after uint64) ([]loggedEvent, error) {
+	eventLog.mu.Lock()
+	defer eventLog.mu.Unlock()
+	return eventLog.readLocked(after)
+}
+
+//readLocked reads the log from the start, leaving the file positioned at the
+//end ready for further appends. Records that can't be decrypted or decoded,
+//e.g. because their key is no longer available, are skipped, and discarded
+//by the next compaction. The caller must hold the lock.
+func (eventLog *FileEventLog) readLocked(after uint64) ([]loggedEvent, error) {
+	if _, err := eventLog.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	defer eventLog.file.Seek(0, io.SeekEnd)
+
+	var events []loggedEvent
+	reader := bufio.NewReader(eventLog.file)
+	for {
+		payload, err := readRecord(reader)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if payload, err = decryptRecord(eventLog.cipher, payload); err != nil {
+			log.Print(logPrefix, "Skipping unreadable record in event log ", eventLog.path, ": ", err)
+			continue
+		}
+
+		var logged loggedEvent
+		if err := json.Unmarshal(payload, &logged); err != nil {
+			log.Print(logPrefix, "Skipping unreadable record in event log ", eventLog.path, ": ", err)
+			continue
+		}
+		if logged.Seq > after {
+			events = append(events, logged)
+		}
+	}
+}
+
+//writeFileAtomic writes data to a temporary file in the same directory and
+//renames it over path, so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
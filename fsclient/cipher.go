@@ -0,0 +1,159 @@
+package fsclient
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"sync"
+)
+
+//errInvalidCiphertext is returned when an encrypted record is too short or
+//fails authentication, e.g. because it was written with a different key or
+//without encryption.
+var errInvalidCiphertext = errors.New("Invalid encrypted record")
+
+//RecordCipher encrypts the records of disk backed queues, logs and stores, as
+//call metadata is often not allowed to be written to disk in plain text. The
+//records are still length and checksum framed, so torn writes are detected
+//without decrypting them.
+type RecordCipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+//KeyProvider supplies an AESCipher's keys, which are 16, 24 or 32 bytes for
+//AES-128, AES-192 or AES-256. CurrentKey is the key new records are
+//encrypted with, and Key returns a key by its id so records written before
+//the key was rotated can still be read. An id must always identify the same
+//key, and be at most 255 bytes.
+type KeyProvider interface {
+	CurrentKey() (id string, key []byte, err error)
+	Key(id string) ([]byte, error)
+}
+
+//StaticKey returns a KeyProvider for a single fixed key.
+func StaticKey(key []byte) KeyProvider {
+	return staticKey(append([]byte(nil), key...))
+}
+
+//staticKey is a KeyProvider for a fixed key, which has an empty id.
+type staticKey []byte
+
+//CurrentKey returns the fixed key.
+func (key staticKey) CurrentKey() (string, []byte, error) {
+	return "", key, nil
+}
+
+//Key returns the fixed key.
+func (key staticKey) Key(id string) ([]byte, error) {
+	if id != "" {
+		return nil, errors.New("Unknown record key: " + id)
+	}
+	return key, nil
+}
+
+//AESCipher is a RecordCipher using AES-GCM, with keys from a KeyProvider.
+//Each record holds the id of its key and a random nonce, followed by the
+//sealed data.
+type AESCipher struct {
+	keys  KeyProvider
+	aeads map[string]cipher.AEAD
+	mu    *sync.Mutex
+}
+
+//NewAESCipher creates an AESCipher using keys.
+func NewAESCipher(keys KeyProvider) *AESCipher {
+	return &AESCipher{keys: keys, aeads: make(map[string]cipher.AEAD), mu: &sync.Mutex{}}
+}
+
+//aead returns the AES-GCM cipher for a key, creating it on first use.
+func (aesCipher *AESCipher) aead(id string, key []byte) (cipher.AEAD, error) {
+	aesCipher.mu.Lock()
+	defer aesCipher.mu.Unlock()
+
+	if aead, ok := aesCipher.aeads[id]; ok {
+		return aead, nil
+	}
+
+	if key == nil {
+		var err error
+		if key, err = aesCipher.keys.Key(id); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	aesCipher.aeads[id] = aead
+	return aead, nil
+}
+
+//Encrypt encrypts a record with the current key.
+func (aesCipher *AESCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	id, key, err := aesCipher.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, errors.New("Record key id too long: " + id)
+	}
+	aead, err := aesCipher.aead(id, key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 1+len(id)+aead.NonceSize(), 1+len(id)+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out[0] = byte(len(id))
+	copy(out[1:], id)
+	nonce := out[1+len(id):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, nonce, plaintext, []byte(id)), nil
+}
+
+//Decrypt decrypts a record with the key it was encrypted with.
+func (aesCipher *AESCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 1 || len(ciphertext) < 1+int(ciphertext[0]) {
+		return nil, errInvalidCiphertext
+	}
+	id := string(ciphertext[1 : 1+int(ciphertext[0])])
+	aead, err := aesCipher.aead(id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := ciphertext[1+len(id):]
+	if len(sealed) < aead.NonceSize() {
+		return nil, errInvalidCiphertext
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, errInvalidCiphertext
+	}
+	return plaintext, nil
+}
+
+//encryptRecord encrypts a record's payload with recordCipher, if it isn't
+//nil.
+func encryptRecord(recordCipher RecordCipher, payload []byte) ([]byte, error) {
+	if recordCipher == nil {
+		return payload, nil
+	}
+	return recordCipher.Encrypt(payload)
+}
+
+//decryptRecord decrypts a record's payload with recordCipher, if it isn't
+//nil.
+func decryptRecord(recordCipher RecordCipher, payload []byte) ([]byte, error) {
+	if recordCipher == nil {
+		return payload, nil
+	}
+	return recordCipher.Decrypt(payload)
+}
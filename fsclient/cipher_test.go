@@ -0,0 +1,261 @@
+package fsclient
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+//testKeys is a KeyProvider whose current key and available keys can be
+//changed, to rotate keys in tests.
+type testKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+//CurrentKey returns the current key.
+func (keys *testKeys) CurrentKey() (string, []byte, error) {
+	return keys.current, keys.keys[keys.current], nil
+}
+
+//Key returns a key by its id.
+func (keys *testKeys) Key(id string) ([]byte, error) {
+	key, ok := keys.keys[id]
+	if !ok {
+		return nil, errors.New("Unknown record key: " + id)
+	}
+	return key, nil
+}
+
+//newTestKeys creates a testKeys with keys "old" and "new", whose current key
+//is "old".
+func newTestKeys() *testKeys {
+	return &testKeys{
+		current: "old",
+		keys: map[string][]byte{
+			"old": bytes.Repeat([]byte{1}, 32),
+			"new": bytes.Repeat([]byte{2}, 16),
+		},
+	}
+}
+
+//tamperCipher is a RecordCipher that changes the last byte of the records
+//it encrypts while tamper is set, so they fail authentication.
+type tamperCipher struct {
+	RecordCipher
+	tamper bool
+}
+
+//Encrypt encrypts a record, tampering with it if tamper is set.
+func (tamper *tamperCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	ciphertext, err := tamper.RecordCipher.Encrypt(plaintext)
+	if err == nil && tamper.tamper {
+		ciphertext[len(ciphertext)-1] ^= 0xff
+	}
+	return ciphertext, err
+}
+
+//TestAESCipher checks that records decrypt with the key they were encrypted
+//with after it is rotated, and fail once it has been removed or if they have
+//been tampered with.
+func TestAESCipher(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(keys *testKeys, ciphertext []byte) []byte
+		wantErr bool
+	}{
+		{"same key", func(keys *testKeys, ciphertext []byte) []byte {
+			return ciphertext
+		}, false},
+		{"rotated key", func(keys *testKeys, ciphertext []byte) []byte {
+			keys.current = "new"
+			return ciphertext
+		}, false},
+		{"removed key", func(keys *testKeys, ciphertext []byte) []byte {
+			keys.current = "new"
+			delete(keys.keys, "old")
+			return ciphertext
+		}, true},
+		{"tampered", func(keys *testKeys, ciphertext []byte) []byte {
+			ciphertext[len(ciphertext)-1] ^= 0xff
+			return ciphertext
+		}, true},
+		{"tampered key id", func(keys *testKeys, ciphertext []byte) []byte {
+			copy(ciphertext[1:], "new")
+			return ciphertext
+		}, true},
+		{"truncated", func(keys *testKeys, ciphertext []byte) []byte {
+			return ciphertext[:10]
+		}, true},
+		{"plaintext", func(keys *testKeys, ciphertext []byte) []byte {
+			return []byte(`{"Event-Name":"HEARTBEAT"}`)
+		}, true},
+		{"empty", func(keys *testKeys, ciphertext []byte) []byte {
+			return nil
+		}, true},
+	}
+
+	plaintext := []byte(`{"Event-Name":"CHANNEL_ANSWER"}`)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := newTestKeys()
+			ciphertext, err := NewAESCipher(keys).Encrypt(plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(ciphertext, plaintext) {
+				t.Fatal("Record isn't encrypted")
+			}
+
+			//A new cipher, as after a restart, so no keys are cached.
+			ciphertext = test.change(keys, ciphertext)
+			got, err := NewAESCipher(keys).Decrypt(ciphertext)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Decrypted %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Errorf("Got %q, want %q", got, plaintext)
+			}
+		})
+	}
+}
+
+//TestEncryptedStores writes events to each encrypted store with a key that
+//is then removed, a tampered record and the new key, and checks that only
+//the readable event is read back after reopening the store.
+func TestEncryptedStores(t *testing.T) {
+	events := []map[string]string{
+		{"Event-Name": "CHANNEL_CREATE", "Unique-ID": "1234"},
+		{"Event-Name": "CHANNEL_ANSWER", "Unique-ID": "1234"},
+		{"Event-Name": "CHANNEL_HANGUP", "Unique-ID": "1234"},
+	}
+
+	tests := []struct {
+		name  string
+		write func(path string, recordCipher RecordCipher, seq int, event map[string]string) error
+		read  func(path string, recordCipher RecordCipher) ([]map[string]string, error)
+	}{
+		{
+			name: "event log",
+			write: func(path string, recordCipher RecordCipher, seq int, event map[string]string) error {
+				eventLog, err := OpenFileEventLogWithCipher(path, recordCipher)
+				if err != nil {
+					return err
+				}
+				defer eventLog.Close()
+				return eventLog.Append(uint64(seq), event)
+			},
+			read: func(path string, recordCipher RecordCipher) ([]map[string]string, error) {
+				eventLog, err := OpenFileEventLogWithCipher(path, recordCipher)
+				if err != nil {
+					return nil, err
+				}
+				defer eventLog.Close()
+
+				//Compacting must keep the readable event.
+				if err := eventLog.Compact(0); err != nil {
+					return nil, err
+				}
+				var got []map[string]string
+				err = eventLog.Replay(0, func(seq uint64, event map[string]string) error {
+					got = append(got, event)
+					return nil
+				})
+				return got, err
+			},
+		},
+		{
+			name: "event store",
+			write: func(path string, recordCipher RecordCipher, seq int, event map[string]string) error {
+				store, err := OpenFileEventStoreWithCipher(path, recordCipher)
+				if err != nil {
+					return err
+				}
+				defer store.Close()
+				return store.Append(event)
+			},
+			read: func(path string, recordCipher RecordCipher) ([]map[string]string, error) {
+				store, err := OpenFileEventStoreWithCipher(path, recordCipher)
+				if err != nil {
+					return nil, err
+				}
+				defer store.Close()
+
+				stored, err := store.QueryByUUID("1234")
+				var got []map[string]string
+				for _, event := range stored {
+					got = append(got, event)
+				}
+				return got, err
+			},
+		},
+		{
+			name: "disk queue",
+			write: func(path string, recordCipher RecordCipher, seq int, event map[string]string) error {
+				queue, err := OpenDiskQueueWithCipher(path, 1<<20, recordCipher)
+				if err != nil {
+					return err
+				}
+				defer queue.Close()
+				return queue.Push(event)
+			},
+			read: func(path string, recordCipher RecordCipher) ([]map[string]string, error) {
+				queue, err := OpenDiskQueueWithCipher(path, 1<<20, recordCipher)
+				if err != nil {
+					return nil, err
+				}
+				defer queue.Close()
+
+				//Unreadable records are skipped by removing them.
+				var got []map[string]string
+				for queue.Len() > 0 {
+					event, _, err := queue.Peek()
+					if err == nil {
+						got = append(got, event)
+					}
+					if err := queue.Remove(); err != nil {
+						return nil, err
+					}
+				}
+				return got, nil
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "records")
+			keys := newTestKeys()
+			recordCipher := &tamperCipher{RecordCipher: NewAESCipher(keys)}
+
+			if err := test.write(path, recordCipher, 1, events[0]); err != nil {
+				t.Fatal(err)
+			}
+			keys.current = "new"
+			recordCipher.tamper = true
+			if err := test.write(path, recordCipher, 2, events[1]); err != nil {
+				t.Fatal(err)
+			}
+			recordCipher.tamper = false
+			if err := test.write(path, recordCipher, 3, events[2]); err != nil {
+				t.Fatal(err)
+			}
+
+			delete(keys.keys, "old")
+			got, err := test.read(path, NewAESCipher(keys))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || got[0]["Event-Name"] != "CHANNEL_HANGUP" {
+				t.Errorf("Got events %v, want only %v", got, events[2])
+			}
+		})
+	}
+}
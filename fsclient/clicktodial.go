@@ -0,0 +1,283 @@
+package fsclient
+
+import (
+	"errors"
+	"sync"
+)
+
+//ClickToDialLeg is a leg of a click-to-dial call.
+type ClickToDialLeg string
+
+//Click-to-dial legs.
+const (
+	AgentLeg       ClickToDialLeg = "agent"
+	DestinationLeg ClickToDialLeg = "destination"
+)
+
+//LegState is the progress of a click-to-dial leg.
+type LegState string
+
+//Click-to-dial leg states.
+const (
+	LegRinging    LegState = "ringing"
+	LegEarlyMedia LegState = "early_media"
+	LegAnswered   LegState = "answered"
+	LegFailed     LegState = "failed"  //The leg couldn't be originated or hung up before answering.
+	LegHungUp     LegState = "hung_up" //The leg hung up after answering.
+)
+
+//ClickToDialProgress reports a change in a click-to-dial leg's state. Cause
+//is the hangup cause, or originate failure, of a failed or hung up leg.
+type ClickToDialProgress struct {
+	Leg   ClickToDialLeg
+	UUID  string
+	State LegState
+	Cause string
+}
+
+//ClickToDialOutcome is how a click-to-dial call finished.
+type ClickToDialOutcome string
+
+//Click-to-dial outcomes.
+const (
+	ClickToDialConnected         ClickToDialOutcome = "connected"          //The agent was bridged to the destination.
+	ClickToDialAgentFailed       ClickToDialOutcome = "agent_failed"       //The agent didn't answer.
+	ClickToDialDestinationFailed ClickToDialOutcome = "destination_failed" //The destination didn't answer and the agent was hung up.
+	ClickToDialAbandoned         ClickToDialOutcome = "abandoned"          //The agent hung up before the destination answered.
+)
+
+//ClickToDialResult is the result of a click-to-dial call. Cause is the hangup
+//cause, or originate failure, of the leg that failed.
+type ClickToDialResult struct {
+	Outcome         ClickToDialOutcome
+	AgentUUID       string
+	DestinationUUID string
+	Cause           string
+}
+
+//ClickToDialRequest is a click-to-dial call from Agent, e.g. an agent's
+//phone with AutoAnswer set, to Destination. The Extension or App of both is
+//ignored. OnProgress, if set, is called as each leg rings, answers or fails.
+type ClickToDialRequest struct {
+	Agent       OriginateRequest
+	Destination OriginateRequest
+	OnProgress  func(progress ClickToDialProgress)
+}
+
+//ClickToDial is a click-to-dial call in progress, as started from a CRM: the
+//agent is called first and, once they answer, the destination is bridged to
+//them, so they hear it ring. If the destination fails the agent is hung up.
+//
+//Register the call's HandleEvent method with a Dispatcher. The client must be
+//subscribed to CHANNEL_PROGRESS, CHANNEL_PROGRESS_MEDIA, CHANNEL_ANSWER,
+//CHANNEL_BRIDGE, CHANNEL_HANGUP_COMPLETE and BACKGROUND_JOB.
+type ClickToDial struct {
+	AgentUUID       string
+	DestinationUUID string
+
+	client     Commander
+	originate  string
+	dialString string
+	onProgress func(ClickToDialProgress)
+	agentUp    bool
+	destUp     bool
+	finished   bool
+	result     ClickToDialResult
+	done       chan struct{}
+	mu         *sync.Mutex
+}
+
+//NewClickToDial creates a click-to-dial call, checking the request and
+//choosing the legs' UUIDs. Register its HandleEvent method before calling
+//Start, so that no events for the legs are missed.
+func NewClickToDial(client Commander, req ClickToDialRequest) (*ClickToDial, error) {
+	agent := withOriginationUUID(req.Agent)
+	agent.Variables["hangup_after_bridge"] = "true"
+	agent.Extension, agent.Dialplan, agent.Context = "", "", ""
+	agent.App, agent.AppArgs = "park", ""
+	originate, err := agent.Command()
+	if err != nil {
+		return nil, err
+	}
+
+	destination := withOriginationUUID(req.Destination)
+	dialString, err := destination.DialString()
+	if err != nil {
+		return nil, err
+	}
+
+	call := &ClickToDial{
+		AgentUUID:       agent.Variables["origination_uuid"],
+		DestinationUUID: destination.Variables["origination_uuid"],
+		client:          client,
+		originate:       originate,
+		dialString:      dialString,
+		onProgress:      req.OnProgress,
+		done:            make(chan struct{}),
+		mu:              &sync.Mutex{},
+	}
+	return call, nil
+}
+
+//withOriginationUUID returns a copy of req with its own variables, including
+//an origination_uuid if it doesn't have one.
+func withOriginationUUID(req OriginateRequest) OriginateRequest {
+	vars := make(map[string]string, len(req.Variables)+2)
+	for key, value := range req.Variables {
+		vars[key] = value
+	}
+	if vars["origination_uuid"] == "" {
+		vars["origination_uuid"] = NewUUID()
+	}
+	req.Variables = vars
+	return req
+}
+
+//Start originates the agent leg.
+func (call *ClickToDial) Start() error {
+	_, err := call.client.BackgroundAPI(call.originate)
+	return err
+}
+
+//Done returns a channel that is closed when the call has been connected or
+//has failed.
+func (call *ClickToDial) Done() <-chan struct{} {
+	return call.done
+}
+
+//Result returns the call's result, which is only set once Done is closed.
+func (call *ClickToDial) Result() ClickToDialResult {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	return call.result
+}
+
+//HandleEvent follows the progress of the call's legs.
+func (call *ClickToDial) HandleEvent(event Event) {
+	leg, ok := call.leg(event)
+	if !ok {
+		if event.Name() == "BACKGROUND_JOB" {
+			uuid, reply, ok := originateJob(event)
+			if ok && uuid == call.AgentUUID && !reply.OK {
+				call.legFailed(AgentLeg, call.AgentUUID, reply.Text)
+			}
+		}
+		return
+	}
+	uuid := event.UUID()
+
+	switch event.Name() {
+	case "CHANNEL_PROGRESS":
+		call.progress(ClickToDialProgress{Leg: leg, UUID: uuid, State: LegRinging})
+	case "CHANNEL_PROGRESS_MEDIA":
+		call.progress(ClickToDialProgress{Leg: leg, UUID: uuid, State: LegEarlyMedia})
+	case "CHANNEL_ANSWER":
+		call.answered(leg, uuid)
+	case "CHANNEL_BRIDGE":
+		if leg == AgentLeg && bridgedTo(event) == call.DestinationUUID {
+			call.finish(ClickToDialResult{Outcome: ClickToDialConnected})
+		}
+	case "CHANNEL_HANGUP_COMPLETE":
+		call.legFailed(leg, uuid, event["Hangup-Cause"])
+	}
+}
+
+//leg returns which leg an event is for.
+func (call *ClickToDial) leg(event Event) (ClickToDialLeg, bool) {
+	switch event.UUID() {
+	case "":
+		return "", false
+	case call.AgentUUID:
+		return AgentLeg, true
+	case call.DestinationUUID:
+		return DestinationLeg, true
+	}
+	return "", false
+}
+
+//bridgedTo returns the other leg of a CHANNEL_BRIDGE event.
+func bridgedTo(event Event) string {
+	a, b := bridgeLegs(event)
+	if a == event.UUID() {
+		return b
+	}
+	return a
+}
+
+//answered records a leg answering, bridging the destination to the agent
+//when the agent answers.
+func (call *ClickToDial) answered(leg ClickToDialLeg, uuid string) {
+	call.mu.Lock()
+	if leg == AgentLeg {
+		call.agentUp = true
+	} else {
+		call.destUp = true
+	}
+	call.mu.Unlock()
+
+	call.progress(ClickToDialProgress{Leg: leg, UUID: uuid, State: LegAnswered})
+	if leg != AgentLeg {
+		return
+	}
+
+	res, err := call.client.Execute("bridge", call.dialString, call.AgentUUID, false)
+	if err == nil && !ParseReply(res).OK {
+		err = errors.New(res)
+	}
+	if err != nil {
+		call.progress(ClickToDialProgress{Leg: DestinationLeg, UUID: call.DestinationUUID, State: LegFailed, Cause: err.Error()})
+		call.client.API("uuid_kill " + call.AgentUUID)
+		call.finish(ClickToDialResult{Outcome: ClickToDialDestinationFailed, Cause: err.Error()})
+	}
+}
+
+//legFailed handles a leg failing or hanging up.
+func (call *ClickToDial) legFailed(leg ClickToDialLeg, uuid string, cause string) {
+	call.mu.Lock()
+	agentUp, destUp := call.agentUp, call.destUp
+	call.mu.Unlock()
+
+	state := LegFailed
+	if (leg == AgentLeg && agentUp) || (leg == DestinationLeg && destUp) {
+		state = LegHungUp
+	}
+	call.progress(ClickToDialProgress{Leg: leg, UUID: uuid, State: state, Cause: cause})
+
+	switch {
+	case leg == AgentLeg && !agentUp:
+		call.finish(ClickToDialResult{Outcome: ClickToDialAgentFailed, Cause: cause})
+	case leg == AgentLeg:
+		call.finish(ClickToDialResult{Outcome: ClickToDialAbandoned, Cause: cause})
+	case !destUp:
+		//The agent was left parked by the failed bridge.
+		call.client.API("uuid_kill " + call.AgentUUID)
+		call.finish(ClickToDialResult{Outcome: ClickToDialDestinationFailed, Cause: cause})
+	}
+}
+
+//progress reports a leg's progress, unless the call has finished.
+func (call *ClickToDial) progress(progress ClickToDialProgress) {
+	call.mu.Lock()
+	finished := call.finished
+	call.mu.Unlock()
+
+	if !finished && call.onProgress != nil {
+		call.onProgress(progress)
+	}
+}
+
+//finish records the call's result and closes Done, if it hasn't finished
+//already.
+func (call *ClickToDial) finish(result ClickToDialResult) {
+	result.AgentUUID, result.DestinationUUID = call.AgentUUID, call.DestinationUUID
+
+	call.mu.Lock()
+	if call.finished {
+		call.mu.Unlock()
+		return
+	}
+	call.finished = true
+	call.result = result
+	call.mu.Unlock()
+	close(call.done)
+}
@@ -0,0 +1,79 @@
+package fsclient
+
+import (
+	"time"
+)
+
+//Clock is the source of time for the client's timeouts, backoff and
+//scheduling, so they can be driven by a fake clock in tests, such as the one
+//in the fsclienttest package.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, fn func()) Timer
+}
+
+//Timer is a function call scheduled by Clock.AfterFunc.
+type Timer interface {
+	Stop() bool
+}
+
+//SystemClock is the real time Clock used by default.
+var SystemClock Clock = systemClock{}
+
+//systemClock is a Clock using the time package.
+type systemClock struct{}
+
+//Now returns the current time.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+//After returns a channel that receives the time after d has elapsed.
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+//AfterFunc calls fn in its own goroutine after d has elapsed.
+func (systemClock) AfterFunc(d time.Duration, fn func()) Timer {
+	return time.AfterFunc(d, fn)
+}
+
+//SetClock sets the clock used for reconnect delays, retry backoff, rate
+//limits, query caching, event delivery timeouts and audit times, or restores
+//the system clock if clock is nil. Parking lots created with the client use
+//it too.
+func (client *Client) SetClock(clock Clock) {
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	client.optMu.Lock()
+	defer client.optMu.Unlock()
+
+	client.clock = clock
+	for _, bucket := range client.limiters {
+		bucket.SetClock(clock)
+	}
+	if client.queries != nil {
+		client.queries.setClock(clock)
+	}
+}
+
+//clockSource returns the client's clock.
+func (client *Client) clockSource() Clock {
+	client.optMu.RLock()
+	defer client.optMu.RUnlock()
+	return client.clock
+}
+
+//now returns the current time from the client's clock.
+func (client *Client) now() time.Time {
+	return client.clockSource().Now()
+}
+
+//after returns a channel that receives the time after d has elapsed on the
+//client's clock.
+func (client *Client) after(d time.Duration) <-chan time.Time {
+	return client.clockSource().After(d)
+}
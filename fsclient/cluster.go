@@ -0,0 +1,203 @@
+package fsclient
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+//ClusterNodeHeader is the event key added by a Cluster to each event it
+//forwards, containing the address of the node the event was received from.
+const ClusterNodeHeader = "fsclient-node"
+
+//Cluster manages connections to a set of Freeswitch nodes that share the same
+//credentials, filters and subscriptions. Events from all nodes are merged onto
+//a single EventCh. Nodes can be added and removed at runtime, either directly
+//or by watching a Discovery source.
+type Cluster struct {
+	passwords    PasswordProvider
+	filters      []string
+	subs         []string
+	eventBufSize int
+	initFunc     func(*Client)
+	EventCh      chan map[string]string
+	nodes        map[string]*Client
+	nodesMu      *sync.Mutex
+	forwardWg    *sync.WaitGroup
+	closeCh      chan struct{}
+	closed       bool
+	clock        Clock
+}
+
+//NewCluster creates a new cluster client with no nodes. The filters,
+//subscriptions and init function are applied to each node's connection.
+func NewCluster(password string, filters []string, subs []string, eventBufSize int, initFunc func(*Client)) *Cluster {
+	return NewClusterWithPasswordProvider(staticPassword(password), filters, subs, eventBufSize, initFunc)
+}
+
+//NewClusterWithPasswordProvider creates a new cluster client like NewCluster
+//whose nodes get their password from passwords each time they connect.
+func NewClusterWithPasswordProvider(passwords PasswordProvider, filters []string, subs []string, eventBufSize int, initFunc func(*Client)) *Cluster {
+	return &Cluster{
+		passwords:    passwords,
+		filters:      filters,
+		subs:         subs,
+		eventBufSize: eventBufSize,
+		initFunc:     initFunc,
+		EventCh:      make(chan map[string]string, eventBufSize),
+		nodes:        make(map[string]*Client),
+		nodesMu:      &sync.Mutex{},
+		forwardWg:    &sync.WaitGroup{},
+		closeCh:      make(chan struct{}),
+		clock:        SystemClock,
+	}
+}
+
+//SetClock sets the clock used for discovery polling and by each node's
+//client, see Client.SetClock.
+func (cluster *Cluster) SetClock(clock Clock) {
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	cluster.nodesMu.Lock()
+	defer cluster.nodesMu.Unlock()
+
+	cluster.clock = clock
+	for _, client := range cluster.nodes {
+		client.SetClock(clock)
+	}
+}
+
+//AddNode starts a client connection to the node at addr. It does nothing if
+//the node is already part of the cluster.
+func (cluster *Cluster) AddNode(addr string) {
+	cluster.nodesMu.Lock()
+	defer cluster.nodesMu.Unlock()
+
+	if cluster.closed {
+		return
+	}
+
+	if _, ok := cluster.nodes[addr]; ok {
+		return
+	}
+
+	log.Print(logPrefix, "Adding cluster node ", addr)
+	client := NewClientWithPasswordProvider(addr, cluster.passwords, cluster.filters, cluster.subs, cluster.eventBufSize, cluster.initFunc)
+	client.SetClock(cluster.clock)
+	cluster.nodes[addr] = client
+	cluster.forwardWg.Add(1)
+	go cluster.forwardEvents(addr, client)
+}
+
+//RemoveNode closes the client connection to the node at addr and removes it
+//from the cluster.
+func (cluster *Cluster) RemoveNode(addr string) {
+	cluster.nodesMu.Lock()
+	client, ok := cluster.nodes[addr]
+	delete(cluster.nodes, addr)
+	cluster.nodesMu.Unlock()
+
+	if ok {
+		log.Print(logPrefix, "Removing cluster node ", addr)
+		client.Close()
+	}
+}
+
+//SetNodes reconciles the cluster with the supplied list of node addresses,
+//adding any new nodes and removing any nodes not in the list.
+func (cluster *Cluster) SetNodes(addrs []string) {
+	wanted := make(map[string]bool)
+	for _, addr := range addrs {
+		wanted[addr] = true
+		cluster.AddNode(addr)
+	}
+
+	for _, addr := range cluster.Nodes() {
+		if !wanted[addr] {
+			cluster.RemoveNode(addr)
+		}
+	}
+}
+
+//Nodes returns the sorted addresses of the nodes currently in the cluster.
+func (cluster *Cluster) Nodes() []string {
+	cluster.nodesMu.Lock()
+	defer cluster.nodesMu.Unlock()
+
+	addrs := make([]string, 0, len(cluster.nodes))
+	for addr := range cluster.nodes {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+//Node returns the client for the node at addr, or nil if it is not part of
+//the cluster.
+func (cluster *Cluster) Node(addr string) *Client {
+	cluster.nodesMu.Lock()
+	defer cluster.nodesMu.Unlock()
+	return cluster.nodes[addr]
+}
+
+//Watch polls the discovery source every interval and reconciles the cluster
+//nodes with the result, until stop is closed. If discovery fails the current
+//set of nodes is kept.
+func (cluster *Cluster) Watch(discovery Discovery, interval time.Duration, stop <-chan struct{}) {
+	for {
+		addrs, err := discovery.Nodes()
+		if err != nil {
+			log.Print(logPrefix, "Node discovery failed: ", err)
+		} else {
+			cluster.SetNodes(addrs)
+		}
+
+		cluster.nodesMu.Lock()
+		clock := cluster.clock
+		cluster.nodesMu.Unlock()
+
+		select {
+		case <-clock.After(interval):
+		case <-stop:
+			return
+		}
+	}
+}
+
+//Close disconnects from all nodes and closes EventCh once all node connections
+//have stopped. Events not yet read from EventCh are discarded.
+func (cluster *Cluster) Close() {
+	cluster.nodesMu.Lock()
+	if cluster.closed {
+		cluster.nodesMu.Unlock()
+		return
+	}
+	cluster.closed = true
+	close(cluster.closeCh)
+	nodes := cluster.nodes
+	cluster.nodes = make(map[string]*Client)
+	cluster.nodesMu.Unlock()
+
+	for _, client := range nodes {
+		client.Close()
+	}
+
+	cluster.forwardWg.Wait()
+	close(cluster.EventCh)
+}
+
+//forwardEvents copies events from a node's EventCh to the cluster EventCh,
+//tagging each with the node address. It returns when the node is closed.
+func (cluster *Cluster) forwardEvents(addr string, client *Client) {
+	defer cluster.forwardWg.Done()
+	for event := range client.EventCh {
+		event[ClusterNodeHeader] = addr
+		select {
+		case cluster.EventCh <- event:
+		case <-cluster.closeCh:
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package fsclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//codec converts between events and the content of the event frames
+//Freeswitch sends in one format. A frame is a block of "Key: value" headers
+//with the Content-Type of its format, followed by Content-Length bytes of
+//content. New formats are supported by adding a codec to eventCodecs.
+type codec interface {
+	//ParseFrame decodes the content of a frame into an event.
+	ParseFrame(content []byte) (map[string]string, error)
+
+	//WriteFrame writes an event as a whole frame, headers and content.
+	WriteFrame(w io.Writer, event map[string]string) error
+}
+
+//eventCodecs are the codecs for each event frame Content-Type.
+var eventCodecs = map[string]codec{
+	"text/event-plain": plainCodec{},
+	"text/event-json":  jsonCodec{},
+	"text/event-xml":   xmlCodec{},
+}
+
+//writeFrame writes a frame with content of the given type.
+func writeFrame(w io.Writer, contentType string, content []byte) error {
+	_, err := fmt.Fprintf(w, "Content-Length: %d\nContent-Type: %s\n\n%s", len(content), contentType, content)
+	return err
+}
+
+//sortedKeys returns the keys of an event in order, so frames are written the
+//same way each time.
+func sortedKeys(event map[string]string) []string {
+	keys := make([]string, 0, len(event))
+	for key := range event {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//plainCodec is the plain event format, url encoded "Key: value" headers
+//followed by a blank line and a body if there is a Content-Length header.
+type plainCodec struct{}
+
+//ParseFrame decodes a plain event.
+func (plainCodec) ParseFrame(content []byte) (map[string]string, error) {
+	event := make(map[string]string)
+	head, body, _ := strings.Cut(string(content), "\n\n")
+
+	bodyLength := 0
+	for _, line := range strings.Split(head, "\n") {
+		if line == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ": ") //Split "Key: value"
+		if !ok {
+			return nil, errors.New("Invalid event header: " + line)
+		}
+		value, err := url.QueryUnescape(value)
+		if err != nil {
+			return nil, err
+		}
+
+		//If the header key indicates there is additional content at the end
+		//of this message, then convert it to an integer for reading later.
+		if key == "Content-Length" || key == "content-length" {
+			bodyLength, _ = strconv.Atoi(value)
+		}
+		event[key] = value
+	}
+
+	//If the bodyLength has been set greater than zero, then read the body of
+	//the event into a special key called "body-string".
+	if bodyLength > 0 {
+		if bodyLength > len(body) {
+			return nil, errors.New("Event body shorter than Content-Length")
+		}
+		event["body-string"] = body[:bodyLength]
+	}
+	return event, nil
+}
+
+//WriteFrame writes a plain event.
+func (plainCodec) WriteFrame(w io.Writer, event map[string]string) error {
+	var content strings.Builder
+	body, hasBody := event["body-string"]
+	for _, key := range sortedKeys(event) {
+		switch key {
+		case "body-string", "Content-Length", "content-length":
+			continue
+		}
+		content.WriteString(key + ": " + url.QueryEscape(event[key]) + "\n")
+	}
+	if hasBody && body != "" {
+		content.WriteString("Content-Length: " + strconv.Itoa(len(body)) + "\n\n" + body)
+	} else {
+		content.WriteString("\n")
+	}
+	return writeFrame(w, "text/event-plain", []byte(content.String()))
+}
+
+//jsonCodec is the json event format, an object of headers with the body under
+//"_body".
+type jsonCodec struct{}
+
+//ParseFrame decodes a json event. Array headers are converted to the
+//"ARRAY::a|:b" form used by plain events.
+func (jsonCodec) ParseFrame(content []byte) (map[string]string, error) {
+	var headers map[string]interface{}
+	if err := json.Unmarshal(content, &headers); err != nil {
+		return nil, err
+	}
+
+	event := make(map[string]string, len(headers))
+	for key, value := range headers {
+		if key == "_body" {
+			key = "body-string"
+		}
+
+		switch value := value.(type) {
+		case string:
+			event[key] = value
+		case []interface{}:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				items = append(items, fmt.Sprint(item))
+			}
+			event[key] = EncodeArray(items)
+		case nil:
+			event[key] = ""
+		default:
+			event[key] = fmt.Sprint(value)
+		}
+	}
+	return event, nil
+}
+
+//WriteFrame writes a json event.
+func (jsonCodec) WriteFrame(w io.Writer, event map[string]string) error {
+	headers := make(map[string]string, len(event))
+	for key, value := range event {
+		if key == "body-string" {
+			key = "_body"
+		}
+		headers[key] = value
+	}
+
+	content, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+	return writeFrame(w, "text/event-json", content)
+}
+
+//xmlCodec is the xml event format, which has the form
+//<event><headers><Name>value</Name>...</headers><body>...</body></event>.
+type xmlCodec struct{}
+
+//ParseFrame decodes an xml event.
+func (xmlCodec) ParseFrame(content []byte) (map[string]string, error) {
+	event := make(map[string]string)
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+
+	var path []string
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			path = append(path, token.Name.Local)
+			text.Reset()
+		case xml.CharData:
+			text.Write(token)
+		case xml.EndElement:
+			switch {
+			case len(path) == 3 && path[1] == "headers":
+				event[token.Name.Local] = text.String()
+			case len(path) == 2 && path[1] == "body":
+				event["body-string"] = text.String()
+			}
+			path = path[:len(path)-1]
+			text.Reset()
+		}
+	}
+
+	if len(event) == 0 {
+		return nil, errors.New("Empty xml event")
+	}
+	return event, nil
+}
+
+//WriteFrame writes an xml event.
+func (xmlCodec) WriteFrame(w io.Writer, event map[string]string) error {
+	var content bytes.Buffer
+	content.WriteString("<event>\n  <headers>\n")
+	for _, key := range sortedKeys(event) {
+		if key == "body-string" {
+			continue
+		}
+		content.WriteString("    <" + key + ">")
+		xml.EscapeText(&content, []byte(event[key]))
+		content.WriteString("</" + key + ">\n")
+	}
+	content.WriteString("  </headers>\n")
+	if body := event["body-string"]; body != "" {
+		content.WriteString("  <body>")
+		xml.EscapeText(&content, []byte(body))
+		content.WriteString("</body>\n")
+	}
+	content.WriteString("</event>")
+	return writeFrame(w, "text/event-xml", content.Bytes())
+}
@@ -0,0 +1,111 @@
+package fsclient
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ConsentVar is the channel variable RecordWithConsent sets to the
+//ConsentResult, so CDRs show whether and how the caller agreed to be
+//recorded.
+const ConsentVar = "fsclient_recording_consent"
+
+//ConsentResult is the outcome of asking a caller for recording consent.
+type ConsentResult string
+
+//Consent results.
+const (
+	ConsentGranted  ConsentResult = "granted"  //The caller pressed an accept digit.
+	ConsentDeclined ConsentResult = "declined" //The caller pressed a decline digit.
+	ConsentTimeout  ConsentResult = "timeout"  //The caller didn't respond.
+	ConsentImplied  ConsentResult = "implied"  //The caller heard the announcement and stayed on.
+)
+
+//ConsentOptions configures RecordWithConsent. Announcement is the file played
+//to the caller and must not contain spaces. If AcceptDigits is empty the
+//announcement is only played and consent is implied, otherwise the caller
+//has Timeout to press one of AcceptDigits or DeclineDigits. Pressing nothing
+//is treated as consent if ImpliedOnTimeout is true.
+//
+//The call is recorded to RecordFile with uuid_record, for up to RecordLimit
+//if it is set.
+type ConsentOptions struct {
+	Announcement     string
+	AcceptDigits     string
+	DeclineDigits    string
+	Timeout          time.Duration
+	ImpliedOnTimeout bool
+	RecordFile       string
+	RecordLimit      time.Duration
+}
+
+//RecordWithConsent plays a recording consent announcement, waits for the
+//caller to accept or decline if digits are configured, tags the channel with
+//the result in ConsentVar and starts recording if the caller consented. The
+//result is returned even if recording fails to start.
+func (session *Session) RecordWithConsent(opts ConsentOptions) (ConsentResult, error) {
+	if opts.Announcement == "" || strings.Contains(opts.Announcement, " ") {
+		return "", errors.New("Invalid consent announcement: " + opts.Announcement)
+	}
+	if opts.RecordFile == "" {
+		return "", errors.New("No recording file for consent")
+	}
+	digits := opts.AcceptDigits + opts.DeclineDigits
+	if strings.Trim(digits, "0123456789*#") != "" || strings.ContainsAny(opts.AcceptDigits, opts.DeclineDigits) {
+		return "", errors.New("Invalid consent digits: " + digits)
+	}
+
+	result, err := session.askConsent(opts)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := session.Execute("set", ConsentVar+"="+string(result)); err != nil {
+		return result, err
+	}
+	if result == ConsentDeclined || (result == ConsentTimeout && !opts.ImpliedOnTimeout) {
+		return result, nil
+	}
+
+	cmd := "uuid_record " + session.UUID + " start " + opts.RecordFile
+	if opts.RecordLimit > 0 {
+		cmd += " " + strconv.Itoa(int((opts.RecordLimit+time.Second-1)/time.Second))
+	}
+	res, err := session.client.API(cmd)
+	if err != nil {
+		return result, err
+	}
+	if !ParseReply(res).OK {
+		return result, errors.New(strings.TrimSpace(res))
+	}
+	return result, nil
+}
+
+//askConsent plays the announcement and collects the caller's answer.
+func (session *Session) askConsent(opts ConsentOptions) (ConsentResult, error) {
+	if opts.AcceptDigits == "" {
+		if err := session.Playback(opts.Announcement); err != nil {
+			return "", err
+		}
+		return ConsentImplied, nil
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	digits := opts.AcceptDigits + opts.DeclineDigits
+	digit, err := session.PlayAndGetDigits(opts.Announcement, "", 1, 1, 1, timeout, timeout, "", "["+digits+"]")
+	switch {
+	case err != nil:
+		return "", err
+	case digit == "":
+		return ConsentTimeout, nil
+	case strings.Contains(opts.DeclineDigits, digit):
+		return ConsentDeclined, nil
+	}
+	return ConsentGranted, nil
+}
@@ -0,0 +1,88 @@
+package fsclient
+
+import "strings"
+
+//Channel variables used to carry correlation IDs through Freeswitch.
+const (
+	TraceIDVar   = "fsclient_trace_id"
+	TenantIDVar  = "fsclient_tenant_id"
+	RequestIDVar = "fsclient_request_id"
+)
+
+//Correlation is a set of IDs that tie a call to the requests and services
+//that handle it. They are stored in channel variables so every service that
+//sees the call's events reads the same IDs.
+type Correlation struct {
+	TraceID   string
+	TenantID  string
+	RequestID string
+}
+
+//Vars returns the correlation as channel variables, omitting empty IDs. The
+//variables are also listed in export_vars so they are copied to legs the
+//channel is bridged to.
+func (correlation Correlation) Vars() map[string]string {
+	vars := make(map[string]string)
+	var names []string
+	for _, pair := range [][2]string{
+		{TraceIDVar, correlation.TraceID},
+		{TenantIDVar, correlation.TenantID},
+		{RequestIDVar, correlation.RequestID},
+	} {
+		if pair[1] != "" {
+			vars[pair[0]] = pair[1]
+			names = append(names, pair[0])
+		}
+	}
+
+	if len(names) > 0 {
+		vars["export_vars"] = strings.Join(names, ",")
+	}
+	return vars
+}
+
+//Merge returns a copy of vars with the correlation variables added, for use
+//with OriginateCommand. Any existing export_vars are kept.
+func (correlation Correlation) Merge(vars map[string]string) map[string]string {
+	merged := make(map[string]string, len(vars)+4)
+	for key, value := range vars {
+		merged[key] = value
+	}
+
+	for key, value := range correlation.Vars() {
+		if key == "export_vars" && merged[key] != "" {
+			value = merged[key] + "," + value
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+//CorrelationFromEvent reads the correlation IDs from an event's channel
+//variables.
+func CorrelationFromEvent(event Event) Correlation {
+	return Correlation{
+		TraceID:   event["variable_"+TraceIDVar],
+		TenantID:  event["variable_"+TenantIDVar],
+		RequestID: event["variable_"+RequestIDVar],
+	}
+}
+
+//Correlation returns the correlation IDs from the call's most recent event.
+func (call *Call) Correlation() Correlation {
+	call.mu.RLock()
+	defer call.mu.RUnlock()
+	return CorrelationFromEvent(call.event)
+}
+
+//SetCorrelation sets the correlation variables on an existing channel, for
+//calls that weren't originated with them. Empty IDs are left unchanged.
+func (client *Client) SetCorrelation(uuid string, correlation Correlation) error {
+	vars := correlation.Vars()
+	delete(vars, "export_vars")
+	if len(vars) == 0 {
+		return nil
+	}
+
+	return client.SetVariables(uuid, vars)
+}
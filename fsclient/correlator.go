@@ -0,0 +1,159 @@
+package fsclient
+
+import (
+	"context"
+	"sync"
+)
+
+//Correlator matches events to the requests waiting for them by the value of
+//a header, e.g. Job-UUID for bgapi results, Application-UUID for application
+//completions, Event-UUID, or a channel variable such as
+//"variable_"+RequestIDVar. Register HandleEvent with a Dispatcher.
+//
+//Call Expect before sending the command the event answers, so an event that
+//arrives before Wait is called isn't missed.
+type Correlator struct {
+	header  string
+	waiters map[string][]*Expectation
+	mu      *sync.Mutex
+}
+
+//Expectation is a wait for an event with a particular header value,
+//registered with Correlator.Expect.
+type Expectation struct {
+	key        string
+	eventNames []string
+	ch         chan Event
+	correlator *Correlator
+}
+
+//NewCorrelator creates a Correlator for events keyed by header.
+func NewCorrelator(header string) *Correlator {
+	return &Correlator{
+		header:  header,
+		waiters: make(map[string][]*Expectation),
+		mu:      &sync.Mutex{},
+	}
+}
+
+//Expect registers a wait for the next event whose header is key and, if any
+//eventNames are given, whose name is one of them.
+func (correlator *Correlator) Expect(key string, eventNames ...string) *Expectation {
+	expectation := &Expectation{
+		key:        key,
+		eventNames: eventNames,
+		ch:         make(chan Event, 1),
+		correlator: correlator,
+	}
+
+	correlator.mu.Lock()
+	defer correlator.mu.Unlock()
+	correlator.waiters[key] = append(correlator.waiters[key], expectation)
+	return expectation
+}
+
+//Await waits for an event like Expect followed by Wait, for events that are
+//known not to have arrived yet.
+func (correlator *Correlator) Await(ctx context.Context, key string, eventNames ...string) (Event, error) {
+	return correlator.Expect(key, eventNames...).Wait(ctx)
+}
+
+//Pending returns the number of expectations waiting for an event.
+func (correlator *Correlator) Pending() int {
+	correlator.mu.Lock()
+	defer correlator.mu.Unlock()
+
+	n := 0
+	for _, waiters := range correlator.waiters {
+		n += len(waiters)
+	}
+	return n
+}
+
+//HandleEvent delivers an event to the expectations waiting for its header
+//value.
+func (correlator *Correlator) HandleEvent(event Event) {
+	key, ok := event[correlator.header]
+	if !ok {
+		return
+	}
+
+	correlator.mu.Lock()
+	defer correlator.mu.Unlock()
+
+	waiters := correlator.waiters[key]
+	remaining := waiters[:0]
+	for _, expectation := range waiters {
+		if expectation.matches(event) {
+			expectation.ch <- event
+		} else {
+			remaining = append(remaining, expectation)
+		}
+	}
+
+	if len(remaining) == 0 {
+		delete(correlator.waiters, key)
+	} else {
+		correlator.waiters[key] = remaining
+	}
+}
+
+//matches returns true if the event is one the expectation is waiting for.
+func (expectation *Expectation) matches(event Event) bool {
+	if len(expectation.eventNames) == 0 {
+		return true
+	}
+	for _, name := range expectation.eventNames {
+		if event.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+//Event returns a channel the expected event is delivered on, for waiting
+//on it together with other channels.
+func (expectation *Expectation) Event() <-chan Event {
+	return expectation.ch
+}
+
+//Wait blocks until the expected event arrives or ctx is done, e.g. because
+//its deadline passed. The expectation is cancelled if ctx is done first.
+func (expectation *Expectation) Wait(ctx context.Context) (Event, error) {
+	select {
+	case event := <-expectation.ch:
+		return event, nil
+	case <-ctx.Done():
+		expectation.Cancel()
+
+		//The event may have arrived while cancelling.
+		select {
+		case event := <-expectation.ch:
+			return event, nil
+		default:
+			return nil, ctx.Err()
+		}
+	}
+}
+
+//Cancel stops waiting for the event. It is safe to call after the event has
+//arrived.
+func (expectation *Expectation) Cancel() {
+	correlator := expectation.correlator
+	correlator.mu.Lock()
+	defer correlator.mu.Unlock()
+
+	waiters := correlator.waiters[expectation.key]
+	for i, existing := range waiters {
+		if existing == expectation {
+			waiters = append(waiters[:i:i], waiters[i+1:]...)
+			break
+		}
+	}
+
+	if len(waiters) == 0 {
+		delete(correlator.waiters, expectation.key)
+	} else {
+		correlator.waiters[expectation.key] = waiters
+	}
+}
@@ -0,0 +1,421 @@
+package fsclient
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+//errDialerStopped is the error reported for targets that were not dialled
+//because the Dialer was stopped.
+var errDialerStopped = errors.New("Dialer stopped")
+
+//DialTarget is a single destination for a Dialer campaign.
+type DialTarget struct {
+	ID       string
+	Endpoint string
+	Vars     map[string]string
+}
+
+//DialResult is the outcome of one attempt to dial a target. Cause is the
+//hangup cause, or the originate failure cause if no channel was created.
+//AMD is the answering machine detection result, if detection was enabled.
+//Retrying is true if the target will be dialled again.
+type DialResult struct {
+	Target   DialTarget
+	Attempt  int
+	UUID     string
+	Answered bool
+	AMD      AMDResult
+	Cause    string
+	Err      error
+	Retrying bool
+}
+
+//dialAttempt is an attempt in progress.
+type dialAttempt struct {
+	target   DialTarget
+	attempt  int
+	uuid     string
+	jobUUID  string
+	answered bool
+	amd      AMDResult
+	done     bool
+}
+
+//Dialer runs an outbound campaign: it originates a call to each target using
+//bgapi, keeping at most a fixed number of calls up at once and pacing new
+//calls, retries unanswered calls and hands answered calls to OnAnswer, for
+//example to bridge them to an agent or run an IVR.
+//
+//Register the Dialer's HandleEvent method with the same Dispatcher as the
+//CallManager, after the CallManager's. The client must be subscribed to
+//BACKGROUND_JOB, CHANNEL_CREATE, CHANNEL_ANSWER and CHANNEL_HANGUP_COMPLETE.
+//The exported fields must be set before Run is called.
+type Dialer struct {
+	//Destination is where answered calls are connected to. It defaults to
+	//"&park()" so OnAnswer can control the call.
+	Destination string
+
+	//MaxAttempts is the number of times a target is dialled before giving up.
+	MaxAttempts int
+
+	//RetryDelay is how long to wait before dialling a target again.
+	RetryDelay time.Duration
+
+	//RetryCauses are the hangup causes of unanswered calls that are retried.
+	//Failures to send the originate are always retried.
+	RetryCauses []string
+
+	//OnAnswer is called in its own goroutine when a call is answered.
+	OnAnswer func(call *Call)
+
+	//AMD, if set, is the answering machine detection application and
+	//arguments run on answered calls, e.g. "amd" or "avmd start". OnAnswer is
+	//then called once the result is known, unless a machine answered, in
+	//which case OnMachine is called instead. The client must also be
+	//subscribed to the application's result events, see OnAMDResult.
+	AMD string
+
+	//OnMachine is called in its own goroutine when AMD detects a machine.
+	OnMachine func(call *Call)
+
+	//OnResult is called when each attempt finishes.
+	OnResult func(result DialResult)
+
+	client      Commander
+	manager     *CallManager
+	pacer       *TokenBucket
+	clock       Clock
+	slots       chan struct{}
+	queue       chan *dialAttempt
+	calls       map[string]*dialAttempt
+	jobs        map[string]*dialAttempt
+	unmatched   map[string]string
+	sending     int
+	outstanding *sync.WaitGroup
+	stopCh      chan struct{}
+	stopOnce    *sync.Once
+	mu          *sync.Mutex
+}
+
+//NewDialer creates a Dialer that keeps at most concurrency calls up at once
+//and starts at most rate calls per second (zero for no pacing). Answered
+//calls are looked up in manager.
+func NewDialer(client Commander, manager *CallManager, concurrency int, rate float64) *Dialer {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	dialer := &Dialer{
+		Destination: "&park()",
+		MaxAttempts: 1,
+		RetryDelay:  30 * time.Second,
+		RetryCauses: []string{"NO_ANSWER", "USER_BUSY", "NO_USER_RESPONSE", "NORMAL_TEMPORARY_FAILURE", "RECOVERY_ON_TIMER_EXPIRE"},
+		client:      client,
+		manager:     manager,
+		clock:       SystemClock,
+		slots:       make(chan struct{}, concurrency),
+		calls:       make(map[string]*dialAttempt),
+		jobs:        make(map[string]*dialAttempt),
+		unmatched:   make(map[string]string),
+		outstanding: &sync.WaitGroup{},
+		stopCh:      make(chan struct{}),
+		stopOnce:    &sync.Once{},
+		mu:          &sync.Mutex{},
+	}
+
+	if rate > 0 {
+		dialer.pacer = NewTokenBucket(rate, 1)
+	}
+	return dialer
+}
+
+//SetClock sets the clock used for pacing and retry delays. Like the dialer's
+//fields it must be set before Run.
+func (dialer *Dialer) SetClock(clock Clock) {
+	dialer.clock = clock
+	if dialer.pacer != nil {
+		dialer.pacer.SetClock(clock)
+	}
+}
+
+//Run dials the targets, blocking until every target has been answered, has
+//used up its attempts or has been abandoned by Stop, and every call has hung
+//up. It must be called only once.
+func (dialer *Dialer) Run(targets []DialTarget) {
+	//Each target is queued at most once at a time, so the queue never blocks.
+	dialer.queue = make(chan *dialAttempt, len(targets))
+	dialer.outstanding.Add(len(targets))
+	for _, target := range targets {
+		dialer.queue <- &dialAttempt{target: target, attempt: 1}
+	}
+
+	doneCh := make(chan struct{})
+	go func() {
+		dialer.outstanding.Wait()
+		close(doneCh)
+	}()
+
+	for {
+		select {
+		case attempt := <-dialer.queue:
+			if !dialer.acquire() {
+				dialer.abandon(attempt)
+				continue
+			}
+			dialer.dial(attempt)
+		case <-doneCh:
+			return
+		}
+	}
+}
+
+//Stop stops dialling new calls. Calls already up are left to finish and any
+//remaining targets are reported with an error.
+func (dialer *Dialer) Stop() {
+	dialer.stopOnce.Do(func() { close(dialer.stopCh) })
+}
+
+//stopped returns true once Stop has been called.
+func (dialer *Dialer) stopped() bool {
+	select {
+	case <-dialer.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+//acquire waits for a free call slot and for the pacer, returning false if the
+//dialer is stopped first.
+func (dialer *Dialer) acquire() bool {
+	select {
+	case dialer.slots <- struct{}{}:
+	case <-dialer.stopCh:
+		return false
+	}
+
+	if dialer.pacer != nil {
+		dialer.pacer.Wait()
+	}
+
+	if dialer.stopped() {
+		<-dialer.slots
+		return false
+	}
+	return true
+}
+
+//dial originates a call for an attempt. The channel UUID is chosen up front
+//with origination_uuid so channel events can be matched to the attempt.
+func (dialer *Dialer) dial(attempt *dialAttempt) {
+	attempt.uuid = NewUUID()
+
+	vars := map[string]string{"origination_uuid": attempt.uuid}
+	for key, value := range attempt.target.Vars {
+		vars[key] = value
+	}
+
+	dialer.mu.Lock()
+	dialer.calls[attempt.uuid] = attempt
+	dialer.sending++
+	dialer.mu.Unlock()
+
+	jobUUID, err := dialer.client.BackgroundAPI(OriginateCommand(attempt.target.Endpoint, dialer.Destination, vars))
+
+	//The job result can arrive before BackgroundAPI returns, in which case it
+	//is waiting in unmatched. Unmatched results are only kept while an
+	//originate is being sent so results of unrelated jobs don't build up.
+	dialer.mu.Lock()
+	body, ok := dialer.unmatched[jobUUID]
+	if err == nil && !ok {
+		attempt.jobUUID = jobUUID
+		dialer.jobs[jobUUID] = attempt
+	}
+	dialer.sending--
+	if dialer.sending == 0 {
+		dialer.unmatched = make(map[string]string)
+	}
+	dialer.mu.Unlock()
+
+	if err != nil {
+		dialer.finish(attempt, "", err)
+		return
+	}
+	if ok {
+		dialer.jobResult(attempt, body)
+	}
+}
+
+//HandleEvent matches job results and channel events to the calls the dialer
+//has originated.
+func (dialer *Dialer) HandleEvent(event Event) {
+	switch event.Name() {
+	case "BACKGROUND_JOB":
+		jobUUID := event["Job-UUID"]
+
+		dialer.mu.Lock()
+		attempt, ok := dialer.jobs[jobUUID]
+		if !ok && dialer.sending > 0 {
+			dialer.unmatched[jobUUID] = event.Body()
+		}
+		dialer.mu.Unlock()
+
+		if ok {
+			dialer.jobResult(attempt, event.Body())
+		}
+
+	case "CHANNEL_ANSWER":
+		dialer.mu.Lock()
+		attempt, ok := dialer.calls[event.UUID()]
+		if ok {
+			attempt.answered = true
+		}
+		dialer.mu.Unlock()
+
+		if !ok {
+			return
+		}
+		if dialer.AMD != "" {
+			go dialer.startAMD(event.UUID())
+			return
+		}
+		dialer.answered(event.UUID(), AMDPending)
+
+	case "CUSTOM", "CHANNEL_EXECUTE_COMPLETE":
+		if dialer.AMD == "" {
+			return
+		}
+		result, ok := decodeAMDEvent(event)
+		if !ok {
+			return
+		}
+		dialer.amdResult(event.UUID(), result)
+
+	case "CHANNEL_HANGUP_COMPLETE":
+		dialer.mu.Lock()
+		attempt, ok := dialer.calls[event.UUID()]
+		dialer.mu.Unlock()
+
+		if ok {
+			dialer.finish(attempt, event["Hangup-Cause"], nil)
+		}
+	}
+}
+
+//startAMD runs answering machine detection on an answered call. If it can't
+//be started the call is handed to OnAnswer with an unknown result.
+func (dialer *Dialer) startAMD(uuid string) {
+	app, arg, _ := strings.Cut(dialer.AMD, " ")
+	if err := startAMD(dialer.client, uuid, app, arg); err != nil {
+		log.Print(logPrefix, "Failed to start AMD on ", uuid, ": ", err)
+		dialer.amdResult(uuid, AMDUnknown)
+	}
+}
+
+//amdResult records the first detection result for a call and hands the call
+//on.
+func (dialer *Dialer) amdResult(uuid string, result AMDResult) {
+	dialer.mu.Lock()
+	attempt, ok := dialer.calls[uuid]
+	first := ok && attempt.amd == AMDPending
+	if first {
+		attempt.amd = result
+	}
+	dialer.mu.Unlock()
+
+	if first {
+		dialer.answered(uuid, result)
+	}
+}
+
+//answered hands an answered call to OnAnswer, or to OnMachine if a machine
+//was detected.
+func (dialer *Dialer) answered(uuid string, result AMDResult) {
+	fn := dialer.OnAnswer
+	if result == AMDMachine {
+		fn = dialer.OnMachine
+	}
+	if fn == nil {
+		return
+	}
+
+	if call := dialer.manager.Call(uuid); call != nil {
+		go fn(call)
+	}
+}
+
+//jobResult handles the result of an originate job. A successful originate is
+//finished by its channel hanging up, a failed one may not have created a
+//channel at all so it is finished here.
+func (dialer *Dialer) jobResult(attempt *dialAttempt, body string) {
+	reply := ParseReply(body)
+	if reply.OK {
+		return
+	}
+	dialer.finish(attempt, reply.Text, nil)
+}
+
+//finish completes an attempt, releasing its call slot and scheduling a retry
+//if required. Only the first call for an attempt has any effect.
+func (dialer *Dialer) finish(attempt *dialAttempt, cause string, err error) {
+	dialer.mu.Lock()
+	if attempt.done {
+		dialer.mu.Unlock()
+		return
+	}
+	attempt.done = true
+	delete(dialer.calls, attempt.uuid)
+	delete(dialer.jobs, attempt.jobUUID)
+	answered := attempt.answered
+	amd := attempt.amd
+	dialer.mu.Unlock()
+
+	<-dialer.slots
+
+	result := DialResult{
+		Target:   attempt.target,
+		Attempt:  attempt.attempt,
+		UUID:     attempt.uuid,
+		Answered: answered,
+		AMD:      amd,
+		Cause:    cause,
+		Err:      err,
+	}
+	result.Retrying = !answered && attempt.attempt < dialer.MaxAttempts && !dialer.stopped() && dialer.retryable(cause, err)
+
+	if dialer.OnResult != nil {
+		dialer.OnResult(result)
+	}
+
+	if result.Retrying {
+		next := &dialAttempt{target: attempt.target, attempt: attempt.attempt + 1}
+		dialer.clock.AfterFunc(dialer.RetryDelay, func() { dialer.queue <- next })
+		return
+	}
+	dialer.outstanding.Done()
+}
+
+//abandon reports a target that won't be dialled because the dialer stopped.
+func (dialer *Dialer) abandon(attempt *dialAttempt) {
+	if dialer.OnResult != nil {
+		dialer.OnResult(DialResult{Target: attempt.target, Attempt: attempt.attempt, Err: errDialerStopped})
+	}
+	dialer.outstanding.Done()
+}
+
+//retryable returns true if an unanswered attempt should be retried.
+func (dialer *Dialer) retryable(cause string, err error) bool {
+	if err != nil {
+		return true
+	}
+	for _, retryCause := range dialer.RetryCauses {
+		if cause == retryCause {
+			return true
+		}
+	}
+	return false
+}
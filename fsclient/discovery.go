@@ -0,0 +1,92 @@
+package fsclient
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//Discovery is a source of Freeswitch node addresses (host:port) for a Cluster.
+type Discovery interface {
+	Nodes() ([]string, error)
+}
+
+//SRVDiscovery discovers nodes from DNS SRV records, for example with
+//Service "esl", Proto "tcp" and Name "example.com" the records for
+//_esl._tcp.example.com are looked up.
+type SRVDiscovery struct {
+	Service string
+	Proto   string
+	Name    string
+}
+
+//Nodes looks up the SRV records and returns the target addresses.
+func (discovery *SRVDiscovery) Nodes() ([]string, error) {
+	_, records, err := net.LookupSRV(discovery.Service, discovery.Proto, discovery.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, 0, len(records))
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+	}
+	return addrs, nil
+}
+
+//StaticDiscovery is a fixed list of node addresses that can be replaced at
+//runtime using Set, for example after reloading application config.
+type StaticDiscovery struct {
+	addrs []string
+	mu    sync.Mutex
+}
+
+//NewStaticDiscovery creates a static discovery source with initial addresses.
+func NewStaticDiscovery(addrs ...string) *StaticDiscovery {
+	return &StaticDiscovery{addrs: addrs}
+}
+
+//Set replaces the list of node addresses.
+func (discovery *StaticDiscovery) Set(addrs ...string) {
+	discovery.mu.Lock()
+	defer discovery.mu.Unlock()
+	discovery.addrs = addrs
+}
+
+//Nodes returns a copy of the current list of node addresses.
+func (discovery *StaticDiscovery) Nodes() ([]string, error) {
+	discovery.mu.Lock()
+	defer discovery.mu.Unlock()
+	return append([]string(nil), discovery.addrs...), nil
+}
+
+//FileDiscovery reads node addresses from a file containing one host:port per
+//line. Blank lines and lines starting with "#" are ignored. The file is read
+//on every call so edits are picked up without restarting.
+type FileDiscovery struct {
+	Path string
+}
+
+//Nodes reads the node addresses from the file.
+func (discovery *FileDiscovery) Nodes() ([]string, error) {
+	file, err := os.Open(discovery.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var addrs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addrs = append(addrs, line)
+	}
+	return addrs, scanner.Err()
+}
@@ -0,0 +1,380 @@
+package fsclient
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+//errQueueFull is returned when pushing to a DiskQueue that has reached its
+//size limit.
+var errQueueFull = errors.New("Queue full")
+
+//queueHeaderSize is the size of the DiskQueue file header, which holds the
+//offset of the next record to read.
+const queueHeaderSize = 8
+
+//queueCompactSize is the amount of delivered data after which a DiskQueue
+//that doesn't empty, such as during sustained overflow, is compacted, as long
+//as it is more than the data still queued.
+const queueCompactSize = 4 << 20
+
+//DiskQueue is a bounded FIFO queue of events stored in a file. Records are
+//length and checksum framed so a record torn by a crash is detected and
+//discarded when the queue is reopened. Events still queued when the process
+//exits are delivered after the queue is reopened. Each push is synced to
+//disk.
+type DiskQueue struct {
+	path     string
+	file     *os.File
+	cipher   RecordCipher
+	maxBytes int64
+	readOff  int64
+	writeOff int64
+	count    int
+	notifyCh chan struct{}
+	mu       *sync.Mutex
+}
+
+//OpenDiskQueue opens or creates a disk queue at path that holds at most
+//maxBytes of data (zero for no limit).
+func OpenDiskQueue(path string, maxBytes int64) (*DiskQueue, error) {
+	return OpenDiskQueueWithCipher(path, maxBytes, nil)
+}
+
+//OpenDiskQueueWithCipher opens or creates a disk queue like OpenDiskQueue
+//that encrypts its events with recordCipher. A queue must always be opened
+//with the same cipher.
+func OpenDiskQueueWithCipher(path string, maxBytes int64, recordCipher RecordCipher) (*DiskQueue, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := &DiskQueue{
+		path:     path,
+		file:     file,
+		cipher:   recordCipher,
+		maxBytes: maxBytes,
+		readOff:  queueHeaderSize,
+		notifyCh: make(chan struct{}, 1),
+		mu:       &sync.Mutex{},
+	}
+
+	//Read the offset of the next unread record, if the file has a header.
+	var header [queueHeaderSize]byte
+	if _, err := file.ReadAt(header[:], 0); err == nil {
+		if off := int64(binary.BigEndian.Uint64(header[:])); off >= queueHeaderSize {
+			queue.readOff = off
+		}
+	}
+
+	//Count the complete records after the read offset and discard any torn
+	//record at the end of the file.
+	queue.writeOff = queue.readOff
+	reader := bufio.NewReader(io.NewSectionReader(file, queue.readOff, 1<<62))
+	for {
+		payload, err := readRecord(reader)
+		if err != nil {
+			if err != io.EOF {
+				log.Print(logPrefix, "Discarding corrupt tail of disk queue ", path)
+			}
+			break
+		}
+		queue.writeOff += int64(8 + len(payload))
+		queue.count++
+	}
+
+	if queue.count == 0 {
+		queue.readOff = queueHeaderSize
+		queue.writeOff = queueHeaderSize
+	}
+	if err := file.Truncate(queue.writeOff); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := queue.reset(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return queue, nil
+}
+
+//Push appends an event to the end of the queue.
+func (queue *DiskQueue) Push(event map[string]string) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if payload, err = encryptRecord(queue.cipher, payload); err != nil {
+		return err
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	size := int64(8 + len(payload))
+	if queue.maxBytes > 0 && queue.writeOff-queue.readOff+size > queue.maxBytes {
+		return errQueueFull
+	}
+
+	writer := &offsetWriter{file: queue.file, off: queue.writeOff}
+	if err := writeRecord(writer, payload); err != nil {
+		return err
+	}
+	if err := queue.file.Sync(); err != nil {
+		return err
+	}
+	queue.writeOff += size
+	queue.count++
+
+	select {
+	case queue.notifyCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+//Peek returns the event at the front of the queue without removing it. The
+//bool result is false if the queue is empty.
+func (queue *DiskQueue) Peek() (map[string]string, bool, error) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if queue.count == 0 {
+		return nil, false, nil
+	}
+
+	payload, err := readRecord(io.NewSectionReader(queue.file, queue.readOff, queue.writeOff-queue.readOff))
+	if err != nil {
+		return nil, false, err
+	}
+	if payload, err = decryptRecord(queue.cipher, payload); err != nil {
+		return nil, false, err
+	}
+
+	var event map[string]string
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, false, err
+	}
+	return event, true, nil
+}
+
+//Remove discards the event at the front of the queue, normally after it has
+//been returned by Peek and successfully delivered.
+func (queue *DiskQueue) Remove() error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if queue.count == 0 {
+		return nil
+	}
+
+	var header [8]byte
+	if _, err := queue.file.ReadAt(header[:], queue.readOff); err != nil {
+		return err
+	}
+	queue.readOff += int64(8 + binary.BigEndian.Uint32(header[:]))
+	queue.count--
+
+	//A corrupt length can't be trusted to find the next record, so the rest
+	//of the queue is discarded.
+	if queue.readOff > queue.writeOff {
+		queue.count = 0
+	}
+
+	//Once the queue is empty start again at the beginning of the file so it
+	//doesn't grow forever.
+	if queue.count == 0 {
+		queue.readOff = queueHeaderSize
+		queue.writeOff = queueHeaderSize
+	}
+	if err := queue.reset(); err != nil {
+		return err
+	}
+
+	delivered := queue.readOff - queueHeaderSize
+	if delivered >= queueCompactSize && delivered > queue.writeOff-queue.readOff {
+		return queue.compact()
+	}
+	return nil
+}
+
+//compact rewrites the queue file without the delivered records, writing the
+//queued ones to a temporary file that is renamed into place so a crash
+//leaves one file or the other. The caller must hold the lock.
+func (queue *DiskQueue) compact() error {
+	tmpPath := queue.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	var header [queueHeaderSize]byte
+	binary.BigEndian.PutUint64(header[:], queueHeaderSize)
+	_, err = tmp.Write(header[:])
+	if err == nil {
+		_, err = io.Copy(tmp, io.NewSectionReader(queue.file, queue.readOff, queue.writeOff-queue.readOff))
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, queue.path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	queue.file.Close()
+	queue.file = tmp
+	queue.writeOff -= queue.readOff - queueHeaderSize
+	queue.readOff = queueHeaderSize
+	return nil
+}
+
+//Len returns the number of events in the queue.
+func (queue *DiskQueue) Len() int {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return queue.count
+}
+
+//Close closes the queue file. Queued events remain on disk.
+func (queue *DiskQueue) Close() error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return queue.file.Close()
+}
+
+//reset writes the read offset to the header and truncates the file at the
+//write offset. The caller must hold the lock.
+func (queue *DiskQueue) reset() error {
+	var header [queueHeaderSize]byte
+	binary.BigEndian.PutUint64(header[:], uint64(queue.readOff))
+	if _, err := queue.file.WriteAt(header[:], 0); err != nil {
+		return err
+	}
+
+	if queue.count == 0 {
+		return queue.file.Truncate(queueHeaderSize)
+	}
+	return nil
+}
+
+//offsetWriter writes sequentially to a file from a starting offset.
+type offsetWriter struct {
+	file *os.File
+	off  int64
+}
+
+func (writer *offsetWriter) Write(p []byte) (int, error) {
+	n, err := writer.file.WriteAt(p, writer.off)
+	writer.off += int64(n)
+	return n, err
+}
+
+//SetOverflowQueue enables spilling events to queue when EventCh is full,
+//instead of waiting and then discarding them. Spilled events are delivered to
+//EventCh in order once the consumer catches up, and any events left in the
+//queue from a previous run are delivered first. Events are only discarded if
+//the queue itself is full. It must be called at most once.
+func (client *Client) SetOverflowQueue(queue *DiskQueue) {
+	client.optMu.Lock()
+	client.overflow = queue
+	client.optMu.Unlock()
+
+	client.drainWg.Add(1)
+	go client.drainOverflow(queue)
+}
+
+//spillEvent adds an event to the overflow queue if one is configured,
+//returning false if the event should be delivered directly. Once events have
+//been spilled, later events are also spilled so that ordering is kept.
+func (client *Client) spillEvent(event map[string]string) bool {
+	client.optMu.RLock()
+	queue := client.overflow
+	client.optMu.RUnlock()
+
+	if queue == nil {
+		return false
+	}
+
+	if queue.Len() == 0 {
+		select {
+		case client.EventCh <- event:
+			return true
+		default:
+		}
+	}
+
+	if err := queue.Push(event); err != nil {
+		log.Print(logPrefix, "Error overflow queue push failed (", err, "), discarded Event: ",
+			event["Unique-ID"], " ", event["Event-Name"])
+	}
+	return true
+}
+
+//drainOverflow moves events from the overflow queue to EventCh until the
+//client is closed.
+func (client *Client) drainOverflow(queue *DiskQueue) {
+	defer client.drainWg.Done()
+
+	for {
+		event, ok, err := queue.Peek()
+		if err != nil {
+			//Skip the record, e.g. one that is corrupt or whose key is no
+			//longer available, so the events after it are still delivered.
+			log.Print(logPrefix, "Overflow queue read failed, discarding event: ", err)
+			if !client.removeOverflow(queue) {
+				return
+			}
+			continue
+		}
+
+		if !ok {
+			select {
+			case <-queue.notifyCh:
+				continue
+			case <-client.closeCh:
+				return
+			}
+		}
+
+		select {
+		case client.EventCh <- event:
+			if !client.removeOverflow(queue) {
+				return
+			}
+		case <-client.closeCh:
+			return
+		}
+	}
+}
+
+//removeOverflow removes the event at the front of the overflow queue,
+//retrying every second if that fails, as events would otherwise be stuck in
+//the queue. It returns false if the client was closed first.
+func (client *Client) removeOverflow(queue *DiskQueue) bool {
+	for {
+		err := queue.Remove()
+		if err == nil {
+			return true
+		}
+		log.Print(logPrefix, "Overflow queue remove failed: ", err)
+
+		select {
+		case <-client.after(1 * time.Second):
+		case <-client.closeCh:
+			return false
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package fsclient
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+//EventHandler processes an event delivered by a Dispatcher.
+type EventHandler func(event Event)
+
+//DispatchMode controls how a Dispatcher runs handlers.
+type DispatchMode int
+
+const (
+	//DispatchSequential runs handlers for one event at a time, in the order
+	//events were received.
+	DispatchSequential DispatchMode = iota
+
+	//DispatchPerUUID runs handlers for events of different channels
+	//concurrently, while events for the same channel (Unique-ID) are handled
+	//one at a time in the order they were received. Events without a
+	//Unique-ID are handled in order on a single worker.
+	DispatchPerUUID
+
+	//DispatchPool runs handlers on a pool of workers, each taking the next
+	//event as soon as it is free. Events are not handled in any particular
+	//order.
+	DispatchPool
+)
+
+//HandlerPanicError is reported to a Dispatcher's error handler when a handler
+//panics.
+type HandlerPanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (err *HandlerPanicError) Error() string {
+	return fmt.Sprint("Event handler panic: ", err.Value)
+}
+
+//handlerEntry is a registered handler. Entries are compared by pointer so a
+//handler can be removed.
+type handlerEntry struct {
+	handler EventHandler
+}
+
+//Dispatcher reads events from a source, such as a Client's EventCh, and runs
+//registered handlers for each of them.
+type Dispatcher struct {
+	source    <-chan map[string]string
+	mode      DispatchMode
+	workers   int
+	queueSize int
+	handlers  []*handlerEntry
+	onError   func(Event, error)
+	mu        *sync.RWMutex
+}
+
+//NewDispatcher creates a Dispatcher for events from source. In DispatchPerUUID
+//and DispatchPool modes, workers sets the number of concurrent workers and
+//queueSize the number of events that can be buffered for them before the
+//dispatcher waits. Both are ignored in DispatchSequential mode.
+func NewDispatcher(source <-chan map[string]string, mode DispatchMode, workers int, queueSize int) *Dispatcher {
+	if workers < 1 || mode == DispatchSequential {
+		workers = 1
+	}
+
+	return &Dispatcher{
+		source:    source,
+		mode:      mode,
+		workers:   workers,
+		queueSize: queueSize,
+		mu:        &sync.RWMutex{},
+	}
+}
+
+//Handle registers a handler to be run for every event, returning a function
+//that removes it again. Handlers are run in the order they were registered.
+func (dispatcher *Dispatcher) Handle(handler EventHandler) func() {
+	entry := &handlerEntry{handler: handler}
+
+	dispatcher.mu.Lock()
+	dispatcher.handlers = append(dispatcher.handlers, entry)
+	dispatcher.mu.Unlock()
+
+	return func() {
+		dispatcher.mu.Lock()
+		defer dispatcher.mu.Unlock()
+
+		for i, existing := range dispatcher.handlers {
+			if existing == entry {
+				dispatcher.handlers = append(dispatcher.handlers[:i:i], dispatcher.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+//WaitFor blocks until an event for which predicate returns true is
+//dispatched, or ctx is done, while the other handlers carry on receiving
+//events as usual. predicate runs on the dispatcher's workers so must not
+//block, and WaitFor must not be called from a handler in DispatchSequential
+//mode as no further events would be dispatched while it waits.
+func (dispatcher *Dispatcher) WaitFor(ctx context.Context, predicate func(event Event) bool) (Event, error) {
+	matched := make(chan Event, 1)
+	remove := dispatcher.Handle(func(event Event) {
+		if predicate(event) {
+			select {
+			case matched <- event:
+			default:
+			}
+		}
+	})
+	defer remove()
+
+	select {
+	case event := <-matched:
+		return event, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+//MatchEvent returns a WaitFor predicate matching events named name for the
+//channel uuid, e.g. MatchEvent("CHANNEL_ANSWER", uuid). An empty uuid
+//matches events for any channel.
+func MatchEvent(name string, uuid string) func(event Event) bool {
+	return func(event Event) bool {
+		return event.Name() == name && (uuid == "" || event.UUID() == uuid)
+	}
+}
+
+//SetErrorHandler sets a function to be called when a handler panics. The
+//panic is recovered, reported as a *HandlerPanicError and the remaining
+//handlers still run. By default panics are logged.
+func (dispatcher *Dispatcher) SetErrorHandler(onError func(event Event, err error)) {
+	dispatcher.mu.Lock()
+	defer dispatcher.mu.Unlock()
+	dispatcher.onError = onError
+}
+
+//Run dispatches events until the source is closed and all handlers have
+//finished.
+func (dispatcher *Dispatcher) Run() {
+	//In pool mode all workers share a single queue, otherwise each worker has
+	//its own queue so events can be partitioned between them.
+	queueCount := dispatcher.workers
+	if dispatcher.mode == DispatchPool {
+		queueCount = 1
+	}
+
+	queues := make([]chan Event, queueCount)
+	for i := range queues {
+		queues[i] = make(chan Event, dispatcher.queueSize)
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < dispatcher.workers; i++ {
+		wg.Add(1)
+		go dispatcher.worker(queues[i%queueCount], wg)
+	}
+
+	for event := range dispatcher.source {
+		queues[dispatcher.partition(Event(event))] <- Event(event)
+	}
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+}
+
+//partition returns the worker that should handle an event.
+func (dispatcher *Dispatcher) partition(event Event) int {
+	if dispatcher.workers == 1 || dispatcher.mode == DispatchPool {
+		return 0
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(event.UUID()))
+	return int(hash.Sum32() % uint32(dispatcher.workers))
+}
+
+//worker runs the handlers for each event in its queue.
+func (dispatcher *Dispatcher) worker(queue chan Event, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for event := range queue {
+		dispatcher.dispatch(event)
+	}
+}
+
+//dispatch runs the registered handlers for an event.
+func (dispatcher *Dispatcher) dispatch(event Event) {
+	dispatcher.mu.RLock()
+	handlers := dispatcher.handlers
+	dispatcher.mu.RUnlock()
+
+	for _, entry := range handlers {
+		dispatcher.runHandler(entry.handler, event)
+	}
+}
+
+//runHandler runs a single handler, recovering and reporting any panic so that
+//one bad handler can't stop the dispatcher.
+func (dispatcher *Dispatcher) runHandler(handler EventHandler, event Event) {
+	defer func() {
+		if value := recover(); value != nil {
+			err := &HandlerPanicError{Value: value, Stack: debug.Stack()}
+
+			dispatcher.mu.RLock()
+			onError := dispatcher.onError
+			dispatcher.mu.RUnlock()
+
+			if onError != nil {
+				onError(event, err)
+				return
+			}
+			log.Print(logPrefix, err, " handling Event: ", event.UUID(), " ", event.Name(), "\n", string(err.Stack))
+		}
+	}()
+
+	handler(event)
+}
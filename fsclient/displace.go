@@ -0,0 +1,121 @@
+package fsclient
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//toneBufSize is the number of detected tones buffered for a Session before
+//further tones are discarded.
+const toneBufSize = 10
+
+//DisplaceFlags control how a file is displaced into a channel's audio.
+//Limit, if non-zero, stops the displacement after that long. Mux mixes the
+//file with the channel's audio instead of replacing it, so the other party
+//is still heard, e.g. for whispering to an agent.
+type DisplaceFlags struct {
+	Limit time.Duration
+	Mux   bool
+}
+
+//Displace plays file into the audio a channel hears, replacing or mixing
+//with the audio from the other leg. It runs in the background until Limit is
+//reached or StopDisplace is called; Freeswitch doesn't send an event when it
+//finishes.
+func (client *Client) Displace(uuid string, file string, flags DisplaceFlags) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+	cmd := "uuid_displace " + uuid + " start " + file
+	if flags.Limit > 0 {
+		//The limit is in whole seconds, round up so a short limit isn't
+		//treated as no limit.
+		cmd += " " + strconv.Itoa(int((flags.Limit+time.Second-1)/time.Second))
+	} else if flags.Mux {
+		cmd += " 0"
+	}
+	if flags.Mux {
+		cmd += " mux"
+	}
+	return client.apiOK(cmd)
+}
+
+//StopDisplace stops displacing file into a channel's audio.
+func (client *Client) StopDisplace(uuid string, file string) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+	return client.apiOK("uuid_displace " + uuid + " stop " + file)
+}
+
+//StartToneDetect starts detecting a tone made up of freqs (in Hz) on a
+//channel's incoming audio. A DETECTED_TONE event with the Detected-Tone
+//header set to key is sent each time the tone is heard, until timeout
+//(zero for no timeout) or StopToneDetect. hits is the number of detections
+//required before an event is sent.
+func (client *Client) StartToneDetect(uuid string, key string, freqs []int, timeout time.Duration, hits int) error {
+	freqList := make([]string, len(freqs))
+	for i, freq := range freqs {
+		freqList[i] = strconv.Itoa(freq)
+	}
+
+	expires := "0"
+	if timeout > 0 {
+		expires = "+" + strconv.FormatInt(timeout.Milliseconds(), 10)
+	}
+	if hits < 1 {
+		hits = 1
+	}
+
+	arg := fmt.Sprintf("%s %s r %s '' '' %d", key, strings.Join(freqList, ","), expires, hits)
+	_, err := client.Execute("tone_detect", arg, uuid, false)
+	return err
+}
+
+//StopToneDetect stops all tone detection on a channel.
+func (client *Client) StopToneDetect(uuid string) error {
+	_, err := client.Execute("stop_tone_detect", "", uuid, false)
+	return err
+}
+
+//DecodeDetectedTone returns the key of the tone reported by a DETECTED_TONE
+//event. The bool result is false for any other event.
+func DecodeDetectedTone(event Event) (string, bool) {
+	if event.Name() != "DETECTED_TONE" {
+		return "", false
+	}
+	return event["Detected-Tone"], true
+}
+
+//Tones returns a channel of the keys of tones detected on the session's
+//channel after StartToneDetect. The client must be subscribed to
+//DETECTED_TONE. Tones are discarded if the channel is full.
+func (session *Session) Tones() <-chan string {
+	return session.toneCh
+}
+
+//GenTones plays tones on the channel and waits for them to finish. tones is
+//a TGML string, e.g. "%(500,500,480,620)" for a busy tone, repeated loops
+//times (zero to play once).
+func (session *Session) GenTones(tones string, loops int) error {
+	arg := tones
+	if loops > 0 {
+		arg += "|" + strconv.Itoa(loops)
+	}
+	_, err := session.Execute("gentones", arg)
+	return err
+}
+
+//deliverTone sends a DETECTED_TONE event's key to the Tones channel.
+func (session *Session) deliverTone(event Event) {
+	key, _ := DecodeDetectedTone(event)
+
+	select {
+	case session.toneCh <- key:
+	default:
+		log.Print(logPrefix, "Tone channel full, discarded tone ", key, " on ", session.UUID)
+	}
+}
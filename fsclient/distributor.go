@@ -0,0 +1,92 @@
+package fsclient
+
+import (
+	"errors"
+	"strings"
+)
+
+//Distribute returns the next node of a mod_distributor list, chosen by the
+//list's weights, e.g. the name of a gateway to route a call through.
+func Distribute(client Commander, list string) (string, error) {
+	if list == "" || strings.ContainsAny(list, " \r\n") {
+		return "", errors.New("Invalid distributor list: " + list)
+	}
+
+	res, err := client.API("distributor " + list)
+	if err != nil {
+		return "", err
+	}
+
+	node := strings.TrimSpace(res)
+	if node == "" || strings.HasPrefix(node, "-") {
+		if node == "" {
+			node = "Empty reply"
+		}
+		return "", errors.New("Distributor list " + list + ": " + node)
+	}
+	return node, nil
+}
+
+//ReloadDistributor reloads the mod_distributor lists from its configuration,
+//e.g. after the weights in distributor.conf have been changed.
+func ReloadDistributor(client Commander) error {
+	res, err := client.API("distributor_ctl reload")
+	if err != nil {
+		return err
+	}
+	if !ParseReply(res).OK {
+		return errors.New(strings.TrimSpace(res))
+	}
+	return nil
+}
+
+//DistributorGateway picks a gateway for an outbound call from a
+//mod_distributor list whose nodes are sofia gateway names. A gateway that
+//isn't up, according to its options pings, or that is in exclude, e.g.
+//because it has just failed the call, is skipped and the list asked again,
+//up to attempts times. Gateways without pings are treated as up.
+func DistributorGateway(client Commander, list string, attempts int, exclude ...string) (string, error) {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		gateway, err := Distribute(client, list)
+		if err != nil {
+			return "", err
+		}
+		if containsString(exclude, gateway) {
+			lastErr = errors.New("Gateway excluded: " + gateway)
+			continue
+		}
+
+		status, err := SofiaGatewayStatus(client, gateway)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if status.Status == "DOWN" {
+			lastErr = errors.New("Gateway down: " + gateway)
+			continue
+		}
+		return gateway, nil
+	}
+	return "", errors.New("No gateway available from distributor list " + list + ": " + lastErr.Error())
+}
+
+//GatewayEndpoint returns the originate endpoint that calls number through a
+//sofia gateway, e.g. "sofia/gateway/carrier/15551234567".
+func GatewayEndpoint(gateway string, number string) string {
+	return "sofia/gateway/" + gateway + "/" + number
+}
+
+//containsString returns true if values contains value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package fsclient
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DefaultEmergencyPatterns match the common emergency numbers: 911 in North
+//America, 112 across Europe and on mobile networks, and 999 in the UK.
+var DefaultEmergencyPatterns = []string{"911", "112", "999"}
+
+//EmergencyCall is a call to an emergency number, with what is known about the
+//caller when it was seen. Pattern is the pattern the destination matched.
+//Username, Domain and NetworkAddr identify the endpoint that placed the call,
+//for looking up its registered location.
+type EmergencyCall struct {
+	UUID           string
+	Destination    string
+	Pattern        string
+	CallerIDName   string
+	CallerIDNumber string
+	Direction      string
+	Context        string
+	ChannelName    string
+	Username       string
+	Domain         string
+	NetworkAddr    string
+	SIPHeaders     SIPHeaders
+	Correlation    Correlation
+	Tenant         string
+	DetectedAt     time.Time
+	Event          Event
+}
+
+//EmergencyDetector watches for calls to emergency numbers and reports each
+//one once, as soon as its destination is known, for mandatory notification of
+//a site's security desk or administrators. Only the caller's leg of a bridged
+//call is reported, as the outbound leg to the emergency service carries the
+//same destination.
+//
+//Register the detector's HandleEvent method with a Dispatcher. The client must
+//be subscribed to CHANNEL_CREATE, CHANNEL_STATE and CHANNEL_HANGUP_COMPLETE.
+type EmergencyDetector struct {
+	//OnEmergency is called for each emergency call. It is run on its own
+	//goroutine so that a slow notification never delays the dispatcher or
+	//waits behind other handlers.
+	OnEmergency func(call EmergencyCall)
+
+	patterns []emergencyPattern
+	clock    Clock
+	seen     map[string]bool
+	mu       *sync.Mutex
+}
+
+//emergencyPattern is a compiled destination pattern.
+type emergencyPattern struct {
+	text   string
+	regexp *regexp.Regexp
+}
+
+//NewEmergencyDetector creates an EmergencyDetector matching destinations
+//against patterns, which are regular expressions that must match the whole
+//destination number, e.g. "911" or "9?911" to allow an outside line prefix.
+//With no patterns DefaultEmergencyPatterns are used.
+func NewEmergencyDetector(patterns ...string) (*EmergencyDetector, error) {
+	if len(patterns) == 0 {
+		patterns = DefaultEmergencyPatterns
+	}
+
+	detector := &EmergencyDetector{
+		clock: SystemClock,
+		seen:  make(map[string]bool),
+		mu:    &sync.Mutex{},
+	}
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return nil, errors.New("Empty emergency pattern")
+		}
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, errors.New("Invalid emergency pattern " + pattern + ": " + err.Error())
+		}
+		detector.patterns = append(detector.patterns, emergencyPattern{text: pattern, regexp: re})
+	}
+	return detector, nil
+}
+
+//SetClock sets the clock used to time detections.
+func (detector *EmergencyDetector) SetClock(clock Clock) {
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+	detector.clock = clock
+}
+
+//Match returns the pattern a destination matches, ignoring a leading + and
+//any spaces or dashes, and whether it matched one.
+func (detector *EmergencyDetector) Match(destination string) (string, bool) {
+	number := strings.TrimPrefix(destination, "+")
+	number = strings.NewReplacer(" ", "", "-", "").Replace(number)
+	for _, pattern := range detector.patterns {
+		if pattern.regexp.MatchString(number) {
+			return pattern.text, true
+		}
+	}
+	return "", false
+}
+
+//HandleEvent checks the destination of new and routing channels, and forgets
+//channels once they hang up.
+func (detector *EmergencyDetector) HandleEvent(event Event) {
+	uuid := event.UUID()
+	if uuid == "" {
+		return
+	}
+
+	switch event.Name() {
+	case "CHANNEL_CREATE", "CHANNEL_STATE":
+		//CHANNEL_STATE is only checked once the channel is routing, when
+		//the destination is known for every kind of endpoint.
+		if event.Name() == "CHANNEL_STATE" && event["Channel-State"] != "CS_ROUTING" {
+			return
+		}
+	case "CHANNEL_HANGUP_COMPLETE":
+		detector.mu.Lock()
+		delete(detector.seen, uuid)
+		detector.mu.Unlock()
+		return
+	default:
+		return
+	}
+
+	//The outbound leg bridged from the caller's channel is the same call.
+	if event["Call-Direction"] == "outbound" && event["Other-Leg-Unique-ID"] != "" {
+		return
+	}
+
+	destination := event["Caller-Destination-Number"]
+	pattern, ok := detector.Match(destination)
+	if !ok {
+		return
+	}
+
+	detector.mu.Lock()
+	seen := detector.seen[uuid]
+	detector.seen[uuid] = true
+	now := detector.clock.Now()
+	detector.mu.Unlock()
+	if seen || detector.OnEmergency == nil {
+		return
+	}
+
+	call := EmergencyCall{
+		UUID:           uuid,
+		Destination:    destination,
+		Pattern:        pattern,
+		CallerIDName:   event["Caller-Caller-ID-Name"],
+		CallerIDNumber: event["Caller-Caller-ID-Number"],
+		Direction:      event["Call-Direction"],
+		Context:        event["Caller-Context"],
+		ChannelName:    event["Channel-Name"],
+		Username:       firstHeader(event, "Caller-Username", "variable_sip_from_user"),
+		Domain:         firstHeader(event, "variable_domain_name", "variable_sip_from_host"),
+		NetworkAddr:    firstHeader(event, "Caller-Network-Addr", "variable_sip_network_ip"),
+		SIPHeaders:     SIPHeadersFromEvent(event),
+		Correlation:    CorrelationFromEvent(event),
+		Tenant:         event.Tenant(),
+		DetectedAt:     now,
+		Event:          event,
+	}
+	go detector.OnEmergency(call)
+}
@@ -0,0 +1,100 @@
+package fsclient_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+)
+
+//channelEvent is a channel event for the channel uuid calling destination.
+func channelEvent(name string, uuid string, state string, direction string, destination string) map[string]string {
+	event := map[string]string{
+		"Event-Name":                name,
+		"Unique-ID":                 uuid,
+		"Channel-State":             state,
+		"Call-Direction":            direction,
+		"Caller-Destination-Number": destination,
+	}
+	if direction == "outbound" {
+		event["Other-Leg-Unique-ID"] = callerUUID
+	}
+	return event
+}
+
+//TestEmergencyDetector checks which channel events are reported as
+//emergency calls.
+func TestEmergencyDetector(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []map[string]string
+		want   []string
+	}{
+		{"inbound", []map[string]string{
+			channelEvent("CHANNEL_CREATE", callerUUID, "CS_INIT", "inbound", "911"),
+		}, []string{callerUUID}},
+		{"routing", []map[string]string{
+			channelEvent("CHANNEL_CREATE", callerUUID, "CS_INIT", "inbound", ""),
+			channelEvent("CHANNEL_STATE", callerUUID, "CS_ROUTING", "inbound", "+1 12"),
+		}, []string{callerUUID}},
+		{"formatted", []map[string]string{
+			channelEvent("CHANNEL_STATE", callerUUID, "CS_ROUTING", "inbound", "+11-2"),
+		}, []string{callerUUID}},
+		{"not routing", []map[string]string{
+			channelEvent("CHANNEL_STATE", callerUUID, "CS_EXECUTE", "inbound", "999"),
+		}, nil},
+		{"not emergency", []map[string]string{
+			channelEvent("CHANNEL_CREATE", callerUUID, "CS_INIT", "inbound", "1000"),
+		}, nil},
+		{"bridged", []map[string]string{
+			channelEvent("CHANNEL_CREATE", callerUUID, "CS_INIT", "inbound", "911"),
+			channelEvent("CHANNEL_STATE", callerUUID, "CS_ROUTING", "inbound", "911"),
+			channelEvent("CHANNEL_CREATE", agentUUID, "CS_INIT", "outbound", "911"),
+			channelEvent("CHANNEL_STATE", agentUUID, "CS_ROUTING", "outbound", "911"),
+		}, []string{callerUUID}},
+		{"originated", []map[string]string{
+			{"Event-Name": "CHANNEL_CREATE", "Unique-ID": agentUUID, "Call-Direction": "outbound", "Caller-Destination-Number": "112"},
+		}, []string{agentUUID}},
+		{"hung up", []map[string]string{
+			channelEvent("CHANNEL_CREATE", callerUUID, "CS_INIT", "inbound", "999"),
+			{"Event-Name": "CHANNEL_HANGUP_COMPLETE", "Unique-ID": callerUUID},
+			channelEvent("CHANNEL_CREATE", callerUUID, "CS_INIT", "inbound", "999"),
+		}, []string{callerUUID, callerUUID}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			detector, err := fsclient.NewEmergencyDetector()
+			if err != nil {
+				t.Fatal(err)
+			}
+			calls := make(chan fsclient.EmergencyCall, 10)
+			detector.OnEmergency = func(call fsclient.EmergencyCall) {
+				calls <- call
+			}
+			client := fsclienttest.NewClient()
+			client.OnEvent(detector.HandleEvent)
+
+			for _, event := range test.events {
+				client.Inject(event)
+			}
+
+			for _, want := range test.want {
+				select {
+				case call := <-calls:
+					if call.UUID != want || call.Pattern == "" {
+						t.Errorf("Reported %s matching %q, want %s", call.UUID, call.Pattern, want)
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("Call %s not reported", want)
+				}
+			}
+			select {
+			case call := <-calls:
+				t.Errorf("Reported %s, want %d calls", call.UUID, len(test.want))
+			case <-time.After(20 * time.Millisecond):
+			}
+		})
+	}
+}
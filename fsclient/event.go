@@ -0,0 +1,22 @@
+package fsclient
+
+//Event is a Freeswitch event as a map of header names to values. The event
+//body, if any, is stored under the "body-string" key. Events received on a
+//Client's EventCh can be converted with Event(event).
+type Event map[string]string
+
+//Name returns the Event-Name header.
+func (event Event) Name() string {
+	return event["Event-Name"]
+}
+
+//UUID returns the Unique-ID header, the UUID of the channel the event relates
+//to, if any.
+func (event Event) UUID() string {
+	return event["Unique-ID"]
+}
+
+//Body returns the event body.
+func (event Event) Body() string {
+	return event["body-string"]
+}
@@ -0,0 +1,86 @@
+package fsclient
+
+import (
+	"errors"
+	"log"
+	"strings"
+)
+
+//EventFormat is the format Freeswitch sends events in.
+type EventFormat string
+
+//Event formats supported by the event command.
+const (
+	FormatPlain EventFormat = "plain"
+	FormatJSON  EventFormat = "json"
+	FormatXML   EventFormat = "xml"
+)
+
+//Subscription is an event subscription made by the client, where Events is
+//the argument to the event command, e.g. "CHANNEL_CREATE HEARTBEAT".
+type Subscription struct {
+	Format EventFormat
+	Events string
+}
+
+//SubscribeFormat enables events like Subscribe, in the given format. Events of
+//every format are decoded into the same map of headers.
+//
+//Freeswitch sends all events on a connection in the format of the most recent
+//event command, so subscriptions with different formats don't coexist but
+//switch the connection's format. Subscriptions are replayed in order after
+//reconnecting, so the connection ends up in the same format.
+func (client *Client) SubscribeFormat(format EventFormat, arg string) error {
+	if format == FormatJSON && client.unsupported(CapabilityJSONEvents) {
+		log.Print(logPrefix, "Server doesn't support json events, subscribing to plain events instead")
+		format = FormatPlain
+	}
+
+	sub := Subscription{Format: format, Events: arg}
+	client.optMu.Lock()
+	client.subs = append(client.subs, sub)
+	client.optMu.Unlock()
+	return client.subcribeEvent(sub)
+}
+
+//Subscriptions returns the client's event subscriptions in the order they
+//were made.
+func (client *Client) Subscriptions() []Subscription {
+	client.optMu.RLock()
+	defer client.optMu.RUnlock()
+	return append([]Subscription(nil), client.subs...)
+}
+
+//plainSubscriptions returns plain format subscriptions for each argument.
+func plainSubscriptions(args []string) []Subscription {
+	subs := make([]Subscription, 0, len(args))
+	for _, arg := range args {
+		subs = append(subs, Subscription{Format: FormatPlain, Events: arg})
+	}
+	return subs
+}
+
+//SubscribeCustom enables CUSTOM events with the given subclasses, e.g.
+//"sofia::register" and "conference::maintenance". The events are sent in the
+//format of the client's most recent subscription, or plain if it has none, so
+//as not to switch the connection's format. The subscription is remembered
+//and reapplied after reconnecting like Subscribe.
+func (client *Client) SubscribeCustom(subclasses ...string) error {
+	if len(subclasses) == 0 {
+		return errors.New("No CUSTOM event subclasses")
+	}
+	for _, subclass := range subclasses {
+		if subclass == "" || strings.ContainsAny(subclass, " \t\r\n") {
+			return errors.New("Invalid CUSTOM event subclass '" + subclass + "'")
+		}
+	}
+
+	format := FormatPlain
+	if subs := client.Subscriptions(); len(subs) > 0 {
+		format = subs[len(subs)-1].Format
+	}
+
+	//Freeswitch treats every name after CUSTOM as a subclass, so CUSTOM must
+	//come first and nothing else can follow it.
+	return client.SubscribeFormat(format, "CUSTOM "+strings.Join(subclasses, " "))
+}
@@ -0,0 +1,114 @@
+package fsclient
+
+import (
+	"log"
+	"sync"
+)
+
+//EventHub distributes events from a single source, such as a Client's
+//EventCh, to any number of subscribers so that several consumers can receive
+//the same events. Subscribers that don't keep up have events discarded rather
+//than blocking the others. Events are shared between subscribers and must not
+//be modified.
+type EventHub struct {
+	subs   map[*EventSubscription]bool
+	mu     *sync.Mutex
+	closed bool
+}
+
+//EventSubscription receives events from an EventHub on EventCh. EventCh is
+//closed when the subscription is closed or the hub's source is closed.
+type EventSubscription struct {
+	EventCh chan map[string]string
+	names   map[string]bool
+	tenant  string
+	scoped  bool
+	hub     *EventHub
+}
+
+//NewEventHub creates a hub that reads events from source until it is closed.
+func NewEventHub(source <-chan map[string]string) *EventHub {
+	hub := &EventHub{
+		subs: make(map[*EventSubscription]bool),
+		mu:   &sync.Mutex{},
+	}
+
+	go hub.run(source)
+	return hub
+}
+
+//Subscribe creates a subscription buffering up to bufSize events. If any event
+//names are given only events with those names are delivered.
+func (hub *EventHub) Subscribe(bufSize int, eventNames ...string) *EventSubscription {
+	return hub.subscribe(&EventSubscription{}, bufSize, eventNames)
+}
+
+//SubscribeTenant creates a subscription like Subscribe that only receives
+//events tagged with tenant by a TenantTagger.
+func (hub *EventHub) SubscribeTenant(tenant string, bufSize int, eventNames ...string) *EventSubscription {
+	return hub.subscribe(&EventSubscription{tenant: tenant, scoped: true}, bufSize, eventNames)
+}
+
+//subscribe registers a subscription with the hub.
+func (hub *EventHub) subscribe(sub *EventSubscription, bufSize int, eventNames []string) *EventSubscription {
+	sub.EventCh = make(chan map[string]string, bufSize)
+	sub.names = make(map[string]bool)
+	sub.hub = hub
+	for _, name := range eventNames {
+		sub.names[name] = true
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if hub.closed {
+		close(sub.EventCh)
+		return sub
+	}
+	hub.subs[sub] = true
+	return sub
+}
+
+//Close stops delivery of events to the subscription and closes EventCh.
+func (sub *EventSubscription) Close() {
+	sub.hub.mu.Lock()
+	defer sub.hub.mu.Unlock()
+
+	if sub.hub.subs[sub] {
+		delete(sub.hub.subs, sub)
+		close(sub.EventCh)
+	}
+}
+
+//run delivers each event from source to the matching subscriptions.
+func (hub *EventHub) run(source <-chan map[string]string) {
+	for event := range source {
+		hub.mu.Lock()
+		for sub := range hub.subs {
+			if len(sub.names) > 0 && !sub.names[event["Event-Name"]] {
+				continue
+			}
+			if sub.scoped && event[TenantHeader] != sub.tenant {
+				continue
+			}
+
+			select {
+			case sub.EventCh <- event:
+			default:
+				log.Print(logPrefix, "Subscription blocked (", len(sub.EventCh),
+					" items), discarded Event: ", event["Unique-ID"], " ", event["Event-Name"])
+			}
+		}
+		hub.mu.Unlock()
+	}
+
+	//The source has closed so end all subscriptions.
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	hub.closed = true
+	for sub := range hub.subs {
+		delete(hub.subs, sub)
+		close(sub.EventCh)
+	}
+}
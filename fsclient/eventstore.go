@@ -0,0 +1,221 @@
+package fsclient
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+//EventStore keeps a history of events so what happened to a call can be
+//looked up later, e.g. from support tooling. Events for a call are those
+//with its Unique-ID and the BACKGROUND_JOB result of its originate. Times are
+//the events' Event-Date-Timestamp, and a time range includes from but not
+//to. Events are returned in time order.
+type EventStore interface {
+	Append(event Event) error
+	QueryByUUID(uuid string) ([]Event, error)
+	QueryByTimeRange(from time.Time, to time.Time) ([]Event, error)
+}
+
+//StoreHandler returns an EventHandler that appends every event to store,
+//logging any that can't be stored, for registering with a Dispatcher.
+func StoreHandler(store EventStore) EventHandler {
+	return func(event Event) {
+		if err := store.Append(event); err != nil {
+			log.Print(logPrefix, "Failed to store event: ", err)
+		}
+	}
+}
+
+//storeKey returns the call an event is stored under, if any.
+func storeKey(event Event) string {
+	if uuid, _, ok := originateJob(event); ok {
+		return uuid
+	}
+	return event.UUID()
+}
+
+//storedEvent is an event as written to a FileEventStore.
+type storedEvent struct {
+	Time  time.Time         `json:"time"`
+	Event map[string]string `json:"event"`
+}
+
+//storeEntry locates an event in a FileEventStore.
+type storeEntry struct {
+	time time.Time
+	off  int64
+}
+
+//FileEventStore is an EventStore in a single append-only file of checksummed
+//records, indexed in memory when it is opened. Appends aren't synced to disk
+//as they are for a FileEventLog, so a crash may lose the latest events, and
+//a partially written record at the end of the file is discarded on open.
+//Nothing is removed from the file, so rotate it by opening a new store, e.g.
+//daily.
+type FileEventStore struct {
+	file     *os.File
+	cipher   RecordCipher
+	writeOff int64
+	byUUID   map[string][]storeEntry
+	byTime   []storeEntry
+	clock    Clock
+	mu       *sync.RWMutex
+}
+
+//OpenFileEventStore opens or creates the event store at path.
+func OpenFileEventStore(path string) (*FileEventStore, error) {
+	return OpenFileEventStoreWithCipher(path, nil)
+}
+
+//OpenFileEventStoreWithCipher opens or creates the event store at path like
+//OpenFileEventStore, encrypting its events with recordCipher. Events that
+//can't be decrypted when it is opened, e.g. because their key is no longer
+//available, are left out of the indexes.
+func OpenFileEventStoreWithCipher(path string, recordCipher RecordCipher) (*FileEventStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &FileEventStore{
+		file:   file,
+		cipher: recordCipher,
+		byUUID: make(map[string][]storeEntry),
+		clock:  SystemClock,
+		mu:     &sync.RWMutex{},
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		payload, err := readRecord(reader)
+		if err != nil {
+			if err != io.EOF {
+				log.Print(logPrefix, "Discarding corrupt tail of event store ", path)
+			}
+			break
+		}
+
+		var stored storedEvent
+		if plaintext, err := decryptRecord(recordCipher, payload); err == nil && json.Unmarshal(plaintext, &stored) == nil {
+			store.index(storeKey(stored.Event), storeEntry{time: stored.Time, off: store.writeOff})
+		}
+		store.writeOff += int64(8 + len(payload))
+	}
+
+	if err := file.Truncate(store.writeOff); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+//SetClock sets the clock that times events without an Event-Date-Timestamp.
+func (store *FileEventStore) SetClock(clock Clock) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.clock = clock
+}
+
+//Append writes an event to the end of the store.
+func (store *FileEventStore) Append(event Event) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	stored := storedEvent{Time: event.Time(), Event: event}
+	if stored.Time.IsZero() {
+		stored.Time = store.clock.Now()
+	}
+	payload, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	if payload, err = encryptRecord(store.cipher, payload); err != nil {
+		return err
+	}
+
+	if err := writeRecord(&offsetWriter{file: store.file, off: store.writeOff}, payload); err != nil {
+		return err
+	}
+	store.index(storeKey(event), storeEntry{time: stored.Time, off: store.writeOff})
+	store.writeOff += int64(8 + len(payload))
+	return nil
+}
+
+//index adds an event's entry to the indexes, keeping them in time order. The
+//caller must hold the lock.
+func (store *FileEventStore) index(uuid string, entry storeEntry) {
+	store.byTime = insertEntry(store.byTime, entry)
+	if uuid != "" {
+		store.byUUID[uuid] = insertEntry(store.byUUID[uuid], entry)
+	}
+}
+
+//insertEntry inserts an entry after those with the same or an earlier time,
+//which is normally at the end.
+func insertEntry(entries []storeEntry, entry storeEntry) []storeEntry {
+	i := len(entries)
+	if i > 0 && entry.time.Before(entries[i-1].time) {
+		i = sort.Search(len(entries), func(j int) bool { return entries[j].time.After(entry.time) })
+	}
+	entries = append(entries, storeEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	return entries
+}
+
+//QueryByUUID returns the events for the call uuid.
+func (store *FileEventStore) QueryByUUID(uuid string) ([]Event, error) {
+	store.mu.RLock()
+	entries := append([]storeEntry(nil), store.byUUID[uuid]...)
+	store.mu.RUnlock()
+
+	return store.read(entries)
+}
+
+//QueryByTimeRange returns the events from from until to.
+func (store *FileEventStore) QueryByTimeRange(from time.Time, to time.Time) ([]Event, error) {
+	store.mu.RLock()
+	start := sort.Search(len(store.byTime), func(i int) bool { return !store.byTime[i].time.Before(from) })
+	end := sort.Search(len(store.byTime), func(i int) bool { return !store.byTime[i].time.Before(to) })
+	var entries []storeEntry
+	if start < end {
+		entries = append(entries, store.byTime[start:end]...)
+	}
+	store.mu.RUnlock()
+
+	return store.read(entries)
+}
+
+//read reads the events at entries.
+func (store *FileEventStore) read(entries []storeEntry) ([]Event, error) {
+	events := make([]Event, 0, len(entries))
+	for _, entry := range entries {
+		payload, err := readRecord(io.NewSectionReader(store.file, entry.off, 1<<62))
+		if err != nil {
+			return nil, err
+		}
+		if payload, err = decryptRecord(store.cipher, payload); err != nil {
+			return nil, err
+		}
+
+		var stored storedEvent
+		if err := json.Unmarshal(payload, &stored); err != nil {
+			return nil, err
+		}
+		events = append(events, stored.Event)
+	}
+	return events, nil
+}
+
+//Close closes the store file.
+func (store *FileEventStore) Close() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.file.Close()
+}
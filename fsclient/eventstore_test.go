@@ -0,0 +1,139 @@
+package fsclient_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+)
+
+//timedEvent is an event for the channel uuid fired at sec seconds past the
+//epoch, identified by its Event-Sequence header.
+func timedEvent(name string, uuid string, sec int64, seq string) map[string]string {
+	return map[string]string{
+		"Event-Name":           name,
+		"Unique-ID":            uuid,
+		"Event-Date-Timestamp": strconv.FormatInt(sec*1e6, 10),
+		"Event-Sequence":       seq,
+	}
+}
+
+//eventIDs returns the Event-Sequence of each event, or the Event-Name of
+//events without one.
+func eventIDs(events []fsclient.Event) string {
+	ids := make([]string, 0, len(events))
+	for _, event := range events {
+		if seq := event["Event-Sequence"]; seq != "" {
+			ids = append(ids, seq)
+		} else {
+			ids = append(ids, event.Name())
+		}
+	}
+	return strings.Join(ids, " ")
+}
+
+//TestFileEventStore checks the events returned for calls and time ranges,
+//both as they are stored and after the store is reopened.
+func TestFileEventStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	store, err := fsclient.OpenFileEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetClock(fsclienttest.NewClock(time.Unix(25, 0)))
+
+	client := fsclienttest.NewClient()
+	scriptCommands(client)
+	client.OnEvent(fsclient.StoreHandler(store))
+	client.Inject(timedEvent("CHANNEL_CREATE", callerUUID, 10, "1"))
+	client.Inject(timedEvent("CHANNEL_CREATE", agentUUID, 20, "2"))
+	client.Inject(timedEvent("CHANNEL_ANSWER", callerUUID, 30, "3"))
+	client.Inject(timedEvent("CHANNEL_PARK", callerUUID, 15, "4"))
+	client.Inject(timedEvent("HEARTBEAT", "", 40, "5"))
+	if _, err := client.BackgroundAPI("originate {origination_uuid=" + consultUUID + "}user/1000 &park"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query func(store *fsclient.FileEventStore) ([]fsclient.Event, error)
+		want  string
+	}{
+		{"call", func(store *fsclient.FileEventStore) ([]fsclient.Event, error) {
+			return store.QueryByUUID(callerUUID)
+		}, "1 4 3"},
+		{"other call", func(store *fsclient.FileEventStore) ([]fsclient.Event, error) {
+			return store.QueryByUUID(agentUUID)
+		}, "2"},
+		{"originate job", func(store *fsclient.FileEventStore) ([]fsclient.Event, error) {
+			return store.QueryByUUID(consultUUID)
+		}, "BACKGROUND_JOB"},
+		{"unknown call", func(store *fsclient.FileEventStore) ([]fsclient.Event, error) {
+			return store.QueryByUUID("44444444-4444-4444-8444-444444444444")
+		}, ""},
+		{"time range", func(store *fsclient.FileEventStore) ([]fsclient.Event, error) {
+			return store.QueryByTimeRange(time.Unix(10, 0), time.Unix(30, 0))
+		}, "1 4 2 BACKGROUND_JOB"},
+		{"later time range", func(store *fsclient.FileEventStore) ([]fsclient.Event, error) {
+			return store.QueryByTimeRange(time.Unix(30, 0), time.Unix(41, 0))
+		}, "3 5"},
+		{"empty time range", func(store *fsclient.FileEventStore) ([]fsclient.Event, error) {
+			return store.QueryByTimeRange(time.Unix(50, 0), time.Unix(60, 0))
+		}, ""},
+		{"reversed time range", func(store *fsclient.FileEventStore) ([]fsclient.Event, error) {
+			return store.QueryByTimeRange(time.Unix(30, 0), time.Unix(10, 0))
+		}, ""},
+	}
+
+	check := func(t *testing.T, store *fsclient.FileEventStore) {
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				events, err := test.query(store)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := eventIDs(events); got != test.want {
+					t.Errorf("Got events %q, want %q", got, test.want)
+				}
+			})
+		}
+	}
+
+	t.Run("stored", func(t *testing.T) {
+		check(t, store)
+	})
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//A partly written record at the end is discarded when it is reopened.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte{0, 0, 1, 0, 'x'})
+	file.Close()
+	captureLog(t)
+
+	reopened, err := fsclient.OpenFileEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	t.Run("reopened", func(t *testing.T) {
+		check(t, reopened)
+	})
+
+	if err := reopened.Append(fsclient.Event(timedEvent("CHANNEL_HANGUP_COMPLETE", callerUUID, 35, "6"))); err != nil {
+		t.Fatal(err)
+	}
+	events, err := reopened.QueryByUUID(callerUUID)
+	if err != nil || eventIDs(events) != "1 4 3 6" {
+		t.Errorf("Got events %q, %v after appending to the reopened store", eventIDs(events), err)
+	}
+}
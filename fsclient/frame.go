@@ -0,0 +1,225 @@
+package fsclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/textproto"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+//ParseMode controls what the client does with frames it can't parse.
+type ParseMode int
+
+const (
+	//ParseLenient, the default, logs and reports frames that can't be parsed
+	//and carries on with the next frame, so that a long running consumer of
+	//the event firehose isn't interrupted by one bad frame.
+	ParseLenient ParseMode = iota
+
+	//ParseStrict reports frames that can't be parsed, including frames with
+	//any malformed header line or with a Content-Type the client doesn't
+	//know, and then resets the connection, so that problems are noticed in
+	//test environments rather than worked around.
+	ParseStrict
+)
+
+//errUnknownContentType is the Err of a ParseError for a frame with a
+//Content-Type the client doesn't handle.
+var errUnknownContentType = errors.New("Unknown Content-Type")
+
+//maxContentLength is the largest Content-Length accepted, so that a corrupt
+//header can't make the client allocate without limit.
+const maxContentLength = 256 << 20
+
+//Frame is a message read from an event socket connection: a block of headers
+//followed by Content-Length bytes of content.
+type Frame struct {
+	Header  textproto.MIMEHeader
+	Content []byte
+}
+
+//ParseError is a frame that was read whole but couldn't be parsed, either
+//because of malformed header lines, which are skipped, because its content
+//couldn't be decoded, or because its Content-Type isn't known. The stream is
+//still at a frame boundary, so reading can carry on with the next frame.
+type ParseError struct {
+	Lines []string
+	Frame Frame
+	Err   error
+}
+
+//Error returns the reason the frame couldn't be parsed.
+func (err *ParseError) Error() string {
+	if len(err.Lines) > 0 {
+		return "Malformed frame header: " + strconv.Quote(err.Lines[0])
+	}
+	if err.Err == nil {
+		return "Malformed " + err.Frame.ContentType() + " frame"
+	}
+	return "Malformed " + err.Frame.ContentType() + " frame: " + err.Err.Error()
+}
+
+//FramePanicError is the Err of a ParseError for a frame whose parsing or
+//handling panicked. The panic is recovered so that input from the network
+//can't crash the application.
+type FramePanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (err *FramePanicError) Error() string {
+	return fmt.Sprint("Frame handling panic: ", err.Value)
+}
+
+//ReadFrame reads a frame from an event socket connection, or a capture of
+//one. Malformed header lines are skipped and the frame returned with a
+//*ParseError, after which the next frame can be read. Other errors leave the
+//stream in an unknown state.
+func ReadFrame(reader *textproto.Reader) (Frame, error) {
+	header, malformed, err := readHeader(reader)
+	if err != nil {
+		return Frame{}, err
+	}
+
+	content, err := readContent(header, reader.R)
+	if err != nil {
+		return Frame{}, err
+	}
+
+	frame := Frame{Header: header, Content: content}
+	if len(malformed) > 0 {
+		return frame, &ParseError{Lines: malformed, Frame: frame}
+	}
+	return frame, nil
+}
+
+//readHeader reads the header block of a frame up to the blank line that ends
+//it. Lines that aren't "Key: value" headers are returned separately instead
+//of failing the whole frame, as the blank line and Content-Length header still
+//show where the next frame starts.
+func readHeader(reader *textproto.Reader) (textproto.MIMEHeader, []string, error) {
+	header := make(textproto.MIMEHeader)
+	var malformed []string
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			return nil, nil, err
+		}
+		if line == "" { //Empty line means end of headers.
+			return header, malformed, nil
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			malformed = append(malformed, line)
+			continue
+		}
+		header.Add(textproto.CanonicalMIMEHeaderKey(key), strings.TrimSpace(value))
+	}
+}
+
+//ContentType returns the frame's Content-Type header.
+func (frame Frame) ContentType() string {
+	return frame.Header.Get("Content-Type")
+}
+
+//IsEvent returns true if the frame is an event in one of the supported
+//formats.
+func (frame Frame) IsEvent() bool {
+	_, ok := eventCodecs[frame.ContentType()]
+	return ok
+}
+
+//Event decodes an event frame, in the same way the client decodes the events
+//it receives.
+func (frame Frame) Event() (Event, error) {
+	codec, ok := eventCodecs[frame.ContentType()]
+	if !ok {
+		return nil, errors.New("Not an event frame: " + frame.ContentType())
+	}
+	return parseFrame(codec, frame.Content)
+}
+
+//parseFrame decodes an event frame's content with codec, returning a panic
+//in the codec as a *FramePanicError.
+func parseFrame(codec codec, content []byte) (event map[string]string, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			event, err = nil, &FramePanicError{Value: value, Stack: debug.Stack()}
+		}
+	}()
+	return codec.ParseFrame(content)
+}
+
+//readContent reads the Content-Length bytes of content that follow a frame's
+//headers.
+func readContent(header textproto.MIMEHeader, r io.Reader) ([]byte, error) {
+	if header.Get("Content-Length") == "" {
+		return nil, nil
+	}
+
+	//Check that Content-Length is numeric.
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 || length > maxContentLength {
+		return nil, errors.New("Invalid Content-Length: " + header.Get("Content-Length"))
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+//SetParseErrorHandler calls handler for each frame received that couldn't be
+//parsed, as well as logging it. Frames with malformed header lines are still
+//handled using the headers that could be parsed, and events that can't be
+//decoded, or have an unknown Content-Type, are discarded, leaving the
+//connection to carry on with the next frame. Only read errors and an unusable
+//Content-Length, which lose track of where the next frame starts, cause a
+//reconnect, unless SetParseMode has made parsing strict. The handler runs on
+//the read handler goroutine so must not block.
+func (client *Client) SetParseErrorHandler(handler func(err *ParseError)) {
+	client.optMu.Lock()
+	defer client.optMu.Unlock()
+	client.parseHandler = handler
+}
+
+//parseError logs a frame that couldn't be parsed and passes it to the parse
+//error handler if one is set.
+func (client *Client) parseError(err *ParseError) {
+	client.optMu.RLock()
+	handler := client.parseHandler
+	redactor := client.redactor
+	client.optMu.RUnlock()
+
+	if redactor != nil {
+		err = redactor.redactParseError(err)
+	}
+	log.Print(logPrefix, "Parse failure: ", err)
+
+	if handler != nil {
+		handler(err)
+	}
+}
+
+//SetParseMode sets what the client does with frames it can't parse. The
+//default is ParseLenient.
+func (client *Client) SetParseMode(mode ParseMode) {
+	client.optMu.Lock()
+	defer client.optMu.Unlock()
+	client.parseMode = mode
+}
+
+//strictParsing returns true if frames that can't be parsed reset the
+//connection.
+func (client *Client) strictParsing() bool {
+	client.optMu.RLock()
+	defer client.optMu.RUnlock()
+	return client.parseMode == ParseStrict
+}
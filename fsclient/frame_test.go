@@ -0,0 +1,184 @@
+package fsclient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//update rewrites the golden files, once the differences have been checked.
+var update = flag.Bool("update", false, "rewrite the testdata/frames golden files")
+
+//goldenFrame is the parsed form of a frame written to golden files. Event is
+//set for event frames and Content for the others.
+type goldenFrame struct {
+	ContentType string            `json:"content_type"`
+	Header      map[string]string `json:"header"`
+	Event       map[string]string `json:"event,omitempty"`
+	Content     string            `json:"content,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Malformed   []string          `json:"malformed,omitempty"`
+}
+
+//TestFrameGolden checks the frame parser against a corpus of captured event
+//socket frames. Each .frame file holds one or more frames exactly as
+//Freeswitch sent them, and its .golden file the expected result of parsing
+//them as JSON.
+func TestFrameGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "frames", "*.frame"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("No .frame files in testdata/frames")
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".frame")
+		t.Run(name, func(t *testing.T) {
+			got, err := parseGoldenFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			goldenFile := strings.TrimSuffix(file, ".frame") + ".golden"
+			if *update {
+				if err := os.WriteFile(goldenFile, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Parsed frames don't match %s, got:\n%s", goldenFile, got)
+			}
+		})
+	}
+}
+
+//TestFrameRoundTrip checks that each event in the corpus is written by its
+//format's codec as a frame that parses back to the same event.
+func TestFrameRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "frames", "*.frame"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".frame")
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			reader := textproto.NewReader(bufio.NewReader(f))
+			for n := 1; ; n++ {
+				frame, err := ReadFrame(reader)
+				if err == io.EOF {
+					return
+				}
+				var parseErr *ParseError
+				if err != nil && !errors.As(err, &parseErr) {
+					t.Fatal(err)
+				}
+
+				codec, ok := eventCodecs[frame.ContentType()]
+				if !ok {
+					continue
+				}
+				want, err := frame.Event()
+				if err != nil {
+					continue
+				}
+
+				var written bytes.Buffer
+				if err := codec.WriteFrame(&written, want); err != nil {
+					t.Fatalf("Frame %d: %v", n, err)
+				}
+				rewritten, err := ReadFrame(textproto.NewReader(bufio.NewReader(&written)))
+				if err != nil {
+					t.Fatalf("Frame %d: %v\n%s", n, err, written.Bytes())
+				}
+				got, err := rewritten.Event()
+				if err != nil {
+					t.Fatalf("Frame %d: %v\n%s", n, err, written.Bytes())
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("Frame %d doesn't round trip, got %v, want %v", n, got, want)
+				}
+			}
+		})
+	}
+}
+
+//parseGoldenFile parses every frame in a file and returns them encoded as
+//JSON.
+func parseGoldenFile(file string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var frames []goldenFrame
+	reader := textproto.NewReader(bufio.NewReader(f))
+	for {
+		frame, err := ReadFrame(reader)
+		if err == io.EOF {
+			break
+		}
+		//Frames with malformed headers are kept, as the client still
+		//handles them.
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		golden := goldenFrame{ContentType: frame.ContentType(), Header: make(map[string]string)}
+		if parseErr != nil {
+			golden.Malformed = parseErr.Lines
+		}
+		for key := range frame.Header {
+			golden.Header[key] = frame.Header.Get(key)
+		}
+
+		if frame.IsEvent() {
+			event, err := frame.Event()
+			if err != nil {
+				golden.Error = err.Error()
+			}
+			golden.Event = event
+		} else {
+			golden.Content = string(frame.Content)
+		}
+		frames = append(frames, golden)
+	}
+
+	//Keep characters like < and & readable in the golden files.
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(frames); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
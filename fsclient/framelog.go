@@ -0,0 +1,274 @@
+package fsclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//maxRecordedFrame is the number of bytes of each frame kept in the frame
+//history. Longer frames, such as large api replies, are truncated.
+const maxRecordedFrame = 16 * 1024
+
+//maxPendingFrame is the amount of data buffered while waiting for the end of
+//a frame, after which it is recorded as it is, so a stream that can't be
+//split into frames doesn't use memory without limit.
+const maxPendingFrame = 1 << 20
+
+//FrameDirection is whether a recorded frame was sent or received.
+type FrameDirection string
+
+//Frame directions.
+const (
+	FrameSent     FrameDirection = "sent"
+	FrameReceived FrameDirection = "received"
+)
+
+//RecordedFrame is a frame in the client's frame history, as written to or
+//read from the connection. Size is the frame's full size, which is larger
+//than Data if it was truncated.
+type RecordedFrame struct {
+	Time      time.Time
+	Direction FrameDirection
+	Size      int
+	Data      []byte
+}
+
+//frameHistory is a ring of the most recently recorded frames. It is empty
+//while the history is disabled. It has its own lock rather than using the
+//client's options lock as it is used for every read and write.
+type frameHistory struct {
+	frames []RecordedFrame
+	next   int
+	full   bool
+	mu     *sync.Mutex
+}
+
+//resize discards the recorded frames and makes room for n.
+func (history *frameHistory) resize(n int) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	history.frames = make([]RecordedFrame, n)
+	history.next = 0
+	history.full = false
+}
+
+//enabled returns true if frames are being recorded.
+func (history *frameHistory) enabled() bool {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	return len(history.frames) > 0
+}
+
+//add records a frame, replacing the oldest if the ring is full.
+func (history *frameHistory) add(frame RecordedFrame) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	if len(history.frames) == 0 {
+		return
+	}
+	history.frames[history.next] = frame
+	history.next = (history.next + 1) % len(history.frames)
+	if history.next == 0 {
+		history.full = true
+	}
+}
+
+//recent returns the recorded frames, oldest first.
+func (history *frameHistory) recent() []RecordedFrame {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	if !history.full {
+		return append([]RecordedFrame(nil), history.frames[:history.next]...)
+	}
+	frames := append([]RecordedFrame(nil), history.frames[history.next:]...)
+	return append(frames, history.frames[:history.next]...)
+}
+
+//SetFrameHistory keeps the last n frames sent and received on the connection
+//in memory, for DumpRecent to show what the protocol exchange looked like
+//when something unexpected happens. Passwords in auth commands are not kept,
+//and other sensitive values are masked if the client has a Redactor.
+//Zero, the default, disables the history. Changing the size discards the
+//frames recorded so far.
+func (client *Client) SetFrameHistory(n int) {
+	if n < 0 {
+		n = 0
+	}
+	client.frames.resize(n)
+}
+
+//RecentFrames returns the frames in the frame history, oldest first.
+func (client *Client) RecentFrames() []RecordedFrame {
+	return client.frames.recent()
+}
+
+//DumpRecent writes the frames in the frame history to w, oldest first, each
+//preceded by a line with its time, direction and size.
+func (client *Client) DumpRecent(w io.Writer) error {
+	for _, frame := range client.RecentFrames() {
+		size := strconv.Itoa(frame.Size) + " bytes"
+		if len(frame.Data) < frame.Size {
+			size += ", truncated"
+		}
+		if _, err := fmt.Fprintf(w, "%s %s (%s)\n", frame.Time.Format(time.RFC3339Nano), frame.Direction, size); err != nil {
+			return err
+		}
+
+		data := frame.Data
+		if !bytes.HasSuffix(data, []byte("\n")) {
+			data = append(data[:len(data):len(data)], '\n')
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//recordFrame adds a frame to the frame history, if it is enabled.
+func (client *Client) recordFrame(direction FrameDirection, data []byte) {
+	frame := RecordedFrame{Time: client.now(), Direction: direction, Size: len(data)}
+	if bytes.HasPrefix(data, []byte("auth ")) {
+		data = []byte("auth <redacted>\n\n")
+	}
+	//Frames are masked whole, as a truncated event can't be parsed.
+	data = client.redactData(data)
+	if len(data) > maxRecordedFrame {
+		data = data[:maxRecordedFrame]
+	}
+	frame.Data = append([]byte(nil), data...)
+	client.frames.add(frame)
+}
+
+//recordingConn is a connection that splits the data written to and read from
+//it into frames for the client's frame history.
+type recordingConn struct {
+	net.Conn
+	client   *Client
+	sent     *frameSplitter
+	received *frameSplitter
+}
+
+//newRecordingConn wraps a connection to record its frames.
+func newRecordingConn(conn net.Conn, client *Client) *recordingConn {
+	return &recordingConn{
+		Conn:     conn,
+		client:   client,
+		sent:     &frameSplitter{},
+		received: &frameSplitter{},
+	}
+}
+
+//Read reads from the connection, recording the frames read.
+func (conn *recordingConn) Read(p []byte) (int, error) {
+	n, err := conn.Conn.Read(p)
+	if n > 0 {
+		conn.split(conn.received, FrameReceived, p[:n])
+	}
+	return n, err
+}
+
+//Write writes to the connection, recording the frames written.
+func (conn *recordingConn) Write(p []byte) (int, error) {
+	n, err := conn.Conn.Write(p)
+	if n > 0 {
+		conn.split(conn.sent, FrameSent, p[:n])
+	}
+	return n, err
+}
+
+//split feeds data to a splitter and records any frames it completes. Nothing
+//is buffered while the frame history is disabled.
+func (conn *recordingConn) split(splitter *frameSplitter, direction FrameDirection, data []byte) {
+	if !conn.client.frames.enabled() {
+		splitter.reset()
+		return
+	}
+	for _, frame := range splitter.feed(data) {
+		conn.client.recordFrame(direction, frame)
+	}
+}
+
+//frameSplitter splits a stream of event socket data into frames: a header
+//block ended by a blank line and Content-Length bytes of content. It is only
+//used by one goroutine at a time.
+type frameSplitter struct {
+	buf []byte
+}
+
+//feed adds data to the stream and returns the frames it completes.
+func (splitter *frameSplitter) feed(data []byte) [][]byte {
+	splitter.buf = append(splitter.buf, data...)
+
+	var frames [][]byte
+	for {
+		//Skip blank lines between frames, such as the extra one after some
+		//commands.
+		splitter.buf = bytes.TrimLeft(splitter.buf, "\r\n")
+
+		end := headerEnd(splitter.buf)
+		if end < 0 {
+			break
+		}
+		size := end + contentLength(splitter.buf[:end])
+		if len(splitter.buf) < size {
+			break
+		}
+
+		frames = append(frames, splitter.buf[:size:size])
+		splitter.buf = splitter.buf[size:]
+	}
+
+	if len(splitter.buf) > maxPendingFrame {
+		frames = append(frames, splitter.buf)
+		splitter.buf = nil
+	}
+	return frames
+}
+
+//reset discards any partly received frame.
+func (splitter *frameSplitter) reset() {
+	splitter.buf = nil
+}
+
+//headerEnd returns the index just after the blank line ending the header
+//block at the start of buf, or -1 if it isn't complete.
+func headerEnd(buf []byte) int {
+	for i := 0; i < len(buf); i++ {
+		if buf[i] != '\n' {
+			continue
+		}
+		switch {
+		case i+1 < len(buf) && buf[i+1] == '\n':
+			return i + 2
+		case i+2 < len(buf) && buf[i+1] == '\r' && buf[i+2] == '\n':
+			return i + 3
+		}
+	}
+	return -1
+}
+
+//contentLength returns the Content-Length of a header block, or zero if it
+//has none.
+func contentLength(header []byte) int {
+	for _, line := range strings.Split(string(header), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "Content-Length") {
+			continue
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(value))
+		if err == nil && length > 0 {
+			return length
+		}
+	}
+	return 0
+}
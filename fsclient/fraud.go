@@ -0,0 +1,304 @@
+package fsclient
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//defaultPrefixLength is the number of digits of a destination in a
+//CallFact's Prefix.
+const defaultPrefixLength = 4
+
+//CallFactKind is the point in a call a CallFact describes.
+type CallFactKind string
+
+//Call fact kinds.
+const (
+	FactStart  CallFactKind = "start"
+	FactAnswer CallFactKind = "answer"
+	FactHangup CallFactKind = "hangup"
+)
+
+//CallFact is a normalized observation of a call for a CallAnalyzer. Source
+//is the account that placed the call, its registered username if it has one,
+//otherwise its caller ID number. Prefix is the start of the destination,
+//without any leading +. Duration is the talk time and HangupCause is set for
+//FactHangup.
+type CallFact struct {
+	Kind        CallFactKind
+	Time        time.Time
+	UUID        string
+	Source      string
+	Destination string
+	Prefix      string
+	Direction   string
+	Tenant      string
+	Duration    time.Duration
+	HangupCause string
+	Event       Event
+}
+
+//Anomaly is a suspicious call pattern found by a CallAnalyzer.
+type Anomaly struct {
+	Analyzer string
+	Reason   string
+	Fact     CallFact
+}
+
+//CallAnalyzer looks for suspicious patterns in calls, e.g. a burst of
+//international calls from one account. Observe is called with every fact in
+//order, from the dispatcher, so it should not block.
+type CallAnalyzer interface {
+	Observe(fact CallFact) []Anomaly
+}
+
+//CallAnalyzerFunc is a CallAnalyzer implemented by a function.
+type CallAnalyzerFunc func(fact CallFact) []Anomaly
+
+//Observe calls the function.
+func (fn CallAnalyzerFunc) Observe(fact CallFact) []Anomaly {
+	return fn(fact)
+}
+
+//FraudMonitor turns channel events into CallFacts for its analyzers and acts
+//on the anomalies they find: calling OnAnomaly, adding the source to a block
+//list, e.g. one checked by a CallScreener, and hanging up the call. Only the
+//A leg of each call is observed.
+//
+//Register the monitor's HandleEvent method with a Dispatcher. The client must
+//be subscribed to CHANNEL_CREATE, CHANNEL_STATE, CHANNEL_ANSWER and
+//CHANNEL_HANGUP_COMPLETE.
+type FraudMonitor struct {
+	//OnAnomaly, if set, is called with each anomaly found.
+	OnAnomaly func(anomaly Anomaly)
+
+	client       Commander
+	analyzers    []CallAnalyzer
+	blockList    *NumberList
+	hangupCause  string
+	prefixLength int
+	clock        Clock
+	started      map[string]CallFact
+	mu           *sync.Mutex
+}
+
+//NewFraudMonitor creates a FraudMonitor feeding analyzers, which only reports
+//anomalies until SetBlockList or SetHangupCause are used.
+func NewFraudMonitor(client Commander, analyzers ...CallAnalyzer) *FraudMonitor {
+	return &FraudMonitor{
+		client:       client,
+		analyzers:    analyzers,
+		prefixLength: defaultPrefixLength,
+		clock:        SystemClock,
+		started:      make(map[string]CallFact),
+		mu:           &sync.Mutex{},
+	}
+}
+
+//SetClock sets the clock used to time facts.
+func (monitor *FraudMonitor) SetClock(clock Clock) {
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+	monitor.clock = clock
+}
+
+//SetPrefixLength sets the number of destination digits in a fact's Prefix.
+func (monitor *FraudMonitor) SetPrefixLength(length int) {
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+	monitor.prefixLength = length
+}
+
+//SetBlockList sets a list that the source of each anomaly is added to, or
+//stops adding them if list is nil.
+func (monitor *FraudMonitor) SetBlockList(list *NumberList) {
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+	monitor.blockList = list
+}
+
+//SetHangupCause hangs up calls with an anomaly with cause, e.g.
+//CALL_REJECTED, or stops hanging them up if cause is empty.
+func (monitor *FraudMonitor) SetHangupCause(cause string) {
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+	monitor.hangupCause = cause
+}
+
+//HandleEvent builds facts from a call's A leg events and passes them to the
+//analyzers.
+func (monitor *FraudMonitor) HandleEvent(event Event) {
+	uuid := event.UUID()
+	if uuid == "" || event["variable_originator"] != "" || event["variable_originating_leg_uuid"] != "" {
+		return
+	}
+
+	monitor.mu.Lock()
+	start, started := monitor.started[uuid]
+	now := monitor.clock.Now()
+	prefixLength := monitor.prefixLength
+	monitor.mu.Unlock()
+
+	var fact CallFact
+	switch event.Name() {
+	case "CHANNEL_CREATE", "CHANNEL_STATE":
+		if started || event["Caller-Destination-Number"] == "" {
+			return
+		}
+		if event.Name() == "CHANNEL_STATE" && event["Channel-State"] != "CS_ROUTING" {
+			return
+		}
+
+		destination := event["Caller-Destination-Number"]
+		prefix := strings.TrimPrefix(destination, "+")
+		if len(prefix) > prefixLength {
+			prefix = prefix[:prefixLength]
+		}
+		fact = CallFact{
+			Kind:        FactStart,
+			UUID:        uuid,
+			Source:      firstHeader(event, "Caller-Username", "Caller-Caller-ID-Number"),
+			Destination: destination,
+			Prefix:      prefix,
+			Direction:   event["Call-Direction"],
+			Tenant:      event.Tenant(),
+		}
+
+		monitor.mu.Lock()
+		monitor.started[uuid] = fact
+		monitor.mu.Unlock()
+	case "CHANNEL_ANSWER":
+		if !started {
+			return
+		}
+		fact = start
+		fact.Kind = FactAnswer
+	case "CHANNEL_HANGUP_COMPLETE":
+		if !started {
+			return
+		}
+		monitor.mu.Lock()
+		delete(monitor.started, uuid)
+		monitor.mu.Unlock()
+
+		fact = start
+		fact.Kind = FactHangup
+		fact.Duration = time.Duration(int64Header(event, "variable_billmsec")) * time.Millisecond
+		fact.HangupCause = event["Hangup-Cause"]
+	default:
+		return
+	}
+	fact.Time = now
+	fact.Event = event
+
+	for _, analyzer := range monitor.analyzers {
+		for _, anomaly := range analyzer.Observe(fact) {
+			monitor.act(anomaly)
+		}
+	}
+}
+
+//act reports an anomaly and blocks its source or hangs up its call if set
+//to.
+func (monitor *FraudMonitor) act(anomaly Anomaly) {
+	monitor.mu.Lock()
+	blockList, cause := monitor.blockList, monitor.hangupCause
+	monitor.mu.Unlock()
+
+	if monitor.OnAnomaly != nil {
+		monitor.OnAnomaly(anomaly)
+	}
+	if blockList != nil && anomaly.Fact.Source != "" {
+		blockList.Add(anomaly.Fact.Source)
+	}
+	if cause == "" || anomaly.Fact.Kind == FactHangup {
+		return
+	}
+
+	res, err := monitor.client.API("uuid_kill " + anomaly.Fact.UUID + " " + cause)
+	if err == nil && !ParseReply(res).OK {
+		err = errors.New(strings.TrimSpace(res))
+	}
+	if err != nil {
+		log.Print(logPrefix, "Failed to hang up call with anomaly: ", err)
+	}
+}
+
+//VelocityDetector is a CallAnalyzer that finds sources starting more than
+//maxCalls calls within a window, a common sign of a hijacked account. With
+//prefixes, only calls to destinations starting with one of them are counted,
+//e.g. "011" and "00" for international calls.
+type VelocityDetector struct {
+	maxCalls  int
+	window    time.Duration
+	prefixes  []string
+	starts    map[string][]time.Time
+	lastSweep time.Time
+	mu        *sync.Mutex
+}
+
+//NewVelocityDetector creates a VelocityDetector.
+func NewVelocityDetector(maxCalls int, window time.Duration, prefixes ...string) *VelocityDetector {
+	return &VelocityDetector{
+		maxCalls: maxCalls,
+		window:   window,
+		prefixes: prefixes,
+		starts:   make(map[string][]time.Time),
+		mu:       &sync.Mutex{},
+	}
+}
+
+//Observe counts a call start against its source, reporting an anomaly for
+//each call over the limit.
+func (detector *VelocityDetector) Observe(fact CallFact) []Anomaly {
+	if fact.Kind != FactStart || fact.Source == "" || !detector.counts(fact.Destination) {
+		return nil
+	}
+
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+
+	since := fact.Time.Add(-detector.window)
+	if fact.Time.Sub(detector.lastSweep) >= detector.window {
+		for source, starts := range detector.starts {
+			if len(starts) == 0 || !starts[len(starts)-1].After(since) {
+				delete(detector.starts, source)
+			}
+		}
+		detector.lastSweep = fact.Time
+	}
+
+	starts := detector.starts[fact.Source]
+	for len(starts) > 0 && !starts[0].After(since) {
+		starts = starts[1:]
+	}
+	starts = append(starts, fact.Time)
+	detector.starts[fact.Source] = starts
+
+	if len(starts) <= detector.maxCalls {
+		return nil
+	}
+	return []Anomaly{{
+		Analyzer: "velocity",
+		Reason:   strconv.Itoa(len(starts)) + " calls from " + fact.Source + " in " + detector.window.String(),
+		Fact:     fact,
+	}}
+}
+
+//counts returns true if calls to destination are counted.
+func (detector *VelocityDetector) counts(destination string) bool {
+	if len(detector.prefixes) == 0 {
+		return true
+	}
+	destination = strings.TrimPrefix(destination, "+")
+	for _, prefix := range detector.prefixes {
+		if strings.HasPrefix(destination, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,12 +2,12 @@
 package fsclient
 
 import (
+	"context"
 	"errors"
-	"io"
 	"log"
 	"net"
 	"net/textproto"
-	"net/url"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,13 +21,44 @@ var logPrefix = "fsclient: "
 type Client struct {
 	eventConn *textproto.Conn
 	addr      string
-	password  string
+	passwords PasswordProvider
 	cmdResCh  chan cmdRes
 	EventCh   chan map[string]string
 	filters   []string
-	subs      []string
+	subs      []Subscription
 	connMu    *sync.Mutex
 	initFunc  func(*Client)
+	closeCh   chan struct{}
+	closeOnce sync.Once
+	optMu     *sync.RWMutex
+	limiters  map[CommandClass]*TokenBucket
+	retry     RetryPolicy
+	queries   *queryGroup
+	overflow  *DiskQueue
+	drainWg   *sync.WaitGroup
+	authorize Authorizer
+	auditSink AuditSink
+	stats     *commandStats
+	frames    *frameHistory
+	redactor  *Redactor
+
+	gapHandler   func(EventGap)
+	parseHandler func(*ParseError)
+	parseMode    ParseMode
+	rawEventCh   chan<- RawEvent
+	lastSeq      uint64
+	reconnected  bool
+	lostEvents   uint64
+
+	server *serverInfo
+	clock  Clock
+
+	commands      *commandScheduler
+	priorityRules []priorityRule
+
+	inflight       map[uint64]*inflightCommand
+	nextInflightID uint64
+	inflightMu     *sync.Mutex
 }
 
 //cmdRes is a response structure for Freeswitch commands.
@@ -39,14 +70,31 @@ type cmdRes struct {
 
 //NewClient creates a new Freeswitch client with filters, subscriptions and an init function.
 func NewClient(addr string, password string, filters []string, subs []string, eventBufSize int, initFunc func(*Client)) *Client {
+	return NewClientWithPasswordProvider(addr, staticPassword(password), filters, subs, eventBufSize, initFunc)
+}
+
+//NewClientWithPasswordProvider creates a new Freeswitch client like NewClient
+//that gets its password from passwords each time it connects.
+func NewClientWithPasswordProvider(addr string, passwords PasswordProvider, filters []string, subs []string, eventBufSize int, initFunc func(*Client)) *Client {
 	fs := &Client{
-		addr:     addr,
-		password: password,
-		EventCh:  make(chan map[string]string, eventBufSize),
-		filters:  filters,
-		subs:     subs,
-		connMu:   &sync.Mutex{},
-		initFunc: initFunc,
+		addr:      addr,
+		passwords: passwords,
+		EventCh:   make(chan map[string]string, eventBufSize),
+		filters:   append([]string(nil), filters...),
+		subs:      plainSubscriptions(subs),
+		connMu:    &sync.Mutex{},
+		initFunc:  initFunc,
+		closeCh:   make(chan struct{}),
+		optMu:     &sync.RWMutex{},
+		limiters:  make(map[CommandClass]*TokenBucket),
+		drainWg:   &sync.WaitGroup{},
+		commands:  newCommandScheduler(),
+		clock:     SystemClock,
+		stats:     newCommandStats(),
+		frames:    &frameHistory{mu: &sync.Mutex{}},
+
+		inflight:   make(map[uint64]*inflightCommand),
+		inflightMu: &sync.Mutex{},
 	}
 
 	go fs.readHandler()
@@ -76,6 +124,13 @@ func (client *Client) connect() (err error) {
 		client.cmdResCh = nil
 	}
 
+	//Get the current password, which may have changed since we last
+	//connected.
+	password, err := client.passwords.Password()
+	if err != nil {
+		return errors.New("Failed to get password: " + err.Error())
+	}
+
 	//Connect to Freeswitch Event Socket.
 	conn, err := net.DialTimeout("tcp", client.addr, time.Duration(5*time.Second))
 	if err != nil {
@@ -83,7 +138,7 @@ func (client *Client) connect() (err error) {
 	}
 
 	//Convert the raw TCP connection to a textproto connection.
-	eventConn := textproto.NewConn(conn)
+	eventConn := textproto.NewConn(newRecordingConn(conn, client))
 
 	//Read the welcome message.
 	resp, err := eventConn.ReadMIMEHeader()
@@ -92,13 +147,20 @@ func (client *Client) connect() (err error) {
 	}
 
 	//Send authentication request to server.
-	eventConn.PrintfLine("auth %s\r\n", client.password)
+	eventConn.PrintfLine("auth %s\r\n", password)
 	if resp, err = eventConn.ReadMIMEHeader(); err != nil {
 		return
 	}
 
 	//Check the command was processed OK.
-	if resp.Get("Reply-Text") == "+OK accepted" {
+	if ParseReply(resp.Get("Reply-Text")).OK {
+		//If the client was closed while we were connecting then don't make
+		//the connection available as nothing will be reading from it.
+		if client.closed() {
+			eventConn.Close()
+			return errDisconnected
+		}
+
 		//The connection is now ready to be used, make available for use
 		//and also create a new cmd response channel.
 		client.eventConn = eventConn
@@ -106,19 +168,27 @@ func (client *Client) connect() (err error) {
 		return
 	}
 
+	//Close the rejected connection, otherwise retrying with a rotated
+	//password leaks a connection each time.
+	eventConn.Close()
 	return errors.New("Authentication failed: " + resp.Get("Reply-Text"))
 }
 
 //setupFilters configures which events to receive from Freeswitch.
 func (client *Client) setupFilters() {
 	log.Print(logPrefix, "Setting up filters...")
-	for _, filter := range client.filters {
+	client.optMu.RLock()
+	filters := append([]string(nil), client.filters...)
+	subs := append([]Subscription(nil), client.subs...)
+	client.optMu.RUnlock()
+
+	for _, filter := range filters {
 		if err := client.addFilter(filter); err != nil {
 			log.Print(logPrefix, err)
 		}
 	}
 
-	for _, sub := range client.subs {
+	for _, sub := range subs {
 		if err := client.subcribeEvent(sub); err != nil {
 			log.Print(logPrefix, err)
 		}
@@ -126,6 +196,25 @@ func (client *Client) setupFilters() {
 	log.Print(logPrefix, "Filters setup")
 }
 
+//AddFilter adds a filter to the connection at runtime, in the same
+//"<header> <value>" format as the filters passed to NewClient. The filter is
+//remembered and reapplied after reconnecting, so if the client is currently
+//disconnected the error can be ignored.
+func (client *Client) AddFilter(arg string) error {
+	client.optMu.Lock()
+	client.filters = append(client.filters, arg)
+	client.optMu.Unlock()
+	return client.addFilter(arg)
+}
+
+//Subscribe enables events by class or all at runtime, in the same format as
+//the subscriptions passed to NewClient. The subscription is remembered and
+//reapplied after reconnecting, so if the client is currently disconnected the
+//error can be ignored.
+func (client *Client) Subscribe(arg string) error {
+	return client.SubscribeFormat(FormatPlain, arg)
+}
+
 //AddFilter specifies event types to listen for.
 //Note, this is not a filter out but rather a "filter in," that is, when a
 //filter is applied only the filtered values are received.
@@ -134,6 +223,10 @@ func (client *Client) addFilter(arg string) (err error) {
 	client.connMu.Lock()
 	defer client.connMu.Unlock()
 
+	if client.cmdResCh == nil {
+		return errDisconnected
+	}
+
 	//Send filter command to server.
 	client.eventConn.PrintfLine("filter %s\r\n", arg)
 	body, err := client.readCmdRes()
@@ -147,12 +240,16 @@ func (client *Client) addFilter(arg string) (err error) {
 }
 
 //SubcribeEvent enables events by class or all.
-func (client *Client) subcribeEvent(arg string) (err error) {
+func (client *Client) subcribeEvent(sub Subscription) (err error) {
 	client.connMu.Lock()
 	defer client.connMu.Unlock()
 
+	if client.cmdResCh == nil {
+		return errDisconnected
+	}
+
 	//Send event command to server.
-	client.eventConn.PrintfLine("event plain %s\r\n", arg)
+	client.eventConn.PrintfLine("event %s %s\r\n", sub.Format, sub.Events)
 	body, _ := client.readCmdRes()
 
 	//Check the command was processed OK.
@@ -160,7 +257,7 @@ func (client *Client) subcribeEvent(arg string) (err error) {
 		return
 	}
 
-	return errors.New("Failed subcribe to event '" + arg + "': " + body)
+	return errors.New("Failed subcribe to event '" + sub.Events + "': " + body)
 }
 
 //readCmdRes waits until Freeswitch delivers a command response message.
@@ -168,7 +265,12 @@ func (client *Client) subcribeEvent(arg string) (err error) {
 //indicating that we have been disconnected from the server, at which point a
 //errDisconnected response is delivered instead.
 func (client *Client) readCmdRes() (string, error) {
-	res := <-client.cmdResCh
+	return cmdResult(<-client.cmdResCh)
+}
+
+//cmdResult converts a command response message to its body, or to
+//errDisconnected if it is the zero response of a closed cmdResCh channel.
+func cmdResult(res cmdRes) (string, error) {
 	if res.body == "" && res.err == nil {
 		return "", errDisconnected
 	}
@@ -177,55 +279,151 @@ func (client *Client) readCmdRes() (string, error) {
 
 //API sends an api command (blocking mode).
 func (client *Client) API(cmd string) (string, error) {
-	client.connMu.Lock()
-	defer client.connMu.Unlock()
+	return client.APIContext(context.Background(), cmd)
+}
 
-	//If the command response channel is not initialised then it means we
-	//are not connected. So no point in sending a command.
-	if client.cmdResCh == nil {
-		return "", errDisconnected
+//APIContext sends an api command (blocking mode), passing ctx to the
+//authorizer if one is set.
+func (client *Client) APIContext(ctx context.Context, cmd string) (res string, err error) {
+	command := parseCommand(ClassAPI, cmd)
+	defer func(start time.Time) { client.audit(ctx, command, start, res, err) }(client.now())
+
+	authorized, err := client.authorizeCommand(ctx, command)
+	if err != nil {
+		return "", err
 	}
-	client.eventConn.PrintfLine("api %s\r\n", cmd)
-	return client.readCmdRes()
+	command = authorized
+	cmd = command.String()
+
+	client.optMu.RLock()
+	queries := client.queries
+	client.optMu.RUnlock()
+
+	//Read-only queries may be shared with other callers.
+	if queries != nil && queries.matches(cmd) {
+		return queries.do(cmd, func(cmd string) (string, error) {
+			return client.withRetry(cmd, client.api)
+		})
+	}
+	return client.withRetry(cmd, client.api)
+}
+
+//apiOK sends an api command that replies "+OK" on success, converting any
+//other reply to an error.
+func (client *Client) apiOK(cmd string) error {
+	res, err := client.API(cmd)
+	if err != nil {
+		return err
+	}
+	if !ParseReply(res).OK {
+		return errors.New(strings.TrimSpace(res))
+	}
+	return nil
+}
+
+//api sends a single api command attempt. The returned bool indicates whether
+//the command was written to the connection.
+func (client *Client) api(cmd string) (string, bool, error) {
+	client.rateLimit(ClassAPI)
+	res, sent, err := client.sendCommand(parseCommand(ClassAPI, cmd), func() {
+		client.eventConn.PrintfLine("api %s\r\n", cmd)
+	})
+	if err != nil {
+		return "", sent, err
+	}
+	body, err := cmdResult(res)
+	return body, true, err
 }
 
 //BackgroundAPI sends a bgapi command (async mode).
 //You need to subscribe to BACKGROUND_JOB events to get the actual response.
 func (client *Client) BackgroundAPI(cmd string) (string, error) {
-	client.connMu.Lock()
-	defer client.connMu.Unlock()
+	return client.BackgroundAPIContext(context.Background(), cmd)
+}
 
-	//If the command response channel is not initialised then it means we
-	//are not connected. So no point in sending a command.
-	if client.cmdResCh == nil {
-		return "", errDisconnected
+//BackgroundAPIContext sends a bgapi command (async mode), passing ctx to the
+//authorizer if one is set.
+func (client *Client) BackgroundAPIContext(ctx context.Context, cmd string) (jobUUID string, err error) {
+	command := parseCommand(ClassBGAPI, cmd)
+	defer func(start time.Time) { client.audit(ctx, command, start, jobUUID, err) }(client.now())
+
+	authorized, err := client.authorizeCommand(ctx, command)
+	if err != nil {
+		return "", err
 	}
-	client.eventConn.PrintfLine("bgapi %s\r\n", cmd)
-	return client.readBackgroundAPIRes()
+	command = authorized
+	return client.withRetry(command.String(), client.backgroundAPI)
 }
 
-//readBackgroundAPIRes waits until Freeswitch delivers a bgapi response message.
-//It will block until a message arrives or until the cmdResCh channel is closed
-//indicating that we have been disconnected from the server, at which point a
-//errDisconnected response is delivered instead.
-func (client *Client) readBackgroundAPIRes() (string, error) {
-	res := <-client.cmdResCh
+//backgroundAPI sends a single bgapi command attempt. The returned bool
+//indicates whether the command was written to the connection.
+func (client *Client) backgroundAPI(cmd string) (string, bool, error) {
+	client.rateLimit(ClassBGAPI)
+	res, sent, err := client.sendCommand(parseCommand(ClassBGAPI, cmd), func() {
+		client.eventConn.PrintfLine("bgapi %s\r\n", cmd)
+	})
+	if err != nil {
+		return "", sent, err
+	}
+	jobUUID, err := backgroundAPIResult(res)
+	return jobUUID, true, err
+}
+
+//backgroundAPIResult converts a bgapi command response message to its Job
+//UUID, or to errDisconnected if it is the zero response of a closed cmdResCh
+//channel.
+func backgroundAPIResult(res cmdRes) (string, error) {
 	if res.body == "" && res.err == nil {
 		return "", errDisconnected
 	}
 
 	//If no other error found, but response body doesn't start with "+OK",
 	//then convert the res.body to an error and return it with empty Job UUID.
-	if res.err == nil && !strings.HasPrefix(res.body, "+OK") {
+	reply := ParseReply(res.body)
+	if res.err == nil && !reply.OK {
 		return "", errors.New(res.body)
 	}
 
+	//Older versions of Freeswitch only return the Job UUID in the reply text
+	//("+OK Job-UUID: <uuid>"), not in a Job-UUID header.
+	if res.jobUUID == "" {
+		res.jobUUID = reply.UUID
+	}
+
 	//Otherwise pass through the upstream response Job UUID and error (if any).
 	return res.jobUUID, res.err
 }
 
 //Execute is used to execute dialplan applications on a channel.
 func (client *Client) Execute(app string, arg string, uuid string, lock bool) (string, error) {
+	return client.execute(context.Background(), app, arg, uuid, lock, "")
+}
+
+//ExecuteContext executes a dialplan application on a channel, passing ctx to
+//the authorizer if one is set.
+func (client *Client) ExecuteContext(ctx context.Context, app string, arg string, uuid string, lock bool) (string, error) {
+	return client.execute(ctx, app, arg, uuid, lock, "")
+}
+
+//execute sends an execute command. If eventUUID is set it is sent with the
+//command and returned in the Application-UUID header of the application's
+//CHANNEL_EXECUTE and CHANNEL_EXECUTE_COMPLETE events. An arg spanning several
+//lines, such as a long SQL statement for the db application, is sent as the
+//command's body as it can't be sent in a header.
+func (client *Client) execute(ctx context.Context, app string, arg string, uuid string, lock bool, eventUUID string) (res string, err error) {
+	command := Command{Class: ClassExecute, Name: app, Args: arg, UUID: uuid}
+	defer func(start time.Time) { client.audit(ctx, command, start, res, err) }(client.now())
+
+	authorized, err := client.authorizeCommand(ctx, command)
+	if err != nil {
+		return "", err
+	}
+	command = authorized
+	app, arg, uuid = command.Name, command.Args, command.UUID
+
+	client.rateLimit(ClassExecute)
+	client.commands.acquire(PriorityHigh)
+	defer client.commands.release()
 	client.connMu.Lock()
 	defer client.connMu.Unlock()
 
@@ -236,24 +434,65 @@ func (client *Client) Execute(app string, arg string, uuid string, lock bool) (s
 	}
 
 	//Send execute command to server.
-	client.eventConn.PrintfLine("sendmsg %s", uuid)
-	client.eventConn.PrintfLine("call-command: execute")
-	client.eventConn.PrintfLine("execute-app-name: %s", app)
+	client.eventConn.W.WriteString(executeFrame(app, arg, uuid, lock, eventUUID))
+	if err := client.eventConn.W.Flush(); err != nil {
+		return "", err
+	}
+	return client.readCmdRes()
+}
 
-	if arg != "" {
-		client.eventConn.PrintfLine("execute-app-arg: %s", arg)
+//executeFrame returns the sendmsg command that executes an application. An
+//arg spanning several lines is sent as the command's body.
+func executeFrame(app string, arg string, uuid string, lock bool, eventUUID string) string {
+	var frame strings.Builder
+	frame.WriteString("sendmsg " + uuid + "\r\n")
+	frame.WriteString("call-command: execute\r\n")
+	frame.WriteString("execute-app-name: " + app + "\r\n")
+
+	multiline := strings.ContainsAny(arg, "\r\n")
+	if multiline {
+		frame.WriteString("content-type: text/plain\r\n")
+		frame.WriteString("content-length: " + strconv.Itoa(len(arg)) + "\r\n")
+	} else if arg != "" {
+		frame.WriteString("execute-app-arg: " + arg + "\r\n")
 	}
 
 	if lock {
-		client.eventConn.PrintfLine("event-lock: true")
+		frame.WriteString("event-lock: true\r\n")
 	}
 
-	client.eventConn.PrintfLine("") //Empty line indicates end of command.
-	return client.readCmdRes()
+	if eventUUID != "" {
+		frame.WriteString("Event-UUID: " + eventUUID + "\r\n")
+	}
+
+	frame.WriteString("\r\n") //Empty line indicates end of command.
+	if multiline {
+		frame.WriteString(arg)
+	}
+	return frame.String()
 }
 
 //SendEvent is used to send an event into the event system.
 func (client *Client) SendEvent(eventName string, eventParams map[string]string, eventBody string) (string, error) {
+	return client.SendEventContext(context.Background(), eventName, eventParams, eventBody)
+}
+
+//SendEventContext sends an event into the event system, passing ctx to the
+//authorizer if one is set.
+func (client *Client) SendEventContext(ctx context.Context, eventName string, eventParams map[string]string, eventBody string) (res string, err error) {
+	command := Command{Class: ClassSendEvent, Name: eventName}
+	defer func(start time.Time) { client.audit(ctx, command, start, res, err) }(client.now())
+
+	authorized, err := client.authorizeCommand(ctx, command)
+	if err != nil {
+		return "", err
+	}
+	command = authorized
+	eventName = command.Name
+
+	client.rateLimit(ClassSendEvent)
+	client.commands.acquire(PriorityNormal)
+	defer client.commands.release()
 	client.connMu.Lock()
 	defer client.connMu.Unlock()
 
@@ -282,133 +521,209 @@ func (client *Client) SendEvent(eventName string, eventParams map[string]string,
 	return client.readCmdRes()
 }
 
+//Close permanently disconnects the client from Freeswitch and stops it from
+//reconnecting. Any commands waiting for a response are returned with an error
+//and EventCh is closed once the read handler has stopped.
+func (client *Client) Close() {
+	client.closeOnce.Do(func() {
+		close(client.closeCh)
+
+		//Closing the connection unblocks the read handler so it can exit.
+		client.connMu.Lock()
+		if client.eventConn != nil {
+			client.eventConn.Close()
+		}
+		client.connMu.Unlock()
+	})
+}
+
+//closed returns true if Close has been called on the client.
+func (client *Client) closed() bool {
+	select {
+	case <-client.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+//shutdown releases any waiting commands and resets the connection state once
+//the client has been closed.
+func (client *Client) shutdown() {
+	if client.cmdResCh != nil {
+		close(client.cmdResCh)
+	}
+
+	client.connMu.Lock()
+	defer client.connMu.Unlock()
+
+	if client.eventConn != nil {
+		client.eventConn.Close()
+		client.eventConn = nil
+	}
+	client.cmdResCh = nil
+
+	//Wait for the overflow queue to stop delivering before closing EventCh.
+	client.drainWg.Wait()
+	close(client.EventCh)
+}
+
 //readHandler receives messages from Freeswitch and distributes them.
 func (client *Client) readHandler() {
+	defer client.shutdown()
+
 ConnectLoop:
 	for {
+		if client.closed() {
+			return
+		}
+
 		log.Print(logPrefix, "Connecting...")
 		err := client.connect()
 		if err != nil {
 			log.Print(logPrefix, "Failed to connect: ", err)
-			time.Sleep(2 * time.Second)
+			select {
+			case <-client.after(2 * time.Second):
+			case <-client.closeCh:
+			}
 			continue ConnectLoop
 		}
 		log.Print(logPrefix, "Connected OK")
+		client.reconnected = true
 		go client.setupFilters()
+		go client.detectServer()
 		go client.initFunc(client)
 
 		//Read next message off Freeswitch connection.
-	MsgLoop:
 		for {
-			resp, err := client.eventConn.ReadMIMEHeader()
+			resp, malformed, err := readHeader(&client.eventConn.Reader)
 			if err != nil {
 				log.Print(logPrefix, "Read failure: ", err)
 				continue ConnectLoop
 			}
 
-			if resp.Get("Content-Type") == "text/event-plain" {
-				if err := client.handleEventMsg(resp); err != nil {
-					continue ConnectLoop
-				}
-			} else if resp.Get("Content-Type") == "api/response" {
-				if err := client.handleAPIMsg(resp); err != nil {
+			//The rest of the frame is still handled, so a reply with a
+			//malformed header line still goes to its command, unless parsing
+			//is strict.
+			if len(malformed) > 0 {
+				client.parseError(&ParseError{Lines: malformed, Frame: Frame{Header: resp}})
+				if client.strictParsing() {
 					continue ConnectLoop
 				}
-			} else if resp.Get("Content-Type") == "command/reply" {
-				client.cmdResCh <- cmdRes{
-					body:    resp.Get("Reply-Text"),
-					jobUUID: resp.Get("Job-UUID"),
-					err:     err,
-				}
-				continue MsgLoop
-			} else if resp.Get("Content-Type") == "text/disconnect-notice" {
-				log.Print(logPrefix, "Freeswitch shutting down...")
-				continue MsgLoop //Get any final messages before it disconnects.
-			} else {
-				log.Print(logPrefix, resp.Get("Content-Type"))
+			}
+
+			if err := client.handleFrame(resp); err != nil {
+				continue ConnectLoop
 			}
 		}
 	}
 }
 
+//handleFrame handles a frame read from the connection, returning an error if
+//the connection must be reset. A panic while handling it is recovered and
+//reported as a ParseError, so bad input can't stop the read handler, and the
+//connection is reset as the stream may no longer be at a frame boundary.
+func (client *Client) handleFrame(resp textproto.MIMEHeader) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &FramePanicError{Value: value, Stack: debug.Stack()}
+			client.parseError(&ParseError{Frame: Frame{Header: resp}, Err: err})
+		}
+	}()
+
+	if codec, ok := eventCodecs[resp.Get("Content-Type")]; ok {
+		return client.handleEventMsg(resp, codec)
+	}
+
+	switch resp.Get("Content-Type") {
+	case "api/response":
+		return client.handleAPIMsg(resp)
+	case "command/reply":
+		client.cmdResCh <- cmdRes{
+			body:    resp.Get("Reply-Text"),
+			jobUUID: resp.Get("Job-UUID"),
+		}
+	case "text/disconnect-notice":
+		//Carry on reading to get any final messages before it disconnects.
+		log.Print(logPrefix, "Freeswitch shutting down...")
+	default:
+		return client.handleUnknownMsg(resp)
+	}
+	return nil
+}
+
 //deliverEvent sends an event to the EventCh channel, logs discarded messages.
 func (client *Client) deliverEvent(event map[string]string) {
+	if client.spillEvent(event) {
+		return
+	}
+
 	chanLen := len(client.EventCh)
 	select {
 	case client.EventCh <- event:
-	case <-time.After(1 * time.Second): //Wait up to 1s to deliver to channel.
+	case <-client.after(1 * time.Second): //Wait up to 1s to deliver to channel.
 		log.Print(logPrefix, "Error Event channel blocked (", chanLen,
 			" items), discarded Event: ", event["Unique-ID"], " ", event["Event-Name"])
 	}
 }
 
-//handleEventMsg processes event messages received from Freeswitch.
-func (client *Client) handleEventMsg(resp textproto.MIMEHeader) error {
-	event := make(map[string]string)
-	bodyLength := 0
-
-	//Check that Content-Length is numeric.
-	_, err := strconv.Atoi(resp.Get("Content-Length"))
+//handleEventMsg processes event messages received from Freeswitch, decoding
+//their content with the codec for their Content-Type.
+func (client *Client) handleEventMsg(resp textproto.MIMEHeader, codec codec) error {
+	buf, err := readContent(resp, client.eventConn.R)
 	if err != nil {
-		log.Print(logPrefix, "Invalid Content-Length", err)
+		log.Print(logPrefix, "Event Read failure: ", err)
 		return err
 	}
 
-	for {
-		//Read each line of the event and store into map.
-		line, err := client.eventConn.ReadLine()
-		if err != nil {
-			log.Print(logPrefix, "Event Read failure: ", err)
-			return err
-		}
-
-		if line == "" { //Empty line means end of event headers.
-			//If the bodyLength has been set greater than zero, then read the
-			//body of the event into a special key called "body".
-			if bodyLength > 0 {
-				buf := make([]byte, bodyLength)
-				client.eventConn.Reader.R.Read(buf)
-				event["body-string"] = string(buf)
-			}
-			client.deliverEvent(event)
-			return err
-		}
-
-		parts := strings.Split(line, ": ") //Split "Key: value"
-		key := parts[0]
-		value, err := url.QueryUnescape(parts[1])
-
-		if err != nil {
-			log.Print(logPrefix, "Parse failure: ", err)
-			return err
-		}
-
-		//If the header key indicates there is additional content at the end
-		//of this message, then convert it to an integer for reading later.
-		if key == "Content-Length" || key == "content-length" {
-			bodyLength, _ = strconv.Atoi(value)
+	//A malformed event leaves the connection in a good state, so is
+	//discarded without reconnecting unless parsing is strict.
+	event, err := parseFrame(codec, buf)
+	if err != nil {
+		perr := &ParseError{Frame: Frame{Header: resp, Content: buf}, Err: err}
+		client.parseError(perr)
+		if client.strictParsing() {
+			return perr
 		}
-
-		event[key] = value
+		return nil
 	}
+
+	client.checkSequence(event)
+	client.deliverEvent(event)
+	return nil
 }
 
 //handleAPIMsg processes API response messages received from Freeswitch.
 //It delivers the response to the waiting function via the cmdResCh channel.
 func (client *Client) handleAPIMsg(resp textproto.MIMEHeader) error {
-	//Check that Content-Length is numeric.
-	length, err := strconv.Atoi(resp.Get("Content-Length"))
+	buf, err := readContent(resp, client.eventConn.R)
 	if err != nil {
-		log.Print(logPrefix, "Invalid Content-Length", err)
-		client.cmdResCh <- cmdRes{body: "", err: err}
-		return err
-	}
-
-	//Read Content-Length bytes into a buffer and convert to string.
-	buf := make([]byte, length)
-	if _, err = io.ReadFull(client.eventConn.R, buf); err != nil {
 		log.Print(logPrefix, "API Read failure: ", err)
 	}
 	client.cmdResCh <- cmdRes{body: string(buf), err: err}
 	return err
 }
+
+//handleUnknownMsg reads a frame with a Content-Type the client doesn't handle
+//and passes it to the raw event channel, or reports it if there isn't one.
+//Its content is read so the stream stays at a frame boundary, and the
+//connection is only reset if parsing is strict.
+func (client *Client) handleUnknownMsg(resp textproto.MIMEHeader) error {
+	buf, err := readContent(resp, client.eventConn.R)
+	if err != nil {
+		log.Print(logPrefix, "Read failure: ", err)
+		return err
+	}
+
+	frame := Frame{Header: resp, Content: buf}
+	if client.deliverRawEvent(frame) {
+		return nil
+	}
+
+	perr := &ParseError{Frame: frame, Err: errUnknownContentType}
+	client.parseError(perr)
+	if client.strictParsing() {
+		return perr
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package fsclienttest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tomponline/fsclient/fsclient"
+)
+
+//Clock is a fake fsclient.Clock whose time only moves when Advance is called,
+//so reconnect delays, retry backoff, rate limits and timeouts can be tested
+//without waiting for them:
+//
+//	clock := fsclienttest.NewClock(time.Unix(0, 0))
+//	client.SetClock(clock)
+//	clock.WaitForTimers(1)
+//	clock.Advance(2 * time.Second)
+type Clock struct {
+	now    time.Time
+	timers []*timer
+	seq    int
+	mu     *sync.Mutex
+	cond   *sync.Cond
+}
+
+var _ fsclient.Clock = (*Clock)(nil)
+
+//timer is a pending After channel or AfterFunc call.
+type timer struct {
+	when  time.Time
+	seq   int
+	ch    chan time.Time
+	fn    func()
+	clock *Clock
+}
+
+//NewClock creates a fake clock starting at start.
+func NewClock(start time.Time) *Clock {
+	clock := &Clock{now: start, mu: &sync.Mutex{}}
+	clock.cond = sync.NewCond(clock.mu)
+	return clock
+}
+
+//Now returns the fake time.
+func (clock *Clock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+//After returns a channel that receives the fake time once the clock has been
+//advanced by d.
+func (clock *Clock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	clock.add(&timer{ch: ch}, d)
+	return ch
+}
+
+//AfterFunc calls fn once the clock has been advanced by d. Unlike
+//time.AfterFunc, fn is called synchronously by Advance.
+func (clock *Clock) AfterFunc(d time.Duration, fn func()) fsclient.Timer {
+	t := &timer{fn: fn}
+	clock.add(t, d)
+	return t
+}
+
+//Advance moves the clock forward by d, firing the timers that are due in the
+//order they are due.
+func (clock *Clock) Advance(d time.Duration) {
+	clock.mu.Lock()
+	end := clock.now.Add(d)
+	for len(clock.timers) > 0 && !clock.timers[0].when.After(end) {
+		t := clock.timers[0]
+		clock.timers = clock.timers[1:]
+		clock.now = t.when
+		clock.mu.Unlock()
+
+		if t.fn != nil {
+			t.fn()
+		} else {
+			t.ch <- t.when
+		}
+
+		clock.mu.Lock()
+	}
+	clock.now = end
+	clock.mu.Unlock()
+}
+
+//Timers returns the number of timers that haven't fired or been stopped.
+func (clock *Clock) Timers() int {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return len(clock.timers)
+}
+
+//WaitForTimers blocks until at least n timers are pending, e.g. until the
+//code under test is waiting on the clock, so that Advance fires its timer.
+func (clock *Clock) WaitForTimers(n int) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	for len(clock.timers) < n {
+		clock.cond.Wait()
+	}
+}
+
+//add schedules a timer d after the fake time. A timer that isn't in the
+//future fires on the next Advance, even of zero.
+func (clock *Clock) add(t *timer, d time.Duration) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	clock.seq++
+	t.when = clock.now.Add(d)
+	t.seq = clock.seq
+	t.clock = clock
+	clock.timers = append(clock.timers, t)
+	sort.Slice(clock.timers, func(i, j int) bool {
+		if clock.timers[i].when.Equal(clock.timers[j].when) {
+			return clock.timers[i].seq < clock.timers[j].seq
+		}
+		return clock.timers[i].when.Before(clock.timers[j].when)
+	})
+	clock.cond.Broadcast()
+}
+
+//Stop cancels an AfterFunc call, returning false if it has already been
+//called or stopped.
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,335 @@
+//Package fsclienttest provides a fake in-memory fsclient.ESLClient for unit
+//testing code that controls calls, such as IVR flows and diallers, without a
+//Freeswitch server:
+//
+//	client := fsclienttest.NewClient()
+//	session := fsclient.NewSession(client, uuid)
+//	client.OnEvent(session.HandleEvent)
+//	client.Complete("answer", nil)
+//	client.CompleteDigits("1")
+//	err := ivr.Run(session, menu)
+//
+//Replies are scripted per command and events are injected synchronously into
+//the registered event handlers, so tests run without goroutines or sockets.
+package fsclienttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tomponline/fsclient/fsclient"
+)
+
+//eventBufSize is the size of the fake client's EventCh buffer.
+const eventBufSize = 100
+
+//Call is a command the fake client has received. EventUUID is the event UUID
+//sent with an execute command, if any.
+type Call struct {
+	fsclient.Command
+	EventUUID string
+}
+
+//Handler scripts the reply to a command. For api, execute, sendevent and raw
+//commands the reply is returned to the caller. For bgapi commands it is the
+//job result, delivered in a BACKGROUND_JOB event. A non-nil error is
+//returned to the caller instead.
+type Handler func(call Call) (string, error)
+
+//route is a scripted handler for commands of a class with a name.
+type route struct {
+	class   fsclient.CommandClass
+	name    string
+	handler Handler
+}
+
+//matches returns true if the route handles a command.
+func (route route) matches(call Call) bool {
+	return route.class == call.Class && (route.name == "" || route.name == call.Name)
+}
+
+//Client is a fake fsclient.ESLClient. Commands are answered by the handler
+//scripted for them, and Default if there is none. Unscripted commands return
+//an error if Default isn't set.
+type Client struct {
+	EventCh  chan map[string]string
+	Default  Handler
+	routes   []route
+	once     []route
+	calls    []Call
+	handlers []fsclient.EventHandler
+	jobs     int
+	closed   bool
+	mu       *sync.Mutex
+}
+
+var _ fsclient.ESLClient = (*Client)(nil)
+
+//NewClient creates a fake client with no scripted replies.
+func NewClient() *Client {
+	return &Client{
+		EventCh: make(chan map[string]string, eventBufSize),
+		mu:      &sync.Mutex{},
+	}
+}
+
+//Handle scripts the reply to commands of class named name, which is the api
+//or bgapi command (e.g. "uuid_kill"), the application of an execute command,
+//the event name of a sendevent or the first word of a raw command. An empty
+//name matches every command of the class. Later handlers take precedence.
+func (client *Client) Handle(class fsclient.CommandClass, name string, handler Handler) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.routes = append(client.routes, route{class: class, name: name, handler: handler})
+}
+
+//Reply scripts a fixed reply to commands of class named name, see Handle.
+func (client *Client) Reply(class fsclient.CommandClass, name string, reply string) {
+	client.Handle(class, name, func(call Call) (string, error) {
+		return reply, nil
+	})
+}
+
+//Once scripts the reply to the next command of class named name, see
+//Handle. Replies scripted with Once are used in the order they were scripted
+//and take precedence over those scripted with Handle.
+func (client *Client) Once(class fsclient.CommandClass, name string, handler Handler) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.once = append(client.once, route{class: class, name: name, handler: handler})
+}
+
+//Complete scripts executes of app to reply "+OK" and complete straight away,
+//injecting a CHANNEL_EXECUTE_COMPLETE event for the channel. headers, if not
+//nil, returns extra headers for the event, such as the variables set by the
+//application.
+func (client *Client) Complete(app string, headers func(call Call) map[string]string) {
+	client.Handle(fsclient.ClassExecute, app, client.completer(headers))
+}
+
+//CompleteDigits scripts the next play_and_get_digits to complete with digits
+//as the caller's input. A sequence of inputs, such as menu choices, is
+//scripted by calling it for each in order.
+func (client *Client) CompleteDigits(digits string) {
+	client.Once(fsclient.ClassExecute, "play_and_get_digits", client.completer(func(call Call) map[string]string {
+		//The variable to store the input in is the eighth argument.
+		args := strings.Fields(call.Args)
+		if len(args) < 8 {
+			return nil
+		}
+		return map[string]string{"variable_" + args[7]: digits}
+	}))
+}
+
+//completer returns a handler that completes an execute with the extra
+//headers returned by headers.
+func (client *Client) completer(headers func(call Call) map[string]string) Handler {
+	return func(call Call) (string, error) {
+		event := map[string]string{
+			"Event-Name":       "CHANNEL_EXECUTE_COMPLETE",
+			"Unique-ID":        call.UUID,
+			"Application":      call.Name,
+			"Application-Data": call.Args,
+			"Application-UUID": call.EventUUID,
+		}
+		if headers != nil {
+			for key, value := range headers(call) {
+				event[key] = value
+			}
+		}
+		client.Inject(event)
+		return "+OK", nil
+	}
+}
+
+//OnEvent registers a handler to be run on every injected event, such as a
+//Session's or CallManager's HandleEvent method.
+func (client *Client) OnEvent(handler fsclient.EventHandler) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.handlers = append(client.handlers, handler)
+}
+
+//Inject runs the event handlers on an event before returning, then delivers
+//it to EventCh if there is room and the client hasn't been closed.
+func (client *Client) Inject(event map[string]string) {
+	client.mu.Lock()
+	handlers := client.handlers
+	client.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(fsclient.Event(event))
+	}
+
+	//The lock is held across the send so Close can't close EventCh while
+	//it is being sent on.
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.closed {
+		return
+	}
+	select {
+	case client.EventCh <- event:
+	default:
+	}
+}
+
+//Hangup injects a CHANNEL_HANGUP_COMPLETE event for the channel uuid.
+func (client *Client) Hangup(uuid string, cause string) {
+	client.Inject(map[string]string{
+		"Event-Name":    "CHANNEL_HANGUP_COMPLETE",
+		"Unique-ID":     uuid,
+		"Channel-State": "CS_REPORTING",
+		"Hangup-Cause":  cause,
+	})
+}
+
+//Calls returns the commands the client has received, in order.
+func (client *Client) Calls() []Call {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return append([]Call(nil), client.calls...)
+}
+
+//call records a command and runs its handler.
+func (client *Client) call(call Call) (string, error) {
+	client.mu.Lock()
+	client.calls = append(client.calls, call)
+	handler := client.Default
+	for i := len(client.routes) - 1; i >= 0; i-- {
+		if client.routes[i].matches(call) {
+			handler = client.routes[i].handler
+			break
+		}
+	}
+	for i, route := range client.once {
+		if route.matches(call) {
+			handler = route.handler
+			client.once = append(client.once[:i], client.once[i+1:]...)
+			break
+		}
+	}
+	client.mu.Unlock()
+
+	if handler == nil {
+		return "", errors.New("No reply scripted for " + call.Class.String() + " " + call.Name)
+	}
+	return handler(call)
+}
+
+//command splits a command line into a Command.
+func command(class fsclient.CommandClass, line string) fsclient.Command {
+	name, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+	return fsclient.Command{Class: class, Name: name, Args: strings.TrimSpace(args)}
+}
+
+//API runs the handler for an api command.
+func (client *Client) API(cmd string) (string, error) {
+	return client.call(Call{Command: command(fsclient.ClassAPI, cmd)})
+}
+
+//APIContext runs the handler for an api command.
+func (client *Client) APIContext(ctx context.Context, cmd string) (string, error) {
+	return client.API(cmd)
+}
+
+//BackgroundAPI runs the handler for a bgapi command and injects its result in
+//a BACKGROUND_JOB event before returning the job UUID. Job UUIDs are numbered
+//in order so they are the same on every run.
+func (client *Client) BackgroundAPI(cmd string) (string, error) {
+	res, err := client.call(Call{Command: command(fsclient.ClassBGAPI, cmd)})
+	if err != nil {
+		return "", err
+	}
+
+	client.mu.Lock()
+	client.jobs++
+	jobUUID := fmt.Sprintf("00000000-0000-0000-0000-%012d", client.jobs)
+	client.mu.Unlock()
+
+	client.Inject(map[string]string{
+		"Event-Name":      "BACKGROUND_JOB",
+		"Job-UUID":        jobUUID,
+		"Job-Command":     command(fsclient.ClassBGAPI, cmd).Name,
+		"Job-Command-Arg": command(fsclient.ClassBGAPI, cmd).Args,
+		"body-string":     res,
+	})
+	return jobUUID, nil
+}
+
+//BackgroundAPIContext runs the handler for a bgapi command like
+//BackgroundAPI.
+func (client *Client) BackgroundAPIContext(ctx context.Context, cmd string) (string, error) {
+	return client.BackgroundAPI(cmd)
+}
+
+//Execute runs the handler for an execute command.
+func (client *Client) Execute(app string, arg string, uuid string, lock bool) (string, error) {
+	return client.ExecuteWithEventUUID(context.Background(), app, arg, uuid, lock, "")
+}
+
+//ExecuteContext runs the handler for an execute command.
+func (client *Client) ExecuteContext(ctx context.Context, app string, arg string, uuid string, lock bool) (string, error) {
+	return client.ExecuteWithEventUUID(ctx, app, arg, uuid, lock, "")
+}
+
+//ExecuteWithEventUUID runs the handler for an execute command.
+func (client *Client) ExecuteWithEventUUID(ctx context.Context, app string, arg string, uuid string, lock bool, eventUUID string) (string, error) {
+	return client.call(Call{
+		Command:   fsclient.Command{Class: fsclient.ClassExecute, Name: app, Args: arg, UUID: uuid},
+		EventUUID: eventUUID,
+	})
+}
+
+//SendEvent runs the handler for a sendevent command.
+func (client *Client) SendEvent(eventName string, eventParams map[string]string, eventBody string) (string, error) {
+	return client.call(Call{Command: fsclient.Command{Class: fsclient.ClassSendEvent, Name: eventName}})
+}
+
+//SendEventContext runs the handler for a sendevent command.
+func (client *Client) SendEventContext(ctx context.Context, eventName string, eventParams map[string]string, eventBody string) (string, error) {
+	return client.SendEvent(eventName, eventParams, eventBody)
+}
+
+//SendRecv runs the handler for a raw command, parsing its reply.
+func (client *Client) SendRecv(raw string) (fsclient.Reply, error) {
+	name, args, _ := strings.Cut(strings.TrimSpace(raw), " ")
+	res, err := client.call(Call{Command: fsclient.Command{Class: fsclient.ClassRaw, Name: name, Args: args}})
+	if err != nil {
+		return fsclient.Reply{}, err
+	}
+	return fsclient.ParseReply(res), nil
+}
+
+//SendRecvContext runs the handler for a raw command like SendRecv.
+func (client *Client) SendRecvContext(ctx context.Context, raw string) (fsclient.Reply, error) {
+	return client.SendRecv(raw)
+}
+
+//Events returns EventCh.
+func (client *Client) Events() <-chan map[string]string {
+	return client.EventCh
+}
+
+//Subscribe does nothing, as every injected event is delivered.
+func (client *Client) Subscribe(arg string) error {
+	return nil
+}
+
+//AddFilter does nothing, as every injected event is delivered.
+func (client *Client) AddFilter(arg string) error {
+	return nil
+}
+
+//Close closes EventCh.
+func (client *Client) Close() {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if !client.closed {
+		client.closed = true
+		close(client.EventCh)
+	}
+}
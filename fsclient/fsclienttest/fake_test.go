@@ -0,0 +1,68 @@
+package fsclienttest
+
+import (
+	"testing"
+
+	"github.com/tomponline/fsclient/fsclient"
+)
+
+//TestClientReplies checks which scripted reply answers a command.
+func TestClientReplies(t *testing.T) {
+	client := NewClient()
+	client.Reply(fsclient.ClassAPI, "", "+OK any")
+	client.Reply(fsclient.ClassAPI, "status", "+OK status")
+	client.Once(fsclient.ClassAPI, "status", func(call Call) (string, error) {
+		return "+OK once", nil
+	})
+
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"status", "+OK once"},
+		{"status", "+OK status"},
+		{"version", "+OK any"},
+	}
+
+	for _, test := range tests {
+		res, err := client.API(test.cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != test.want {
+			t.Errorf("API(%q) = %q, want %q", test.cmd, res, test.want)
+		}
+	}
+
+	if _, err := client.Execute("answer", "", "1234", false); err == nil {
+		t.Error("Unscripted execute didn't fail")
+	}
+	if calls := client.Calls(); len(calls) != 4 {
+		t.Errorf("Got %d calls, want 4", len(calls))
+	}
+}
+
+//TestClientInjectAfterClose checks that events injected after the client is
+//closed still reach its handlers without sending on the closed EventCh.
+func TestClientInjectAfterClose(t *testing.T) {
+	client := NewClient()
+	handled := 0
+	client.OnEvent(func(event fsclient.Event) {
+		handled++
+	})
+
+	client.Inject(map[string]string{"Event-Name": "HEARTBEAT"})
+	client.Close()
+	client.Inject(map[string]string{"Event-Name": "HEARTBEAT"})
+
+	if handled != 2 {
+		t.Errorf("Handled %d events, want 2", handled)
+	}
+	events := 0
+	for range client.EventCh {
+		events++
+	}
+	if events != 1 {
+		t.Errorf("Got %d events on EventCh, want 1", events)
+	}
+}
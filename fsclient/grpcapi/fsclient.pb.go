@@ -0,0 +1,561 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: fsclient.proto
+
+package grpcapi
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CommandRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Command       string                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CommandRequest) Reset() {
+	*x = CommandRequest{}
+	mi := &file_fsclient_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommandRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommandRequest) ProtoMessage() {}
+
+func (x *CommandRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fsclient_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommandRequest.ProtoReflect.Descriptor instead.
+func (*CommandRequest) Descriptor() ([]byte, []int) {
+	return file_fsclient_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CommandRequest) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+type CommandReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Body          string                 `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CommandReply) Reset() {
+	*x = CommandReply{}
+	mi := &file_fsclient_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommandReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommandReply) ProtoMessage() {}
+
+func (x *CommandReply) ProtoReflect() protoreflect.Message {
+	mi := &file_fsclient_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommandReply.ProtoReflect.Descriptor instead.
+func (*CommandReply) Descriptor() ([]byte, []int) {
+	return file_fsclient_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CommandReply) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+type JobReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobUuid       string                 `protobuf:"bytes,1,opt,name=job_uuid,json=jobUuid,proto3" json:"job_uuid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobReply) Reset() {
+	*x = JobReply{}
+	mi := &file_fsclient_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobReply) ProtoMessage() {}
+
+func (x *JobReply) ProtoReflect() protoreflect.Message {
+	mi := &file_fsclient_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobReply.ProtoReflect.Descriptor instead.
+func (*JobReply) Descriptor() ([]byte, []int) {
+	return file_fsclient_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *JobReply) GetJobUuid() string {
+	if x != nil {
+		return x.JobUuid
+	}
+	return ""
+}
+
+type OriginateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Endpoint      string                 `protobuf:"bytes,1,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	Destination   string                 `protobuf:"bytes,2,opt,name=destination,proto3" json:"destination,omitempty"`
+	Variables     map[string]string      `protobuf:"bytes,3,rep,name=variables,proto3" json:"variables,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Background    bool                   `protobuf:"varint,4,opt,name=background,proto3" json:"background,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OriginateRequest) Reset() {
+	*x = OriginateRequest{}
+	mi := &file_fsclient_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OriginateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OriginateRequest) ProtoMessage() {}
+
+func (x *OriginateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fsclient_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OriginateRequest.ProtoReflect.Descriptor instead.
+func (*OriginateRequest) Descriptor() ([]byte, []int) {
+	return file_fsclient_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *OriginateRequest) GetEndpoint() string {
+	if x != nil {
+		return x.Endpoint
+	}
+	return ""
+}
+
+func (x *OriginateRequest) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *OriginateRequest) GetVariables() map[string]string {
+	if x != nil {
+		return x.Variables
+	}
+	return nil
+}
+
+func (x *OriginateRequest) GetBackground() bool {
+	if x != nil {
+		return x.Background
+	}
+	return false
+}
+
+type OriginateReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Body          string                 `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+	Uuid          string                 `protobuf:"bytes,2,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	JobUuid       string                 `protobuf:"bytes,3,opt,name=job_uuid,json=jobUuid,proto3" json:"job_uuid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OriginateReply) Reset() {
+	*x = OriginateReply{}
+	mi := &file_fsclient_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OriginateReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OriginateReply) ProtoMessage() {}
+
+func (x *OriginateReply) ProtoReflect() protoreflect.Message {
+	mi := &file_fsclient_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OriginateReply.ProtoReflect.Descriptor instead.
+func (*OriginateReply) Descriptor() ([]byte, []int) {
+	return file_fsclient_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *OriginateReply) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *OriginateReply) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *OriginateReply) GetJobUuid() string {
+	if x != nil {
+		return x.JobUuid
+	}
+	return ""
+}
+
+type ExecuteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Uuid          string                 `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	App           string                 `protobuf:"bytes,2,opt,name=app,proto3" json:"app,omitempty"`
+	Arg           string                 `protobuf:"bytes,3,opt,name=arg,proto3" json:"arg,omitempty"`
+	Lock          bool                   `protobuf:"varint,4,opt,name=lock,proto3" json:"lock,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteRequest) Reset() {
+	*x = ExecuteRequest{}
+	mi := &file_fsclient_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteRequest) ProtoMessage() {}
+
+func (x *ExecuteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fsclient_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteRequest.ProtoReflect.Descriptor instead.
+func (*ExecuteRequest) Descriptor() ([]byte, []int) {
+	return file_fsclient_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ExecuteRequest) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetApp() string {
+	if x != nil {
+		return x.App
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetArg() string {
+	if x != nil {
+		return x.Arg
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetLock() bool {
+	if x != nil {
+		return x.Lock
+	}
+	return false
+}
+
+type EventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EventNames    []string               `protobuf:"bytes,1,rep,name=event_names,json=eventNames,proto3" json:"event_names,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EventsRequest) Reset() {
+	*x = EventsRequest{}
+	mi := &file_fsclient_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventsRequest) ProtoMessage() {}
+
+func (x *EventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fsclient_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventsRequest.ProtoReflect.Descriptor instead.
+func (*EventsRequest) Descriptor() ([]byte, []int) {
+	return file_fsclient_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *EventsRequest) GetEventNames() []string {
+	if x != nil {
+		return x.EventNames
+	}
+	return nil
+}
+
+type Event struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Headers       map[string]string      `protobuf:"bytes,1,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Body          string                 `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_fsclient_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_fsclient_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_fsclient_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Event) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *Event) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+var File_fsclient_proto protoreflect.FileDescriptor
+
+const file_fsclient_proto_rawDesc = "" +
+	"\n" +
+	"\x0efsclient.proto\x12\vfsclient.v1\"*\n" +
+	"\x0eCommandRequest\x12\x18\n" +
+	"\acommand\x18\x01 \x01(\tR\acommand\"\"\n" +
+	"\fCommandReply\x12\x12\n" +
+	"\x04body\x18\x01 \x01(\tR\x04body\"%\n" +
+	"\bJobReply\x12\x19\n" +
+	"\bjob_uuid\x18\x01 \x01(\tR\ajobUuid\"\xfa\x01\n" +
+	"\x10OriginateRequest\x12\x1a\n" +
+	"\bendpoint\x18\x01 \x01(\tR\bendpoint\x12 \n" +
+	"\vdestination\x18\x02 \x01(\tR\vdestination\x12J\n" +
+	"\tvariables\x18\x03 \x03(\v2,.fsclient.v1.OriginateRequest.VariablesEntryR\tvariables\x12\x1e\n" +
+	"\n" +
+	"background\x18\x04 \x01(\bR\n" +
+	"background\x1a<\n" +
+	"\x0eVariablesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"S\n" +
+	"\x0eOriginateReply\x12\x12\n" +
+	"\x04body\x18\x01 \x01(\tR\x04body\x12\x12\n" +
+	"\x04uuid\x18\x02 \x01(\tR\x04uuid\x12\x19\n" +
+	"\bjob_uuid\x18\x03 \x01(\tR\ajobUuid\"\\\n" +
+	"\x0eExecuteRequest\x12\x12\n" +
+	"\x04uuid\x18\x01 \x01(\tR\x04uuid\x12\x10\n" +
+	"\x03app\x18\x02 \x01(\tR\x03app\x12\x10\n" +
+	"\x03arg\x18\x03 \x01(\tR\x03arg\x12\x12\n" +
+	"\x04lock\x18\x04 \x01(\bR\x04lock\"0\n" +
+	"\rEventsRequest\x12\x1f\n" +
+	"\vevent_names\x18\x01 \x03(\tR\n" +
+	"eventNames\"\x92\x01\n" +
+	"\x05Event\x129\n" +
+	"\aheaders\x18\x01 \x03(\v2\x1f.fsclient.v1.Event.HeadersEntryR\aheaders\x12\x12\n" +
+	"\x04body\x18\x02 \x01(\tR\x04body\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x012\xd6\x02\n" +
+	"\bFSClient\x12=\n" +
+	"\x03API\x12\x1b.fsclient.v1.CommandRequest\x1a\x19.fsclient.v1.CommandReply\x12C\n" +
+	"\rBackgroundAPI\x12\x1b.fsclient.v1.CommandRequest\x1a\x15.fsclient.v1.JobReply\x12G\n" +
+	"\tOriginate\x12\x1d.fsclient.v1.OriginateRequest\x1a\x1b.fsclient.v1.OriginateReply\x12A\n" +
+	"\aExecute\x12\x1b.fsclient.v1.ExecuteRequest\x1a\x19.fsclient.v1.CommandReply\x12:\n" +
+	"\x06Events\x12\x1a.fsclient.v1.EventsRequest\x1a\x12.fsclient.v1.Event0\x01B1Z/github.com/tomponline/fsclient/fsclient/grpcapib\x06proto3"
+
+var (
+	file_fsclient_proto_rawDescOnce sync.Once
+	file_fsclient_proto_rawDescData []byte
+)
+
+func file_fsclient_proto_rawDescGZIP() []byte {
+	file_fsclient_proto_rawDescOnce.Do(func() {
+		file_fsclient_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_fsclient_proto_rawDesc), len(file_fsclient_proto_rawDesc)))
+	})
+	return file_fsclient_proto_rawDescData
+}
+
+var file_fsclient_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_fsclient_proto_goTypes = []any{
+	(*CommandRequest)(nil),   // 0: fsclient.v1.CommandRequest
+	(*CommandReply)(nil),     // 1: fsclient.v1.CommandReply
+	(*JobReply)(nil),         // 2: fsclient.v1.JobReply
+	(*OriginateRequest)(nil), // 3: fsclient.v1.OriginateRequest
+	(*OriginateReply)(nil),   // 4: fsclient.v1.OriginateReply
+	(*ExecuteRequest)(nil),   // 5: fsclient.v1.ExecuteRequest
+	(*EventsRequest)(nil),    // 6: fsclient.v1.EventsRequest
+	(*Event)(nil),            // 7: fsclient.v1.Event
+	nil,                      // 8: fsclient.v1.OriginateRequest.VariablesEntry
+	nil,                      // 9: fsclient.v1.Event.HeadersEntry
+}
+var file_fsclient_proto_depIdxs = []int32{
+	8, // 0: fsclient.v1.OriginateRequest.variables:type_name -> fsclient.v1.OriginateRequest.VariablesEntry
+	9, // 1: fsclient.v1.Event.headers:type_name -> fsclient.v1.Event.HeadersEntry
+	0, // 2: fsclient.v1.FSClient.API:input_type -> fsclient.v1.CommandRequest
+	0, // 3: fsclient.v1.FSClient.BackgroundAPI:input_type -> fsclient.v1.CommandRequest
+	3, // 4: fsclient.v1.FSClient.Originate:input_type -> fsclient.v1.OriginateRequest
+	5, // 5: fsclient.v1.FSClient.Execute:input_type -> fsclient.v1.ExecuteRequest
+	6, // 6: fsclient.v1.FSClient.Events:input_type -> fsclient.v1.EventsRequest
+	1, // 7: fsclient.v1.FSClient.API:output_type -> fsclient.v1.CommandReply
+	2, // 8: fsclient.v1.FSClient.BackgroundAPI:output_type -> fsclient.v1.JobReply
+	4, // 9: fsclient.v1.FSClient.Originate:output_type -> fsclient.v1.OriginateReply
+	1, // 10: fsclient.v1.FSClient.Execute:output_type -> fsclient.v1.CommandReply
+	7, // 11: fsclient.v1.FSClient.Events:output_type -> fsclient.v1.Event
+	7, // [7:12] is the sub-list for method output_type
+	2, // [2:7] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_fsclient_proto_init() }
+func file_fsclient_proto_init() {
+	if File_fsclient_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_fsclient_proto_rawDesc), len(file_fsclient_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_fsclient_proto_goTypes,
+		DependencyIndexes: file_fsclient_proto_depIdxs,
+		MessageInfos:      file_fsclient_proto_msgTypes,
+	}.Build()
+	File_fsclient_proto = out.File
+	file_fsclient_proto_goTypes = nil
+	file_fsclient_proto_depIdxs = nil
+}
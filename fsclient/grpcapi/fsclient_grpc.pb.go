@@ -0,0 +1,277 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             v5.29.3
+// source: fsclient.proto
+
+package grpcapi
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	FSClient_API_FullMethodName           = "/fsclient.v1.FSClient/API"
+	FSClient_BackgroundAPI_FullMethodName = "/fsclient.v1.FSClient/BackgroundAPI"
+	FSClient_Originate_FullMethodName     = "/fsclient.v1.FSClient/Originate"
+	FSClient_Execute_FullMethodName       = "/fsclient.v1.FSClient/Execute"
+	FSClient_Events_FullMethodName        = "/fsclient.v1.FSClient/Events"
+)
+
+// FSClientClient is the client API for FSClient service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FSClientClient interface {
+	API(ctx context.Context, in *CommandRequest, opts ...grpc.CallOption) (*CommandReply, error)
+	BackgroundAPI(ctx context.Context, in *CommandRequest, opts ...grpc.CallOption) (*JobReply, error)
+	Originate(ctx context.Context, in *OriginateRequest, opts ...grpc.CallOption) (*OriginateReply, error)
+	Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*CommandReply, error)
+	Events(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type fSClientClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFSClientClient(cc grpc.ClientConnInterface) FSClientClient {
+	return &fSClientClient{cc}
+}
+
+func (c *fSClientClient) API(ctx context.Context, in *CommandRequest, opts ...grpc.CallOption) (*CommandReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CommandReply)
+	err := c.cc.Invoke(ctx, FSClient_API_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClientClient) BackgroundAPI(ctx context.Context, in *CommandRequest, opts ...grpc.CallOption) (*JobReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobReply)
+	err := c.cc.Invoke(ctx, FSClient_BackgroundAPI_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClientClient) Originate(ctx context.Context, in *OriginateRequest, opts ...grpc.CallOption) (*OriginateReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OriginateReply)
+	err := c.cc.Invoke(ctx, FSClient_Originate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClientClient) Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*CommandReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CommandReply)
+	err := c.cc.Invoke(ctx, FSClient_Execute_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClientClient) Events(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FSClient_ServiceDesc.Streams[0], FSClient_Events_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[EventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FSClient_EventsClient = grpc.ServerStreamingClient[Event]
+
+// FSClientServer is the server API for FSClient service.
+// All implementations must embed UnimplementedFSClientServer
+// for forward compatibility.
+type FSClientServer interface {
+	API(context.Context, *CommandRequest) (*CommandReply, error)
+	BackgroundAPI(context.Context, *CommandRequest) (*JobReply, error)
+	Originate(context.Context, *OriginateRequest) (*OriginateReply, error)
+	Execute(context.Context, *ExecuteRequest) (*CommandReply, error)
+	Events(*EventsRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedFSClientServer()
+}
+
+// UnimplementedFSClientServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFSClientServer struct{}
+
+func (UnimplementedFSClientServer) API(context.Context, *CommandRequest) (*CommandReply, error) {
+	return nil, status.Error(codes.Unimplemented, "method API not implemented")
+}
+func (UnimplementedFSClientServer) BackgroundAPI(context.Context, *CommandRequest) (*JobReply, error) {
+	return nil, status.Error(codes.Unimplemented, "method BackgroundAPI not implemented")
+}
+func (UnimplementedFSClientServer) Originate(context.Context, *OriginateRequest) (*OriginateReply, error) {
+	return nil, status.Error(codes.Unimplemented, "method Originate not implemented")
+}
+func (UnimplementedFSClientServer) Execute(context.Context, *ExecuteRequest) (*CommandReply, error) {
+	return nil, status.Error(codes.Unimplemented, "method Execute not implemented")
+}
+func (UnimplementedFSClientServer) Events(*EventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Error(codes.Unimplemented, "method Events not implemented")
+}
+func (UnimplementedFSClientServer) mustEmbedUnimplementedFSClientServer() {}
+func (UnimplementedFSClientServer) testEmbeddedByValue()                  {}
+
+// UnsafeFSClientServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FSClientServer will
+// result in compilation errors.
+type UnsafeFSClientServer interface {
+	mustEmbedUnimplementedFSClientServer()
+}
+
+func RegisterFSClientServer(s grpc.ServiceRegistrar, srv FSClientServer) {
+	// If the following call panics, it indicates UnimplementedFSClientServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&FSClient_ServiceDesc, srv)
+}
+
+func _FSClient_API_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommandRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSClientServer).API(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FSClient_API_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSClientServer).API(ctx, req.(*CommandRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FSClient_BackgroundAPI_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommandRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSClientServer).BackgroundAPI(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FSClient_BackgroundAPI_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSClientServer).BackgroundAPI(ctx, req.(*CommandRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FSClient_Originate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OriginateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSClientServer).Originate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FSClient_Originate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSClientServer).Originate(ctx, req.(*OriginateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FSClient_Execute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExecuteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSClientServer).Execute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FSClient_Execute_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSClientServer).Execute(ctx, req.(*ExecuteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FSClient_Events_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(EventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FSClientServer).Events(m, &grpc.GenericServerStream[EventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FSClient_EventsServer = grpc.ServerStreamingServer[Event]
+
+// FSClient_ServiceDesc is the grpc.ServiceDesc for FSClient service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FSClient_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fsclient.v1.FSClient",
+	HandlerType: (*FSClientServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "API",
+			Handler:    _FSClient_API_Handler,
+		},
+		{
+			MethodName: "BackgroundAPI",
+			Handler:    _FSClient_BackgroundAPI_Handler,
+		},
+		{
+			MethodName: "Originate",
+			Handler:    _FSClient_Originate_Handler,
+		},
+		{
+			MethodName: "Execute",
+			Handler:    _FSClient_Execute_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Events",
+			Handler:       _FSClient_Events_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "fsclient.proto",
+}
@@ -0,0 +1,138 @@
+//Package grpcapi exposes a Freeswitch client as a gRPC service, so services
+//written in other languages can use fsclient as a sidecar. The service is
+//defined in fsclient.proto.
+package grpcapi
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative fsclient.proto
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//streamBufSize is the number of events buffered for each Events stream before
+//events are discarded for that stream.
+const streamBufSize = 100
+
+//Server implements the FSClient gRPC service on top of a Client. Commands are
+//sent with the RPC's context, so the client's authorizer and audit sink see
+//the caller set with fsclient.WithCaller, e.g. by an authenticating
+//interceptor.
+type Server struct {
+	UnimplementedFSClientServer
+	client fsclient.Commander
+	hub    *fsclient.EventHub
+}
+
+//NewServer creates a gRPC service backed by client, usually a Client. Events
+//streams subscribe to hub, which should be fed from the client's EventCh.
+func NewServer(client fsclient.Commander, hub *fsclient.EventHub) *Server {
+	return &Server{
+		client: client,
+		hub:    hub,
+	}
+}
+
+//Register registers the service with a gRPC server.
+func (server *Server) Register(grpcServer *grpc.Server) {
+	RegisterFSClientServer(grpcServer, server)
+}
+
+//API sends an api command and returns the response body.
+func (server *Server) API(ctx context.Context, req *CommandRequest) (*CommandReply, error) {
+	body, err := server.client.APIContext(ctx, req.GetCommand())
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &CommandReply{Body: body}, nil
+}
+
+//BackgroundAPI sends a bgapi command and returns its Job-UUID.
+func (server *Server) BackgroundAPI(ctx context.Context, req *CommandRequest) (*JobReply, error) {
+	jobUUID, err := server.client.BackgroundAPIContext(ctx, req.GetCommand())
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &JobReply{JobUuid: jobUUID}, nil
+}
+
+//Originate starts a new call. In background mode the Job-UUID is returned
+//immediately, otherwise the call blocks until the originate completes and the
+//new channel UUID is returned.
+func (server *Server) Originate(ctx context.Context, req *OriginateRequest) (*OriginateReply, error) {
+	if req.GetEndpoint() == "" || req.GetDestination() == "" {
+		return nil, status.Error(codes.InvalidArgument, "endpoint and destination are required")
+	}
+
+	cmd := fsclient.OriginateCommand(req.GetEndpoint(), req.GetDestination(), req.GetVariables())
+	if req.GetBackground() {
+		jobUUID, err := server.client.BackgroundAPIContext(ctx, cmd)
+		if err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		return &OriginateReply{JobUuid: jobUUID}, nil
+	}
+
+	body, err := server.client.APIContext(ctx, cmd)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	body = strings.TrimSpace(body)
+	reply := fsclient.ParseReply(body)
+	if !reply.OK {
+		return nil, status.Error(codes.Aborted, body)
+	}
+	return &OriginateReply{Body: body, Uuid: reply.UUID}, nil
+}
+
+//Execute runs a dialplan application on a channel.
+func (server *Server) Execute(ctx context.Context, req *ExecuteRequest) (*CommandReply, error) {
+	if req.GetUuid() == "" || req.GetApp() == "" {
+		return nil, status.Error(codes.InvalidArgument, "uuid and app are required")
+	}
+
+	body, err := server.client.ExecuteContext(ctx, req.GetApp(), req.GetArg(), req.GetUuid(), req.GetLock())
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &CommandReply{Body: body}, nil
+}
+
+//Events streams received events to the caller, optionally limited to a set of
+//event names. Events are discarded for streams that are not read fast enough.
+func (server *Server) Events(req *EventsRequest, stream FSClient_EventsServer) error {
+	sub := server.hub.Subscribe(streamBufSize, req.GetEventNames()...)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-sub.EventCh:
+			if !ok {
+				return status.Error(codes.Unavailable, "client closed")
+			}
+			if err := stream.Send(toEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+//toEvent converts a client event to its protobuf representation, moving the
+//event body out of the headers.
+func toEvent(event map[string]string) *Event {
+	headers := make(map[string]string, len(event))
+	for key, value := range event {
+		if key != "body-string" {
+			headers[key] = value
+		}
+	}
+	return &Event{Headers: headers, Body: event["body-string"]}
+}
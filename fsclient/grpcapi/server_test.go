@@ -0,0 +1,184 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+//newTestService serves a Server backed by a fake client over an in-memory
+//connection and returns a gRPC client for it.
+func newTestService(t *testing.T) (FSClientClient, *fsclienttest.Client) {
+	fake := fsclienttest.NewClient()
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	NewServer(fake, fsclient.NewEventHub(fake.Events())).Register(grpcServer)
+	go grpcServer.Serve(listener)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		grpcServer.Stop()
+		fake.Close()
+	})
+	return NewFSClientClient(conn), fake
+}
+
+//TestServerCommands runs commands through the generated service and checks
+//the commands the client was sent and the replies.
+func TestServerCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		call     func(ctx context.Context, service FSClientClient) (string, error)
+		wantCmd  fsclient.Command
+		wantBody string
+		wantCode codes.Code
+	}{
+		{
+			name: "api",
+			call: func(ctx context.Context, service FSClientClient) (string, error) {
+				reply, err := service.API(ctx, &CommandRequest{Command: "status"})
+				return reply.GetBody(), err
+			},
+			wantCmd:  fsclient.Command{Class: fsclient.ClassAPI, Name: "status"},
+			wantBody: "+OK status",
+		},
+		{
+			name: "bgapi",
+			call: func(ctx context.Context, service FSClientClient) (string, error) {
+				reply, err := service.BackgroundAPI(ctx, &CommandRequest{Command: "status"})
+				return reply.GetJobUuid(), err
+			},
+			wantCmd:  fsclient.Command{Class: fsclient.ClassBGAPI, Name: "status"},
+			wantBody: "00000000-0000-0000-0000-000000000001",
+		},
+		{
+			name: "originate",
+			call: func(ctx context.Context, service FSClientClient) (string, error) {
+				reply, err := service.Originate(ctx, &OriginateRequest{
+					Endpoint:    "user/1000",
+					Destination: "&park()",
+					Variables:   map[string]string{"origination_uuid": "0d3a46e6-8c0c-4f6e-9d8c-1b2f3a4b5c6d"},
+				})
+				return reply.GetUuid(), err
+			},
+			wantCmd:  fsclient.Command{Class: fsclient.ClassAPI, Name: "originate", Args: "{origination_uuid=0d3a46e6-8c0c-4f6e-9d8c-1b2f3a4b5c6d}user/1000 &park()"},
+			wantBody: "0d3a46e6-8c0c-4f6e-9d8c-1b2f3a4b5c6d",
+		},
+		{
+			name: "originate without destination",
+			call: func(ctx context.Context, service FSClientClient) (string, error) {
+				reply, err := service.Originate(ctx, &OriginateRequest{Endpoint: "user/1000"})
+				return reply.GetUuid(), err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "execute",
+			call: func(ctx context.Context, service FSClientClient) (string, error) {
+				reply, err := service.Execute(ctx, &ExecuteRequest{Uuid: "1234", App: "answer"})
+				return reply.GetBody(), err
+			},
+			wantCmd:  fsclient.Command{Class: fsclient.ClassExecute, Name: "answer", UUID: "1234"},
+			wantBody: "+OK",
+		},
+		{
+			name: "client error",
+			call: func(ctx context.Context, service FSClientClient) (string, error) {
+				reply, err := service.Execute(ctx, &ExecuteRequest{Uuid: "1234", App: "playback"})
+				return reply.GetBody(), err
+			},
+			wantCmd:  fsclient.Command{Class: fsclient.ClassExecute, Name: "playback", UUID: "1234"},
+			wantCode: codes.Unavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service, fake := newTestService(t)
+			fake.Reply(fsclient.ClassAPI, "status", "+OK status")
+			fake.Reply(fsclient.ClassBGAPI, "status", "+OK status")
+			fake.Reply(fsclient.ClassAPI, "originate", "+OK 0d3a46e6-8c0c-4f6e-9d8c-1b2f3a4b5c6d\n")
+			fake.Reply(fsclient.ClassExecute, "answer", "+OK")
+
+			body, err := test.call(context.Background(), service)
+			if code := status.Code(err); code != test.wantCode {
+				t.Fatalf("Got code %v, want %v: %v", code, test.wantCode, err)
+			}
+			if body != test.wantBody {
+				t.Errorf("Got %q, want %q", body, test.wantBody)
+			}
+
+			calls := fake.Calls()
+			if test.wantCmd.Name == "" {
+				if len(calls) != 0 {
+					t.Errorf("Got commands %v, want none", calls)
+				}
+				return
+			}
+			if len(calls) != 1 || calls[0].Command != test.wantCmd {
+				t.Errorf("Got commands %v, want %v", calls, test.wantCmd)
+			}
+		})
+	}
+}
+
+//TestServerEvents checks that Events streams the events named in the
+//request.
+func TestServerEvents(t *testing.T) {
+	service, fake := newTestService(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := service.Events(ctx, &EventsRequest{EventNames: []string{"CHANNEL_ANSWER"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//The stream's subscription is made once the server gets the request, so
+	//keep injecting events until one arrives.
+	received := make(chan *Event, 1)
+	go func() {
+		event, err := stream.Recv()
+		if err == nil {
+			received <- event
+		}
+		close(received)
+	}()
+
+	var event *Event
+	for event == nil {
+		fake.Inject(map[string]string{"Event-Name": "HEARTBEAT"})
+		fake.Inject(map[string]string{"Event-Name": "CHANNEL_ANSWER", "Unique-ID": "1234", "body-string": "body"})
+		select {
+		case event = <-received:
+			if event == nil {
+				t.Fatal("Stream ended without an event")
+			}
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if event.GetHeaders()["Event-Name"] != "CHANNEL_ANSWER" || event.GetHeaders()["Unique-ID"] != "1234" || event.GetBody() != "body" {
+		t.Errorf("Got event %v", event)
+	}
+	if _, ok := event.GetHeaders()["body-string"]; ok {
+		t.Error("Event body left in its headers")
+	}
+}
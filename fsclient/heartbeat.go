@@ -0,0 +1,139 @@
+package fsclient
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+//Heartbeat is a decoded HEARTBEAT event, which Freeswitch sends every 20
+//seconds by default. Node is the cluster node the event came from, if it was
+//received through a Cluster.
+type Heartbeat struct {
+	Node                  string
+	Hostname              string
+	Version               string
+	Time                  time.Time
+	Uptime                time.Duration
+	SessionCount          int64
+	MaxSessions           int64
+	SessionsSinceStartup  int64
+	SessionPeakMax        int64
+	SessionPeakFiveMin    int64
+	SessionsPerSec        float64
+	SessionsPerSecMax     float64
+	SessionsPerSecFiveMin float64
+	IdleCPU               float64
+}
+
+//ParseHeartbeat decodes a HEARTBEAT event. The bool result is false for any
+//other event.
+func ParseHeartbeat(event Event) (Heartbeat, bool) {
+	if event.Name() != "HEARTBEAT" {
+		return Heartbeat{}, false
+	}
+
+	heartbeat := Heartbeat{
+		Node:                  event[ClusterNodeHeader],
+		Hostname:              event["FreeSWITCH-Hostname"],
+		Version:               event["FreeSWITCH-Version"],
+		Uptime:                time.Duration(int64Header(event, "Uptime-msec")) * time.Millisecond,
+		SessionCount:          int64Header(event, "Session-Count"),
+		MaxSessions:           int64Header(event, "Max-Sessions"),
+		SessionsSinceStartup:  int64Header(event, "Session-Since-Startup"),
+		SessionPeakMax:        int64Header(event, "Session-Peak-Max"),
+		SessionPeakFiveMin:    int64Header(event, "Session-Peak-FiveMin"),
+		SessionsPerSec:        floatHeader(event, "Session-Per-Sec"),
+		SessionsPerSecMax:     floatHeader(event, "Session-Per-Sec-Max"),
+		SessionsPerSecFiveMin: floatHeader(event, "Session-Per-Sec-FiveMin"),
+		IdleCPU:               floatHeader(event, "Idle-CPU"),
+		Time:                  event.Time(),
+	}
+	return heartbeat, true
+}
+
+//Gauge is a metric that can be set to a value, such as a Prometheus gauge.
+type Gauge interface {
+	Set(value float64)
+}
+
+//HeartbeatGauges are the gauges a HeartbeatWatcher updates from each
+//heartbeat. Any of them may be nil.
+type HeartbeatGauges struct {
+	Sessions       Gauge
+	SessionsPerSec Gauge
+	IdleCPU        Gauge
+	Uptime         Gauge
+}
+
+//HeartbeatWatcher decodes HEARTBEAT events, delivering them on HeartbeatCh
+//and keeping the latest heartbeat from each node. Heartbeats are discarded
+//from HeartbeatCh if it is full, but Latest is always updated.
+//
+//Register the watcher's HandleEvent method with a Dispatcher. The client
+//must be subscribed to HEARTBEAT.
+type HeartbeatWatcher struct {
+	HeartbeatCh chan Heartbeat
+	latest      map[string]Heartbeat
+	gauges      HeartbeatGauges
+	mu          *sync.RWMutex
+}
+
+//NewHeartbeatWatcher creates a HeartbeatWatcher buffering up to bufSize
+//heartbeats on HeartbeatCh.
+func NewHeartbeatWatcher(bufSize int) *HeartbeatWatcher {
+	return &HeartbeatWatcher{
+		HeartbeatCh: make(chan Heartbeat, bufSize),
+		latest:      make(map[string]Heartbeat),
+		mu:          &sync.RWMutex{},
+	}
+}
+
+//SetGauges sets the gauges to update from each heartbeat. With a Cluster the
+//gauges are set from whichever node's heartbeat arrived last, so use a
+//watcher per node or read Latest to report nodes separately.
+func (watcher *HeartbeatWatcher) SetGauges(gauges HeartbeatGauges) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	watcher.gauges = gauges
+}
+
+//Latest returns the most recent heartbeat from a node, or from the only node
+//of a single Client if node is empty.
+func (watcher *HeartbeatWatcher) Latest(node string) (Heartbeat, bool) {
+	watcher.mu.RLock()
+	defer watcher.mu.RUnlock()
+	heartbeat, ok := watcher.latest[node]
+	return heartbeat, ok
+}
+
+//HandleEvent decodes HEARTBEAT events.
+func (watcher *HeartbeatWatcher) HandleEvent(event Event) {
+	heartbeat, ok := ParseHeartbeat(event)
+	if !ok {
+		return
+	}
+
+	watcher.mu.Lock()
+	watcher.latest[heartbeat.Node] = heartbeat
+	gauges := watcher.gauges
+	watcher.mu.Unlock()
+
+	setGauge(gauges.Sessions, float64(heartbeat.SessionCount))
+	setGauge(gauges.SessionsPerSec, heartbeat.SessionsPerSec)
+	setGauge(gauges.IdleCPU, heartbeat.IdleCPU)
+	setGauge(gauges.Uptime, heartbeat.Uptime.Seconds())
+
+	select {
+	case watcher.HeartbeatCh <- heartbeat:
+	default:
+		log.Print(logPrefix, "Heartbeat channel full, discarded heartbeat from ", heartbeat.Hostname)
+	}
+}
+
+//setGauge sets a gauge if it isn't nil.
+func setGauge(gauge Gauge, value float64) {
+	if gauge != nil {
+		gauge.Set(value)
+	}
+}
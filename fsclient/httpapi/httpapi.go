@@ -0,0 +1,278 @@
+//Package httpapi exposes a Freeswitch client as a small REST/JSON control
+//plane:
+//
+//	POST /api       {"command": "status", "background": false}
+//	POST /calls     {"endpoint": "user/1000", "destination": "&park()", "variables": {...}}
+//	GET  /channels  the rows of "show channels as json"
+//	GET  /events    WebSocket stream of events as JSON, ?events=NAME,NAME to filter
+//
+//Authentication and other policies are applied by passing Middleware to
+//NewServer, for example BearerAuth.
+package httpapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"golang.org/x/net/websocket"
+)
+
+//streamBufSize is the number of events buffered for each WebSocket before
+//events are discarded for that connection.
+const streamBufSize = 100
+
+//maxBodySize limits the size of request bodies.
+const maxBodySize = 1 << 20
+
+//Middleware wraps a handler, for example to authenticate requests.
+type Middleware func(http.Handler) http.Handler
+
+//Server is an http.Handler serving the REST API for a Client.
+type Server struct {
+	client  fsclient.Commander
+	hub     *fsclient.EventHub
+	handler http.Handler
+}
+
+//apiRequest is the body of POST /api.
+type apiRequest struct {
+	Command    string `json:"command"`
+	Background bool   `json:"background"`
+}
+
+//callRequest is the body of POST /calls.
+type callRequest struct {
+	Endpoint    string            `json:"endpoint"`
+	Destination string            `json:"destination"`
+	Variables   map[string]string `json:"variables"`
+	Background  bool              `json:"background"`
+}
+
+//response is the body of successful command responses.
+type response struct {
+	Body    string `json:"body,omitempty"`
+	UUID    string `json:"uuid,omitempty"`
+	JobUUID string `json:"job_uuid,omitempty"`
+}
+
+//errorResponse is the body of error responses.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+//NewServer creates a REST API backed by client, usually a Client. The /events
+//endpoint subscribes to hub, which should be fed from the client's EventCh.
+//Middleware is applied to every endpoint, the first middleware being the
+//outermost.
+func NewServer(client fsclient.Commander, hub *fsclient.EventHub, middleware ...Middleware) *Server {
+	server := &Server{
+		client: client,
+		hub:    hub,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api", server.handleAPI)
+	mux.HandleFunc("/calls", server.handleCalls)
+	mux.HandleFunc("/channels", server.handleChannels)
+	mux.Handle("/events", websocket.Server{Handler: server.handleEvents})
+
+	var handler http.Handler = mux
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	server.handler = handler
+	return server
+}
+
+//ServeHTTP implements http.Handler.
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.handler.ServeHTTP(w, r)
+}
+
+//BearerAuth returns middleware that only allows requests carrying an
+//"Authorization: Bearer <token>" header with one of the given tokens. The
+//request's commands are sent with the caller "bearer:N", for the Nth token,
+//for the client's authorizer and audit records.
+func BearerAuth(tokens ...string) Middleware {
+	callers := make(map[string]string, len(tokens))
+	for i, token := range tokens {
+		callers[token] = "bearer:" + strconv.Itoa(i+1)
+	}
+	return BearerAuthCallers(callers)
+}
+
+//BearerAuthCallers returns middleware like BearerAuth for a map of tokens to
+//the callers their requests' commands are sent as.
+func BearerAuthCallers(callers map[string]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			for allowed, caller := range callers {
+				if subtle.ConstantTimeCompare([]byte(token), []byte(allowed)) == 1 {
+					next.ServeHTTP(w, r.WithContext(fsclient.WithCaller(r.Context(), caller)))
+					return
+				}
+			}
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+		})
+	}
+}
+
+func (server *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req apiRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Command == "" {
+		writeError(w, http.StatusBadRequest, "command is required")
+		return
+	}
+
+	if req.Background {
+		jobUUID, err := server.client.BackgroundAPIContext(r.Context(), req.Command)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusAccepted, response{JobUUID: jobUUID})
+		return
+	}
+
+	body, err := server.client.APIContext(r.Context(), req.Command)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, response{Body: body})
+}
+
+func (server *Server) handleCalls(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req callRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Endpoint == "" || req.Destination == "" {
+		writeError(w, http.StatusBadRequest, "endpoint and destination are required")
+		return
+	}
+
+	cmd := fsclient.OriginateCommand(req.Endpoint, req.Destination, req.Variables)
+	if req.Background {
+		jobUUID, err := server.client.BackgroundAPIContext(r.Context(), cmd)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusAccepted, response{JobUUID: jobUUID})
+		return
+	}
+
+	body, err := server.client.APIContext(r.Context(), cmd)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	body = strings.TrimSpace(body)
+	reply := fsclient.ParseReply(body)
+	if !reply.OK {
+		writeError(w, http.StatusUnprocessableEntity, body)
+		return
+	}
+	writeJSON(w, http.StatusCreated, response{Body: body, UUID: reply.UUID})
+}
+
+func (server *Server) handleChannels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	body, err := server.client.APIContext(r.Context(), "show channels as json")
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	var result struct {
+		Rows []map[string]string `json:"rows"`
+	}
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		writeError(w, http.StatusBadGateway, "invalid response from Freeswitch: "+strings.TrimSpace(body))
+		return
+	}
+
+	//No rows are returned when there are no channels.
+	if result.Rows == nil {
+		result.Rows = []map[string]string{}
+	}
+	writeJSON(w, http.StatusOK, result.Rows)
+}
+
+//handleEvents streams events to a WebSocket as JSON objects.
+func (server *Server) handleEvents(ws *websocket.Conn) {
+	defer ws.Close()
+
+	var names []string
+	if filter := ws.Request().URL.Query().Get("events"); filter != "" {
+		names = strings.Split(filter, ",")
+	}
+
+	sub := server.hub.Subscribe(streamBufSize, names...)
+	defer sub.Close()
+
+	//Discard anything the peer sends and detect when it goes away.
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, ws)
+		close(closed)
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-sub.EventCh:
+			if !ok {
+				return
+			}
+			if err := websocket.JSON.Send(ws, event); err != nil {
+				return
+			}
+		}
+	}
+}
+
+//readJSON decodes the request body, writing an error response on failure.
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
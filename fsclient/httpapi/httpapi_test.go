@@ -0,0 +1,158 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+	"golang.org/x/net/websocket"
+)
+
+//testUUID is the channel UUID returned by the scripted originate.
+const testUUID = "0d3a46e6-8c0c-4f6e-9d8c-1b2f3a4b5c6d"
+
+//newTestServer creates a Server backed by a fake client with scripted
+//replies.
+func newTestServer(middleware ...Middleware) (*Server, *fsclienttest.Client) {
+	fake := fsclienttest.NewClient()
+	fake.Reply(fsclient.ClassAPI, "status", "+OK status")
+	fake.Reply(fsclient.ClassBGAPI, "status", "+OK status")
+	fake.Reply(fsclient.ClassAPI, "originate", "+OK "+testUUID+"\n")
+	fake.Reply(fsclient.ClassAPI, "show", `{"row_count":1,"rows":[{"uuid":"`+testUUID+`"}]}`)
+	return NewServer(fake, fsclient.NewEventHub(fake.Events()), middleware...), fake
+}
+
+//TestServerHandlers checks the responses of the REST endpoints and the
+//commands they send.
+func TestServerHandlers(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantCmd    string
+	}{
+		{"api", "POST", "/api", `{"command":"status"}`, http.StatusOK, `{"body":"+OK status"}`, "status"},
+		{"bgapi", "POST", "/api", `{"command":"status","background":true}`, http.StatusAccepted, `{"job_uuid":"00000000-0000-0000-0000-000000000001"}`, "status"},
+		{"api without command", "POST", "/api", `{}`, http.StatusBadRequest, `{"error":"command is required"}`, ""},
+		{"api invalid body", "POST", "/api", `{`, http.StatusBadRequest, "", ""},
+		{"api get", "GET", "/api", "", http.StatusMethodNotAllowed, `{"error":"method not allowed"}`, ""},
+		{"api unscripted", "POST", "/api", `{"command":"version"}`, http.StatusBadGateway, "", "version"},
+		{"call", "POST", "/calls", `{"endpoint":"user/1000","destination":"&park()","variables":{"a":"b"}}`, http.StatusCreated, `{"body":"+OK ` + testUUID + `","uuid":"` + testUUID + `"}`, "originate {a=b}user/1000 &park()"},
+		{"call without endpoint", "POST", "/calls", `{"destination":"&park()"}`, http.StatusBadRequest, `{"error":"endpoint and destination are required"}`, ""},
+		{"channels", "GET", "/channels", "", http.StatusOK, `[{"uuid":"` + testUUID + `"}]`, "show channels as json"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, fake := newTestServer()
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+
+			if recorder.Code != test.wantStatus {
+				t.Errorf("Got status %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body)
+			}
+			body := strings.TrimSpace(recorder.Body.String())
+			if test.wantBody != "" && body != test.wantBody {
+				t.Errorf("Got body %s, want %s", body, test.wantBody)
+			}
+
+			var cmds []string
+			for _, call := range fake.Calls() {
+				cmds = append(cmds, strings.TrimSpace(call.Name+" "+call.Args))
+			}
+			if test.wantCmd == "" && len(cmds) != 0 || test.wantCmd != "" && (len(cmds) != 1 || cmds[0] != test.wantCmd) {
+				t.Errorf("Got commands %q, want %q", cmds, test.wantCmd)
+			}
+		})
+	}
+}
+
+//TestBearerAuth checks that only requests with a known token are passed on,
+//with the token's caller.
+func TestBearerAuth(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantCaller    string
+	}{
+		{"first token", "Bearer one", http.StatusOK, "bearer:1"},
+		{"second token", "Bearer two", http.StatusOK, "bearer:2"},
+		{"unknown token", "Bearer three", http.StatusUnauthorized, ""},
+		{"no token", "", http.StatusUnauthorized, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var caller string
+			handler := BearerAuth("one", "two")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				caller = fsclient.CallerFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/channels", nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.wantStatus {
+				t.Errorf("Got status %d, want %d", recorder.Code, test.wantStatus)
+			}
+			if caller != test.wantCaller {
+				t.Errorf("Got caller %q, want %q", caller, test.wantCaller)
+			}
+		})
+	}
+}
+
+//TestServerEvents checks that /events streams the requested events to a
+//WebSocket.
+func TestServerEvents(t *testing.T) {
+	server, fake := newTestServer()
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	defer fake.Close()
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/events?events=CHANNEL_ANSWER"
+	ws, err := websocket.Dial(url, "", httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	//The subscription is made once the handler runs, so keep injecting
+	//events until one arrives.
+	received := make(chan map[string]string, 1)
+	go func() {
+		var event map[string]string
+		if err := websocket.JSON.Receive(ws, &event); err == nil {
+			received <- event
+		}
+		close(received)
+	}()
+
+	var event map[string]string
+	for event == nil {
+		fake.Inject(map[string]string{"Event-Name": "HEARTBEAT"})
+		fake.Inject(map[string]string{"Event-Name": "CHANNEL_ANSWER", "Unique-ID": testUUID})
+		select {
+		case event = <-received:
+			if event == nil {
+				t.Fatal("WebSocket closed without an event")
+			}
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if event["Event-Name"] != "CHANNEL_ANSWER" || event["Unique-ID"] != testUUID {
+		t.Errorf("Got event %v", event)
+	}
+}
@@ -0,0 +1,125 @@
+package fsclient
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+)
+
+//HTTPAudioModule is the Freeswitch module that plays a prompt from an HTTP
+//server.
+type HTTPAudioModule string
+
+//HTTP audio modules.
+const (
+	HTTPCache HTTPAudioModule = "mod_http_cache" //Downloads and caches files, over http or https.
+	Shout     HTTPAudioModule = "mod_shout"      //Streams MP3 files over http.
+)
+
+//httpCacheFormats are the file extensions mod_http_cache can play, as
+//Freeswitch picks the file format module from the cached file's extension.
+var httpCacheFormats = map[string]bool{
+	".wav":  true,
+	".mp3":  true,
+	".ogg":  true,
+	".opus": true,
+	".flac": true,
+	".gsm":  true,
+	".ul":   true,
+	".al":   true,
+	".r8":   true,
+	".r16":  true,
+}
+
+//HTTPAudioURL returns the file string for playing rawURL, an http or https
+//URL, with module, e.g. "http_cache://https://example.com/hello.wav". Query
+//parameters, such as authentication tokens or signatures, are added to the
+//URL's own query and escaped, as are spaces and other characters that would
+//otherwise split or end the file string.
+func HTTPAudioURL(module HTTPAudioModule, rawURL string, params url.Values) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		//The parse error includes the URL, which may hold credentials.
+		return "", errors.New("Invalid audio URL")
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("Invalid audio URL: " + redactURL(u))
+	}
+	if u.User != nil {
+		return "", errors.New("Audio URL credentials must be query parameters: " + redactURL(u))
+	}
+	if len(params) > 0 {
+		query := u.Query()
+		for key, values := range params {
+			query[key] = append(query[key], values...)
+		}
+		u.RawQuery = query.Encode()
+	}
+	u.Fragment = ""
+
+	//Escape "!", which separates the files of a file_string, as it is valid
+	//but unusual in a URL.
+	file := strings.ReplaceAll(u.String(), "!", "%21")
+	ext := strings.ToLower(path.Ext(u.Path))
+
+	switch module {
+	case HTTPCache:
+		if !httpCacheFormats[ext] {
+			return "", errors.New("Unsupported audio format for mod_http_cache: " + redactURL(u))
+		}
+		return "http_cache://" + file, nil
+	case Shout:
+		if u.Scheme != "http" {
+			return "", errors.New("mod_shout can't stream https: " + redactURL(u))
+		}
+		if ext != ".mp3" {
+			return "", errors.New("Unsupported audio format for mod_shout: " + redactURL(u))
+		}
+		return "shout://" + strings.TrimPrefix(file, "http://"), nil
+	}
+	return "", errors.New("Unknown HTTP audio module: " + string(module))
+}
+
+//PlaybackURL plays a prompt from an HTTP server with module, building the
+//file string with HTTPAudioURL. The module is checked to be loaded first, and
+//a prompt that couldn't be fetched or played is returned as an error, rather
+//than the call silently hearing nothing. The query is left out of errors so
+//that authentication parameters aren't logged.
+func (session *Session) PlaybackURL(module HTTPAudioModule, rawURL string, params url.Values) error {
+	file, err := HTTPAudioURL(module, rawURL, params)
+	if err != nil {
+		return err
+	}
+
+	loaded, err := ModuleExists(session.client, string(module))
+	if err != nil {
+		return err
+	}
+	if !loaded {
+		return errors.New("Module not loaded: " + string(module))
+	}
+
+	event, err := session.Execute("playback", file)
+	if err != nil {
+		return err
+	}
+	if res := event["Application-Response"]; res != "" && res != "FILE PLAYED" {
+		u, _ := url.Parse(rawURL)
+		return errors.New("Playback failed, " + res + ": " + redactURL(u))
+	}
+	return nil
+}
+
+//redactURL returns a URL without its query, fragment and credentials.
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = ""
+	redacted.ForceQuery = false
+	redacted.Fragment = ""
+	return redacted.String()
+}
@@ -0,0 +1,170 @@
+package fsclient
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var errCancelled = errors.New("Command cancelled")
+
+//InflightCommand is an api or bgapi command that has not had its reply yet.
+//Sent is false while it is waiting for its turn on the connection.
+type InflightCommand struct {
+	ID      uint64
+	Command Command
+	Sent    bool
+	Start   time.Time
+	clock   Clock
+}
+
+//Age returns how long ago the command was started, by the client's clock.
+func (cmd InflightCommand) Age() time.Duration {
+	if cmd.clock == nil {
+		return SystemClock.Now().Sub(cmd.Start)
+	}
+	return cmd.clock.Now().Sub(cmd.Start)
+}
+
+//inflightCommand tracks an in-flight command so it can be cancelled.
+type inflightCommand struct {
+	info       InflightCommand
+	cancelCh   chan struct{}
+	cancelOnce *sync.Once
+}
+
+//InflightCommands returns the api and bgapi commands waiting to be sent or
+//for their reply, oldest first.
+func (client *Client) InflightCommands() []InflightCommand {
+	client.inflightMu.Lock()
+	defer client.inflightMu.Unlock()
+
+	cmds := make([]InflightCommand, 0, len(client.inflight))
+	for _, entry := range client.inflight {
+		cmds = append(cmds, entry.info)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].ID < cmds[j].ID })
+	return cmds
+}
+
+//CancelCommand cancels an in-flight command by ID, returning an error to its
+//caller straight away. A command waiting to be sent is never sent. For a
+//command that has been sent the reply is still read and discarded when it
+//arrives, keeping later replies matched to their commands, so the connection
+//stays busy until then. Returns false if the command isn't in flight.
+func (client *Client) CancelCommand(id uint64) bool {
+	client.inflightMu.Lock()
+	entry, ok := client.inflight[id]
+	client.inflightMu.Unlock()
+
+	if ok {
+		entry.cancelOnce.Do(func() { close(entry.cancelCh) })
+	}
+	return ok
+}
+
+//trackCommand starts tracking an in-flight command.
+func (client *Client) trackCommand(cmd Command) *inflightCommand {
+	clock := client.clockSource()
+
+	client.inflightMu.Lock()
+	defer client.inflightMu.Unlock()
+
+	client.nextInflightID++
+	entry := &inflightCommand{
+		info:       InflightCommand{ID: client.nextInflightID, Command: cmd, Start: clock.Now(), clock: clock},
+		cancelCh:   make(chan struct{}),
+		cancelOnce: &sync.Once{},
+	}
+	client.inflight[entry.info.ID] = entry
+	return entry
+}
+
+//untrackCommand stops tracking a command once it has finished.
+func (client *Client) untrackCommand(entry *inflightCommand) {
+	client.inflightMu.Lock()
+	defer client.inflightMu.Unlock()
+	delete(client.inflight, entry.info.ID)
+}
+
+//sendCommand waits for a command's turn on the connection, writes it with
+//write and waits for its reply, unless it is cancelled first. The returned
+//bool indicates whether the command was written to the connection.
+func (client *Client) sendCommand(cmd Command, write func()) (cmdRes, bool, error) {
+	entry := client.trackCommand(cmd)
+	defer client.untrackCommand(entry)
+
+	if !client.commands.acquireOrCancel(client.commandPriority(cmd.Class, cmd.String()), entry.cancelCh) {
+		return cmdRes{}, false, errCancelled
+	}
+	client.connMu.Lock()
+
+	//If the command response channel is not initialised then it means we
+	//are not connected. So no point in sending a command.
+	resCh := client.cmdResCh
+	if resCh == nil {
+		client.connMu.Unlock()
+		client.commands.release()
+		return cmdRes{}, false, errDisconnected
+	}
+
+	write()
+	client.inflightMu.Lock()
+	entry.info.Sent = true
+	client.inflightMu.Unlock()
+
+	select {
+	case res := <-resCh:
+		client.connMu.Unlock()
+		client.commands.release()
+		return res, true, nil
+	case <-entry.cancelCh:
+		//The reply must still be read before the connection can be used
+		//again, or it would be taken as the reply to the next command.
+		go func() {
+			<-resCh
+			client.connMu.Unlock()
+			client.commands.release()
+		}()
+		return cmdRes{}, true, errCancelled
+	}
+}
+
+//acquireOrCancel waits until the connection is free for a command of a
+//priority like acquire, returning false without acquiring it if cancelCh is
+//closed first.
+func (scheduler *commandScheduler) acquireOrCancel(priority Priority, cancelCh <-chan struct{}) bool {
+	scheduler.mu.Lock()
+	if !scheduler.busy {
+		scheduler.busy = true
+		scheduler.mu.Unlock()
+		return true
+	}
+
+	turn := make(chan struct{})
+	scheduler.waiting[priority] = append(scheduler.waiting[priority], turn)
+	scheduler.mu.Unlock()
+
+	select {
+	case <-turn:
+		return true
+	case <-cancelCh:
+	}
+
+	scheduler.mu.Lock()
+	waiting := scheduler.waiting[priority]
+	for i := range waiting {
+		if waiting[i] == turn {
+			scheduler.waiting[priority] = append(waiting[:i:i], waiting[i+1:]...)
+			scheduler.mu.Unlock()
+			return false
+		}
+	}
+	scheduler.mu.Unlock()
+
+	//The turn was handed over just as the command was cancelled, so pass it
+	//on to the next command.
+	scheduler.release()
+	return false
+}
@@ -0,0 +1,49 @@
+package fsclient
+
+import "context"
+
+//Commander sends commands to Freeswitch. Client implements it, so code that
+//only sends commands, such as a Session or Dialer, can be given a fake in
+//tests instead of a connected Client.
+type Commander interface {
+	API(cmd string) (string, error)
+	APIContext(ctx context.Context, cmd string) (string, error)
+	BackgroundAPI(cmd string) (string, error)
+	BackgroundAPIContext(ctx context.Context, cmd string) (string, error)
+	Execute(app string, arg string, uuid string, lock bool) (string, error)
+	ExecuteContext(ctx context.Context, app string, arg string, uuid string, lock bool) (string, error)
+	ExecuteWithEventUUID(ctx context.Context, app string, arg string, uuid string, lock bool, eventUUID string) (string, error)
+	SendEvent(eventName string, eventParams map[string]string, eventBody string) (string, error)
+	SendEventContext(ctx context.Context, eventName string, eventParams map[string]string, eventBody string) (string, error)
+	SendRecv(raw string) (Reply, error)
+	SendRecvContext(ctx context.Context, raw string) (Reply, error)
+}
+
+//EventReader receives events from Freeswitch. Client implements it.
+type EventReader interface {
+	Events() <-chan map[string]string
+	Subscribe(arg string) error
+	AddFilter(arg string) error
+}
+
+//ESLClient is the whole of an event socket client, as implemented by Client.
+type ESLClient interface {
+	Commander
+	EventReader
+	Close()
+}
+
+var _ ESLClient = (*Client)(nil)
+
+//Events returns the channel events are delivered on, which is EventCh.
+func (client *Client) Events() <-chan map[string]string {
+	return client.EventCh
+}
+
+//ExecuteWithEventUUID executes a dialplan application on a channel like
+//ExecuteContext, sending eventUUID with the command. It is returned in the
+//Application-UUID header of the application's CHANNEL_EXECUTE and
+//CHANNEL_EXECUTE_COMPLETE events, so the caller can tell when it completes.
+func (client *Client) ExecuteWithEventUUID(ctx context.Context, app string, arg string, uuid string, lock bool, eventUUID string) (string, error) {
+	return client.execute(ctx, app, arg, uuid, lock, eventUUID)
+}
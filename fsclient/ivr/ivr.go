@@ -0,0 +1,243 @@
+//Package ivr runs IVR menus defined as data on a Freeswitch channel:
+//
+//	main := &ivr.Menu{
+//		Prompt:  "ivr/main.wav",
+//		Retries: 2,
+//		Bindings: map[string]ivr.Binding{
+//			"1": {Menu: sales},
+//			"2": {Handler: transferToSupport},
+//			"9": {Back: true},
+//		},
+//	}
+//	err := ivr.Run(session, main)
+//
+//Prompts are played and digits collected with play_and_get_digits, so
+//Freeswitch itself handles timeouts and retries within a menu.
+package ivr
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tomponline/fsclient/fsclient"
+)
+
+//Default menu timings.
+const (
+	defaultTimeout      = 5 * time.Second
+	defaultDigitTimeout = 3 * time.Second
+)
+
+//Handler is run when a binding is selected, with the digits the caller
+//entered. It returns the menu to run next, or nil to end the flow.
+type Handler func(session *fsclient.Session, digits string) (*Menu, error)
+
+//Binding is what happens when a menu option is selected. Exactly one of Menu,
+//Handler and Back should be set; an empty Binding ends the flow.
+type Binding struct {
+	//Menu is a sub-menu to run. Back in the sub-menu returns to this menu.
+	Menu *Menu
+
+	//Handler is a function to run.
+	Handler Handler
+
+	//Back returns to the menu this one was entered from, or repeats this
+	//menu if it is the first.
+	Back bool
+}
+
+//Menu is a single IVR menu: a prompt and the options the caller can select
+//with DTMF digits.
+type Menu struct {
+	//Prompt is played to the caller, as a file or "say:" text.
+	Prompt string
+
+	//InvalidPrompt is played after input that doesn't match any option.
+	InvalidPrompt string
+
+	//FailurePrompt is played when all tries are used without valid input.
+	FailurePrompt string
+
+	//Timeout is the time allowed for input after the prompt, and
+	//DigitTimeout the time allowed between digits.
+	Timeout      time.Duration
+	DigitTimeout time.Duration
+
+	//Retries is the number of extra tries the caller gets after a timeout or
+	//invalid input.
+	Retries int
+
+	//Bindings maps the digits of each option to its binding.
+	Bindings map[string]Binding
+
+	//Input, if set, handles any input that doesn't match a binding, e.g. an
+	//account number of MinDigits to MaxDigits digits ended with "#".
+	Input     Handler
+	MinDigits int
+	MaxDigits int
+
+	//OnFailure, if set, is followed when all tries are used without valid
+	//input. Otherwise the flow ends.
+	OnFailure *Binding
+}
+
+//Run runs a menu on the session, and the menus it leads to, until a binding
+//ends the flow. It returns an error if a menu is not valid, a handler fails or
+//the channel hangs up.
+func Run(session *fsclient.Session, menu *Menu) error {
+	if err := menu.Validate(); err != nil {
+		return err
+	}
+
+	var stack []*Menu
+	current := menu
+
+	for current != nil {
+		digits, err := current.collect(session)
+		if err != nil {
+			return err
+		}
+
+		binding, ok := current.match(digits)
+		if !ok {
+			if current.FailurePrompt != "" {
+				if err := session.Playback(current.FailurePrompt); err != nil {
+					return err
+				}
+			}
+			if current.OnFailure == nil {
+				return nil
+			}
+			binding = *current.OnFailure
+		}
+
+		switch {
+		case binding.Back:
+			if len(stack) > 0 {
+				current = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case binding.Menu != nil:
+			stack = append(stack, current)
+			current = binding.Menu
+		case binding.Handler != nil:
+			next, err := binding.Handler(session, digits)
+			if err != nil {
+				return err
+			}
+			if err := next.Validate(); err != nil {
+				return err
+			}
+			if next != nil {
+				stack = append(stack, current)
+			}
+			current = next
+		default:
+			current = nil
+		}
+	}
+	return nil
+}
+
+//Validate checks that a menu, and the menus its bindings lead to, accept some
+//input: a menu needs at least one binding or an Input handler.
+func (menu *Menu) Validate() error {
+	return menu.validate(make(map[*Menu]bool))
+}
+
+//validate validates a menu and the menus it leads to that aren't in seen.
+func (menu *Menu) validate(seen map[*Menu]bool) error {
+	if menu == nil || seen[menu] {
+		return nil
+	}
+	seen[menu] = true
+
+	if len(menu.Bindings) == 0 && menu.Input == nil {
+		return errors.New("Menu has no bindings or input")
+	}
+	for digits, binding := range menu.Bindings {
+		if digits == "" {
+			return errors.New("Menu binding has no digits")
+		}
+		if err := binding.Menu.validate(seen); err != nil {
+			return err
+		}
+	}
+	if menu.OnFailure != nil {
+		return menu.OnFailure.Menu.validate(seen)
+	}
+	return nil
+}
+
+//match returns the binding selected by digits. The bool result is false if
+//the input is not valid for the menu.
+func (menu *Menu) match(digits string) (Binding, bool) {
+	if digits == "" {
+		return Binding{}, false
+	}
+	if binding, ok := menu.Bindings[digits]; ok {
+		return binding, true
+	}
+	if menu.Input != nil {
+		return Binding{Handler: menu.Input}, true
+	}
+	return Binding{}, false
+}
+
+//collect plays the menu prompt and returns the valid digits entered, or an
+//empty string if there were none.
+func (menu *Menu) collect(session *fsclient.Session) (string, error) {
+	timeout := menu.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	digitTimeout := menu.DigitTimeout
+	if digitTimeout == 0 {
+		digitTimeout = defaultDigitTimeout
+	}
+
+	min, max, terminators, pattern := menu.inputSpec()
+	return session.PlayAndGetDigits(menu.Prompt, menu.InvalidPrompt, min, max, menu.Retries+1,
+		timeout, digitTimeout, terminators, pattern)
+}
+
+//inputSpec returns the digit limits, terminators and regular expression that
+//describe valid input for the menu.
+func (menu *Menu) inputSpec() (int, int, string, string) {
+	keys := make([]string, 0, len(menu.Bindings))
+	for key := range menu.Bindings {
+		keys = append(keys, regexp.QuoteMeta(key))
+	}
+	sort.Strings(keys)
+
+	min, max := 0, 0
+	for key := range menu.Bindings {
+		if min == 0 || len(key) < min {
+			min = len(key)
+		}
+		if len(key) > max {
+			max = len(key)
+		}
+	}
+
+	if menu.Input == nil {
+		return min, max, "none", "^(" + strings.Join(keys, "|") + ")$"
+	}
+
+	if menu.MinDigits > 0 && (min == 0 || menu.MinDigits < min) {
+		min = menu.MinDigits
+	}
+	if menu.MaxDigits > max {
+		max = menu.MaxDigits
+	}
+	if min == 0 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	return min, max, "#", `^[0-9*]+$`
+}
@@ -0,0 +1,156 @@
+package ivr_test
+
+import (
+	"testing"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+	"github.com/tomponline/fsclient/fsclient/ivr"
+)
+
+const callerUUID = "11111111-1111-4111-8111-111111111111"
+
+//record returns a handler that records the binding it was selected for and
+//ends the flow.
+func record(selected *[]string, name string) ivr.Handler {
+	return func(session *fsclient.Session, digits string) (*ivr.Menu, error) {
+		*selected = append(*selected, name+":"+digits)
+		return nil, nil
+	}
+}
+
+//TestRun checks which bindings are followed for a caller's input.
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		menu         string
+		digits       []string
+		wantErr      bool
+		wantSelected []string
+		wantPlayback bool
+	}{
+		{"handler", "main", []string{"2"}, false, []string{"support:2"}, false},
+		{"sub-menu", "main", []string{"1", "1"}, false, []string{"sales:1"}, false},
+		{"back", "main", []string{"1", "9", "2"}, false, []string{"support:2"}, false},
+		{"back from first", "main", []string{"9", "2"}, false, []string{"support:2"}, false},
+		{"end", "main", []string{"0"}, false, nil, false},
+		{"failure", "main", []string{""}, false, nil, true},
+		{"on failure", "sales", []string{"1", ""}, false, []string{"operator:"}, false},
+		{"input", "account", []string{"12345"}, false, []string{"account:12345"}, false},
+		{"input binding", "account", []string{"0"}, false, []string{"operator:0"}, false},
+		{"no input", "empty", nil, true, nil, false},
+		{"next menu invalid", "next", []string{"1"}, true, nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var selected []string
+			operator := ivr.Binding{Handler: record(&selected, "operator")}
+			sales := &ivr.Menu{
+				Prompt:    "ivr/sales.wav",
+				Bindings:  map[string]ivr.Binding{"1": {Handler: record(&selected, "sales")}},
+				OnFailure: &operator,
+			}
+			menus := map[string]*ivr.Menu{
+				"main": {
+					Prompt:        "ivr/main.wav",
+					FailurePrompt: "ivr/goodbye.wav",
+					Bindings: map[string]ivr.Binding{
+						"0": {},
+						"1": {Menu: &ivr.Menu{
+							Prompt: "ivr/sales.wav",
+							Bindings: map[string]ivr.Binding{
+								"1": {Handler: record(&selected, "sales")},
+								"9": {Back: true},
+							},
+						}},
+						"2": {Handler: record(&selected, "support")},
+						"9": {Back: true},
+					},
+				},
+				"sales": {Prompt: "ivr/main.wav", Bindings: map[string]ivr.Binding{"1": {Menu: sales}}},
+				"account": {
+					Prompt:    "ivr/account.wav",
+					Bindings:  map[string]ivr.Binding{"0": operator},
+					Input:     record(&selected, "account"),
+					MinDigits: 4,
+					MaxDigits: 8,
+				},
+				"empty": {Prompt: "ivr/main.wav"},
+				"next": {Prompt: "ivr/main.wav", Bindings: map[string]ivr.Binding{"1": {Handler: func(session *fsclient.Session, digits string) (*ivr.Menu, error) {
+					return &ivr.Menu{Prompt: "ivr/empty.wav"}, nil
+				}}}},
+			}
+
+			client := fsclienttest.NewClient()
+			client.Complete("playback", nil)
+			for _, digits := range test.digits {
+				client.CompleteDigits(digits)
+			}
+			session := fsclient.NewSession(client, callerUUID)
+			client.OnEvent(session.HandleEvent)
+
+			err := ivr.Run(session, menus[test.menu])
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Got error %v, want error %v", err, test.wantErr)
+			}
+			if len(selected) != len(test.wantSelected) {
+				t.Fatalf("Selected %q, want %q", selected, test.wantSelected)
+			}
+			for i := range selected {
+				if selected[i] != test.wantSelected[i] {
+					t.Errorf("Selected %q, want %q", selected, test.wantSelected)
+				}
+			}
+
+			collected, played := 0, false
+			for _, call := range client.Calls() {
+				switch call.Name {
+				case "play_and_get_digits":
+					collected++
+				case "playback":
+					played = call.Args == "ivr/goodbye.wav"
+				}
+			}
+			if collected != len(test.digits) {
+				t.Errorf("Collected input %d times, want %d", collected, len(test.digits))
+			}
+			if played != test.wantPlayback {
+				t.Errorf("Played failure prompt %v, want %v", played, test.wantPlayback)
+			}
+		})
+	}
+}
+
+//TestMenuValidate checks that menus that can't accept any input are
+//rejected.
+func TestMenuValidate(t *testing.T) {
+	handler := func(session *fsclient.Session, digits string) (*ivr.Menu, error) {
+		return nil, nil
+	}
+	cyclic := &ivr.Menu{}
+	cyclic.Bindings = map[string]ivr.Binding{"1": {Menu: cyclic}}
+
+	tests := []struct {
+		name    string
+		menu    *ivr.Menu
+		wantErr bool
+	}{
+		{"bindings", &ivr.Menu{Bindings: map[string]ivr.Binding{"1": {Handler: handler}}}, false},
+		{"input", &ivr.Menu{Input: handler, MaxDigits: 4}, false},
+		{"cyclic", cyclic, false},
+		{"empty", &ivr.Menu{Prompt: "ivr/main.wav"}, true},
+		{"empty bindings", &ivr.Menu{Bindings: map[string]ivr.Binding{}}, true},
+		{"no digits", &ivr.Menu{Bindings: map[string]ivr.Binding{"": {Handler: handler}}}, true},
+		{"empty sub-menu", &ivr.Menu{Bindings: map[string]ivr.Binding{"1": {Menu: &ivr.Menu{}}}}, true},
+		{"empty failure menu", &ivr.Menu{Input: handler, OnFailure: &ivr.Binding{Menu: &ivr.Menu{}}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.menu.Validate(); (err != nil) != test.wantErr {
+				t.Errorf("Got error %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package ivr
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/tomponline/fsclient/fsclient"
+)
+
+//PromptSet maps logical prompt names to sound files or "say:" text in one or
+//more languages. A prompt may contain {name} placeholders which are replaced
+//by Render, for variable announcements such as
+//"file_string://you_have.wav!digits/{count}.wav!messages.wav" or
+//"say:You have {count} messages".
+type PromptSet struct {
+	defaultLanguage string
+	prompts         map[string]map[string]string
+}
+
+//NewPromptSet creates an empty PromptSet. Prompts missing in a requested
+//language fall back to defaultLanguage.
+func NewPromptSet(defaultLanguage string) *PromptSet {
+	return &PromptSet{
+		defaultLanguage: defaultLanguage,
+		prompts:         make(map[string]map[string]string),
+	}
+}
+
+//Add adds or replaces a prompt in a language. It is not safe to call Add
+//while the set is in use.
+func (set *PromptSet) Add(language string, name string, prompt string) {
+	if set.prompts[language] == nil {
+		set.prompts[language] = make(map[string]string)
+	}
+	set.prompts[language][name] = prompt
+}
+
+//Lookup returns a prompt in the closest available language: the language
+//itself (e.g. "fr-CA"), its base language ("fr") and then the default
+//language. The bool result is false if the prompt isn't defined.
+func (set *PromptSet) Lookup(name string, language string) (string, bool) {
+	candidates := []string{language}
+	if base, _, ok := strings.Cut(language, "-"); ok {
+		candidates = append(candidates, base)
+	}
+	candidates = append(candidates, set.defaultLanguage)
+
+	for _, candidate := range candidates {
+		if prompt, ok := set.prompts[candidate][name]; ok {
+			return prompt, true
+		}
+	}
+	return "", false
+}
+
+//Render looks up a prompt and replaces its {name} placeholders with vars.
+//Values may not contain characters that would change the meaning of the
+//prompt, such as the "!" file separator or spaces in a file name.
+func (set *PromptSet) Render(name string, language string, vars map[string]string) (string, error) {
+	prompt, ok := set.Lookup(name, language)
+	if !ok {
+		return "", errors.New("Unknown prompt: " + name)
+	}
+	return Substitute(prompt, vars)
+}
+
+//Play renders a prompt and plays it on the session.
+func (set *PromptSet) Play(session *fsclient.Session, name string, language string, vars map[string]string) error {
+	prompt, err := set.Render(name, language, vars)
+	if err != nil {
+		return err
+	}
+	return session.Playback(prompt)
+}
+
+//Substitute replaces the {name} placeholders in a prompt with vars. Spaces
+//are only allowed in values of "say:" prompts.
+func Substitute(prompt string, vars map[string]string) (string, error) {
+	tts := strings.HasPrefix(prompt, "say:")
+
+	var out strings.Builder
+	rest := prompt
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			out.WriteString(rest)
+			return out.String(), nil
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return "", errors.New("Unterminated placeholder in prompt: " + prompt)
+		}
+
+		key := rest[start+1 : start+end]
+		value, ok := vars[key]
+		if !ok {
+			return "", errors.New("Missing value for placeholder: " + key)
+		}
+		if strings.ContainsAny(value, "!{}\r\n") || (!tts && strings.Contains(value, " ")) {
+			return "", errors.New("Invalid value for placeholder: " + key)
+		}
+
+		out.WriteString(rest[:start])
+		out.WriteString(value)
+		rest = rest[start+end+1:]
+	}
+}
+
+//Validate checks that every sound file used by the set exists on the
+//Freeswitch host, using the file_exists api. Relative paths are resolved
+//against the sound_prefix global variable. Text-to-speech, streams and files
+//with placeholders can't be checked and are skipped. The missing files are
+//returned sorted, with a non-nil error if any are missing.
+func (set *PromptSet) Validate(client fsclient.Commander) ([]string, error) {
+	files := make(map[string]bool)
+	for _, prompts := range set.prompts {
+		for _, prompt := range prompts {
+			for _, file := range promptFiles(prompt) {
+				files[file] = true
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	prefix, err := client.API("global_getvar sound_prefix")
+	if err != nil {
+		return nil, err
+	}
+	prefix = strings.TrimSpace(prefix)
+
+	var missing []string
+	for file := range files {
+		full := file
+		if !path.IsAbs(full) {
+			full = path.Join(prefix, full)
+		}
+
+		exists, err := fsclient.FileExists(client, full)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, file)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	sort.Strings(missing)
+	return missing, errors.New("Missing prompt files: " + strings.Join(missing, ", "))
+}
+
+//promptFiles returns the files in a prompt that can be checked for
+//existence.
+func promptFiles(prompt string) []string {
+	parts := []string{prompt}
+	if strings.HasPrefix(prompt, "file_string://") {
+		parts = strings.Split(strings.TrimPrefix(prompt, "file_string://"), "!")
+	}
+
+	var files []string
+	for _, part := range parts {
+		if part == "" || strings.Contains(part, "://") || strings.Contains(part, "{") ||
+			strings.HasPrefix(part, "say:") || strings.HasPrefix(part, "$") {
+			continue
+		}
+		files = append(files, part)
+	}
+	return files
+}
@@ -0,0 +1,72 @@
+package fsclient
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+//ShowResult is the JSON result of a "show ... as json" command, with each
+//row decoded into T, e.g. ShowResult[map[string]string] for any show command.
+type ShowResult[T any] struct {
+	RowCount int `json:"row_count"`
+	Rows     []T `json:"rows"`
+}
+
+//JSONCommand returns cmd with the argument that makes it reply in JSON added,
+//if it is a command that supports one: "as json" for show commands and
+//"json" for uuid_dump. Other commands, and commands that already ask for
+//JSON, are returned as they are.
+func JSONCommand(cmd string) string {
+	cmd = strings.TrimSpace(cmd)
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return cmd
+	}
+
+	switch fields[0] {
+	case "show":
+		if len(fields) >= 3 && fields[len(fields)-2] == "as" {
+			return cmd
+		}
+		return cmd + " as json"
+	case "uuid_dump":
+		if len(fields) == 2 {
+			return cmd + " json"
+		}
+	}
+	return cmd
+}
+
+//ParseJSONResult decodes the JSON result of an api command into T. A -ERR
+//reply, or any other reply that isn't JSON, such as the text of a command
+//that doesn't support JSON output, is returned as an error.
+func ParseJSONResult[T any](res string) (T, error) {
+	var result T
+
+	trimmed := strings.TrimSpace(res)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		line, _, _ := strings.Cut(trimmed, "\n")
+		if strings.HasPrefix(line, "-ERR") {
+			return result, errors.New(line)
+		}
+		return result, errors.New("Reply isn't JSON: " + line)
+	}
+
+	if err := json.Unmarshal([]byte(trimmed), &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+//APIJSON sends an api command, asking for a JSON reply as described for
+//JSONCommand, and decodes the result with ParseJSONResult, e.g.
+//APIJSON[ShowResult[map[string]string]](client, "show registrations").
+func APIJSON[T any](client Commander, cmd string) (T, error) {
+	res, err := client.API(JSONCommand(cmd))
+	if err != nil {
+		var result T
+		return result, err
+	}
+	return ParseJSONResult[T](res)
+}
@@ -0,0 +1,221 @@
+package fsclient
+
+import (
+	"strconv"
+	"strings"
+)
+
+//DiagramFormat is the text format of a call ladder diagram.
+type DiagramFormat int
+
+//Diagram formats. Both can be rendered to SVG by their own tools, e.g. the
+//Mermaid CLI or PlantUML's -tsvg option.
+const (
+	DiagramMermaid DiagramFormat = iota
+	DiagramPlantUML
+)
+
+//ladderStep is an arrow or note in a ladder diagram. A note has no to.
+type ladderStep struct {
+	from   string
+	to     string
+	label  string
+	dashed bool
+}
+
+//ladderParticipant is a column of a ladder diagram.
+type ladderParticipant struct {
+	id    string
+	label string
+}
+
+//Diagram renders the timeline as a SIP ladder style sequence diagram between
+//the channel's far end, Freeswitch and the legs it was bridged to. The far
+//end is labelled from the channel's SIP variables, and SIP messages are
+//inferred from the events, e.g. CHANNEL_PROGRESS as 180 Ringing and the
+//hangup from sip_hangup_disposition. Other steps are shown as notes.
+func (timeline Timeline) Diagram(format DiagramFormat) string {
+	participants, steps := timeline.ladder()
+
+	var b strings.Builder
+	switch format {
+	case DiagramPlantUML:
+		b.WriteString("@startuml\n")
+		for _, participant := range participants {
+			b.WriteString("participant \"" + diagramText(participant.label) + "\" as " + participant.id + "\n")
+		}
+		for _, step := range steps {
+			switch {
+			case step.to == "":
+				b.WriteString("note over " + step.from + ": " + diagramText(step.label) + "\n")
+			case step.dashed:
+				b.WriteString(step.from + " --> " + step.to + ": " + diagramText(step.label) + "\n")
+			default:
+				b.WriteString(step.from + " -> " + step.to + ": " + diagramText(step.label) + "\n")
+			}
+		}
+		b.WriteString("@enduml\n")
+	default:
+		b.WriteString("sequenceDiagram\n")
+		for _, participant := range participants {
+			b.WriteString("    participant " + participant.id + " as " + diagramText(participant.label) + "\n")
+		}
+		for _, step := range steps {
+			switch {
+			case step.to == "":
+				b.WriteString("    Note over " + step.from + ": " + diagramText(step.label) + "\n")
+			case step.dashed:
+				b.WriteString("    " + step.from + "-->>" + step.to + ": " + diagramText(step.label) + "\n")
+			default:
+				b.WriteString("    " + step.from + "->>" + step.to + ": " + diagramText(step.label) + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+//ladder works out the participants and steps of the timeline's diagram.
+//Steps are sent by the far end of an inbound call and by Freeswitch on an
+//outbound one.
+func (timeline Timeline) ladder() ([]ladderParticipant, []ladderStep) {
+	far := ladderParticipant{id: "A", label: "Caller"}
+	inbound := true
+	for _, entry := range timeline {
+		if direction := entry.Event["Call-Direction"]; direction != "" {
+			inbound = direction == "inbound"
+			break
+		}
+	}
+	if !inbound {
+		far.label = "Callee"
+	}
+	for _, entry := range timeline {
+		if label := farEndLabel(entry.Event); label != "" {
+			far.label = label
+			break
+		}
+	}
+	participants := []ladderParticipant{far, {id: "FS", label: "Freeswitch"}}
+
+	//request returns a step from the side that placed the call, and
+	//response one back from the other side.
+	request := func(label string) ladderStep {
+		if inbound {
+			return ladderStep{from: "A", to: "FS", label: label}
+		}
+		return ladderStep{from: "FS", to: "A", label: label}
+	}
+	response := func(label string) ladderStep {
+		step := request(label)
+		step.from, step.to, step.dashed = step.to, step.from, true
+		return step
+	}
+
+	peers := make(map[string]string)
+	peer := func(event Event) string {
+		uuid := bridgedTo(event)
+		if id, ok := peers[uuid]; ok {
+			return id
+		}
+		id := "P" + strconv.Itoa(len(peers)+1)
+		peers[uuid] = id
+		label := firstHeader(event, "Other-Leg-Channel-Name", "Other-Leg-Caller-ID-Number")
+		if label == "" {
+			label = uuid
+		}
+		participants = append(participants, ladderParticipant{id: id, label: label})
+		return id
+	}
+
+	var steps []ladderStep
+	for _, entry := range timeline {
+		event := entry.Event
+		switch entry.Name {
+		case "CHANNEL_CREATE":
+			label := "INVITE " + event["Caller-Destination-Number"]
+			if callID := event["variable_sip_call_id"]; callID != "" {
+				label += " (Call-ID " + callID + ")"
+			}
+			steps = append(steps, request(label))
+		case "CHANNEL_PROGRESS":
+			steps = append(steps, response("180 Ringing"))
+		case "CHANNEL_PROGRESS_MEDIA":
+			steps = append(steps, response("183 Session Progress"))
+		case "CHANNEL_ANSWER":
+			steps = append(steps, response("200 OK"), request("ACK"))
+		case "DTMF":
+			steps = append(steps, ladderStep{from: "A", to: "FS", label: "DTMF " + event["DTMF-Digit"]})
+		case "CHANNEL_BRIDGE":
+			steps = append(steps, ladderStep{from: "FS", to: peer(event), label: "Bridged"})
+		case "CHANNEL_UNBRIDGE":
+			steps = append(steps, ladderStep{from: "FS", to: peer(event), label: "Unbridged", dashed: true})
+		case "CHANNEL_HANGUP_COMPLETE":
+			steps = append(steps, hangupSteps(event)...)
+		default:
+			steps = append(steps, ladderStep{from: "FS", label: entry.Description})
+		}
+	}
+	return participants, steps
+}
+
+//farEndLabel returns a label for the far end of a SIP channel, e.g.
+//"1000@10.0.0.5 (Yealink SIP-T46S)".
+func farEndLabel(event Event) string {
+	user := firstHeader(event, "variable_sip_from_user", "Caller-Username")
+	if event["Call-Direction"] == "outbound" {
+		user = firstHeader(event, "variable_sip_to_user", "Caller-Destination-Number")
+	}
+	host := firstHeader(event, "variable_sip_network_ip", "Caller-Network-Addr")
+	if user == "" && host == "" {
+		return ""
+	}
+
+	label := user
+	if host != "" {
+		label += "@" + host
+	}
+	if agent := event["variable_sip_user_agent"]; agent != "" {
+		label += " (" + agent + ")"
+	}
+	return label
+}
+
+//hangupSteps returns the SIP messages that ended the call, from its
+//sip_hangup_disposition, e.g. recv_bye for a BYE from the far end, with the
+//final SIP status of a call that was refused.
+func hangupSteps(event Event) []ladderStep {
+	cause := event["Hangup-Cause"]
+	status := event["variable_sip_term_status"]
+	disposition := event["variable_sip_hangup_disposition"]
+
+	sent := strings.HasPrefix(disposition, "send_")
+	from, to := "A", "FS"
+	if sent {
+		from, to = "FS", "A"
+	}
+
+	switch strings.TrimPrefix(strings.TrimPrefix(disposition, "send_"), "recv_") {
+	case "bye":
+		return []ladderStep{
+			{from: from, to: to, label: "BYE (" + cause + ")"},
+			{from: to, to: from, label: "200 OK", dashed: true},
+		}
+	case "cancel":
+		return []ladderStep{
+			{from: from, to: to, label: "CANCEL (" + cause + ")"},
+			{from: to, to: from, label: "487 Request Terminated", dashed: true},
+		}
+	case "refuse":
+		if status == "" {
+			status = "Refused"
+		}
+		return []ladderStep{{from: from, to: to, label: status + " (" + cause + ")", dashed: true}}
+	}
+	return []ladderStep{{from: "FS", label: "Hung up: " + cause}}
+}
+
+//diagramText makes text safe for a diagram line, as both formats treat some
+//characters specially.
+func diagramText(text string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ", ";", ",", "#", "", "\"", "'").Replace(text)
+}
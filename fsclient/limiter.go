@@ -0,0 +1,235 @@
+package fsclient
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//LimitKeyFunc returns the key a channel's calls are limited under, e.g. its
+//tenant, gateway or DID, or an empty string if it isn't known yet.
+type LimitKeyFunc func(event Event) string
+
+//LimitKeyFromHeaders returns a LimitKeyFunc that uses the first of headers
+//that is set on an event, e.g. "variable_fsclient_tenant_id",
+//"variable_sip_gateway_name" or "Caller-Destination-Number".
+func LimitKeyFromHeaders(headers ...string) LimitKeyFunc {
+	resolver := TenantFromHeaders(headers...)
+	return func(event Event) string {
+		return resolver(event)
+	}
+}
+
+//CallLimiter counts the active calls under each key, from channel events,
+//and hangs up calls that would take a key over its limit. A call's channels
+//are counted once: a B leg counts as part of the call that originated it, so
+//that a limit keyed on a gateway holds whichever leg goes through it.
+//
+//A channel is counted from its first event with a key, so a key set by a
+//channel variable in the dialplan limits the call as soon as it is set.
+//
+//Register the limiter's HandleEvent method with a Dispatcher. The client must
+//be subscribed to the events the key is read from, such as CHANNEL_CREATE and
+//CHANNEL_ANSWER, and to CHANNEL_HANGUP_COMPLETE.
+type CallLimiter struct {
+	//OnReject, if set, is called when a call is hung up for taking key over
+	//its limit.
+	OnReject func(key string, uuid string, limit int)
+
+	client       Commander
+	key          LimitKeyFunc
+	defaultLimit int
+	limits       map[string]int
+	cause        string
+	channels     map[string]limitedChannel
+	calls        map[string]map[string]int
+	rejected     map[string]bool
+	mu           *sync.Mutex
+}
+
+//limitedChannel is a channel counted by a CallLimiter, under key as part of
+//call, the UUID of its A leg.
+type limitedChannel struct {
+	key  string
+	call string
+}
+
+//NewCallLimiter creates a CallLimiter counting calls under key, allowing up
+//to defaultLimit calls per key, or any number if it is zero. Calls over a
+//limit are hung up with NORMAL_CIRCUIT_CONGESTION.
+func NewCallLimiter(client Commander, key LimitKeyFunc, defaultLimit int) *CallLimiter {
+	return &CallLimiter{
+		client:       client,
+		key:          key,
+		defaultLimit: defaultLimit,
+		limits:       make(map[string]int),
+		cause:        "NORMAL_CIRCUIT_CONGESTION",
+		channels:     make(map[string]limitedChannel),
+		calls:        make(map[string]map[string]int),
+		rejected:     make(map[string]bool),
+		mu:           &sync.Mutex{},
+	}
+}
+
+//SetLimit sets the limit of a key, overriding the default. A negative limit
+//removes the override.
+func (limiter *CallLimiter) SetLimit(key string, limit int) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if limit < 0 {
+		delete(limiter.limits, key)
+		return
+	}
+	limiter.limits[key] = limit
+}
+
+//SetCause sets the hangup cause of calls over their limit, e.g. USER_BUSY.
+func (limiter *CallLimiter) SetCause(cause string) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.cause = cause
+}
+
+//Limit returns the limit of a key, zero meaning unlimited.
+func (limiter *CallLimiter) Limit(key string) int {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return limiter.limit(key)
+}
+
+//limit returns the limit of a key. The lock must be held.
+func (limiter *CallLimiter) limit(key string) int {
+	if limit, ok := limiter.limits[key]; ok {
+		return limit
+	}
+	return limiter.defaultLimit
+}
+
+//Count returns the number of active calls under a key.
+func (limiter *CallLimiter) Count(key string) int {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return len(limiter.calls[key])
+}
+
+//Counts returns the number of active calls under each key that has any.
+func (limiter *CallLimiter) Counts() map[string]int {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	counts := make(map[string]int, len(limiter.calls))
+	for key, calls := range limiter.calls {
+		counts[key] = len(calls)
+	}
+	return counts
+}
+
+//Keys returns the keys with active calls, sorted.
+func (limiter *CallLimiter) Keys() []string {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	keys := make([]string, 0, len(limiter.calls))
+	for key := range limiter.calls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//Check returns an error if a key is at its limit, for refusing a call the
+//application is about to originate.
+func (limiter *CallLimiter) Check(key string) error {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	limit := limiter.limit(key)
+	if limit > 0 && len(limiter.calls[key]) >= limit {
+		return errors.New("Call limit reached for " + key + ": " + strconv.Itoa(limit) + " calls")
+	}
+	return nil
+}
+
+//HandleEvent counts channels as their key becomes known and removes them once
+//they hang up, hanging up channels that take their key over its limit.
+func (limiter *CallLimiter) HandleEvent(event Event) {
+	uuid := event.UUID()
+	if uuid == "" {
+		return
+	}
+
+	if event.Name() == "CHANNEL_HANGUP_COMPLETE" {
+		limiter.remove(uuid)
+		return
+	}
+
+	key := limiter.key(event)
+	if key == "" {
+		return
+	}
+	call := firstHeader(event, "variable_originating_leg_uuid", "variable_originator")
+	if call == "" {
+		call = uuid
+	}
+
+	limiter.mu.Lock()
+	if _, counted := limiter.channels[uuid]; counted || limiter.rejected[uuid] {
+		limiter.mu.Unlock()
+		return
+	}
+	calls := limiter.calls[key]
+	limit := limiter.limit(key)
+	if calls[call] == 0 && limit > 0 && len(calls) >= limit {
+		limiter.rejected[uuid] = true
+		cause := limiter.cause
+		limiter.mu.Unlock()
+		limiter.reject(key, uuid, limit, cause)
+		return
+	}
+	if calls == nil {
+		calls = make(map[string]int)
+		limiter.calls[key] = calls
+	}
+	calls[call]++
+	limiter.channels[uuid] = limitedChannel{key: key, call: call}
+	limiter.mu.Unlock()
+}
+
+//remove stops counting a channel that has hung up.
+func (limiter *CallLimiter) remove(uuid string) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	delete(limiter.rejected, uuid)
+	channel, ok := limiter.channels[uuid]
+	if !ok {
+		return
+	}
+	delete(limiter.channels, uuid)
+
+	calls := limiter.calls[channel.key]
+	if calls[channel.call]--; calls[channel.call] <= 0 {
+		delete(calls, channel.call)
+	}
+	if len(calls) == 0 {
+		delete(limiter.calls, channel.key)
+	}
+}
+
+//reject hangs up a channel over its key's limit.
+func (limiter *CallLimiter) reject(key string, uuid string, limit int, cause string) {
+	res, err := limiter.client.API("uuid_kill " + uuid + " " + cause)
+	if err == nil && !ParseReply(res).OK {
+		err = errors.New(strings.TrimSpace(res))
+	}
+	if err != nil {
+		log.Print(logPrefix, "Failed to hang up call over limit for ", key, ": ", err)
+	}
+
+	if limiter.OnReject != nil {
+		limiter.OnReject(key, uuid, limit)
+	}
+}
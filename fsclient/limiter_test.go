@@ -0,0 +1,130 @@
+package fsclient_test
+
+import (
+	"testing"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+)
+
+//limitEvent is a channel event for the channel uuid with tenant key, as a B
+//leg of the call originator if it is set.
+func limitEvent(name string, uuid string, key string, originator string) map[string]string {
+	event := map[string]string{"Event-Name": name, "Unique-ID": uuid}
+	if key != "" {
+		event["variable_fsclient_tenant_id"] = key
+	}
+	if originator != "" {
+		event["variable_originating_leg_uuid"] = originator
+	}
+	return event
+}
+
+//TestCallLimiter checks the calls counted under each key and which are hung
+//up for going over their limit.
+func TestCallLimiter(t *testing.T) {
+	hangup := func(uuid string) map[string]string {
+		return limitEvent("CHANNEL_HANGUP_COMPLETE", uuid, "", "")
+	}
+
+	tests := []struct {
+		name         string
+		limits       map[string]int
+		events       []map[string]string
+		wantCounts   map[string]int
+		wantKilled   []string
+		wantCheckErr bool
+	}{
+		{"under limit", nil, []map[string]string{
+			limitEvent("CHANNEL_CREATE", callerUUID, "acme", ""),
+		}, map[string]int{"acme": 1}, nil, true},
+		{"over limit", nil, []map[string]string{
+			limitEvent("CHANNEL_CREATE", callerUUID, "acme", ""),
+			limitEvent("CHANNEL_CREATE", agentUUID, "acme", ""),
+			limitEvent("CHANNEL_ANSWER", agentUUID, "acme", ""),
+		}, map[string]int{"acme": 1}, []string{agentUUID}, true},
+		{"b leg", nil, []map[string]string{
+			limitEvent("CHANNEL_CREATE", callerUUID, "acme", ""),
+			limitEvent("CHANNEL_CREATE", agentUUID, "acme", callerUUID),
+		}, map[string]int{"acme": 1}, nil, true},
+		{"hung up", nil, []map[string]string{
+			limitEvent("CHANNEL_CREATE", callerUUID, "acme", ""),
+			hangup(callerUUID),
+			limitEvent("CHANNEL_CREATE", agentUUID, "acme", ""),
+		}, map[string]int{"acme": 1}, nil, true},
+		{"rejected hung up", nil, []map[string]string{
+			limitEvent("CHANNEL_CREATE", callerUUID, "acme", ""),
+			limitEvent("CHANNEL_CREATE", agentUUID, "acme", ""),
+			hangup(agentUUID),
+			limitEvent("CHANNEL_CREATE", consultUUID, "acme", ""),
+		}, map[string]int{"acme": 1}, []string{agentUUID, consultUUID}, true},
+		{"all hung up", nil, []map[string]string{
+			limitEvent("CHANNEL_CREATE", callerUUID, "acme", ""),
+			limitEvent("CHANNEL_CREATE", agentUUID, "acme", callerUUID),
+			hangup(agentUUID),
+			hangup(callerUUID),
+		}, map[string]int{}, nil, false},
+		{"key set later", nil, []map[string]string{
+			limitEvent("CHANNEL_CREATE", callerUUID, "acme", ""),
+			limitEvent("CHANNEL_CREATE", agentUUID, "", ""),
+			limitEvent("CHANNEL_EXECUTE", agentUUID, "acme", ""),
+		}, map[string]int{"acme": 1}, []string{agentUUID}, true},
+		{"other keys", nil, []map[string]string{
+			limitEvent("CHANNEL_CREATE", callerUUID, "acme", ""),
+			limitEvent("CHANNEL_CREATE", agentUUID, "initech", ""),
+		}, map[string]int{"acme": 1, "initech": 1}, nil, true},
+		{"raised limit", map[string]int{"acme": 2}, []map[string]string{
+			limitEvent("CHANNEL_CREATE", callerUUID, "acme", ""),
+			limitEvent("CHANNEL_CREATE", agentUUID, "acme", ""),
+		}, map[string]int{"acme": 2}, nil, true},
+		{"unlimited", map[string]int{"acme": 0}, []map[string]string{
+			limitEvent("CHANNEL_CREATE", callerUUID, "acme", ""),
+			limitEvent("CHANNEL_CREATE", agentUUID, "acme", ""),
+		}, map[string]int{"acme": 2}, nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fsclienttest.NewClient()
+			client.Reply(fsclient.ClassAPI, "uuid_kill", "+OK")
+			limiter := fsclient.NewCallLimiter(client, fsclient.LimitKeyFromHeaders("variable_fsclient_tenant_id"), 1)
+			limiter.SetCause("USER_BUSY")
+			for key, limit := range test.limits {
+				limiter.SetLimit(key, limit)
+			}
+			var rejected []string
+			limiter.OnReject = func(key string, uuid string, limit int) {
+				rejected = append(rejected, uuid)
+			}
+			client.OnEvent(limiter.HandleEvent)
+
+			for _, event := range test.events {
+				client.Inject(event)
+			}
+
+			counts := limiter.Counts()
+			if len(counts) != len(test.wantCounts) {
+				t.Errorf("Got counts %v, want %v", counts, test.wantCounts)
+			}
+			for key, want := range test.wantCounts {
+				if counts[key] != want || limiter.Count(key) != want {
+					t.Errorf("Got counts %v, want %v", counts, test.wantCounts)
+				}
+			}
+
+			cmds := sentCommands(client)
+			if len(cmds) != len(test.wantKilled) || len(rejected) != len(test.wantKilled) {
+				t.Fatalf("Sent %q and rejected %q, want %q killed", cmds, rejected, test.wantKilled)
+			}
+			for i, uuid := range test.wantKilled {
+				if cmds[i] != "uuid_kill "+uuid+" USER_BUSY" || rejected[i] != uuid {
+					t.Errorf("Sent %q and rejected %q, want %q killed", cmds, rejected, test.wantKilled)
+				}
+			}
+
+			if err := limiter.Check("acme"); (err != nil) != test.wantCheckErr {
+				t.Errorf("Got check error %v, want error %v", err, test.wantCheckErr)
+			}
+		})
+	}
+}
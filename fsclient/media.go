@@ -0,0 +1,128 @@
+package fsclient
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+//MediaInfo describes the media of a channel: its codecs, addresses, jitter
+//buffer and RTP statistics. HasStats is false if no RTP statistics were
+//available, e.g. for channels without RTP media. Raw holds all the channel's
+//headers and variables.
+type MediaInfo struct {
+	ReadCodec       string
+	ReadRate        int
+	WriteCodec      string
+	WriteRate       int
+	LocalMediaIP    string
+	LocalMediaPort  int
+	RemoteMediaIP   string
+	RemoteMediaPort int
+	JitterBuffer    string
+
+	HasStats          bool
+	InBytes           int64
+	InPackets         int64
+	InSkipPackets     int64
+	InJitterPackets   int64
+	InFlawTotal       int64
+	OutBytes          int64
+	OutPackets        int64
+	JitterLossRate    float64
+	JitterBurstRate   float64
+	MOS               float64
+	QualityPercentage float64
+
+	Raw map[string]string
+}
+
+//MediaInfo returns the media information for a channel. The channel's RTP
+//statistics are refreshed first with uuid_set_media_stats where the
+//Freeswitch version supports it.
+func (client *Client) MediaInfo(uuid string) (MediaInfo, error) {
+	if err := ValidateUUID(uuid); err != nil {
+		return MediaInfo{}, err
+	}
+
+	//Older versions don't have uuid_set_media_stats, in which case the
+	//statistics are only as recent as the last time they were set.
+	if !client.unsupported(CapabilityMediaStats) {
+		client.API("uuid_set_media_stats " + uuid)
+	}
+
+	res, err := client.API("uuid_dump " + uuid + " json")
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	if strings.HasPrefix(strings.TrimSpace(res), "-ERR") {
+		return MediaInfo{}, errors.New(strings.TrimSpace(res))
+	}
+
+	var dump map[string]string
+	if err := json.Unmarshal([]byte(res), &dump); err != nil {
+		return MediaInfo{}, err
+	}
+	return ParseMediaInfo(dump), nil
+}
+
+//ParseMediaInfo extracts media information from a channel dump or from the
+//headers of a channel event, such as CHANNEL_HANGUP_COMPLETE which carries
+//the final RTP statistics.
+func ParseMediaInfo(headers map[string]string) MediaInfo {
+	info := MediaInfo{
+		ReadCodec:       firstHeader(headers, "Channel-Read-Codec-Name", "variable_read_codec"),
+		ReadRate:        atoiHeader(headers, "Channel-Read-Codec-Rate", "variable_read_rate"),
+		WriteCodec:      firstHeader(headers, "Channel-Write-Codec-Name", "variable_write_codec"),
+		WriteRate:       atoiHeader(headers, "Channel-Write-Codec-Rate", "variable_write_rate"),
+		LocalMediaIP:    headers["variable_local_media_ip"],
+		LocalMediaPort:  atoiHeader(headers, "variable_local_media_port"),
+		RemoteMediaIP:   headers["variable_remote_media_ip"],
+		RemoteMediaPort: atoiHeader(headers, "variable_remote_media_port"),
+		JitterBuffer:    firstHeader(headers, "variable_jitterbuffer_msec", "variable_rtp_jitter_buffer_during_bridge"),
+		Raw:             headers,
+	}
+
+	_, info.HasStats = headers["variable_rtp_audio_in_raw_bytes"]
+	info.InBytes = int64Header(headers, "variable_rtp_audio_in_raw_bytes")
+	info.InPackets = int64Header(headers, "variable_rtp_audio_in_packet_count")
+	info.InSkipPackets = int64Header(headers, "variable_rtp_audio_in_skip_packet_count")
+	info.InJitterPackets = int64Header(headers, "variable_rtp_audio_in_jitter_packet_count")
+	info.InFlawTotal = int64Header(headers, "variable_rtp_audio_in_flaw_total")
+	info.OutBytes = int64Header(headers, "variable_rtp_audio_out_raw_bytes")
+	info.OutPackets = int64Header(headers, "variable_rtp_audio_out_packet_count")
+	info.JitterLossRate = floatHeader(headers, "variable_rtp_audio_in_jitter_loss_rate")
+	info.JitterBurstRate = floatHeader(headers, "variable_rtp_audio_in_jitter_burst_rate")
+	info.MOS = floatHeader(headers, "variable_rtp_audio_in_mos")
+	info.QualityPercentage = floatHeader(headers, "variable_rtp_audio_in_quality_percentage")
+	return info
+}
+
+//firstHeader returns the first of names that is set in headers.
+func firstHeader(headers map[string]string, names ...string) string {
+	for _, name := range names {
+		if value := headers[name]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+//atoiHeader returns the first of names that is set in headers as an int.
+func atoiHeader(headers map[string]string, names ...string) int {
+	value, _ := strconv.Atoi(firstHeader(headers, names...))
+	return value
+}
+
+//int64Header returns a header as an int64.
+func int64Header(headers map[string]string, name string) int64 {
+	value, _ := strconv.ParseInt(headers[name], 10, 64)
+	return value
+}
+
+//floatHeader returns a header as a float64.
+func floatHeader(headers map[string]string, name string) float64 {
+	value, _ := strconv.ParseFloat(headers[name], 64)
+	return value
+}
@@ -0,0 +1,193 @@
+package fsclient
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+//MonitorOptions configure a CallMonitor.
+//
+//ListenAddr is the local UDP address the audio is received on, by default an
+//ephemeral port on all interfaces. AdvertiseAddr is the address Freeswitch
+//sends the audio to, by default the listener's address, or if that is all
+//interfaces its port on the address this host uses to reach Freeswitch, which
+//is only known when monitoring with a Client. FreeswitchAddr is the address Freeswitch binds its
+//side of the stream to, by default an ephemeral port on all interfaces.
+//Native sends the audio in the channel's codec instead of 16 bit linear PCM.
+type MonitorOptions struct {
+	ListenAddr     string
+	AdvertiseAddr  string
+	FreeswitchAddr string
+	Native         bool
+}
+
+//CallMonitor delivers the live audio of a call to an io.Writer, such as a
+//transcription service's stream or a UDP connection, where each Write is one
+//packet of audio. It eavesdrops on the call from a loopback channel, UUID,
+//whose audio is sent to a local UDP socket with unicast. Register HandleEvent
+//with a Dispatcher so the monitor stops when either channel hangs up.
+type CallMonitor struct {
+	UUID     string
+	CallUUID string
+	client   Commander
+	conn     net.PacketConn
+	sink     io.Writer
+	done     chan struct{}
+	stopOnce *sync.Once
+	err      error
+	mu       *sync.Mutex
+}
+
+//MonitorCall starts delivering the audio of the call uuid, both directions
+//mixed, to sink, using client, usually a Client. Writes to sink are made from
+//the monitor's own goroutine.
+func MonitorCall(client Commander, uuid string, sink io.Writer, opts MonitorOptions) (*CallMonitor, error) {
+	if err := ValidateUUID(uuid); err != nil {
+		return nil, err
+	}
+	if sink == nil {
+		return nil, errors.New("Call monitor has no sink")
+	}
+
+	listenAddr := opts.ListenAddr
+	if listenAddr == "" {
+		listenAddr = ":0"
+	}
+	conn, err := net.ListenPacket("udp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	advertiseAddr := opts.AdvertiseAddr
+	if advertiseAddr == "" {
+		if advertiseAddr, err = monitorAddr(client, conn.LocalAddr()); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	monitor := &CallMonitor{
+		UUID:     NewUUID(),
+		CallUUID: uuid,
+		client:   client,
+		conn:     conn,
+		sink:     sink,
+		done:     make(chan struct{}),
+		stopOnce: &sync.Once{},
+		mu:       &sync.Mutex{},
+	}
+
+	//The loopback channel's other leg answers and eavesdrops on the call,
+	//so the call's audio is what the parked leg reads and sends on.
+	req := OriginateRequest{
+		Endpoint:     "loopback/answer,eavesdrop:" + uuid + "/default/inline",
+		App:          "park",
+		CallerIDName: "monitor",
+		Variables:    map[string]string{"origination_uuid": monitor.UUID},
+	}
+	if _, err := req.Originate(client); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	freeswitchAddr := opts.FreeswitchAddr
+	if freeswitchAddr == "" {
+		freeswitchAddr = "0.0.0.0:0"
+	}
+	flags := ""
+	if opts.Native {
+		flags = UnicastNative
+	}
+	if err := unicast(client, monitor.UUID, freeswitchAddr, advertiseAddr, UnicastUDP, flags); err != nil {
+		client.API("uuid_kill " + monitor.UUID)
+		conn.Close()
+		return nil, err
+	}
+
+	go monitor.run()
+	return monitor, nil
+}
+
+//monitorAddr returns the address Freeswitch can send to a local listener on,
+//using the local address of a UDP socket "connected" to the Freeswitch host,
+//which sends nothing, if the listener is on all interfaces.
+func monitorAddr(client Commander, local net.Addr) (string, error) {
+	udpAddr, ok := local.(*net.UDPAddr)
+	if !ok || !udpAddr.IP.IsUnspecified() {
+		return local.String(), nil
+	}
+
+	fsClient, ok := client.(*Client)
+	if !ok {
+		return "", errors.New("Call monitor has no advertise address")
+	}
+	probe, err := net.Dial("udp", fsClient.addr)
+	if err != nil {
+		return "", err
+	}
+	defer probe.Close()
+
+	host := probe.LocalAddr().(*net.UDPAddr).IP
+	return (&net.UDPAddr{IP: host, Port: udpAddr.Port}).String(), nil
+}
+
+//run copies received audio to the sink until the monitor is stopped or a
+//write fails.
+func (monitor *CallMonitor) run() {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := monitor.conn.ReadFrom(buf)
+		if err != nil {
+			monitor.finish(nil)
+			return
+		}
+		if _, err := monitor.sink.Write(buf[:n]); err != nil {
+			monitor.finish(err)
+			return
+		}
+	}
+}
+
+//Stop hangs up the monitoring channel and stops delivering audio. The call
+//being monitored isn't affected.
+func (monitor *CallMonitor) Stop() {
+	monitor.finish(nil)
+}
+
+//Done returns a channel that is closed when the monitor stops.
+func (monitor *CallMonitor) Done() <-chan struct{} {
+	return monitor.done
+}
+
+//Err returns the error writing to the sink that stopped the monitor, if any.
+func (monitor *CallMonitor) Err() error {
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+	return monitor.err
+}
+
+//HandleEvent stops the monitor when the call or the monitoring channel hangs
+//up.
+func (monitor *CallMonitor) HandleEvent(event Event) {
+	if event.Name() != "CHANNEL_HANGUP_COMPLETE" {
+		return
+	}
+	if uuid := event.UUID(); uuid == monitor.UUID || uuid == monitor.CallUUID {
+		monitor.finish(nil)
+	}
+}
+
+//finish stops the monitor once, recording err.
+func (monitor *CallMonitor) finish(err error) {
+	monitor.stopOnce.Do(func() {
+		monitor.mu.Lock()
+		monitor.err = err
+		monitor.mu.Unlock()
+
+		monitor.conn.Close()
+		go monitor.client.API("uuid_kill " + monitor.UUID)
+		close(monitor.done)
+	})
+}
@@ -0,0 +1,175 @@
+package fsclient_test
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+)
+
+//unicastRemote matches the address a unicast command sends media to.
+var unicastRemote = regexp.MustCompile(`remote-ip: (.*)\nremote-port: (.*)\n`)
+
+//packetSink is an io.Writer that delivers each write as a packet, failing
+//the write numbered fail if it is set.
+type packetSink struct {
+	packets chan string
+	writes  int
+	fail    int
+}
+
+//Write delivers a packet.
+func (sink *packetSink) Write(p []byte) (int, error) {
+	if sink.writes++; sink.writes == sink.fail {
+		return 0, errors.New("Sink closed")
+	}
+	sink.packets <- string(p)
+	return len(p), nil
+}
+
+//waitKilled waits for the client to be sent a uuid_kill, which a monitor
+//sends from its own goroutine when it stops, and returns whether it was.
+func waitKilled(client *fsclienttest.Client) bool {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		for _, call := range client.Calls() {
+			if call.Name == "uuid_kill" {
+				return true
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
+
+//TestMonitorCall checks that the audio sent to a monitor is delivered to its
+//sink until it stops, and that its channel is hung up when it does.
+func TestMonitorCall(t *testing.T) {
+	tests := []struct {
+		name    string
+		stop    func(client *fsclienttest.Client, monitor *fsclient.CallMonitor)
+		fail    int
+		wantErr bool
+	}{
+		{"stopped", func(client *fsclienttest.Client, monitor *fsclient.CallMonitor) {
+			monitor.Stop()
+		}, 0, false},
+		{"call hung up", func(client *fsclienttest.Client, monitor *fsclient.CallMonitor) {
+			client.Hangup(callerUUID, "NORMAL_CLEARING")
+		}, 0, false},
+		{"monitor hung up", func(client *fsclienttest.Client, monitor *fsclient.CallMonitor) {
+			client.Hangup(monitor.UUID, "NORMAL_CLEARING")
+		}, 0, false},
+		{"sink failed", func(client *fsclienttest.Client, monitor *fsclient.CallMonitor) {
+		}, 3, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fsclienttest.NewClient()
+			scriptCommands(client)
+			sink := &packetSink{packets: make(chan string, 10), fail: test.fail}
+
+			monitor, err := fsclient.MonitorCall(client, callerUUID, sink, fsclient.MonitorOptions{ListenAddr: "127.0.0.1:0", Native: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.OnEvent(monitor.HandleEvent)
+
+			cmds := sentCommands(client)
+			if len(cmds) != 2 || !strings.Contains(cmds[0], "origination_uuid="+monitor.UUID+"}loopback/answer,eavesdrop:"+callerUUID+"/default/inline &park()") {
+				t.Fatalf("Sent %q", cmds)
+			}
+			unicast := client.Calls()[1].Args
+			match := unicastRemote.FindStringSubmatch(unicast)
+			if match == nil || !strings.HasPrefix(unicast, monitor.UUID) || !strings.Contains(unicast, "flags: native") {
+				t.Fatalf("Sent unicast %q", unicast)
+			}
+
+			conn, err := net.Dial("udp", net.JoinHostPort(match[1], match[2]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			for _, packet := range []string{"one", "two", "three"} {
+				conn.Write([]byte(packet))
+			}
+			for _, want := range []string{"one", "two"} {
+				select {
+				case packet := <-sink.packets:
+					if packet != want {
+						t.Errorf("Got packet %q, want %q", packet, want)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("Audio not delivered")
+				}
+			}
+
+			test.stop(client, monitor)
+			select {
+			case <-monitor.Done():
+			case <-time.After(time.Second):
+				t.Fatal("Monitor not stopped")
+			}
+			if (monitor.Err() != nil) != test.wantErr {
+				t.Errorf("Got error %v, want error %v", monitor.Err(), test.wantErr)
+			}
+			if !waitKilled(client) {
+				t.Error("Monitoring channel not hung up")
+			}
+		})
+	}
+}
+
+//TestMonitorCallFailed checks that a monitor isn't started, and its channel
+//is hung up, if it can't be set up.
+func TestMonitorCallFailed(t *testing.T) {
+	tests := []struct {
+		name       string
+		uuid       string
+		sink       bool
+		opts       fsclient.MonitorOptions
+		fail       string
+		wantCmds   int
+		wantKilled bool
+	}{
+		{"invalid uuid", "1234", true, fsclient.MonitorOptions{ListenAddr: "127.0.0.1:0"}, "", 0, false},
+		{"no sink", callerUUID, false, fsclient.MonitorOptions{ListenAddr: "127.0.0.1:0"}, "", 0, false},
+		{"no advertise address", callerUUID, true, fsclient.MonitorOptions{}, "", 0, false},
+		{"originate failed", callerUUID, true, fsclient.MonitorOptions{ListenAddr: "127.0.0.1:0"}, "originate", 1, false},
+		{"unicast failed", callerUUID, true, fsclient.MonitorOptions{ListenAddr: "127.0.0.1:0"}, "sendmsg", 3, true},
+		{"invalid address", callerUUID, true, fsclient.MonitorOptions{ListenAddr: "127.0.0.1:0", FreeswitchAddr: "0.0.0.0"}, "", 2, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fsclienttest.NewClient()
+			scriptCommands(client, test.fail)
+			var sink *packetSink
+			if test.sink {
+				sink = &packetSink{packets: make(chan string, 10)}
+			}
+
+			var err error
+			if sink != nil {
+				_, err = fsclient.MonitorCall(client, test.uuid, sink, test.opts)
+			} else {
+				_, err = fsclient.MonitorCall(client, test.uuid, nil, test.opts)
+			}
+			if err == nil {
+				t.Fatal("Monitor started")
+			}
+
+			cmds := sentCommands(client)
+			killed := len(cmds) > 0 && strings.HasPrefix(cmds[len(cmds)-1], "uuid_kill ")
+			if len(cmds) != test.wantCmds || killed != test.wantKilled {
+				t.Errorf("Sent %q", cmds)
+			}
+		})
+	}
+}
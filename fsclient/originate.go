@@ -0,0 +1,250 @@
+package fsclient
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//OriginateRequest is an originate api command. The call to Endpoint, e.g.
+//"user/1000" or "sofia/gateway/carrier/15551234567", is connected to the
+//dialplan Extension, or to App with AppArgs if App is set.
+//
+//CallerIDName, CallerIDNumber, Timeout and IgnoreEarlyMedia are set as their
+//channel variables, taking precedence over the same ones in Variables, as are
+//the SIP headers of the AutoAnswer profile if one is set. SIPHeaders are
+//added to the INVITE and, with ExportSIPHeaders, exported so that they are
+//also sent to calls the channel is bridged to. Identity is a signed
+//STIR/SHAKEN Identity header for the INVITE, see ParseIdentity.
+//
+//OnProgress, if set, is called when a call originated with
+//CallManager.Originate starts ringing or gets early media, after the
+//manager's OnProgress callbacks. Set IgnoreEarlyMedia too to keep the
+//originate waiting for answer rather than treating early media as success.
+type OriginateRequest struct {
+	Endpoint         string
+	Extension        string
+	Dialplan         string
+	Context          string
+	App              string
+	AppArgs          string
+	CallerIDName     string
+	CallerIDNumber   string
+	Timeout          time.Duration
+	IgnoreEarlyMedia bool
+	AutoAnswer       AutoAnswerProfile
+	SIPHeaders       SIPHeaders
+	ExportSIPHeaders bool
+	Identity         string
+	Variables        map[string]string
+	OnProgress       func(call *Call, progress Progress)
+}
+
+//Command returns the originate api command for the request. Variables and
+//arguments are quoted and escaped so that values containing commas, spaces
+//and quotes reach the channel unchanged.
+func (req OriginateRequest) Command() (string, error) {
+	if req.Endpoint == "" {
+		return "", errors.New("Originate request has no endpoint")
+	}
+	if (req.Extension == "") == (req.App == "") {
+		return "", errors.New("Originate request needs one of an extension or an app")
+	}
+	vars, err := req.variables()
+	if err != nil {
+		return "", err
+	}
+
+	destination := quoteArg(req.Extension)
+	if req.App != "" {
+		destination = quoteArg("&" + req.App + "(" + req.AppArgs + ")")
+	}
+	if req.Dialplan != "" || req.Context != "" {
+		dialplan := req.Dialplan
+		if dialplan == "" {
+			dialplan = "XML"
+		}
+		destination += " " + quoteArg(dialplan)
+		if req.Context != "" {
+			destination += " " + quoteArg(req.Context)
+		}
+	}
+
+	return OriginateCommand(req.Endpoint, destination, vars), nil
+}
+
+//DialString returns the request's endpoint with its channel variables in
+//the {key=value,...} prefix, for the bridge application. The Extension or App
+//is ignored.
+func (req OriginateRequest) DialString() (string, error) {
+	if req.Endpoint == "" {
+		return "", errors.New("Originate request has no endpoint")
+	}
+	vars, err := req.variables()
+	if err != nil {
+		return "", err
+	}
+	return OriginateVariables(vars) + req.Endpoint, nil
+}
+
+//variables checks the request's endpoint and returns its channel variables,
+//including those set by its other fields.
+func (req OriginateRequest) variables() (map[string]string, error) {
+	if strings.ContainsAny(req.Endpoint, " \n") {
+		return nil, errors.New("Invalid originate endpoint: " + req.Endpoint)
+	}
+	if uuid, ok := req.Variables["origination_uuid"]; ok {
+		if err := ValidateUUID(uuid); err != nil {
+			return nil, err
+		}
+	}
+
+	vars := make(map[string]string, len(req.Variables)+4)
+	for key, value := range req.Variables {
+		vars[key] = value
+	}
+	if req.CallerIDName != "" {
+		vars["origination_caller_id_name"] = req.CallerIDName
+	}
+	if req.CallerIDNumber != "" {
+		vars["origination_caller_id_number"] = req.CallerIDNumber
+	}
+	if req.Timeout > 0 {
+		vars["originate_timeout"] = strconv.Itoa(int(math.Ceil(req.Timeout.Seconds())))
+	}
+	if req.IgnoreEarlyMedia {
+		vars["ignore_early_media"] = "true"
+	}
+	if req.AutoAnswer != "" {
+		autoAnswer, err := AutoAnswerVars(req.AutoAnswer)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range autoAnswer {
+			vars[key] = value
+		}
+	}
+	if len(req.SIPHeaders) > 0 {
+		if err := req.SIPHeaders.Validate(); err != nil {
+			return nil, err
+		}
+		for key, value := range req.SIPHeaders.Vars() {
+			vars[key] = value
+		}
+		if req.ExportSIPHeaders {
+			exports := make([]string, 0, len(req.SIPHeaders)+1)
+			if vars["export_vars"] != "" {
+				exports = append(exports, vars["export_vars"])
+			}
+			for _, name := range req.SIPHeaders.names() {
+				exports = append(exports, SIPHeaderPrefix+name)
+			}
+			vars["export_vars"] = strings.Join(exports, ",")
+		}
+	}
+	if req.Identity != "" {
+		if strings.ContainsAny(req.Identity, "\r\n") {
+			return nil, errors.New("Invalid Identity header")
+		}
+		vars[SIPHeaderPrefix+IdentityHeader] = req.Identity
+	}
+	return vars, nil
+}
+
+//Originate originates the call, waiting until it is answered, and returns
+//the new channel's UUID.
+func (req OriginateRequest) Originate(client Commander) (string, error) {
+	cmd, err := req.Command()
+	if err != nil {
+		return "", err
+	}
+	return originate(client, cmd)
+}
+
+//BackgroundOriginate originates the call with bgapi and returns the job UUID
+//of the BACKGROUND_JOB event with the result.
+func (req OriginateRequest) BackgroundOriginate(client Commander) (string, error) {
+	cmd, err := req.Command()
+	if err != nil {
+		return "", err
+	}
+	return client.BackgroundAPI(cmd)
+}
+
+//OriginateCommand builds an originate api command for endpoint, connecting the
+//call to destination (an extension or "&app(args)"), with channel variables
+//rendered in the {key=value,...} prefix. Variables are sorted by name and
+//escaped as described for OriginateVariables.
+func OriginateCommand(endpoint string, destination string, vars map[string]string) string {
+	return "originate " + OriginateVariables(vars) + endpoint + " " + destination
+}
+
+//OriginateVariables renders channel variables as the {key=value,...} prefix of
+//an originate endpoint, or an empty string if there are none. Values
+//containing commas, such as export_vars lists, are kept whole by switching to
+//a delimiter that no variable uses with the ^^ prefix, e.g.
+//{^^:export_vars=a,b:foo=bar}. Values containing spaces or quotes are single
+//quoted with their quotes and backslashes escaped.
+func OriginateVariables(vars map[string]string) string {
+	if len(vars) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+QuoteVariable(vars[key]))
+	}
+
+	//Use the first delimiter no variable contains, quoting values as a last
+	//resort if they all do.
+	switch delimiter := unusedDelimiter(pairs); delimiter {
+	case ",":
+		return "{" + strings.Join(pairs, ",") + "}"
+	case "":
+		for i, key := range keys {
+			pairs[i] = key + "=" + quoteValue(vars[key])
+		}
+		return "{" + strings.Join(pairs, ",") + "}"
+	default:
+		return "{^^" + delimiter + strings.Join(pairs, delimiter) + "}"
+	}
+}
+
+//quoteArg single quotes an originate argument that contains spaces, such as
+//an app with arguments, so that it isn't split into several arguments.
+func quoteArg(arg string) string {
+	if strings.ContainsAny(arg, " '") {
+		return quoteValue(arg)
+	}
+	return arg
+}
+
+//Originate originates a call with the api command built by OriginateCommand,
+//waiting until it is answered, and returns the new channel's UUID.
+func (client *Client) Originate(endpoint string, destination string, vars map[string]string) (string, error) {
+	return originate(client, OriginateCommand(endpoint, destination, vars))
+}
+
+//originate sends an originate api command and returns the new channel's
+//UUID.
+func originate(client Commander, cmd string) (string, error) {
+	res, err := client.API(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	reply := ParseReply(res)
+	if !reply.OK {
+		return "", errors.New(strings.TrimSpace(res))
+	}
+	return reply.UUID, nil
+}
@@ -0,0 +1,74 @@
+package fsclient
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+//pageTimeout is how long each phone in a group page rings before it is
+//given up on, as phones that auto answer do so straight away.
+const pageTimeout = 10 * time.Second
+
+//PageResult is the result of paging one extension. UUID is the channel
+//playing the announcement, if the phone answered.
+type PageResult struct {
+	Extension string
+	UUID      string
+	Err       error
+}
+
+//GroupPage pages extensions, e.g. "1000" for the registered user or a full
+//endpoint such as "sofia/gateway/paging/2000", asking each phone to auto
+//answer and playing announceFile to it. The extensions are called at the
+//same time and the announcement starts on each as soon as it answers. A
+//result is returned for every extension, and an error only if none of them
+//could be paged.
+func GroupPage(client Commander, extensions []string, announceFile string) ([]PageResult, error) {
+	return GroupPageProfile(client, extensions, announceFile, AutoAnswerGeneric)
+}
+
+//GroupPageProfile pages extensions like GroupPage, asking the phones to auto
+//answer with the headers of a vendor profile.
+func GroupPageProfile(client Commander, extensions []string, announceFile string, profile AutoAnswerProfile) ([]PageResult, error) {
+	if len(extensions) == 0 {
+		return nil, errors.New("Page has no extensions")
+	}
+	if announceFile == "" {
+		return nil, errors.New("Page has no announcement")
+	}
+
+	results := make([]PageResult, len(extensions))
+	wg := &sync.WaitGroup{}
+	for i, extension := range extensions {
+		results[i].Extension = extension
+
+		endpoint := extension
+		if !strings.Contains(endpoint, "/") {
+			endpoint = "user/" + extension
+		}
+		req := OriginateRequest{
+			Endpoint:         endpoint,
+			App:              "playback",
+			AppArgs:          announceFile,
+			Timeout:          pageTimeout,
+			IgnoreEarlyMedia: true,
+			AutoAnswer:       profile,
+		}
+
+		wg.Add(1)
+		go func(result *PageResult) {
+			defer wg.Done()
+			result.UUID, result.Err = req.Originate(client)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Err == nil {
+			return results, nil
+		}
+	}
+	return results, errors.New("No extensions could be paged: " + results[0].Err.Error())
+}
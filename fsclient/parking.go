@@ -0,0 +1,214 @@
+package fsclient
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//errLotFull is returned when parking a call in a lot with no free slots.
+var errLotFull = errors.New("Parking lot full")
+
+//ParkedCall is a call waiting in a ParkingLot slot. Parker is the endpoint
+//that parked it, e.g. "user/1000", which is rung back on timeout.
+type ParkedCall struct {
+	Slot     string
+	UUID     string
+	Parker   string
+	ParkedAt time.Time
+}
+
+//ParkingLot parks calls in numbered slots of a valet_parking lot, tracks
+//which slots are occupied from valet_parking events, including calls parked
+//by the dialplan, and rings back the parker of a call that isn't picked up in
+//time.
+//
+//Register the lot's HandleEvent method with a Dispatcher. The client must be
+//subscribed to "CUSTOM valet_parking::info" and CHANNEL_HANGUP_COMPLETE.
+type ParkingLot struct {
+	Name string
+
+	//OnTimeout, if set, is called when a call has been parked for longer than
+	//the lot's timeout, after the parker has been rung back. The call stays
+	//parked.
+	OnTimeout func(parked ParkedCall)
+
+	client  *Client
+	minSlot int
+	maxSlot int
+	timeout time.Duration
+	slots   map[string]*ParkedCall
+	timers  map[string]Timer
+	mu      *sync.Mutex
+}
+
+//NewParkingLot creates a ParkingLot for the valet_parking lot name using
+//slots minSlot to maxSlot. Calls parked for longer than timeout (zero for no
+//timeout) are rung back.
+func NewParkingLot(client *Client, name string, minSlot int, maxSlot int, timeout time.Duration) *ParkingLot {
+	return &ParkingLot{
+		Name:    name,
+		client:  client,
+		minSlot: minSlot,
+		maxSlot: maxSlot,
+		timeout: timeout,
+		slots:   make(map[string]*ParkedCall),
+		timers:  make(map[string]Timer),
+		mu:      &sync.Mutex{},
+	}
+}
+
+//Park transfers the channel uuid into the lowest free slot and returns the
+//slot. parker is the endpoint to ring back on timeout, or empty for none.
+func (lot *ParkingLot) Park(uuid string, parker string) (string, error) {
+	if err := ValidateUUID(uuid); err != nil {
+		return "", err
+	}
+
+	lot.mu.Lock()
+	slot := ""
+	for i := lot.minSlot; i <= lot.maxSlot; i++ {
+		if _, used := lot.slots[strconv.Itoa(i)]; !used {
+			slot = strconv.Itoa(i)
+			break
+		}
+	}
+	if slot == "" {
+		lot.mu.Unlock()
+		return "", errLotFull
+	}
+
+	//Reserve the slot until the hold event confirms it.
+	lot.slots[slot] = &ParkedCall{Slot: slot, UUID: uuid, Parker: parker, ParkedAt: lot.client.now()}
+	lot.mu.Unlock()
+
+	if err := lot.client.apiOK("uuid_transfer " + uuid + " " + lot.destination(slot)); err != nil {
+		lot.release(slot)
+		return "", err
+	}
+	return slot, nil
+}
+
+//Pickup transfers the channel uuid to the call parked in slot, bridging the
+//two.
+func (lot *ParkingLot) Pickup(uuid string, slot string) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+	return lot.client.apiOK("uuid_transfer " + uuid + " " + lot.destination(slot))
+}
+
+//Occupancy returns the parked calls sorted by slot.
+func (lot *ParkingLot) Occupancy() []ParkedCall {
+	lot.mu.Lock()
+	defer lot.mu.Unlock()
+
+	parked := make([]ParkedCall, 0, len(lot.slots))
+	for _, call := range lot.slots {
+		parked = append(parked, *call)
+	}
+	sort.Slice(parked, func(i, j int) bool {
+		a, _ := strconv.Atoi(parked[i].Slot)
+		b, _ := strconv.Atoi(parked[j].Slot)
+		return a < b
+	})
+	return parked
+}
+
+//HandleEvent updates the lot's occupancy from valet_parking and hangup
+//events.
+func (lot *ParkingLot) HandleEvent(event Event) {
+	switch {
+	case event.Name() == "CUSTOM" && event["Event-Subclass"] == "valet_parking::info":
+		if event["Valet-Lot-Name"] != lot.Name {
+			return
+		}
+
+		slot := event["Valet-Extension"]
+		switch event["Action"] {
+		case "hold":
+			lot.hold(slot, event.UUID())
+		case "bridge", "exit":
+			lot.release(slot)
+		}
+
+	case event.Name() == "CHANNEL_HANGUP_COMPLETE":
+		lot.mu.Lock()
+		slot := ""
+		for _, call := range lot.slots {
+			if call.UUID == event.UUID() {
+				slot = call.Slot
+				break
+			}
+		}
+		lot.mu.Unlock()
+
+		if slot != "" {
+			lot.release(slot)
+		}
+	}
+}
+
+//destination returns the inline dialplan that parks in or picks up from a
+//slot.
+func (lot *ParkingLot) destination(slot string) string {
+	return "'valet_parking:" + lot.Name + " " + slot + "' inline"
+}
+
+//hold records a call as parked in a slot and starts its timeout.
+func (lot *ParkingLot) hold(slot string, uuid string) {
+	lot.mu.Lock()
+	defer lot.mu.Unlock()
+
+	call, ok := lot.slots[slot]
+	if !ok || call.UUID != uuid {
+		//Parked by the dialplan rather than Park.
+		call = &ParkedCall{Slot: slot, UUID: uuid, ParkedAt: lot.client.now()}
+		lot.slots[slot] = call
+	}
+
+	if timer, ok := lot.timers[slot]; ok {
+		timer.Stop()
+	}
+	if lot.timeout > 0 {
+		parked := *call
+		lot.timers[slot] = lot.client.clockSource().AfterFunc(lot.timeout, func() { lot.expire(parked) })
+	}
+}
+
+//release frees a slot.
+func (lot *ParkingLot) release(slot string) {
+	lot.mu.Lock()
+	defer lot.mu.Unlock()
+
+	delete(lot.slots, slot)
+	if timer, ok := lot.timers[slot]; ok {
+		timer.Stop()
+		delete(lot.timers, slot)
+	}
+}
+
+//expire rings back the parker of a call that has been parked too long.
+func (lot *ParkingLot) expire(parked ParkedCall) {
+	lot.mu.Lock()
+	current, ok := lot.slots[parked.Slot]
+	lot.mu.Unlock()
+
+	if !ok || current.UUID != parked.UUID {
+		return
+	}
+
+	if parked.Parker != "" {
+		vars := map[string]string{"origination_caller_id_name": "Parked_" + parked.Slot}
+		if _, err := lot.client.BackgroundAPI(OriginateCommand(parked.Parker, lot.destination(parked.Slot), vars)); err != nil {
+			log.Print(logPrefix, "Failed to ring back parker ", parked.Parker, " for slot ", parked.Slot, ": ", err)
+		}
+	}
+
+	if lot.OnTimeout != nil {
+		lot.OnTimeout(parked)
+	}
+}
@@ -0,0 +1,24 @@
+package fsclient
+
+//PasswordProvider supplies the event socket password. Password is called each
+//time a client connects or reconnects, so the password can be fetched from a
+//secrets store such as Vault or SSM and rotated without restarting.
+type PasswordProvider interface {
+	Password() (string, error)
+}
+
+//PasswordFunc is a PasswordProvider implemented by a function.
+type PasswordFunc func() (string, error)
+
+//Password calls the function.
+func (fn PasswordFunc) Password() (string, error) {
+	return fn()
+}
+
+//staticPassword is a PasswordProvider for a fixed password.
+type staticPassword string
+
+//Password returns the fixed password.
+func (password staticPassword) Password() (string, error) {
+	return string(password), nil
+}
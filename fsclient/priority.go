@@ -0,0 +1,120 @@
+package fsclient
+
+import (
+	"strings"
+	"sync"
+)
+
+//Priority orders outgoing commands waiting for the connection. Commands are
+//sent one at a time, so when several are waiting the highest priority is sent
+//next, and commands of the same priority are sent in the order they arrived.
+type Priority int
+
+//Command priorities.
+const (
+	PriorityLow    Priority = iota //Monitoring queries, e.g. "show channels".
+	PriorityNormal                 //Everything else.
+	PriorityHigh                   //Call control, e.g. "uuid_kill".
+)
+
+//priorityRule gives commands whose command line starts with prefix a
+//priority.
+type priorityRule struct {
+	prefix   string
+	priority Priority
+}
+
+//defaultPriorityRules are the built in priorities of api and bgapi commands.
+//Execute commands are always PriorityHigh.
+var defaultPriorityRules = []priorityRule{
+	{"uuid_", PriorityHigh},
+	{"hupall", PriorityHigh},
+	{"originate", PriorityHigh},
+	{"show ", PriorityLow},
+	{"status", PriorityLow},
+	{"sofia status", PriorityLow},
+	{"sofia xmlstatus", PriorityLow},
+	{"version", PriorityLow},
+	{"list_users", PriorityLow},
+	{"global_getvar", PriorityLow},
+}
+
+//SetCommandPriority gives api and bgapi commands that start with prefix,
+//e.g. "callcenter_config queue list", a priority. Later rules take precedence
+//over earlier ones and over the built in rules, which make uuid_ commands,
+//hupall and originate PriorityHigh and status and show queries PriorityLow.
+//
+//Priorities only order commands waiting to be sent, not those waiting for a
+//rate limit, so give monitoring queries a lower rate limit than call control
+//where both are limited. Priorities outside PriorityLow to PriorityHigh are
+//clamped to that range.
+func (client *Client) SetCommandPriority(prefix string, priority Priority) {
+	if priority < PriorityLow {
+		priority = PriorityLow
+	} else if priority > PriorityHigh {
+		priority = PriorityHigh
+	}
+
+	client.optMu.Lock()
+	defer client.optMu.Unlock()
+	client.priorityRules = append(client.priorityRules, priorityRule{prefix: prefix, priority: priority})
+}
+
+//commandPriority returns the priority of a command.
+func (client *Client) commandPriority(class CommandClass, cmd string) Priority {
+	if class == ClassExecute {
+		return PriorityHigh
+	}
+	if class != ClassAPI && class != ClassBGAPI {
+		return PriorityNormal
+	}
+
+	client.optMu.RLock()
+	rules := client.priorityRules
+	client.optMu.RUnlock()
+
+	for i := len(rules) - 1; i >= 0; i-- {
+		if strings.HasPrefix(cmd, rules[i].prefix) {
+			return rules[i].priority
+		}
+	}
+	for _, rule := range defaultPriorityRules {
+		if strings.HasPrefix(cmd, rule.prefix) {
+			return rule.priority
+		}
+	}
+	return PriorityNormal
+}
+
+//commandScheduler lets one command at a time use the connection, choosing the
+//highest priority waiting command each time it is released.
+type commandScheduler struct {
+	busy    bool
+	waiting [PriorityHigh + 1][]chan struct{}
+	mu      *sync.Mutex
+}
+
+//newCommandScheduler creates an idle commandScheduler.
+func newCommandScheduler() *commandScheduler {
+	return &commandScheduler{mu: &sync.Mutex{}}
+}
+
+//acquire waits until the connection is free for a command of a priority.
+func (scheduler *commandScheduler) acquire(priority Priority) {
+	scheduler.acquireOrCancel(priority, nil)
+}
+
+//release hands the connection to the highest priority waiting command.
+func (scheduler *commandScheduler) release() {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	for priority := PriorityHigh; priority >= PriorityLow; priority-- {
+		if waiting := scheduler.waiting[priority]; len(waiting) > 0 {
+			scheduler.waiting[priority] = waiting[1:]
+			close(waiting[0])
+			return
+		}
+	}
+	scheduler.busy = false
+}
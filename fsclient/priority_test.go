@@ -0,0 +1,64 @@
+package fsclient
+
+import (
+	"testing"
+	"time"
+)
+
+//TestSetCommandPriority checks that commands get the priority of the last
+//matching rule, and that out of range priorities are clamped so waiting
+//commands can still be scheduled.
+func TestSetCommandPriority(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		priority Priority
+		cmd      string
+		want     Priority
+	}{
+		{"builtin high", "", 0, "uuid_kill 1234", PriorityHigh},
+		{"builtin low", "", 0, "show channels", PriorityLow},
+		{"default", "", 0, "reloadxml", PriorityNormal},
+		{"rule", "callcenter_config", PriorityLow, "callcenter_config queue list", PriorityLow},
+		{"rule over builtin", "uuid_dump", PriorityLow, "uuid_dump 1234", PriorityLow},
+		{"too high", "callcenter_config", Priority(5), "callcenter_config queue list", PriorityHigh},
+		{"too low", "callcenter_config", Priority(-1), "callcenter_config queue list", PriorityLow},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewClient("127.0.0.1:0", "", nil, nil, 0, nil)
+			defer client.Close()
+
+			if test.prefix != "" {
+				client.SetCommandPriority(test.prefix, test.priority)
+			}
+
+			priority := client.commandPriority(ClassAPI, test.cmd)
+			if priority != test.want {
+				t.Fatalf("Got priority %d, want %d", priority, test.want)
+			}
+
+			//A command of the priority must be able to wait its turn.
+			client.commands.acquire(PriorityNormal)
+			done := make(chan struct{})
+			go func() {
+				client.commands.acquire(priority)
+				close(done)
+			}()
+			for waiting := 0; waiting == 0; {
+				client.commands.mu.Lock()
+				waiting = len(client.commands.waiting[priority])
+				client.commands.mu.Unlock()
+				time.Sleep(time.Millisecond)
+			}
+			client.commands.release()
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("Waiting command wasn't scheduled")
+			}
+		})
+	}
+}
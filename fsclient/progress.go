@@ -0,0 +1,86 @@
+package fsclient
+
+import "time"
+
+//Progress is a CHANNEL_PROGRESS event, sent when an outbound call starts
+//ringing (a SIP 180), or a CHANNEL_PROGRESS_MEDIA event, sent when it starts
+//sending early media (a SIP 183), such as a ringback tone or an announcement
+//from the carrier. Media is true for early media. Codec is the channel's read
+//codec, which is only known once media has started.
+type Progress struct {
+	UUID        string
+	Media       bool
+	Time        time.Time
+	AnswerState string
+	Codec       string
+}
+
+//ParseProgress decodes a CHANNEL_PROGRESS or CHANNEL_PROGRESS_MEDIA event.
+//The bool result is false for any other event.
+func ParseProgress(event Event) (Progress, bool) {
+	progress := Progress{
+		UUID:        event.UUID(),
+		AnswerState: event["Answer-State"],
+		Codec:       event["Channel-Read-Codec-Name"],
+	}
+
+	switch event.Name() {
+	case "CHANNEL_PROGRESS":
+		progress.Time = event.Timestamp("Caller-Channel-Progress-Time")
+	case "CHANNEL_PROGRESS_MEDIA":
+		progress.Media = true
+		progress.Time = event.Timestamp("Caller-Channel-Progress-Media-Time")
+	default:
+		return Progress{}, false
+	}
+
+	if progress.Time.IsZero() {
+		progress.Time = event.Time()
+	}
+	return progress, true
+}
+
+//EarlyMedia returns true if the call has early media and hasn't been
+//answered or hung up.
+func (call *Call) EarlyMedia() bool {
+	call.mu.RLock()
+	defer call.mu.RUnlock()
+	return call.earlyMedia && !call.hungUp && call.event["Answer-State"] != "answered"
+}
+
+//OnProgress registers a function to be called when a call starts ringing or
+//gets early media, for example to play a local ringback tone or start
+//answering machine detection before the call is answered. The client must be
+//subscribed to CHANNEL_PROGRESS and CHANNEL_PROGRESS_MEDIA.
+func (manager *CallManager) OnProgress(fn func(call *Call, progress Progress)) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.onProgress = append(manager.onProgress, fn)
+}
+
+//progress records a progress event on a tracked call and runs the progress
+//callbacks, including the call's own from CallManager.Originate.
+func (manager *CallManager) progress(call *Call, event Event) {
+	progress, ok := ParseProgress(event)
+	if !ok || call == nil {
+		return
+	}
+
+	manager.mu.RLock()
+	onProgress := manager.onProgress
+	manager.mu.RUnlock()
+
+	call.mu.Lock()
+	if progress.Media {
+		call.earlyMedia = true
+	}
+	callProgress := call.onProgress
+	call.mu.Unlock()
+
+	for _, fn := range onProgress {
+		fn(call, progress)
+	}
+	if callProgress != nil {
+		callProgress(call, progress)
+	}
+}
@@ -0,0 +1,75 @@
+package fsclient
+
+//poorMOS is the MOS below which a call's audio quality is considered poor.
+const poorMOS = 3.5
+
+//QualityReport is the received audio quality of a call, from the RTP
+//statistics Freeswitch adds to a channel's variables. Jitter is the maximum
+//jitter variance in milliseconds. MOS is the mean opinion score reported by
+//Freeswitch, or if it didn't report one, estimated from the packet loss and
+//jitter, in which case Estimated is true.
+type QualityReport struct {
+	HasStats          bool
+	Packets           int64
+	LostPackets       int64
+	LossPercent       float64
+	Jitter            float64
+	MOS               float64
+	Estimated         bool
+	QualityPercentage float64
+}
+
+//ParseQualityReport builds a QualityReport from an event's channel
+//variables, normally a CHANNEL_HANGUP_COMPLETE, which carries the call's
+//final RTP statistics.
+func ParseQualityReport(event Event) QualityReport {
+	_, hasStats := event["variable_rtp_audio_in_packet_count"]
+	if !hasStats {
+		return QualityReport{}
+	}
+
+	report := QualityReport{
+		HasStats:          true,
+		Packets:           int64Header(event, "variable_rtp_audio_in_packet_count"),
+		LostPackets:       int64Header(event, "variable_rtp_audio_in_skip_packet_count"),
+		Jitter:            floatHeader(event, "variable_rtp_audio_in_jitter_max_variance"),
+		MOS:               floatHeader(event, "variable_rtp_audio_in_mos"),
+		QualityPercentage: floatHeader(event, "variable_rtp_audio_in_quality_percentage"),
+	}
+
+	if expected := report.Packets + report.LostPackets; expected > 0 {
+		report.LossPercent = 100 * float64(report.LostPackets) / float64(expected)
+	}
+
+	if report.MOS == 0 && report.Packets > 0 {
+		report.MOS = estimateMOS(report.LossPercent, report.Jitter)
+		report.Estimated = true
+	}
+	return report
+}
+
+//Poor returns true if the report shows poor audio quality.
+func (report QualityReport) Poor() bool {
+	return report.HasStats && report.MOS > 0 && report.MOS < poorMOS
+}
+
+//estimateMOS estimates a MOS from packet loss and jitter using a simplified
+//ITU-T G.107 E-model, assuming a G.711 codec and low network latency.
+func estimateMOS(lossPercent float64, jitter float64) float64 {
+	//Jitter buffers add delay of about twice the jitter.
+	latency := 2*jitter + 10
+
+	r := 93.2 - latency/40
+	if latency >= 160 {
+		r = 93.2 - (latency-120)/10
+	}
+	r -= 2.5 * lossPercent
+
+	switch {
+	case r <= 0:
+		return 1
+	case r >= 100:
+		return 4.5
+	}
+	return 1 + 0.035*r + 0.000007*r*(r-60)*(100-r)
+}
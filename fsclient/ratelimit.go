@@ -0,0 +1,150 @@
+package fsclient
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//CommandClass identifies a type of outgoing command for rate limiting and
+//authorization.
+type CommandClass int
+
+//Command classes that can be rate limited independently.
+const (
+	ClassAPI CommandClass = iota
+	ClassBGAPI
+	ClassExecute
+	ClassSendEvent
+	ClassRaw
+)
+
+func (class CommandClass) String() string {
+	switch class {
+	case ClassAPI:
+		return "api"
+	case ClassBGAPI:
+		return "bgapi"
+	case ClassExecute:
+		return "execute"
+	case ClassSendEvent:
+		return "sendevent"
+	case ClassRaw:
+		return "raw"
+	}
+	return "unknown"
+}
+
+//TokenBucket is a token bucket rate limiter. Tokens are added at a fixed rate
+//up to a maximum burst size and each command consumes one token. Commands that
+//arrive when the bucket is empty wait their turn in arrival order.
+type TokenBucket struct {
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+	waiting int64
+	clock   Clock
+	mu      *sync.Mutex
+}
+
+//NewTokenBucket creates a token bucket that allows rate commands per second
+//with bursts of up to burst commands. The bucket starts full.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	bucket := &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		clock:  SystemClock,
+		mu:     &sync.Mutex{},
+	}
+	bucket.last = bucket.clock.Now()
+	return bucket
+}
+
+//Wait blocks until a token is available and consumes it.
+func (bucket *TokenBucket) Wait() {
+	atomic.AddInt64(&bucket.waiting, 1)
+	defer atomic.AddInt64(&bucket.waiting, -1)
+
+	if delay, clock := bucket.reserve(); delay > 0 {
+		<-clock.After(delay)
+	}
+}
+
+//SetClock sets the clock the bucket is refilled and waited on with.
+func (bucket *TokenBucket) SetClock(clock Clock) {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+	bucket.clock = clock
+	bucket.last = clock.Now()
+}
+
+//QueueDepth returns the number of callers currently waiting for a token.
+func (bucket *TokenBucket) QueueDepth() int {
+	return int(atomic.LoadInt64(&bucket.waiting))
+}
+
+//reserve takes a token from the bucket and returns how long the caller must
+//wait before using it, and the clock to wait on. The token count is allowed to
+//go negative so that later callers queue up behind earlier ones.
+func (bucket *TokenBucket) reserve() (time.Duration, Clock) {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	now := bucket.clock.Now()
+	bucket.tokens += now.Sub(bucket.last).Seconds() * bucket.rate
+	if bucket.tokens > bucket.burst {
+		bucket.tokens = bucket.burst
+	}
+	bucket.last = now
+	bucket.tokens--
+
+	if bucket.tokens >= 0 {
+		return 0, bucket.clock
+	}
+	return time.Duration(-bucket.tokens / bucket.rate * float64(time.Second)), bucket.clock
+}
+
+//SetRateLimit limits commands of the given class to rate per second with
+//bursts of up to burst commands. A rate of zero or less removes the limit.
+func (client *Client) SetRateLimit(class CommandClass, rate float64, burst int) {
+	client.optMu.Lock()
+	defer client.optMu.Unlock()
+
+	if rate <= 0 {
+		delete(client.limiters, class)
+		return
+	}
+	bucket := NewTokenBucket(rate, burst)
+	bucket.SetClock(client.clock)
+	client.limiters[class] = bucket
+}
+
+//QueueDepth returns the number of commands of the given class waiting for the
+//rate limiter. It is always zero if the class is not rate limited.
+func (client *Client) QueueDepth(class CommandClass) int {
+	client.optMu.RLock()
+	bucket := client.limiters[class]
+	client.optMu.RUnlock()
+
+	if bucket == nil {
+		return 0
+	}
+	return bucket.QueueDepth()
+}
+
+//rateLimit blocks until a command of the given class is allowed to be sent.
+func (client *Client) rateLimit(class CommandClass) {
+	client.optMu.RLock()
+	bucket := client.limiters[class]
+	client.optMu.RUnlock()
+
+	if bucket != nil {
+		bucket.Wait()
+	}
+}
@@ -0,0 +1,129 @@
+package fsclient
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//SendRecv sends a raw command, such as one the client doesn't have a method
+//for, and returns its reply. The command may span several lines, e.g. a
+//sendmsg with its headers, and may have a body after a blank line if it has a
+//matching Content-Length header. The reply is the Reply-Text of a
+//command/reply or the body of an api/response, with the Job-UUID of a bgapi
+//command as its UUID.
+//
+//Commands that change the connection, such as event, filter or exit, aren't
+//remembered for reconnecting, so use the client's methods for them instead.
+func (client *Client) SendRecv(raw string) (Reply, error) {
+	return client.SendRecvContext(context.Background(), raw)
+}
+
+//SendRecvContext sends a raw command like SendRecv, passing ctx to the
+//authorizer if one is set.
+func (client *Client) SendRecvContext(ctx context.Context, raw string) (reply Reply, err error) {
+	command := parseRawCommand(raw)
+	defer func(start time.Time) { client.audit(ctx, command, start, reply.Text, err) }(client.now())
+
+	authorized, err := client.authorizeCommand(ctx, command)
+	if err != nil {
+		return Reply{}, err
+	}
+	command = authorized
+
+	head, body, err := frameRawCommand(rawCommandText(command))
+	if err != nil {
+		return Reply{}, err
+	}
+
+	client.rateLimit(ClassRaw)
+	client.commands.acquire(PriorityNormal)
+	defer client.commands.release()
+	client.connMu.Lock()
+	defer client.connMu.Unlock()
+
+	//If the command response channel is not intialised then it means we
+	//are not connected. So no point in sending a command.
+	if client.cmdResCh == nil {
+		return Reply{}, errDisconnected
+	}
+
+	//The empty line indicates the end of the command headers.
+	client.eventConn.W.WriteString(head + "\n\n" + body)
+	if err := client.eventConn.W.Flush(); err != nil {
+		return Reply{}, err
+	}
+
+	res := <-client.cmdResCh
+	if res.body == "" && res.err == nil {
+		return Reply{}, errDisconnected
+	}
+	if res.err != nil {
+		return Reply{}, res.err
+	}
+
+	reply = ParseReply(res.body)
+	if res.jobUUID != "" {
+		reply.UUID = res.jobUUID
+	}
+	return reply, nil
+}
+
+//parseRawCommand splits a raw command into a Command at the first space or
+//new line.
+func parseRawCommand(raw string) Command {
+	raw = strings.TrimLeft(strings.ReplaceAll(raw, "\r\n", "\n"), " \n")
+
+	i := strings.IndexAny(raw, " \n")
+	switch {
+	case i < 0:
+		return Command{Class: ClassRaw, Name: raw}
+	case raw[i] == ' ':
+		return Command{Class: ClassRaw, Name: raw[:i], Args: raw[i+1:]}
+	}
+	return Command{Class: ClassRaw, Name: raw[:i], Args: raw[i:]}
+}
+
+//rawCommandText returns the raw text of a command made by parseRawCommand.
+func rawCommandText(cmd Command) string {
+	if strings.HasPrefix(cmd.Args, "\n") {
+		return cmd.Name + cmd.Args
+	}
+	return cmd.String()
+}
+
+//frameRawCommand splits a raw command into its headers and body, checking a
+//body has a matching Content-Length header so Freeswitch reads exactly one
+//command and the replies stay in step with the commands.
+func frameRawCommand(raw string) (string, string, error) {
+	head, body, hasBody := strings.Cut(raw, "\n\n")
+	head = strings.TrimRight(head, "\n")
+	if head == "" {
+		return "", "", errors.New("Empty raw command")
+	}
+
+	length := -1
+	for _, line := range strings.Split(head, "\n") {
+		key, value, _ := strings.Cut(line, ":")
+		if strings.EqualFold(strings.TrimSpace(key), "Content-Length") {
+			var err error
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return "", "", errors.New("Invalid Content-Length in raw command: " + value)
+			}
+		}
+	}
+
+	if length < 0 {
+		if hasBody && strings.Trim(body, "\n") != "" {
+			return "", "", errors.New("Raw command has a body without a Content-Length header")
+		}
+		return head, "", nil
+	}
+
+	if len(body) != length {
+		return "", "", errors.New("Raw command body length " + strconv.Itoa(len(body)) + " doesn't match Content-Length " + strconv.Itoa(length))
+	}
+	return head, body, nil
+}
@@ -0,0 +1,48 @@
+package fsclient
+
+import (
+	"log"
+	"net/textproto"
+	"time"
+)
+
+//RawEvent is a frame with a Content-Type the client doesn't handle itself,
+//such as a frame type added by a newer Freeswitch version, passed through as
+//it was read.
+type RawEvent struct {
+	ContentType string
+	Header      textproto.MIMEHeader
+	Body        []byte
+}
+
+//SetRawEventChannel sends frames with a Content-Type the client doesn't
+//handle to ch as RawEvents, instead of reporting them as parse errors. They
+//aren't treated as errors even if parsing is strict, as the application has
+//said it will handle them. A nil channel, the default, stops the passthrough.
+//Frames are discarded if ch stays full for more than a second.
+func (client *Client) SetRawEventChannel(ch chan<- RawEvent) {
+	client.optMu.Lock()
+	defer client.optMu.Unlock()
+	client.rawEventCh = ch
+}
+
+//deliverRawEvent sends a frame to the raw event channel, returning false if
+//none is set.
+func (client *Client) deliverRawEvent(frame Frame) bool {
+	client.optMu.RLock()
+	ch := client.rawEventCh
+	client.optMu.RUnlock()
+
+	if ch == nil {
+		return false
+	}
+
+	raw := RawEvent{ContentType: frame.ContentType(), Header: frame.Header, Body: frame.Content}
+	select {
+	case ch <- raw:
+	case <-client.after(1 * time.Second):
+		log.Print(logPrefix, "Error Raw event channel blocked, discarded ", raw.ContentType, " frame")
+	case <-client.closeCh:
+	}
+	return true
+}
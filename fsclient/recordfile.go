@@ -0,0 +1,56 @@
+package fsclient
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+//errCorruptRecord indicates a record on disk was incomplete or failed its
+//checksum, typically because the process crashed part way through a write.
+var errCorruptRecord = errors.New("Corrupt record")
+
+//maxRecordSize limits the size of a single record so a corrupt length can't
+//cause a huge allocation.
+const maxRecordSize = 64 * 1024 * 1024
+
+//writeRecord writes payload framed by a 4 byte length and 4 byte CRC32
+//checksum (both big-endian) so that partially written records can be detected
+//when the file is read back.
+func writeRecord(w io.Writer, payload []byte) error {
+	buf := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint32(buf[0:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(payload))
+	copy(buf[8:], payload)
+	_, err := w.Write(buf)
+	return err
+}
+
+//readRecord reads the next record written by writeRecord. It returns io.EOF
+//at a clean end of file and errCorruptRecord if the record is incomplete or
+//its checksum doesn't match.
+func readRecord(r io.Reader) ([]byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, errCorruptRecord
+	}
+
+	length := binary.BigEndian.Uint32(header[0:])
+	if length > maxRecordSize {
+		return nil, errCorruptRecord
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errCorruptRecord
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, errCorruptRecord
+	}
+	return payload, nil
+}
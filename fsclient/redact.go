@@ -0,0 +1,307 @@
+package fsclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//DefaultRedactionMask replaces the values of redacted headers.
+const DefaultRedactionMask = "<redacted>"
+
+//CallerNumberHeaders are the event headers and variables that carry the
+//caller's and callee's numbers and names, for passing to NewRedactor.
+var CallerNumberHeaders = []string{
+	"Caller-Caller-ID-Name",
+	"Caller-Caller-ID-Number",
+	"Caller-Orig-Caller-ID-Name",
+	"Caller-Orig-Caller-ID-Number",
+	"Caller-Callee-ID-Name",
+	"Caller-Callee-ID-Number",
+	"Caller-ANI",
+	"Caller-Destination-Number",
+	"Caller-Username",
+	"Other-Leg-Caller-ID-Name",
+	"Other-Leg-Caller-ID-Number",
+	"Other-Leg-Destination-Number",
+	"variable_sip_from_user",
+	"variable_sip_from_uri",
+	"variable_sip_to_user",
+	"variable_sip_to_uri",
+	"variable_sip_req_user",
+	"variable_sip_req_uri",
+	"variable_sip_contact_user",
+	"variable_caller_id_name",
+	"variable_caller_id_number",
+	"variable_effective_caller_id_name",
+	"variable_effective_caller_id_number",
+	"variable_origination_caller_id_name",
+	"variable_origination_caller_id_number",
+	"variable_destination_number",
+	"variable_ani",
+}
+
+//Redactor masks the values of sensitive headers and variables in events
+//before they are stored, logged or shown, e.g. for PCI DSS or GDPR. Names are
+//matched case insensitively and a name ending with "*" matches any with that
+//prefix, e.g. "variable_sip_h_X-Card-*". Variables are matched by their event
+//header name, with the "variable_" prefix.
+//
+//The DTMF-Digit of channels in secure mode, e.g. while card details are
+//being entered, is masked as well, whether or not it is a configured name,
+//and their DTMF events are dropped by RedactHandler. SecureDTMF puts
+//channels in secure mode.
+//
+//Set the client's redactor with SetRedactor to mask its frame history and
+//parse errors, and wrap handlers that store or forward events with
+//RedactHandler. Events on the client's EventCh aren't masked, so that the
+//application can still route and screen calls.
+type Redactor struct {
+	names    map[string]bool
+	prefixes []string
+	mask     string
+	secure   map[string]bool
+	mu       *sync.RWMutex
+}
+
+//NewRedactor creates a Redactor masking the headers and variables names, e.g.
+//CallerNumberHeaders.
+func NewRedactor(names ...string) *Redactor {
+	redactor := &Redactor{
+		names:  make(map[string]bool),
+		mask:   DefaultRedactionMask,
+		secure: make(map[string]bool),
+		mu:     &sync.RWMutex{},
+	}
+	redactor.Add(names...)
+	return redactor
+}
+
+//Add adds headers and variables to be masked.
+func (redactor *Redactor) Add(names ...string) {
+	redactor.mu.Lock()
+	defer redactor.mu.Unlock()
+
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if strings.HasSuffix(name, "*") {
+			redactor.prefixes = append(redactor.prefixes, strings.TrimSuffix(name, "*"))
+		} else if name != "" {
+			redactor.names[name] = true
+		}
+	}
+}
+
+//SetMask sets the text that replaces masked values. The default is
+//DefaultRedactionMask.
+func (redactor *Redactor) SetMask(mask string) {
+	redactor.mu.Lock()
+	defer redactor.mu.Unlock()
+	redactor.mask = mask
+}
+
+//SetSecure sets whether the channel uuid is in secure mode, masking its
+//DTMF digits. Channels should be taken out of secure mode when they hang up.
+func (redactor *Redactor) SetSecure(uuid string, secure bool) {
+	redactor.mu.Lock()
+	defer redactor.mu.Unlock()
+
+	if secure {
+		redactor.secure[uuid] = true
+	} else {
+		delete(redactor.secure, uuid)
+	}
+}
+
+//Secure returns true if the channel uuid is in secure mode.
+func (redactor *Redactor) Secure(uuid string) bool {
+	redactor.mu.RLock()
+	defer redactor.mu.RUnlock()
+	return redactor.secure[uuid]
+}
+
+//Redacted returns true if the header name of an event for the channel uuid
+//is masked.
+func (redactor *Redactor) Redacted(uuid string, name string) bool {
+	redactor.mu.RLock()
+	defer redactor.mu.RUnlock()
+	return redactor.redacted(uuid, name)
+}
+
+//redacted returns true if a header is masked. The caller must hold the lock.
+func (redactor *Redactor) redacted(uuid string, name string) bool {
+	if strings.EqualFold(name, "DTMF-Digit") && redactor.secure[uuid] {
+		return true
+	}
+
+	name = strings.ToLower(name)
+	if redactor.names[name] {
+		return true
+	}
+	for _, prefix := range redactor.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//Redact returns a copy of event with its sensitive values masked. The event
+//itself isn't changed, as other handlers may still need them.
+func (redactor *Redactor) Redact(event Event) Event {
+	redactor.mu.RLock()
+	defer redactor.mu.RUnlock()
+
+	uuid := event.UUID()
+	redacted := make(Event, len(event))
+	for name, value := range event {
+		if value != "" && redactor.redacted(uuid, name) {
+			value = redactor.mask
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+//frameUUID matches the Unique-ID of an event frame in the plain, JSON and
+//XML formats.
+var frameUUID = regexp.MustCompile(`Unique-ID(?:: |>|"\s*:\s*")([0-9A-Za-z-]+)`)
+
+//xmlElement matches an element of an XML event that holds a value.
+var xmlElement = regexp.MustCompile(`<([A-Za-z0-9_.-]+)>([^<]*)</([A-Za-z0-9_.-]+)>`)
+
+//redactFrame returns a copy of a frame sent or received on the connection
+//with sensitive values masked, or the frame itself if there are none. XML
+//elements are masked across the frame, and other lines as "Name: value"
+//header lines or JSON objects. A JSON line that can't be parsed is masked
+//whole, as which of its values are sensitive can't be told.
+func (redactor *Redactor) redactFrame(data []byte) []byte {
+	redactor.mu.RLock()
+	defer redactor.mu.RUnlock()
+
+	//The channel is needed to know whether DTMF digits are masked.
+	var uuid string
+	if match := frameUUID.FindSubmatch(data); match != nil {
+		uuid = string(match[1])
+	}
+
+	changed := false
+	if bytes.Contains(data, []byte("</")) {
+		data = xmlElement.ReplaceAllFunc(data, func(element []byte) []byte {
+			match := xmlElement.FindSubmatch(element)
+			if !bytes.Equal(match[1], match[3]) || len(bytes.TrimSpace(match[2])) == 0 || !redactor.redacted(uuid, string(match[1])) {
+				return element
+			}
+			changed = true
+			return []byte("<" + string(match[1]) + ">" + html.EscapeString(redactor.mask) + "</" + string(match[1]) + ">")
+		})
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if bytes.HasPrefix(line, []byte("{")) {
+			if masked, ok := redactor.redactJSON(line); ok {
+				lines[i], changed = masked, true
+			}
+			continue
+		}
+
+		name, value, ok := bytes.Cut(line, []byte(": "))
+		if ok && len(bytes.TrimSpace(value)) > 0 && redactor.redacted(uuid, string(name)) {
+			lines[i] = append(append(name[:len(name):len(name)], ": "...), redactor.mask...)
+			changed = true
+		}
+	}
+	if !changed {
+		return data
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+//redactJSON masks the sensitive values of a JSON event, returning false if
+//there are none. A line that isn't a JSON object is replaced by the mask. The
+//caller must hold the lock.
+func (redactor *Redactor) redactJSON(line []byte) ([]byte, bool) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return []byte(redactor.mask), true
+	}
+
+	uuid, _ := fields["Unique-ID"].(string)
+	changed := false
+	for name := range fields {
+		if redactor.redacted(uuid, name) {
+			fields[name] = redactor.mask
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, false
+	}
+
+	//The mask is written as it is rather than escaped for HTML.
+	var masked bytes.Buffer
+	encoder := json.NewEncoder(&masked)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(fields); err != nil {
+		return []byte(redactor.mask), true
+	}
+	return bytes.TrimSuffix(masked.Bytes(), []byte("\n")), true
+}
+
+//redactParseError returns a copy of a parse error with its malformed lines
+//and frame content masked.
+func (redactor *Redactor) redactParseError(err *ParseError) *ParseError {
+	redacted := *err
+	if len(err.Lines) > 0 {
+		redacted.Lines = make([]string, len(err.Lines))
+		for i, line := range err.Lines {
+			redacted.Lines[i] = string(redactor.redactFrame([]byte(line)))
+		}
+	}
+	if len(err.Frame.Content) > 0 {
+		redacted.Frame.Content = redactor.redactFrame(err.Frame.Content)
+	}
+	return &redacted
+}
+
+//Suppressed returns true if event is the DTMF event of a channel in secure
+//mode, which shouldn't reach sinks at all.
+func (redactor *Redactor) Suppressed(event Event) bool {
+	return event.Name() == "DTMF" && redactor.Secure(event.UUID())
+}
+
+//RedactHandler returns an EventHandler that passes events masked by redactor
+//to handler, e.g. StoreHandler(store), for registering with a Dispatcher.
+//Suppressed events aren't passed on.
+func RedactHandler(redactor *Redactor, handler EventHandler) EventHandler {
+	return func(event Event) {
+		if !redactor.Suppressed(event) {
+			handler(redactor.Redact(event))
+		}
+	}
+}
+
+//SetRedactor sets the redactor that masks sensitive values in the client's
+//frame history and in the frames of parse errors, before they are logged or
+//passed to the parse error handler, or removes it if redactor is nil.
+func (client *Client) SetRedactor(redactor *Redactor) {
+	client.optMu.Lock()
+	defer client.optMu.Unlock()
+	client.redactor = redactor
+}
+
+//redactData masks data with the client's redactor, if it has one.
+func (client *Client) redactData(data []byte) []byte {
+	client.optMu.RLock()
+	redactor := client.redactor
+	client.optMu.RUnlock()
+
+	if redactor == nil || len(data) == 0 {
+		return data
+	}
+	return redactor.redactFrame(data)
+}
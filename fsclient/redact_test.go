@@ -0,0 +1,150 @@
+package fsclient
+
+import (
+	"strings"
+	"testing"
+)
+
+const redactUUID = "11111111-1111-4111-8111-111111111111"
+
+//newTestRedactor creates a Redactor masking caller numbers, card headers and
+//DTMF digits on the channel redactUUID.
+func newTestRedactor() *Redactor {
+	redactor := NewRedactor(CallerNumberHeaders...)
+	redactor.Add("variable_sip_h_X-Card-*")
+	redactor.SetSecure(redactUUID, true)
+	return redactor
+}
+
+//TestRedactorRedact checks which event headers are masked.
+func TestRedactorRedact(t *testing.T) {
+	tests := []struct {
+		name       string
+		uuid       string
+		header     string
+		value      string
+		wantMasked bool
+	}{
+		{"caller number", redactUUID, "Caller-Caller-ID-Number", "5551234", true},
+		{"variable", redactUUID, "variable_sip_from_user", "5551234", true},
+		{"other case", redactUUID, "caller-caller-id-number", "5551234", true},
+		{"prefix", redactUUID, "variable_sip_h_X-Card-Number", "4111111111111111", true},
+		{"prefix other case", redactUUID, "variable_sip_h_x-card-cvv", "123", true},
+		{"secure dtmf", redactUUID, "DTMF-Digit", "4", true},
+		{"dtmf", "22222222-2222-4222-8222-222222222222", "DTMF-Digit", "4", false},
+		{"not sensitive", redactUUID, "Channel-State", "CS_EXECUTE", false},
+		{"empty", redactUUID, "Caller-Caller-ID-Number", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := Event{"Event-Name": "DTMF", "Unique-ID": test.uuid, test.header: test.value}
+			redacted := newTestRedactor().Redact(event)
+
+			want := test.value
+			if test.wantMasked {
+				want = DefaultRedactionMask
+			}
+			if redacted[test.header] != want {
+				t.Errorf("Got %q, want %q", redacted[test.header], want)
+			}
+			if event[test.header] != test.value {
+				t.Error("Redact changed the event")
+			}
+			if redacted.UUID() != test.uuid {
+				t.Errorf("Masked Unique-ID %q", redacted.UUID())
+			}
+		})
+	}
+}
+
+//TestRedactHandler checks that handlers are passed masked events, and not
+//passed the DTMF of secure channels at all.
+func TestRedactHandler(t *testing.T) {
+	redactor := newTestRedactor()
+	redactor.SetMask("***")
+
+	tests := []struct {
+		name       string
+		event      Event
+		wantPassed bool
+		wantNumber string
+	}{
+		{"masked", Event{"Event-Name": "CHANNEL_CREATE", "Unique-ID": redactUUID, "Caller-Caller-ID-Number": "5551234"}, true, "***"},
+		{"secure dtmf", Event{"Event-Name": "DTMF", "Unique-ID": redactUUID, "DTMF-Digit": "4"}, false, ""},
+		{"dtmf", Event{"Event-Name": "DTMF", "Unique-ID": "22222222-2222-4222-8222-222222222222", "DTMF-Digit": "4"}, true, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var passed Event
+			RedactHandler(redactor, func(event Event) {
+				passed = event
+			})(test.event)
+
+			if (passed != nil) != test.wantPassed {
+				t.Fatalf("Passed %v, want passed %v", passed, test.wantPassed)
+			}
+			if passed != nil && passed["Caller-Caller-ID-Number"] != test.wantNumber {
+				t.Errorf("Passed number %q, want %q", passed["Caller-Caller-ID-Number"], test.wantNumber)
+			}
+		})
+	}
+
+	redactor.SetSecure(redactUUID, false)
+	if redactor.Suppressed(Event{"Event-Name": "DTMF", "Unique-ID": redactUUID}) {
+		t.Error("DTMF suppressed after leaving secure mode")
+	}
+}
+
+//TestRedactorRedactFrame checks that sensitive values are masked in the
+//plain, JSON and XML event formats, and in commands.
+func TestRedactorRedactFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		frame  string
+		want   string
+		secret string
+	}{
+		{"plain",
+			"Event-Name: CHANNEL_CREATE\nUnique-ID: " + redactUUID + "\nCaller-Caller-ID-Number: 5551234\nChannel-State: CS_INIT",
+			"Event-Name: CHANNEL_CREATE\nUnique-ID: " + redactUUID + "\nCaller-Caller-ID-Number: <redacted>\nChannel-State: CS_INIT",
+			"5551234"},
+		{"plain dtmf",
+			"Event-Name: DTMF\nUnique-ID: " + redactUUID + "\nDTMF-Digit: 4",
+			"Event-Name: DTMF\nUnique-ID: " + redactUUID + "\nDTMF-Digit: <redacted>",
+			"Digit: 4"},
+		{"json",
+			`{"Event-Name":"CHANNEL_CREATE","Unique-ID":"` + redactUUID + `","variable_sip_h_X-Card-Number":"4111111111111111"}`,
+			`{"Event-Name":"CHANNEL_CREATE","Unique-ID":"` + redactUUID + `","variable_sip_h_X-Card-Number":"<redacted>"}`,
+			"4111111111111111"},
+		{"invalid json",
+			`{"Caller-Caller-ID-Number":"5551234"`,
+			"<redacted>",
+			"5551234"},
+		{"xml",
+			"<event><headers><Unique-ID>" + redactUUID + "</Unique-ID><Caller-ANI>5551234</Caller-ANI></headers></event>",
+			"<event><headers><Unique-ID>" + redactUUID + "</Unique-ID><Caller-ANI>&lt;redacted&gt;</Caller-ANI></headers></event>",
+			"5551234"},
+		{"command",
+			"sendmsg " + redactUUID + "\ncall-command: execute\nvariable_sip_h_X-Card-Cvv: 123",
+			"sendmsg " + redactUUID + "\ncall-command: execute\nvariable_sip_h_X-Card-Cvv: <redacted>",
+			"123"},
+		{"nothing sensitive",
+			"Event-Name: HEARTBEAT\nUp-Time: 0 years, 1 day",
+			"Event-Name: HEARTBEAT\nUp-Time: 0 years, 1 day",
+			""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := string(newTestRedactor().redactFrame([]byte(test.frame)))
+			if got != test.want {
+				t.Errorf("Got %q, want %q", got, test.want)
+			}
+			if test.secret != "" && strings.Contains(got, test.secret) {
+				t.Errorf("Frame still contains %q", test.secret)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package fsclient
+
+import "strings"
+
+//Reply is a parsed "+OK ..." or "-ERR ..." command reply. Text is the reply
+//after the status prefix. UUID is the UUID the reply returned, if any, such
+//as the Job-UUID of a bgapi command or the channel UUID of an originate.
+type Reply struct {
+	OK   bool
+	Text string
+	UUID string
+}
+
+//ParseReply parses a command reply, e.g. "+OK accepted",
+//"+OK Job-UUID: <uuid>", "+OK <uuid>" or "-ERR NO_ANSWER".
+func ParseReply(text string) Reply {
+	text = strings.TrimSpace(text)
+
+	var reply Reply
+	switch {
+	case strings.HasPrefix(text, "+OK"):
+		reply.OK = true
+		reply.Text = strings.TrimSpace(strings.TrimPrefix(text, "+OK"))
+	case strings.HasPrefix(text, "-ERR"):
+		reply.Text = strings.TrimSpace(strings.TrimPrefix(text, "-ERR"))
+		return reply
+	default:
+		reply.Text = text
+		return reply
+	}
+
+	if jobUUID, ok := strings.CutPrefix(reply.Text, "Job-UUID:"); ok {
+		reply.UUID = strings.TrimSpace(jobUUID)
+	} else if ValidUUID(reply.Text) {
+		reply.UUID = reply.Text
+	}
+	return reply
+}
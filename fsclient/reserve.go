@@ -0,0 +1,132 @@
+package fsclient
+
+import (
+	"errors"
+	"strings"
+)
+
+//errUUIDInUse is returned when originating a call with the UUID of a call
+//that is already tracked.
+var errUUIDInUse = errors.New("Call UUID already in use")
+
+//Reserve starts tracking a call that doesn't exist yet, such as one about to
+//be originated with its UUID in origination_uuid, and returns it. Values can
+//be attached to the call straight away, so that OnCreate and event handlers
+//find them however quickly Freeswitch creates the channel. The call's
+//OnCreate callbacks run when its first event arrives. An error is returned if
+//a call with the UUID is already tracked, as only one originate can use it.
+func (manager *CallManager) Reserve(uuid string) (*Call, error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if _, ok := manager.calls[uuid]; ok {
+		return nil, errUUIDInUse
+	}
+	call := newCall(uuid, manager)
+	call.reserved = true
+	manager.calls[uuid] = call
+	return call, nil
+}
+
+//Reserved returns true if the call was reserved with Reserve and hasn't had
+//any events yet.
+func (call *Call) Reserved() bool {
+	call.manager.mu.RLock()
+	defer call.manager.mu.RUnlock()
+	return call.reserved
+}
+
+//Originate originates a call with bgapi and returns it without waiting for
+//the result. The call's UUID is set with origination_uuid, using the one in
+//the request's Variables if there is one or a new one otherwise, and the call
+//is reserved before the command is sent, so there is no race between
+//registering interest in the call and its first events.
+//
+//If the originate fails before a channel is created, the call is hung up
+//with the failure as its Hangup-Cause when the BACKGROUND_JOB event arrives,
+//so the client must be subscribed to BACKGROUND_JOB.
+func (manager *CallManager) Originate(client Commander, req OriginateRequest) (*Call, error) {
+	vars := make(map[string]string, len(req.Variables)+1)
+	for key, value := range req.Variables {
+		vars[key] = value
+	}
+	if vars["origination_uuid"] == "" {
+		vars["origination_uuid"] = NewUUID()
+	}
+	req.Variables = vars
+	uuid := vars["origination_uuid"]
+
+	cmd, err := req.Command()
+	if err != nil {
+		return nil, err
+	}
+
+	call, err := manager.Reserve(uuid)
+	if err != nil {
+		return nil, err
+	}
+	if req.OnProgress != nil {
+		call.mu.Lock()
+		call.onProgress = req.OnProgress
+		call.mu.Unlock()
+	}
+
+	if _, err := client.BackgroundAPI(cmd); err != nil {
+		manager.unreserve(call)
+		return nil, err
+	}
+	return call, nil
+}
+
+//unreserve stops tracking a reserved call that won't be created.
+func (manager *CallManager) unreserve(call *Call) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if call.reserved && manager.calls[call.UUID] == call {
+		delete(manager.calls, call.UUID)
+	}
+}
+
+//originateResult hangs up a reserved call whose originate job failed without
+//creating a channel. The call is found from origination_uuid in the job's
+//arguments, as the job result can arrive before the job UUID is known.
+func (manager *CallManager) originateResult(event Event) {
+	uuid, reply, ok := originateJob(event)
+	if !ok || reply.OK {
+		return
+	}
+
+	manager.mu.RLock()
+	call, ok := manager.calls[uuid]
+	reserved := ok && call.reserved
+	manager.mu.RUnlock()
+
+	if !reserved {
+		return
+	}
+
+	manager.hangup(uuid, Event{
+		"Event-Name":         "CHANNEL_HANGUP_COMPLETE",
+		"Unique-ID":          uuid,
+		"Channel-State":      string(StateHangup),
+		"Channel-Call-State": string(CallStateHangup),
+		"Hangup-Cause":       reply.Text,
+		"Job-UUID":           event["Job-UUID"],
+	})
+}
+
+//originateJob returns the origination_uuid and result of an originate
+//BACKGROUND_JOB event. The bool result is false for other jobs and for
+//originates without an origination_uuid.
+func originateJob(event Event) (string, Reply, bool) {
+	if event.Name() != "BACKGROUND_JOB" || event["Job-Command"] != "originate" {
+		return "", Reply{}, false
+	}
+
+	_, uuid, ok := strings.Cut(event["Job-Command-Arg"], "origination_uuid=")
+	if !ok || len(uuid) < 36 || !ValidUUID(uuid[:36]) {
+		return "", Reply{}, false
+	}
+	return uuid[:36], ParseReply(event.Body()), true
+}
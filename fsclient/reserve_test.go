@@ -0,0 +1,113 @@
+package fsclient_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+)
+
+//TestCallManagerOriginate checks that originated calls are reserved before
+//the command is sent, and what happens to them when the originate fails.
+func TestCallManagerOriginate(t *testing.T) {
+	tests := []struct {
+		name         string
+		reply        string
+		fail         string
+		wantErr      bool
+		wantTracked  bool
+		wantReserved bool
+		wantCause    string
+	}{
+		{"originated", "+OK " + callerUUID, "", false, true, true, ""},
+		{"job failed", "-ERR USER_BUSY", "", false, false, true, "USER_BUSY"},
+		{"not sent", "", "originate", true, false, false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fsclienttest.NewClient()
+			scriptCommands(client, test.fail)
+			if test.reply != "" {
+				client.Reply(fsclient.ClassBGAPI, "originate", test.reply)
+			}
+			manager := fsclient.NewCallManager()
+			client.OnEvent(manager.HandleEvent)
+
+			call, err := manager.Originate(client, fsclient.OriginateRequest{
+				Endpoint:  "user/1000",
+				App:       "park",
+				Variables: map[string]string{"origination_uuid": callerUUID},
+			})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Got error %v, want error %v", err, test.wantErr)
+			}
+
+			tracked := manager.Call(callerUUID)
+			if (tracked != nil) != test.wantTracked {
+				t.Fatalf("Call tracked %v, want %v", tracked != nil, test.wantTracked)
+			}
+			if call == nil {
+				return
+			}
+			if tracked != nil && tracked != call {
+				t.Error("Originate returned a different call to the one tracked")
+			}
+			if call.Reserved() != test.wantReserved {
+				t.Errorf("Call reserved %v, want %v", call.Reserved(), test.wantReserved)
+			}
+			if call.HungUp() != (test.wantCause != "") || call.HangupCause() != test.wantCause {
+				t.Errorf("Got hangup cause %q, want %q", call.HangupCause(), test.wantCause)
+			}
+
+			cmds := sentCommands(client)
+			if len(cmds) != 1 || !strings.Contains(cmds[0], "origination_uuid="+callerUUID) {
+				t.Errorf("Sent %q", cmds)
+			}
+		})
+	}
+}
+
+//TestCallManagerOriginateSameUUID checks that of several concurrent
+//originates with the same UUID only one is sent.
+func TestCallManagerOriginateSameUUID(t *testing.T) {
+	client := fsclienttest.NewClient()
+	scriptCommands(client)
+	manager := fsclient.NewCallManager()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := manager.Originate(client, fsclient.OriginateRequest{
+				Endpoint:  "user/1000",
+				App:       "park",
+				Variables: map[string]string{"origination_uuid": callerUUID},
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d originates succeeded, want 1", succeeded)
+	}
+	if cmds := sentCommands(client); len(cmds) != 1 {
+		t.Errorf("Sent %d originates, want 1", len(cmds))
+	}
+
+	if _, err := manager.Reserve(callerUUID); err == nil {
+		t.Error("Reserved a UUID already in use")
+	}
+}
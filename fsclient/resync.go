@@ -0,0 +1,122 @@
+package fsclient
+
+//ResyncHangupCause is the Hangup-Cause given to calls Resync finds have
+//vanished, as their real cause is unknown.
+const ResyncHangupCause = "FSCLIENT_RESYNC"
+
+//ResyncHeader is set to "true" in the events Resync creates for calls.
+const ResyncHeader = "fsclient-resync"
+
+//ResyncReport lists the calls changed by Resync. Added are channels that
+//weren't tracked, Removed are calls that no longer exist in Freeswitch and
+//have been hung up with ResyncHangupCause, and Updated are calls whose state
+//had changed.
+type ResyncReport struct {
+	Added   []*Call
+	Removed []*Call
+	Updated []*Call
+}
+
+//channelRow is a row of the "show channels as json" command.
+type channelRow struct {
+	UUID       string `json:"uuid"`
+	Direction  string `json:"direction"`
+	Name       string `json:"name"`
+	State      string `json:"state"`
+	CIDName    string `json:"cid_name"`
+	CIDNum     string `json:"cid_num"`
+	Dest       string `json:"dest"`
+	CallState  string `json:"callstate"`
+	PresenceID string `json:"presence_id"`
+}
+
+//OnResync registers a function to be called with the changes made by each
+//Resync, after the create, hangup and transition callbacks for the changed
+//calls have run.
+func (manager *CallManager) OnResync(fn func(report ResyncReport)) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.onResync = append(manager.onResync, fn)
+}
+
+//Resync reconciles the tracked calls with the channels that exist in
+//Freeswitch, for when events may have been missed, such as after the client
+//reconnects (call it from the client's init function) or when a gap in
+//events is detected. Vanished calls are hung up, running the hangup
+//callbacks, and new channels are tracked, running the create callbacks.
+func (manager *CallManager) Resync(client *Client) (ResyncReport, error) {
+	//Calls tracked from events received while the command runs are newer
+	//than its result, so only calls tracked beforehand can have vanished.
+	tracked := manager.Calls()
+
+	channels, err := APIJSON[ShowResult[channelRow]](client, "show channels")
+	if err != nil {
+		return ResyncReport{}, err
+	}
+
+	var report ResyncReport
+	live := make(map[string]bool, len(channels.Rows))
+	for _, row := range channels.Rows {
+		live[row.UUID] = true
+
+		call := manager.Call(row.UUID)
+		if call == nil {
+			if call = manager.track(row.UUID, row.event(Event{}, "CHANNEL_DATA")); call != nil {
+				report.Added = append(report.Added, call)
+			}
+			continue
+		}
+
+		updated := false
+		if state := ChannelState(row.State); state != "" && state != call.State() {
+			manager.track(call.UUID, row.event(call.Event(), "CHANNEL_STATE"))
+			updated = true
+		}
+		if callState := CallState(row.CallState); callState != "" && callState != call.CallState() {
+			manager.track(call.UUID, row.event(call.Event(), "CHANNEL_CALLSTATE"))
+			updated = true
+		}
+		if updated {
+			report.Updated = append(report.Updated, call)
+		}
+	}
+
+	for _, call := range tracked {
+		if live[call.UUID] || call.HungUp() || call.Reserved() {
+			continue
+		}
+
+		event := call.Event()
+		event["Event-Name"] = "CHANNEL_HANGUP_COMPLETE"
+		event["Channel-State"] = string(StateHangup)
+		event["Channel-Call-State"] = string(CallStateHangup)
+		event["Hangup-Cause"] = ResyncHangupCause
+		event[ResyncHeader] = "true"
+		manager.hangup(call.UUID, event)
+		report.Removed = append(report.Removed, call)
+	}
+
+	manager.mu.RLock()
+	onResync := manager.onResync
+	manager.mu.RUnlock()
+
+	for _, fn := range onResync {
+		fn(report)
+	}
+	return report, nil
+}
+
+//event returns headers with the row's values set, as an event called name.
+func (row channelRow) event(headers Event, name string) Event {
+	headers["Event-Name"] = name
+	headers["Unique-ID"] = row.UUID
+	headers["Call-Direction"] = row.Direction
+	headers["Channel-Name"] = row.Name
+	headers["Channel-State"] = row.State
+	headers["Channel-Call-State"] = row.CallState
+	headers["Caller-Caller-ID-Name"] = row.CIDName
+	headers["Caller-Caller-ID-Number"] = row.CIDNum
+	headers["Caller-Destination-Number"] = row.Dest
+	headers[ResyncHeader] = "true"
+	return headers
+}
@@ -0,0 +1,168 @@
+package fsclient
+
+import (
+	"strings"
+	"time"
+)
+
+//RetryPolicy decides whether a failed api or bgapi command should be retried.
+//It is called after each attempt with the attempt number (starting at 1), the
+//response body and the error (if any), and returns how long to wait before
+//the next attempt and whether to retry at all.
+//
+//Commands that are not known to be idempotent (see IsIdempotent) are never
+//offered to the policy once they have been sent to Freeswitch, as the server
+//may have acted on them even though no reply was received.
+type RetryPolicy interface {
+	Retry(cmd string, attempt int, res string, err error) (time.Duration, bool)
+}
+
+//DefaultRetryPolicy retries commands that failed because the client was
+//disconnected or because Freeswitch replied with an error containing one of
+//the TransientErrors, waiting Backoff before the first retry and doubling it
+//up to MaxBackoff.
+type DefaultRetryPolicy struct {
+	MaxAttempts     int
+	Backoff         time.Duration
+	MaxBackoff      time.Duration
+	TransientErrors []string
+}
+
+//NewDefaultRetryPolicy returns a DefaultRetryPolicy allowing 3 attempts with
+//backoff starting at 500ms, treating "Command not found!" as transient, as
+//Freeswitch replies with it while the module providing a command, such as
+//mod_sofia, is being reloaded.
+func NewDefaultRetryPolicy() *DefaultRetryPolicy {
+	return &DefaultRetryPolicy{
+		MaxAttempts:     3,
+		Backoff:         500 * time.Millisecond,
+		MaxBackoff:      5 * time.Second,
+		TransientErrors: []string{"Command not found!"},
+	}
+}
+
+//Retry implements RetryPolicy.
+func (policy *DefaultRetryPolicy) Retry(cmd string, attempt int, res string, err error) (time.Duration, bool) {
+	if attempt >= policy.MaxAttempts || !policy.transient(res, err) {
+		return 0, false
+	}
+
+	backoff := policy.Backoff
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+			break
+		}
+	}
+	return backoff, true
+}
+
+//transient returns true if the response or error indicates a failure that may
+//succeed if the command is sent again.
+func (policy *DefaultRetryPolicy) transient(res string, err error) bool {
+	if err == errDisconnected {
+		return true
+	}
+
+	//bgapi failures are returned as errors rather than response bodies.
+	if err != nil {
+		res = err.Error()
+	}
+
+	if !strings.HasPrefix(res, "-ERR") {
+		return false
+	}
+	for _, transientErr := range policy.TransientErrors {
+		if strings.Contains(res, transientErr) {
+			return true
+		}
+	}
+	return false
+}
+
+//idempotentCommands are the api commands, and subcommands, that only read
+//state and so can be repeated if it is unknown whether Freeswitch acted on
+//them. Commands such as sofia also have subcommands that change state, so
+//only their read-only ones are listed.
+var idempotentCommands = [][]string{
+	{"show"},
+	{"status"},
+	{"uptime"},
+	{"version"},
+	{"hostname"},
+	{"global_getvar"},
+	{"module_exists"},
+	{"uuid_exists"},
+	{"uuid_getvar"},
+	{"uuid_dump"},
+	{"uuid_buglist"},
+	{"sofia", "status"},
+	{"sofia", "xmlstatus"},
+	{"callcenter_config", "queue", "list"},
+	{"callcenter_config", "queue", "count"},
+	{"callcenter_config", "agent", "list"},
+	{"callcenter_config", "tier", "list"},
+	{"conference", "list"},
+	{"conference", "xml_list"},
+	{"conference", "json_list"},
+}
+
+//IsIdempotent returns true if the api command can safely be sent more than
+//once, which is only known for read-only commands such as show, status,
+//uuid_getvar and sofia status. Anything else, such as originate, uuid_kill or
+//uuid_send_dtmf, may have changed a call even if no reply was received.
+func IsIdempotent(cmd string) bool {
+	fields := strings.Fields(strings.ToLower(cmd))
+	for _, words := range idempotentCommands {
+		if len(fields) >= len(words) && equalWords(fields[:len(words)], words) {
+			return true
+		}
+	}
+	return false
+}
+
+//equalWords returns true if a and b hold the same words.
+func equalWords(a []string, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+//SetRetryPolicy sets the policy used to retry failed api and bgapi commands.
+//A nil policy (the default) disables retries.
+func (client *Client) SetRetryPolicy(policy RetryPolicy) {
+	client.optMu.Lock()
+	defer client.optMu.Unlock()
+	client.retry = policy
+}
+
+//withRetry runs send for cmd, retrying according to the client's retry policy.
+//The send function reports whether the command reached the connection so that
+//non-idempotent commands are only retried if they were never sent.
+func (client *Client) withRetry(cmd string, send func(string) (string, bool, error)) (string, error) {
+	client.optMu.RLock()
+	policy := client.retry
+	client.optMu.RUnlock()
+
+	for attempt := 1; ; attempt++ {
+		res, sent, err := send(cmd)
+		if policy == nil || err == errCancelled || (sent && !IsIdempotent(cmd)) {
+			return res, err
+		}
+
+		delay, retry := policy.Retry(cmd, attempt, res, err)
+		if !retry {
+			return res, err
+		}
+
+		select {
+		case <-client.after(delay):
+		case <-client.closeCh:
+			return res, err
+		}
+	}
+}
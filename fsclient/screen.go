@@ -0,0 +1,277 @@
+package fsclient
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+//screenTimeout is how long a CallScreener waits for its lists to answer
+//before letting a call through.
+const screenTimeout = 2 * time.Second
+
+//ScreenList is a list of numbers checked by a CallScreener, such as a table
+//of blocked callers or a feed of known robocallers.
+type ScreenList interface {
+	Contains(ctx context.Context, number string) (bool, error)
+}
+
+//ScreenListFunc is a ScreenList implemented by a function.
+type ScreenListFunc func(ctx context.Context, number string) (bool, error)
+
+//Contains calls the function.
+func (fn ScreenListFunc) Contains(ctx context.Context, number string) (bool, error) {
+	return fn(ctx, number)
+}
+
+//NumberList is a ScreenList held in memory. Numbers are matched with any
+//leading + removed.
+type NumberList struct {
+	numbers map[string]bool
+	mu      *sync.RWMutex
+}
+
+//NewNumberList creates a NumberList holding numbers.
+func NewNumberList(numbers ...string) *NumberList {
+	list := &NumberList{numbers: make(map[string]bool), mu: &sync.RWMutex{}}
+	list.Add(numbers...)
+	return list
+}
+
+//Add adds numbers to the list.
+func (list *NumberList) Add(numbers ...string) {
+	list.mu.Lock()
+	defer list.mu.Unlock()
+	for _, number := range numbers {
+		list.numbers[strings.TrimPrefix(number, "+")] = true
+	}
+}
+
+//Remove removes numbers from the list.
+func (list *NumberList) Remove(numbers ...string) {
+	list.mu.Lock()
+	defer list.mu.Unlock()
+	for _, number := range numbers {
+		delete(list.numbers, strings.TrimPrefix(number, "+"))
+	}
+}
+
+//Contains returns true if number is on the list.
+func (list *NumberList) Contains(ctx context.Context, number string) (bool, error) {
+	list.mu.RLock()
+	defer list.mu.RUnlock()
+	return list.numbers[strings.TrimPrefix(number, "+")], nil
+}
+
+//ScreenField is the number of a call a ScreenRule checks.
+type ScreenField string
+
+//Screened numbers.
+const (
+	ScreenCaller      ScreenField = "caller"
+	ScreenDestination ScreenField = "destination"
+)
+
+//ScreenAction is what a CallScreener does with a call.
+type ScreenAction string
+
+//Screening actions.
+const (
+	ScreenAllow  ScreenAction = "allow"  //The call is let through.
+	ScreenKill   ScreenAction = "kill"   //The call is hung up with uuid_kill.
+	ScreenDivert ScreenAction = "divert" //The call is sent elsewhere with uuid_transfer.
+)
+
+//ScreenRule acts on calls whose Field is on List, a block list, or with
+//Allow set, calls whose Field isn't on List, an allow list. Calls are killed
+//with Cause, CALL_REJECTED by default, or diverted to Divert, a uuid_transfer
+//destination such as "blocked XML default" or "-both 'playback:rejected.wav'
+//inline".
+type ScreenRule struct {
+	Name   string
+	Field  ScreenField
+	List   ScreenList
+	Allow  bool
+	Action ScreenAction
+	Cause  string
+	Divert string
+}
+
+//ScreenRecord is the audit record of a screening decision. Rule is the rule
+//that matched, if any, and Err is set if a list couldn't be checked or the
+//action failed.
+type ScreenRecord struct {
+	Time        time.Time
+	UUID        string
+	Caller      string
+	Destination string
+	Rule        string
+	Action      ScreenAction
+	Err         error
+}
+
+//CallScreener checks the caller and destination of each inbound call against
+//its rules as soon as the call is seen, and kills or diverts calls that
+//match, in the order the rules were added. Calls are let through if a list
+//can't be checked in time. Killing and diverting are sent with the caller
+//identity "fsclient/screen" (see WithCaller) so they also appear in the
+//client's audit records.
+//
+//Register the screener's HandleEvent method with a Dispatcher. The client must
+//be subscribed to CHANNEL_CREATE, CHANNEL_STATE and CHANNEL_HANGUP_COMPLETE.
+type CallScreener struct {
+	//OnScreened, if set, is called with the record of every call screened,
+	//including those let through.
+	OnScreened func(record ScreenRecord)
+
+	client Commander
+	rules  []ScreenRule
+	clock  Clock
+	seen   map[string]bool
+	mu     *sync.Mutex
+}
+
+//NewCallScreener creates a CallScreener with no rules.
+func NewCallScreener(client Commander) *CallScreener {
+	return &CallScreener{
+		client: client,
+		clock:  SystemClock,
+		seen:   make(map[string]bool),
+		mu:     &sync.Mutex{},
+	}
+}
+
+//SetClock sets the clock used to time screening records.
+func (screener *CallScreener) SetClock(clock Clock) {
+	screener.mu.Lock()
+	defer screener.mu.Unlock()
+	screener.clock = clock
+}
+
+//AddRule adds a rule, checked after those already added.
+func (screener *CallScreener) AddRule(rule ScreenRule) error {
+	if rule.List == nil {
+		return errors.New("Screen rule has no list: " + rule.Name)
+	}
+	switch rule.Field {
+	case ScreenCaller, ScreenDestination:
+	default:
+		return errors.New("Invalid screen field: " + string(rule.Field))
+	}
+	switch rule.Action {
+	case ScreenKill:
+		if rule.Cause == "" {
+			rule.Cause = "CALL_REJECTED"
+		}
+	case ScreenDivert:
+		if rule.Divert == "" || strings.Contains(rule.Divert, "\n") {
+			return errors.New("Invalid screen divert destination: " + rule.Divert)
+		}
+	default:
+		return errors.New("Invalid screen action: " + string(rule.Action))
+	}
+
+	screener.mu.Lock()
+	defer screener.mu.Unlock()
+	screener.rules = append(screener.rules, rule)
+	return nil
+}
+
+//HandleEvent screens inbound channels once their numbers are known, and
+//forgets channels once they hang up.
+func (screener *CallScreener) HandleEvent(event Event) {
+	uuid := event.UUID()
+	if uuid == "" {
+		return
+	}
+
+	switch event.Name() {
+	case "CHANNEL_CREATE", "CHANNEL_STATE":
+		if event.Name() == "CHANNEL_STATE" && event["Channel-State"] != "CS_ROUTING" {
+			return
+		}
+	case "CHANNEL_HANGUP_COMPLETE":
+		screener.mu.Lock()
+		delete(screener.seen, uuid)
+		screener.mu.Unlock()
+		return
+	default:
+		return
+	}
+	if event["Call-Direction"] != "inbound" || event["Caller-Destination-Number"] == "" {
+		return
+	}
+
+	screener.mu.Lock()
+	seen := screener.seen[uuid]
+	screener.seen[uuid] = true
+	rules := screener.rules
+	screener.mu.Unlock()
+	if seen {
+		return
+	}
+
+	go screener.screen(event, rules)
+}
+
+//screen checks a call against the rules and acts on the first that matches.
+func (screener *CallScreener) screen(event Event, rules []ScreenRule) {
+	record := ScreenRecord{
+		UUID:        event.UUID(),
+		Caller:      event["Caller-Caller-ID-Number"],
+		Destination: event["Caller-Destination-Number"],
+		Action:      ScreenAllow,
+	}
+
+	callerCtx := WithCaller(context.Background(), "fsclient/screen")
+	ctx, cancel := context.WithTimeout(callerCtx, screenTimeout)
+	defer cancel()
+
+	for _, rule := range rules {
+		number := record.Caller
+		if rule.Field == ScreenDestination {
+			number = record.Destination
+		}
+
+		listed, err := rule.List.Contains(ctx, number)
+		if err != nil {
+			record.Err = errors.New("Screen rule " + rule.Name + ": " + err.Error())
+			log.Print(logPrefix, "Call screening failed, allowing call: ", record.Err)
+			break
+		}
+		if listed == rule.Allow {
+			continue
+		}
+
+		record.Rule, record.Action = rule.Name, rule.Action
+		record.Err = screener.act(callerCtx, record.UUID, rule)
+		break
+	}
+
+	screener.mu.Lock()
+	record.Time = screener.clock.Now()
+	screener.mu.Unlock()
+	if screener.OnScreened != nil {
+		screener.OnScreened(record)
+	}
+}
+
+//act kills or diverts a call that matched a rule.
+func (screener *CallScreener) act(ctx context.Context, uuid string, rule ScreenRule) error {
+	cmd := "uuid_kill " + uuid + " " + rule.Cause
+	if rule.Action == ScreenDivert {
+		cmd = "uuid_transfer " + uuid + " " + rule.Divert
+	}
+
+	res, err := screener.client.APIContext(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if !ParseReply(res).OK {
+		return errors.New(strings.TrimSpace(res))
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package fsclient_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+)
+
+//screenEvent is an event for the channel uuid from caller to destination.
+func screenEvent(name string, state string, direction string, caller string, destination string) map[string]string {
+	return map[string]string{
+		"Event-Name":                name,
+		"Unique-ID":                 callerUUID,
+		"Channel-State":             state,
+		"Call-Direction":            direction,
+		"Caller-Caller-ID-Number":   caller,
+		"Caller-Destination-Number": destination,
+	}
+}
+
+//newCallScreener creates a CallScreener with rules on a fake client, and
+//returns the records it reports.
+func newCallScreener(t *testing.T, client *fsclienttest.Client, rules ...fsclient.ScreenRule) <-chan fsclient.ScreenRecord {
+	screener := fsclient.NewCallScreener(client)
+	for _, rule := range rules {
+		if err := screener.AddRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := make(chan fsclient.ScreenRecord, 10)
+	screener.OnScreened = func(record fsclient.ScreenRecord) {
+		records <- record
+	}
+	client.OnEvent(screener.HandleEvent)
+	return records
+}
+
+//TestCallScreener checks what is done with calls for the rules they match.
+func TestCallScreener(t *testing.T) {
+	blocked := fsclient.NewNumberList("+15551111")
+	premium := fsclient.NewNumberList("900")
+	extensions := fsclient.NewNumberList("1000", "1001")
+	failing := fsclient.ScreenListFunc(func(ctx context.Context, number string) (bool, error) {
+		return false, errors.New("Lookup failed")
+	})
+
+	kill := fsclient.ScreenRule{Name: "blocked", Field: fsclient.ScreenCaller, List: blocked, Action: fsclient.ScreenKill}
+	divert := fsclient.ScreenRule{Name: "premium", Field: fsclient.ScreenDestination, List: premium, Action: fsclient.ScreenDivert, Divert: "blocked XML default"}
+	allow := fsclient.ScreenRule{Name: "extensions", Field: fsclient.ScreenDestination, List: extensions, Allow: true, Action: fsclient.ScreenKill, Cause: "UNALLOCATED_NUMBER"}
+
+	tests := []struct {
+		name        string
+		rules       []fsclient.ScreenRule
+		caller      string
+		destination string
+		reply       string
+		wantRule    string
+		wantAction  fsclient.ScreenAction
+		wantCmd     string
+		wantErr     bool
+	}{
+		{"allowed", []fsclient.ScreenRule{kill, divert}, "5552222", "1000", "+OK", "", fsclient.ScreenAllow, "", false},
+		{"killed", []fsclient.ScreenRule{kill, divert}, "15551111", "1000", "+OK", "blocked", fsclient.ScreenKill, "uuid_kill " + callerUUID + " CALL_REJECTED", false},
+		{"diverted", []fsclient.ScreenRule{kill, divert}, "5552222", "900", "+OK", "premium", fsclient.ScreenDivert, "uuid_transfer " + callerUUID + " blocked XML default", false},
+		{"first rule", []fsclient.ScreenRule{divert, kill}, "15551111", "900", "+OK", "premium", fsclient.ScreenDivert, "uuid_transfer " + callerUUID + " blocked XML default", false},
+		{"allow list", []fsclient.ScreenRule{allow}, "5552222", "1001", "+OK", "", fsclient.ScreenAllow, "", false},
+		{"not on allow list", []fsclient.ScreenRule{allow}, "5552222", "2000", "+OK", "extensions", fsclient.ScreenKill, "uuid_kill " + callerUUID + " UNALLOCATED_NUMBER", false},
+		{"list failed", []fsclient.ScreenRule{{Name: "feed", Field: fsclient.ScreenCaller, List: failing, Action: fsclient.ScreenKill}, kill}, "15551111", "1000", "+OK", "", fsclient.ScreenAllow, "", true},
+		{"kill failed", []fsclient.ScreenRule{kill}, "15551111", "1000", "-ERR No such channel!", "blocked", fsclient.ScreenKill, "uuid_kill " + callerUUID + " CALL_REJECTED", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			captureLog(t)
+			client := fsclienttest.NewClient()
+			client.Reply(fsclient.ClassAPI, "uuid_kill", test.reply)
+			client.Reply(fsclient.ClassAPI, "uuid_transfer", test.reply)
+			records := newCallScreener(t, client, test.rules...)
+
+			client.Inject(screenEvent("CHANNEL_CREATE", "CS_INIT", "inbound", test.caller, test.destination))
+
+			var record fsclient.ScreenRecord
+			select {
+			case record = <-records:
+			case <-time.After(time.Second):
+				t.Fatal("Call not screened")
+			}
+			if record.UUID != callerUUID || record.Caller != test.caller || record.Destination != test.destination {
+				t.Errorf("Got record %+v", record)
+			}
+			if record.Rule != test.wantRule || record.Action != test.wantAction || (record.Err != nil) != test.wantErr {
+				t.Errorf("Got rule %q action %q error %v", record.Rule, record.Action, record.Err)
+			}
+
+			cmds := sentCommands(client)
+			if test.wantCmd == "" && len(cmds) != 0 || test.wantCmd != "" && (len(cmds) != 1 || cmds[0] != test.wantCmd) {
+				t.Errorf("Sent %q, want %q", cmds, test.wantCmd)
+			}
+		})
+	}
+}
+
+//TestCallScreenerEvents checks which channel events are screened.
+func TestCallScreenerEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []map[string]string
+		want   int
+	}{
+		{"created", []map[string]string{
+			screenEvent("CHANNEL_CREATE", "CS_INIT", "inbound", "5551111", "1000"),
+		}, 1},
+		{"routing", []map[string]string{
+			screenEvent("CHANNEL_CREATE", "CS_INIT", "inbound", "5551111", ""),
+			screenEvent("CHANNEL_STATE", "CS_ROUTING", "inbound", "5551111", "1000"),
+		}, 1},
+		{"screened once", []map[string]string{
+			screenEvent("CHANNEL_CREATE", "CS_INIT", "inbound", "5551111", "1000"),
+			screenEvent("CHANNEL_STATE", "CS_ROUTING", "inbound", "5551111", "1000"),
+		}, 1},
+		{"new call", []map[string]string{
+			screenEvent("CHANNEL_CREATE", "CS_INIT", "inbound", "5551111", "1000"),
+			{"Event-Name": "CHANNEL_HANGUP_COMPLETE", "Unique-ID": callerUUID},
+			screenEvent("CHANNEL_CREATE", "CS_INIT", "inbound", "5551111", "1000"),
+		}, 2},
+		{"outbound", []map[string]string{
+			screenEvent("CHANNEL_CREATE", "CS_INIT", "outbound", "5551111", "1000"),
+		}, 0},
+		{"not routing", []map[string]string{
+			screenEvent("CHANNEL_STATE", "CS_EXECUTE", "inbound", "5551111", "1000"),
+		}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fsclienttest.NewClient()
+			client.Reply(fsclient.ClassAPI, "uuid_kill", "+OK")
+			records := newCallScreener(t, client, fsclient.ScreenRule{Name: "blocked", Field: fsclient.ScreenCaller, List: fsclient.NewNumberList("5551111"), Action: fsclient.ScreenKill})
+
+			for _, event := range test.events {
+				client.Inject(event)
+			}
+			for i := 0; i < test.want; i++ {
+				select {
+				case <-records:
+				case <-time.After(time.Second):
+					t.Fatalf("Screened %d calls, want %d", i, test.want)
+				}
+			}
+			select {
+			case record := <-records:
+				t.Errorf("Screened %+v, want %d calls", record, test.want)
+			case <-time.After(20 * time.Millisecond):
+			}
+		})
+	}
+}
+
+//TestCallScreenerAddRule checks that rules that can't be acted on are
+//rejected.
+func TestCallScreenerAddRule(t *testing.T) {
+	list := fsclient.NewNumberList("5551111")
+
+	tests := []struct {
+		name    string
+		rule    fsclient.ScreenRule
+		wantErr bool
+	}{
+		{"kill", fsclient.ScreenRule{Field: fsclient.ScreenCaller, List: list, Action: fsclient.ScreenKill}, false},
+		{"divert", fsclient.ScreenRule{Field: fsclient.ScreenDestination, List: list, Action: fsclient.ScreenDivert, Divert: "blocked XML default"}, false},
+		{"no list", fsclient.ScreenRule{Field: fsclient.ScreenCaller, Action: fsclient.ScreenKill}, true},
+		{"invalid field", fsclient.ScreenRule{Field: "network", List: list, Action: fsclient.ScreenKill}, true},
+		{"allow action", fsclient.ScreenRule{Field: fsclient.ScreenCaller, List: list, Action: fsclient.ScreenAllow}, true},
+		{"no divert", fsclient.ScreenRule{Field: fsclient.ScreenCaller, List: list, Action: fsclient.ScreenDivert}, true},
+		{"divert command", fsclient.ScreenRule{Field: fsclient.ScreenCaller, List: list, Action: fsclient.ScreenDivert, Divert: "blocked\napi system"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := fsclient.NewCallScreener(fsclienttest.NewClient()).AddRule(test.rule)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Got error %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
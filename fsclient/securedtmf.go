@@ -0,0 +1,159 @@
+package fsclient
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//SecureDTMF pauses a call's recordings and keeps its DTMF out of event sinks
+//while sensitive digits, such as card details, are entered, for PCI DSS. Its
+//recordings are masked with uuid_record, so the digits' tones are replaced
+//by silence, and the channel is put in secure mode on the Redactor, so
+//RedactHandler drops its DTMF events and its digits are masked in the
+//client's frame history.
+//
+//Register its HandleEvent method with a Dispatcher so it knows which
+//recordings each channel has. The client must be subscribed to RECORD_START,
+//RECORD_STOP and CHANNEL_HANGUP_COMPLETE.
+type SecureDTMF struct {
+	client     Commander
+	redactor   *Redactor
+	recordings map[string]map[string]bool
+	secure     map[string]bool
+	mu         *sync.Mutex
+}
+
+//NewSecureDTMF creates a SecureDTMF masking recordings with client and DTMF
+//with redactor, which may be nil to only mask recordings.
+func NewSecureDTMF(client Commander, redactor *Redactor) *SecureDTMF {
+	return &SecureDTMF{
+		client:     client,
+		redactor:   redactor,
+		recordings: make(map[string]map[string]bool),
+		secure:     make(map[string]bool),
+		mu:         &sync.Mutex{},
+	}
+}
+
+//StartSecureDTMF masks the channel uuid's recordings and DTMF until
+//StopSecureDTMF is called or it hangs up. Recordings started in the meantime
+//are masked too. The channel is in secure mode even if masking a recording
+//fails, so that no digits reach sinks, and the first error is returned.
+func (secure *SecureDTMF) StartSecureDTMF(uuid string) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+
+	secure.mu.Lock()
+	secure.secure[uuid] = true
+	paths := secure.paths(uuid)
+	secure.mu.Unlock()
+
+	if secure.redactor != nil {
+		secure.redactor.SetSecure(uuid, true)
+	}
+	return secure.mask(uuid, "mask", paths)
+}
+
+//StopSecureDTMF unmasks the channel uuid's recordings and lets its DTMF
+//reach sinks again. The channel is taken out of secure mode even if
+//unmasking a recording fails, and the first error is returned.
+func (secure *SecureDTMF) StopSecureDTMF(uuid string) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+
+	secure.mu.Lock()
+	delete(secure.secure, uuid)
+	paths := secure.paths(uuid)
+	secure.mu.Unlock()
+
+	if secure.redactor != nil {
+		secure.redactor.SetSecure(uuid, false)
+	}
+	return secure.mask(uuid, "unmask", paths)
+}
+
+//Secure returns true if the channel uuid's DTMF is being masked.
+func (secure *SecureDTMF) Secure(uuid string) bool {
+	secure.mu.Lock()
+	defer secure.mu.Unlock()
+	return secure.secure[uuid]
+}
+
+//paths returns the channel's recordings in order. The caller must hold the
+//lock.
+func (secure *SecureDTMF) paths(uuid string) []string {
+	paths := make([]string, 0, len(secure.recordings[uuid]))
+	for path := range secure.recordings[uuid] {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+//mask runs uuid_record mask or unmask on each of a channel's recordings,
+//carrying on after a failure and returning the first error.
+func (secure *SecureDTMF) mask(uuid string, action string, paths []string) error {
+	var first error
+	for _, path := range paths {
+		res, err := secure.client.API("uuid_record " + uuid + " " + action + " " + path)
+		if err == nil && !ParseReply(res).OK {
+			err = errors.New(strings.TrimSpace(res))
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+//HandleEvent tracks channels' recordings, masking those started while a
+//channel is in secure mode.
+func (secure *SecureDTMF) HandleEvent(event Event) {
+	uuid, path := event.UUID(), event["Record-File-Path"]
+	if uuid == "" {
+		return
+	}
+
+	secure.mu.Lock()
+	defer secure.mu.Unlock()
+
+	switch event.Name() {
+	case "RECORD_START":
+		if path == "" {
+			return
+		}
+		if secure.recordings[uuid] == nil {
+			secure.recordings[uuid] = make(map[string]bool)
+		}
+		secure.recordings[uuid][path] = true
+		if secure.secure[uuid] {
+			go func() {
+				if err := secure.mask(uuid, "mask", []string{path}); err != nil {
+					log.Print(logPrefix, "Failed to mask recording ", path, " of secure call ", uuid, ": ", err)
+				}
+				//Secure mode may have ended while it was being masked.
+				if !secure.Secure(uuid) {
+					secure.mask(uuid, "unmask", []string{path})
+				}
+			}()
+		}
+	case "RECORD_STOP":
+		delete(secure.recordings[uuid], path)
+		if len(secure.recordings[uuid]) == 0 {
+			delete(secure.recordings, uuid)
+		}
+	case "CHANNEL_HANGUP_COMPLETE":
+		delete(secure.recordings, uuid)
+		if secure.secure[uuid] {
+			delete(secure.secure, uuid)
+			if secure.redactor != nil {
+				secure.redactor.SetSecure(uuid, false)
+			}
+		}
+	}
+}
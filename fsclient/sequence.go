@@ -0,0 +1,72 @@
+package fsclient
+
+import (
+	"log"
+	"strconv"
+	"sync/atomic"
+)
+
+//EventGap describes events lost between two received events, detected from a
+//gap in their Event-Sequence numbers. Reconnected is true if the client
+//reconnected between the two events, in which case the missed events were
+//fired while it was disconnected.
+type EventGap struct {
+	Last        uint64
+	Next        uint64
+	Missed      uint64
+	Reconnected bool
+}
+
+//SetGapHandler enables event loss detection, calling handler for each gap in
+//the Event-Sequence numbers of received events, or disables it if handler is
+//nil. Gaps are also logged and counted by LostEvents. The handler runs on the
+//read handler goroutine so must not block; start a goroutine to resync state.
+//
+//Freeswitch numbers every event it fires, so gaps are only meaningful when the
+//client is subscribed to all events without filters. Otherwise events the
+//client didn't ask for show up as gaps.
+func (client *Client) SetGapHandler(handler func(gap EventGap)) {
+	client.optMu.Lock()
+	defer client.optMu.Unlock()
+	client.gapHandler = handler
+}
+
+//LostEvents returns the number of events detected as lost from gaps in their
+//Event-Sequence numbers since event loss detection was enabled.
+func (client *Client) LostEvents() uint64 {
+	return atomic.LoadUint64(&client.lostEvents)
+}
+
+//checkSequence checks an event's Event-Sequence number follows on from the
+//last event received. It is only called from the read handler goroutine.
+func (client *Client) checkSequence(event map[string]string) {
+	client.optMu.RLock()
+	handler := client.gapHandler
+	client.optMu.RUnlock()
+
+	if handler == nil {
+		client.lastSeq = 0
+		return
+	}
+
+	seq, err := strconv.ParseUint(event["Event-Sequence"], 10, 64)
+	if err != nil {
+		return
+	}
+
+	last := client.lastSeq
+	reconnected := client.reconnected
+	client.lastSeq = seq
+	client.reconnected = false
+
+	//A lower sequence number means Freeswitch restarted, so there is nothing
+	//to compare against.
+	if last == 0 || seq <= last || seq == last+1 {
+		return
+	}
+
+	gap := EventGap{Last: last, Next: seq, Missed: seq - last - 1, Reconnected: reconnected}
+	atomic.AddUint64(&client.lostEvents, gap.Missed)
+	log.Print(logPrefix, "Lost ", gap.Missed, " events between Event-Sequence ", last, " and ", seq)
+	handler(gap)
+}
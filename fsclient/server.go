@@ -0,0 +1,158 @@
+package fsclient
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//versionPattern matches the version number in the reply to "version", e.g.
+//"FreeSWITCH Version 1.10.11-release+git~20231209T150012Z~6b4f7ba0dd~64bit".
+var versionPattern = regexp.MustCompile(`Version (\d+)\.(\d+)\.(\d+)`)
+
+//ServerVersion is the version of a Freeswitch server. Raw is the full reply to
+//the version command.
+type ServerVersion struct {
+	Major int
+	Minor int
+	Patch int
+	Raw   string
+}
+
+//ParseServerVersion parses the reply to the version api command.
+func ParseServerVersion(text string) (ServerVersion, error) {
+	match := versionPattern.FindStringSubmatch(text)
+	if match == nil {
+		return ServerVersion{}, errors.New("Unknown version: " + strings.TrimSpace(text))
+	}
+
+	version := ServerVersion{Raw: strings.TrimSpace(text)}
+	version.Major, _ = strconv.Atoi(match[1])
+	version.Minor, _ = strconv.Atoi(match[2])
+	version.Patch, _ = strconv.Atoi(match[3])
+	return version, nil
+}
+
+//AtLeast returns true if the version is major.minor.patch or later.
+func (version ServerVersion) AtLeast(major int, minor int, patch int) bool {
+	if version.Major != major {
+		return version.Major > major
+	}
+	if version.Minor != minor {
+		return version.Minor > minor
+	}
+	return version.Patch >= patch
+}
+
+//String returns the version number, e.g. "1.10.11".
+func (version ServerVersion) String() string {
+	return strconv.Itoa(version.Major) + "." + strconv.Itoa(version.Minor) + "." + strconv.Itoa(version.Patch)
+}
+
+//Capability is a feature that not every Freeswitch server has.
+type Capability string
+
+//Capabilities detected when the client connects.
+const (
+	//CapabilityJSONEvents is the json event format, from Freeswitch 1.2.
+	CapabilityJSONEvents Capability = "json-events"
+
+	//CapabilityMediaStats is the uuid_set_media_stats api command.
+	CapabilityMediaStats Capability = "uuid_set_media_stats"
+)
+
+//serverInfo is what the client has detected about the server it is
+//connected to.
+type serverInfo struct {
+	version ServerVersion
+	apis    map[string]bool
+}
+
+//ServerVersion returns the version of the Freeswitch server, which is
+//detected each time the client connects. The bool result is false until it
+//has been detected.
+func (client *Client) ServerVersion() (ServerVersion, bool) {
+	client.optMu.RLock()
+	defer client.optMu.RUnlock()
+	if client.server == nil {
+		return ServerVersion{}, false
+	}
+	return client.server.version, true
+}
+
+//Supports returns true if the Freeswitch server has a capability. It returns
+//false until the server has been detected after connecting.
+func (client *Client) Supports(capability Capability) bool {
+	client.optMu.RLock()
+	server := client.server
+	client.optMu.RUnlock()
+
+	if server == nil {
+		return false
+	}
+	return server.supports(capability)
+}
+
+//unsupported returns true if the server is known not to have a capability, so
+//helpers that can do without it only skip it once the server is detected.
+func (client *Client) unsupported(capability Capability) bool {
+	client.optMu.RLock()
+	server := client.server
+	client.optMu.RUnlock()
+
+	return server != nil && !server.supports(capability)
+}
+
+//supports returns true if the server has a capability. Api commands are
+//assumed to exist if the list of them couldn't be fetched.
+func (server *serverInfo) supports(capability Capability) bool {
+	switch capability {
+	case CapabilityJSONEvents:
+		return server.version.AtLeast(1, 2, 0)
+	}
+	return server.apis == nil || server.apis[string(capability)]
+}
+
+//detectServer runs the version and "show api" commands to find out what the
+//server supports. It is run each time the client connects, as the server may
+//have been upgraded. Like event subscriptions, the commands aren't authorized
+//or audited.
+func (client *Client) detectServer() {
+	client.optMu.Lock()
+	client.server = nil
+	client.optMu.Unlock()
+
+	res, _, err := client.api("version")
+	if err != nil {
+		log.Print(logPrefix, "Failed to get server version: ", err)
+		return
+	}
+	version, err := ParseServerVersion(res)
+	if err != nil {
+		log.Print(logPrefix, err)
+		return
+	}
+
+	server := &serverInfo{version: version}
+	if res, _, err := client.api("show api as json"); err == nil {
+		var commands struct {
+			Rows []struct {
+				Name string `json:"name"`
+			} `json:"rows"`
+		}
+		if err := json.Unmarshal([]byte(res), &commands); err == nil {
+			server.apis = make(map[string]bool, len(commands.Rows))
+			for _, row := range commands.Rows {
+				server.apis[row.Name] = true
+			}
+		}
+	}
+
+	client.optMu.Lock()
+	client.server = server
+	client.optMu.Unlock()
+	log.Print(logPrefix, "Connected to Freeswitch ", version)
+}
@@ -0,0 +1,166 @@
+package fsclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//errHungUp is returned by Session methods once the channel has hung up.
+var errHungUp = errors.New("Channel hung up")
+
+//Session controls a single channel, inbound or outbound, by running dialplan
+//applications on it and waiting for them to complete.
+//
+//Register the Session's HandleEvent method with a Dispatcher so it can see
+//the channel's events, and remove it again once the session is finished. The
+//client must be subscribed to CHANNEL_EXECUTE_COMPLETE and CHANNEL_HANGUP.
+type Session struct {
+	UUID       string
+	client     Commander
+	completed  *Correlator
+	speechCh   chan SpeechResult
+	toneCh     chan string
+	hangupCh   chan struct{}
+	hangupOnce *sync.Once
+	tts        TTSProvider
+	mu         *sync.Mutex
+}
+
+//NewSession creates a Session for the channel uuid.
+func NewSession(client Commander, uuid string) *Session {
+	return &Session{
+		UUID:       uuid,
+		client:     client,
+		completed:  NewCorrelator("Application-UUID"),
+		speechCh:   make(chan SpeechResult, speechBufSize),
+		toneCh:     make(chan string, toneBufSize),
+		hangupCh:   make(chan struct{}),
+		hangupOnce: &sync.Once{},
+		mu:         &sync.Mutex{},
+	}
+}
+
+//HandleEvent delivers application completions, speech results, detected
+//tones and hangups for the session's channel.
+func (session *Session) HandleEvent(event Event) {
+	if event.UUID() != session.UUID {
+		return
+	}
+
+	switch event.Name() {
+	case "CHANNEL_EXECUTE_COMPLETE":
+		session.completed.HandleEvent(event)
+	case "DETECTED_SPEECH":
+		session.deliverSpeech(event)
+	case "DETECTED_TONE":
+		session.deliverTone(event)
+	case "CHANNEL_HANGUP", "CHANNEL_HANGUP_COMPLETE":
+		session.hangupOnce.Do(func() { close(session.hangupCh) })
+	}
+}
+
+//HungUp returns a channel that is closed when the session's channel hangs up.
+func (session *Session) HungUp() <-chan struct{} {
+	return session.hangupCh
+}
+
+//Execute runs a dialplan application on the channel and waits for it to
+//complete, returning its CHANNEL_EXECUTE_COMPLETE event.
+func (session *Session) Execute(app string, arg string) (Event, error) {
+	select {
+	case <-session.hangupCh:
+		return nil, errHungUp
+	default:
+	}
+
+	appUUID := NewUUID()
+	completion := session.completed.Expect(appUUID)
+	defer completion.Cancel()
+
+	res, err := session.client.ExecuteWithEventUUID(context.Background(), app, arg, session.UUID, false, appUUID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(res, "-ERR") {
+		return nil, errors.New(res)
+	}
+
+	select {
+	case event := <-completion.Event():
+		return event, nil
+	case <-session.hangupCh:
+		//The application normally completes as the channel hangs up, so
+		//prefer its completion if it has arrived.
+		select {
+		case event := <-completion.Event():
+			return event, nil
+		default:
+			return nil, errHungUp
+		}
+	}
+}
+
+//Answer answers the channel.
+func (session *Session) Answer() error {
+	_, err := session.Execute("answer", "")
+	return err
+}
+
+//Hangup hangs up the channel with a hangup cause, e.g. "NORMAL_CLEARING".
+func (session *Session) Hangup(cause string) error {
+	_, err := session.client.ExecuteWithEventUUID(context.Background(), "hangup", cause, session.UUID, false, "")
+	return err
+}
+
+//Playback plays a sound file, stream or "say:" text-to-speech on the channel.
+func (session *Session) Playback(file string) error {
+	file, err := session.renderPrompt(file)
+	if err != nil {
+		return err
+	}
+	_, err = session.Execute("playback", file)
+	return err
+}
+
+//PlayAndGetDigits plays prompt and collects between min and max DTMF digits,
+//ending early on one of the terminators (e.g. "#"). Up to tries attempts are
+//made, with invalid played after input that doesn't match regexp. timeout is
+//the time allowed for input after the prompt and digitTimeout the time
+//allowed between digits. An empty string is returned if no valid input was
+//received. Prompts containing spaces, such as "say:" text, are single
+//quoted.
+func (session *Session) PlayAndGetDigits(prompt string, invalid string, min int, max int, tries int, timeout time.Duration, digitTimeout time.Duration, terminators string, regexp string) (string, error) {
+	if terminators == "" {
+		terminators = "none"
+	}
+	if invalid == "" {
+		invalid = "silence_stream://250"
+	}
+	if regexp == "" {
+		regexp = `\d+`
+	}
+	prompt, err := session.renderPrompt(prompt)
+	if err != nil {
+		return "", err
+	}
+	invalid, err = session.renderPrompt(invalid)
+	if err != nil {
+		return "", err
+	}
+
+	//Use a new variable each time so input from an earlier call is never
+	//mistaken for this one's.
+	variable := "fsclient_digits_" + NewUUID()[:8]
+	arg := fmt.Sprintf("%d %d %d %d %s %s %s %s %s %d", min, max, tries, timeout.Milliseconds(),
+		terminators, quoteArg(prompt), quoteArg(invalid), variable, regexp, digitTimeout.Milliseconds())
+
+	event, err := session.Execute("play_and_get_digits", arg)
+	if err != nil {
+		return "", err
+	}
+	return event["variable_"+variable], nil
+}
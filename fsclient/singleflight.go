@@ -0,0 +1,120 @@
+package fsclient
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+//queryCall is an in-progress or recently completed api query.
+type queryCall struct {
+	wg       sync.WaitGroup
+	res      string
+	err      error
+	finished bool
+	expires  time.Time
+}
+
+//queryGroup coalesces concurrent identical api queries so that only one is
+//sent to Freeswitch, optionally caching successful results for a short time.
+type queryGroup struct {
+	ttl      time.Duration
+	prefixes []string
+	calls    map[string]*queryCall
+	clock    Clock
+	mu       *sync.Mutex
+}
+
+//matches returns true if cmd is one of the read-only queries to coalesce.
+func (group *queryGroup) matches(cmd string) bool {
+	for _, prefix := range group.prefixes {
+		if strings.HasPrefix(cmd, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//do runs fn for cmd unless an identical query is already in flight or has a
+//cached result, in which case that result is shared instead.
+func (group *queryGroup) do(cmd string, fn func(string) (string, error)) (string, error) {
+	group.mu.Lock()
+	if call, ok := group.calls[cmd]; ok && (!call.finished || group.clock.Now().Before(call.expires)) {
+		group.mu.Unlock()
+		call.wg.Wait()
+		return call.res, call.err
+	}
+
+	call := &queryCall{}
+	call.wg.Add(1)
+	group.calls[cmd] = call
+	group.mu.Unlock()
+
+	res, err := fn(cmd)
+
+	group.mu.Lock()
+	call.res, call.err = res, err
+	call.finished = true
+	call.expires = group.clock.Now().Add(group.ttl)
+
+	//Errors are never cached so the next caller tries again, whether the
+	//command failed or Freeswitch replied with an error.
+	cached := group.ttl > 0 && err == nil && !errorReply(res)
+	if !cached {
+		delete(group.calls, cmd)
+	}
+	clock, ttl := group.clock, group.ttl
+	group.mu.Unlock()
+	call.wg.Done()
+
+	//Remove the result once it expires, so that queries with arguments
+	//that are never repeated don't accumulate.
+	if cached {
+		clock.AfterFunc(ttl, func() {
+			group.mu.Lock()
+			defer group.mu.Unlock()
+			if group.calls[cmd] == call {
+				delete(group.calls, cmd)
+			}
+		})
+	}
+	return res, err
+}
+
+//errorReply returns true if an api result is an error reply, such as
+//"-ERR no such channel" or "-USAGE: ...".
+func errorReply(res string) bool {
+	res = strings.TrimSpace(res)
+	return strings.HasPrefix(res, "-ERR") || strings.HasPrefix(res, "-USAGE")
+}
+
+//SetQueryCoalescing enables deduplication of concurrent api queries that
+//start with any of the given prefixes, e.g. "status" or "show channels count".
+//Callers issuing an identical query while one is in flight share its result.
+//If ttl is greater than zero, successful results are also reused for that
+//long. Only read-only commands should be listed. Calling with no prefixes
+//disables coalescing.
+func (client *Client) SetQueryCoalescing(ttl time.Duration, prefixes ...string) {
+	client.optMu.Lock()
+	defer client.optMu.Unlock()
+
+	if len(prefixes) == 0 {
+		client.queries = nil
+		return
+	}
+
+	client.queries = &queryGroup{
+		ttl:      ttl,
+		prefixes: prefixes,
+		calls:    make(map[string]*queryCall),
+		clock:    client.clock,
+		mu:       &sync.Mutex{},
+	}
+}
+
+//setClock sets the clock cached results expire by.
+func (group *queryGroup) setClock(clock Clock) {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	group.clock = clock
+}
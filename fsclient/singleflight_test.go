@@ -0,0 +1,70 @@
+package fsclient
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+//TestQueryGroup checks which queries are answered from the cache, and that
+//cached results are removed once they expire.
+func TestQueryGroup(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		res       string
+		err       error
+		wantSends int
+	}{
+		{"cached", time.Hour, "+OK", nil, 1},
+		{"not cached", 0, "+OK", nil, 3},
+		{"error reply", time.Hour, "-ERR no such channel", nil, 3},
+		{"usage reply", time.Hour, "-USAGE: <uuid>", nil, 3},
+		{"error", time.Hour, "", errors.New("Not connected"), 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			group := &queryGroup{ttl: test.ttl, calls: make(map[string]*queryCall), clock: SystemClock, mu: &sync.Mutex{}}
+			sends := 0
+			for i := 0; i < 3; i++ {
+				res, err := group.do("uuid_exists 1234", func(cmd string) (string, error) {
+					sends++
+					return test.res, test.err
+				})
+				if res != test.res || err != test.err {
+					t.Fatalf("Got %q, %v", res, err)
+				}
+			}
+			if sends != test.wantSends {
+				t.Errorf("Sent %d queries, want %d", sends, test.wantSends)
+			}
+		})
+	}
+}
+
+//TestQueryGroupExpiry checks that cached results of queries that aren't
+//repeated are removed once they expire.
+func TestQueryGroupExpiry(t *testing.T) {
+	group := &queryGroup{ttl: 10 * time.Millisecond, calls: make(map[string]*queryCall), clock: SystemClock, mu: &sync.Mutex{}}
+	for _, cmd := range []string{"uuid_exists 1", "uuid_exists 2", "uuid_exists 3"} {
+		group.do(cmd, func(cmd string) (string, error) {
+			return "true", nil
+		})
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		group.mu.Lock()
+		cached := len(group.calls)
+		group.mu.Unlock()
+		if cached == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d expired results still cached", cached)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,147 @@
+package fsclient
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+//Channel variable prefixes that add custom SIP headers to a call.
+const (
+	SIPHeaderPrefix         = "sip_h_"  //Headers sent in requests, such as an originated INVITE.
+	SIPResponseHeaderPrefix = "sip_rh_" //Headers sent in responses, such as the 200 OK answering a call.
+)
+
+//SIPHeaders is a set of custom SIP headers by name, e.g. "X-Account-ID".
+type SIPHeaders map[string]string
+
+//Get returns a header's value, matching its name without regard to case as
+//SIP does.
+func (headers SIPHeaders) Get(name string) string {
+	if value, ok := headers[name]; ok {
+		return value
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+//Validate returns an error if a header name isn't a valid SIP token or a
+//value spans several lines.
+func (headers SIPHeaders) Validate() error {
+	for name, value := range headers {
+		if !validSIPToken(name) || strings.ContainsAny(value, "\r\n") {
+			return errors.New("Invalid SIP header: " + name)
+		}
+	}
+	return nil
+}
+
+//Vars returns the channel variables that send the headers in requests.
+func (headers SIPHeaders) Vars() map[string]string {
+	return headers.prefixed(SIPHeaderPrefix)
+}
+
+//ResponseVars returns the channel variables that send the headers in
+//responses.
+func (headers SIPHeaders) ResponseVars() map[string]string {
+	return headers.prefixed(SIPResponseHeaderPrefix)
+}
+
+//prefixed returns the headers as channel variables with a prefix.
+func (headers SIPHeaders) prefixed(prefix string) map[string]string {
+	vars := make(map[string]string, len(headers))
+	for name, value := range headers {
+		vars[prefix+name] = value
+	}
+	return vars
+}
+
+//names returns the header names, sorted.
+func (headers SIPHeaders) names() []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//validSIPToken returns true if name is a SIP token, as header names are.
+func validSIPToken(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-.!%*_+`'~", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+//SIPHeadersFromEvent returns the custom SIP headers of a channel from an
+//event's variable_sip_h_ headers, e.g. the X- headers of an inbound INVITE.
+func SIPHeadersFromEvent(event Event) SIPHeaders {
+	prefix := "variable_" + SIPHeaderPrefix
+	headers := make(SIPHeaders)
+	for key, value := range event {
+		if strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+			headers[key[len(prefix):]] = value
+		}
+	}
+	return headers
+}
+
+//SIPHeaders returns the custom SIP headers from the call's most recent event.
+func (call *Call) SIPHeaders() SIPHeaders {
+	call.mu.RLock()
+	defer call.mu.RUnlock()
+	return SIPHeadersFromEvent(call.event)
+}
+
+//SetSIPHeaders sets headers to be sent in the channel's later requests, such
+//as a re-INVITE, REFER or BYE. An empty value stops a header being sent.
+func (client *Client) SetSIPHeaders(uuid string, headers SIPHeaders) error {
+	if err := headers.Validate(); err != nil {
+		return err
+	}
+	return client.SetVariables(uuid, headers.Vars())
+}
+
+//SetSIPResponseHeaders sets headers to be sent in the channel's responses,
+//e.g. on an inbound call before it is answered so they are in the 200 OK.
+func (client *Client) SetSIPResponseHeaders(uuid string, headers SIPHeaders) error {
+	if err := headers.Validate(); err != nil {
+		return err
+	}
+	return client.SetVariables(uuid, headers.ResponseVars())
+}
+
+//ExportSIPHeaders sets headers on the channel and exports them, so they are
+//also sent in the INVITE of calls the channel is later bridged to.
+func (client *Client) ExportSIPHeaders(uuid string, headers SIPHeaders) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+	if err := headers.Validate(); err != nil {
+		return err
+	}
+
+	for _, name := range headers.names() {
+		res, err := client.Execute("export", SIPHeaderPrefix+name+"="+headers[name], uuid, false)
+		if err != nil {
+			return err
+		}
+		if !ParseReply(res).OK {
+			return errors.New(strings.TrimSpace(res))
+		}
+	}
+	return nil
+}
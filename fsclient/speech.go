@@ -0,0 +1,160 @@
+package fsclient
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+//speechBufSize is the number of speech results buffered for a Session before
+//further results are discarded.
+const speechBufSize = 10
+
+//SpeechResult is a speech recognition result, decoded from a DETECTED_SPEECH
+//event or the detect_speech_result variable. Text is what was said and
+//Interpretation the grammar's semantic interpretation, if any. Confidence is
+//as reported by the recognizer, usually 0-100 or 0-1. Raw holds the
+//undecoded result.
+type SpeechResult struct {
+	Type           string
+	Text           string
+	Interpretation string
+	Confidence     float64
+	Grammar        string
+	Raw            string
+}
+
+//nlsmlResult is the NLSML XML result format used by mod_unimrcp and
+//mod_pocketsphinx.
+type nlsmlResult struct {
+	Interpretations []struct {
+		Grammar    string `xml:"grammar,attr"`
+		Confidence string `xml:"confidence,attr"`
+		Instance   struct {
+			Inner string `xml:",innerxml"`
+		} `xml:"instance"`
+		Input string `xml:"input"`
+	} `xml:"interpretation"`
+}
+
+//ParseSpeechResult decodes a recognizer result in NLSML XML or JSON. Only the
+//first (best) interpretation is used. JSON results are decoded from the
+//common "text" or "transcript", "confidence" and "grammar" fields.
+func ParseSpeechResult(raw string) (SpeechResult, error) {
+	result := SpeechResult{Raw: raw}
+	trimmed := strings.TrimSpace(raw)
+
+	switch {
+	case trimmed == "":
+		return result, nil
+
+	case strings.HasPrefix(trimmed, "{"):
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(trimmed), &fields); err != nil {
+			return result, err
+		}
+		result.Text = jsonString(fields, "text", "transcript")
+		result.Interpretation = jsonString(fields, "interpretation", "intent")
+		result.Grammar = jsonString(fields, "grammar")
+		if confidence, ok := fields["confidence"].(float64); ok {
+			result.Confidence = confidence
+		}
+		return result, nil
+
+	case strings.HasPrefix(trimmed, "<"):
+		var nlsml nlsmlResult
+		if err := xml.Unmarshal([]byte(trimmed), &nlsml); err != nil {
+			return result, err
+		}
+		if len(nlsml.Interpretations) == 0 {
+			return result, nil
+		}
+		best := nlsml.Interpretations[0]
+		result.Text = strings.TrimSpace(best.Input)
+		result.Interpretation = strings.TrimSpace(best.Instance.Inner)
+		result.Grammar = best.Grammar
+		result.Confidence, _ = strconv.ParseFloat(best.Confidence, 64)
+		return result, nil
+	}
+
+	return result, errors.New("Unknown speech result format")
+}
+
+//jsonString returns the first of keys that is a string in fields.
+func jsonString(fields map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := fields[key].(string); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+//decodeDetectedSpeech decodes a DETECTED_SPEECH event. Only events of
+//Speech-Type "detected-speech" carry a result; others, such as
+//"begin-speaking", are returned with just the type.
+func decodeDetectedSpeech(event Event) (SpeechResult, error) {
+	speechType := event["Speech-Type"]
+	if speechType != "detected-speech" {
+		return SpeechResult{Type: speechType}, nil
+	}
+
+	result, err := ParseSpeechResult(event.Body())
+	result.Type = speechType
+	return result, err
+}
+
+//Speech returns a channel of the results of background speech detection
+//started with DetectSpeech. The client must be subscribed to
+//DETECTED_SPEECH. Results are discarded if the channel is full.
+func (session *Session) Speech() <-chan SpeechResult {
+	return session.speechCh
+}
+
+//DetectSpeech starts speech detection in the background using a speech
+//engine (e.g. "unimrcp" or "pocketsphinx") and a grammar, loaded from
+//grammarPath if not already loaded. Results are delivered on Speech until
+//StopDetectSpeech is called.
+func (session *Session) DetectSpeech(engine string, grammar string, grammarPath string) error {
+	_, err := session.Execute("detect_speech", engine+" "+grammar+" "+grammarPath)
+	return err
+}
+
+//StopDetectSpeech stops background speech detection.
+func (session *Session) StopDetectSpeech() error {
+	_, err := session.Execute("detect_speech", "stop")
+	return err
+}
+
+//PlayAndDetectSpeech plays prompt while listening for speech matching
+//grammar with a speech engine, and returns the result once speech is
+//detected or the recognizer gives up. An empty result means nothing was
+//recognised.
+func (session *Session) PlayAndDetectSpeech(prompt string, engine string, grammar string) (SpeechResult, error) {
+	event, err := session.Execute("play_and_detect_speech", fmt.Sprintf("%s detect:%s %s", prompt, engine, grammar))
+	if err != nil {
+		return SpeechResult{}, err
+	}
+
+	result, err := ParseSpeechResult(event["variable_detect_speech_result"])
+	result.Type = "detected-speech"
+	return result, err
+}
+
+//deliverSpeech sends a DETECTED_SPEECH event's result to the Speech channel.
+func (session *Session) deliverSpeech(event Event) {
+	result, err := decodeDetectedSpeech(event)
+	if err != nil {
+		log.Print(logPrefix, "Failed to decode speech result on ", session.UUID, ": ", err)
+	}
+
+	select {
+	case session.speechCh <- result:
+	default:
+		log.Print(logPrefix, "Speech channel full, discarded result on ", session.UUID)
+	}
+}
@@ -0,0 +1,124 @@
+package fsclient
+
+import (
+	"errors"
+	"strings"
+)
+
+//SpyMode is how a supervisor joins an agent's call.
+type SpyMode string
+
+const (
+	//SpyListen only lets the supervisor hear the call.
+	SpyListen SpyMode = "listen"
+
+	//SpyWhisper lets the supervisor speak to the agent without the other
+	//party hearing.
+	SpyWhisper SpyMode = "whisper"
+
+	//SpyBarge lets the supervisor speak to both parties.
+	SpyBarge SpyMode = "barge"
+)
+
+//SpyRequest is a request for a supervisor to listen to an agent's calls.
+//Agent is the agent's extension, e.g. "1000" or "1000@example.com", and
+//Supervisor the call to the supervisor; its Extension and App are set by
+//ListenToAgent. With Userspy the supervisor stays connected with mod_spy's
+//userspy application and hears each of the agent's calls as they happen,
+//which needs Agent to include the domain; otherwise the agent's current call
+//is eavesdropped.
+type SpyRequest struct {
+	Agent      string
+	Supervisor OriginateRequest
+	Mode       SpyMode
+	Userspy    bool
+}
+
+//AgentChannel returns the UUID of the active channel of an agent's
+//extension, found in "show channels" by the channel's presence ID or name.
+//An answered channel is preferred to one that is still ringing.
+func AgentChannel(client Commander, agent string) (string, error) {
+	if agent == "" || strings.ContainsAny(agent, " \r\n") {
+		return "", errors.New("Invalid agent extension: " + agent)
+	}
+
+	channels, err := APIJSON[ShowResult[channelRow]](client, "show channels")
+	if err != nil {
+		return "", err
+	}
+
+	ringing := ""
+	for _, row := range channels.Rows {
+		if !row.isUser(agent) {
+			continue
+		}
+		switch row.CallState {
+		case "ACTIVE", "HELD":
+			return row.UUID, nil
+		case "RINGING", "EARLY", "RING_WAIT":
+			if ringing == "" {
+				ringing = row.UUID
+			}
+		}
+	}
+	if ringing != "" {
+		return ringing, nil
+	}
+	return "", errors.New("Agent has no active call: " + agent)
+}
+
+//isUser returns true if the channel belongs to the extension user, given
+//with or without its domain.
+func (row channelRow) isUser(user string) bool {
+	presence := row.PresenceID
+	if !strings.Contains(user, "@") {
+		presence, _, _ = strings.Cut(presence, "@")
+	}
+	if presence == user {
+		return true
+	}
+
+	//Registered endpoints are named e.g. "sofia/internal/1000@10.0.0.1".
+	return strings.Contains(row.Name, "/"+user+"@") || strings.HasSuffix(row.Name, "/"+user)
+}
+
+//ListenToAgent calls the supervisor and connects them to the agent's call, or
+//with Userspy to each of the agent's calls, returning the supervisor's
+//channel UUID once they answer. DTMF on the supervisor's phone switches mode
+//as usual for eavesdrop: 1 and 2 whisper to each party, 3 barges and 0
+//returns to listening.
+func ListenToAgent(client Commander, req SpyRequest) (string, error) {
+	supervisor := req.Supervisor
+	supervisor.Extension = ""
+
+	if req.Userspy {
+		if !strings.Contains(req.Agent, "@") {
+			return "", errors.New("Userspy needs the agent's domain: " + req.Agent)
+		}
+		supervisor.App, supervisor.AppArgs = "userspy", req.Agent
+	} else {
+		uuid, err := AgentChannel(client, req.Agent)
+		if err != nil {
+			return "", err
+		}
+		supervisor.App, supervisor.AppArgs = "eavesdrop", uuid
+	}
+
+	vars := make(map[string]string, len(supervisor.Variables)+2)
+	for key, value := range supervisor.Variables {
+		vars[key] = value
+	}
+	switch req.Mode {
+	case SpyWhisper:
+		vars["eavesdrop_whisper_aleg"] = "true"
+	case SpyBarge:
+		vars["eavesdrop_whisper_aleg"] = "true"
+		vars["eavesdrop_whisper_bleg"] = "true"
+	case SpyListen, "":
+	default:
+		return "", errors.New("Invalid spy mode: " + string(req.Mode))
+	}
+	supervisor.Variables = vars
+
+	return supervisor.Originate(client)
+}
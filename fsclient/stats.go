@@ -0,0 +1,155 @@
+package fsclient
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+//latencyWindow is the number of recent latencies kept for each command to
+//calculate its percentiles.
+const latencyWindow = 1024
+
+//maxStatsCommands is the number of command names latencies are kept for
+//separately. Commands beyond it are counted together under OtherCommands, so
+//that api commands built from arbitrary input can't grow the stats without
+//limit.
+const maxStatsCommands = 256
+
+//OtherCommands is the name CommandStats uses for commands beyond the number
+//tracked separately.
+const OtherCommands = "other"
+
+//CommandStats are the latency statistics of a command, e.g. the api command
+//"status" or the application "playback" run with execute. Count and Errors
+//are totals since the client was created or ResetStats was called, the
+//percentiles and Max are over the most recent latencyWindow commands. Latency
+//is measured from when the command was called, so it includes time spent
+//waiting for the connection, and for bgapi commands it is the time to get
+//the job UUID rather than the job's result.
+type CommandStats struct {
+	Class  CommandClass
+	Name   string
+	Count  uint64
+	Errors uint64
+	P50    time.Duration
+	P90    time.Duration
+	P99    time.Duration
+	Max    time.Duration
+}
+
+//statsKey identifies a command in commandStats.
+type statsKey struct {
+	class CommandClass
+	name  string
+}
+
+//latencyRing holds a command's recent latencies and its totals.
+type latencyRing struct {
+	samples []time.Duration
+	next    int
+	count   uint64
+	errors  uint64
+}
+
+//commandStats records the latency of each command the client sends.
+type commandStats struct {
+	commands map[statsKey]*latencyRing
+	mu       *sync.Mutex
+}
+
+//newCommandStats returns empty command latency statistics.
+func newCommandStats() *commandStats {
+	return &commandStats{
+		commands: make(map[statsKey]*latencyRing),
+		mu:       &sync.Mutex{},
+	}
+}
+
+//record adds the latency of a completed command.
+func (stats *commandStats) record(cmd Command, latency time.Duration, err error) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	key := statsKey{class: cmd.Class, name: cmd.Name}
+	ring, ok := stats.commands[key]
+	if !ok {
+		if len(stats.commands) >= maxStatsCommands {
+			key.name = OtherCommands
+			ring = stats.commands[key]
+		}
+		if ring == nil {
+			ring = &latencyRing{samples: make([]time.Duration, 0, 16)}
+			stats.commands[key] = ring
+		}
+	}
+
+	if len(ring.samples) < latencyWindow {
+		ring.samples = append(ring.samples, latency)
+	} else {
+		ring.samples[ring.next] = latency
+		ring.next = (ring.next + 1) % latencyWindow
+	}
+	ring.count++
+	if err != nil {
+		ring.errors++
+	}
+}
+
+//Stats returns the latency statistics of each command the client has sent,
+//sorted by class and name, so operators can see Freeswitch slowing down
+//before commands start failing.
+func (client *Client) Stats() []CommandStats {
+	stats := client.stats
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	snapshot := make([]CommandStats, 0, len(stats.commands))
+	for key, ring := range stats.commands {
+		samples := append([]time.Duration(nil), ring.samples...)
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+		snapshot = append(snapshot, CommandStats{
+			Class:  key.class,
+			Name:   key.name,
+			Count:  ring.count,
+			Errors: ring.errors,
+			P50:    percentile(samples, 0.5),
+			P90:    percentile(samples, 0.9),
+			P99:    percentile(samples, 0.99),
+			Max:    percentile(samples, 1),
+		})
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Class != snapshot[j].Class {
+			return snapshot[i].Class < snapshot[j].Class
+		}
+		return snapshot[i].Name < snapshot[j].Name
+	})
+	return snapshot
+}
+
+//ResetStats discards the command latency statistics.
+func (client *Client) ResetStats() {
+	stats := client.stats
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.commands = make(map[statsKey]*latencyRing)
+}
+
+//percentile returns the p'th percentile of sorted latencies using the nearest
+//rank method, or zero if there are none.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p*float64(len(sorted))+0.999999) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,108 @@
+package fsclient
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Status is the result of the status api command, the usual health check for
+//a Freeswitch server. Ready is true once Freeswitch has finished starting.
+//SessionsPerSecLimit is the configured sessions-per-second limit, and
+//StackSize and MaxStackSize are in bytes.
+type Status struct {
+	Ready                 bool
+	Version               string
+	Uptime                time.Duration
+	SessionsSinceStartup  int64
+	SessionCount          int64
+	SessionPeakMax        int64
+	SessionPeakFiveMin    int64
+	SessionsPerSec        float64
+	SessionsPerSecLimit   float64
+	SessionsPerSecMax     float64
+	SessionsPerSecFiveMin float64
+	MaxSessions           int64
+	MinIdleCPU            float64
+	IdleCPU               float64
+	StackSize             int64
+	MaxStackSize          int64
+}
+
+//Patterns for the lines of the status output.
+var (
+	statusUptime    = regexp.MustCompile(`(\d+) (year|day|hour|minute|second|millisecond|microsecond)`)
+	statusVersion   = regexp.MustCompile(`\(Version ([^)]*)\)`)
+	statusSince     = regexp.MustCompile(`^(\d+) session\(s\) since startup`)
+	statusSessions  = regexp.MustCompile(`^(\d+) session\(s\) - peak (\d+), last 5min (\d+)`)
+	statusPerSec    = regexp.MustCompile(`^([\d.]+) session\(s\) per Sec out of max ([\d.]+), peak ([\d.]+), last 5min ([\d.]+)`)
+	statusMax       = regexp.MustCompile(`^(\d+) session\(s\) max`)
+	statusCPU       = regexp.MustCompile(`^min idle cpu ([\d.]+)/([\d.]+)`)
+	statusStack     = regexp.MustCompile(`^Current Stack Size/Max (\d+)K/(\d+)K`)
+	uptimeUnitSizes = map[string]time.Duration{
+		"year":        365 * 24 * time.Hour,
+		"day":         24 * time.Hour,
+		"hour":        time.Hour,
+		"minute":      time.Minute,
+		"second":      time.Second,
+		"millisecond": time.Millisecond,
+		"microsecond": time.Microsecond,
+	}
+)
+
+//Status runs the status api command and parses the result.
+func (client *Client) Status() (Status, error) {
+	res, err := client.API("status")
+	if err != nil {
+		return Status{}, err
+	}
+	return ParseStatus(res)
+}
+
+//ParseStatus parses the output of the status api command. Lines that aren't
+//recognised, such as ones added by newer Freeswitch versions, are skipped.
+func ParseStatus(res string) (Status, error) {
+	res = strings.TrimSpace(res)
+	if !strings.HasPrefix(res, "UP ") {
+		line, _, _ := strings.Cut(res, "\n")
+		return Status{}, errors.New("Unexpected status reply: " + line)
+	}
+
+	var status Status
+	for _, line := range strings.Split(res, "\n") {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "UP ") {
+			for _, match := range statusUptime.FindAllStringSubmatch(line, -1) {
+				n, _ := strconv.ParseInt(match[1], 10, 64)
+				status.Uptime += time.Duration(n) * uptimeUnitSizes[match[2]]
+			}
+		} else if match := statusVersion.FindStringSubmatch(line); match != nil {
+			status.Version = match[1]
+			status.Ready = strings.HasSuffix(line, "is ready")
+		} else if match := statusSince.FindStringSubmatch(line); match != nil {
+			status.SessionsSinceStartup, _ = strconv.ParseInt(match[1], 10, 64)
+		} else if match := statusSessions.FindStringSubmatch(line); match != nil {
+			status.SessionCount, _ = strconv.ParseInt(match[1], 10, 64)
+			status.SessionPeakMax, _ = strconv.ParseInt(match[2], 10, 64)
+			status.SessionPeakFiveMin, _ = strconv.ParseInt(match[3], 10, 64)
+		} else if match := statusPerSec.FindStringSubmatch(line); match != nil {
+			status.SessionsPerSec, _ = strconv.ParseFloat(match[1], 64)
+			status.SessionsPerSecLimit, _ = strconv.ParseFloat(match[2], 64)
+			status.SessionsPerSecMax, _ = strconv.ParseFloat(match[3], 64)
+			status.SessionsPerSecFiveMin, _ = strconv.ParseFloat(match[4], 64)
+		} else if match := statusMax.FindStringSubmatch(line); match != nil {
+			status.MaxSessions, _ = strconv.ParseInt(match[1], 10, 64)
+		} else if match := statusCPU.FindStringSubmatch(line); match != nil {
+			status.MinIdleCPU, _ = strconv.ParseFloat(match[1], 64)
+			status.IdleCPU, _ = strconv.ParseFloat(match[2], 64)
+		} else if match := statusStack.FindStringSubmatch(line); match != nil {
+			size, _ := strconv.ParseInt(match[1], 10, 64)
+			max, _ := strconv.ParseInt(match[2], 10, 64)
+			status.StackSize, status.MaxStackSize = size*1024, max*1024
+		}
+	}
+	return status, nil
+}
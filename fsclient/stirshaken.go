@@ -0,0 +1,153 @@
+package fsclient
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+//Attestation is the STIR/SHAKEN attestation level an originating carrier
+//gives the caller ID of a call.
+type Attestation string
+
+//STIR/SHAKEN attestation levels.
+const (
+	AttestationFull    Attestation = "A" //The carrier knows the caller and that they may use the number.
+	AttestationPartial Attestation = "B" //The carrier knows the caller but not that they may use the number.
+	AttestationGateway Attestation = "C" //The carrier only knows where the call entered its network.
+)
+
+//Verstat is the result of a terminating carrier verifying a call's caller ID,
+//passed on in the verstat parameter of the From or P-Asserted-Identity header.
+type Verstat string
+
+//Verstat values.
+const (
+	VerstatPassed       Verstat = "TN-Validation-Passed"
+	VerstatFailed       Verstat = "TN-Validation-Failed"
+	VerstatNoValidation Verstat = "No-TN-Validation"
+)
+
+//Channel variables holding the caller ID verification of an inbound call.
+const (
+	VerstatVar         = "sip_verstat"
+	VerstatDetailedVar = "sip_verstat_detailed"
+)
+
+//IdentityHeader is the SIP header carrying a signed STIR/SHAKEN PASSporT.
+const IdentityHeader = "Identity"
+
+//Identity is a STIR/SHAKEN Identity header. Its PASSporT claims are decoded
+//but the signature isn't verified, which is left to the carrier or a
+//verification service.
+type Identity struct {
+	Header      string
+	Attestation Attestation
+	OrigTN      string
+	DestTNs     []string
+	OrigID      string
+	IssuedAt    time.Time
+	Info        string //The URL of the signing certificate.
+	Alg         string
+	PPT         string
+}
+
+//passport is the payload of a SHAKEN PASSporT.
+type passport struct {
+	Attest string `json:"attest"`
+	Dest   struct {
+		TN []string `json:"tn"`
+	} `json:"dest"`
+	IAT  int64 `json:"iat"`
+	Orig struct {
+		TN string `json:"tn"`
+	} `json:"orig"`
+	OrigID string `json:"origid"`
+}
+
+//ParseIdentity parses an Identity header, e.g.
+//"eyJhbGciOi...;info=<https://cert.example.com/cert.pem>;alg=ES256;ppt=shaken".
+func ParseIdentity(header string) (Identity, error) {
+	identity := Identity{Header: header}
+
+	parts := strings.Split(strings.TrimSpace(header), ";")
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		value = strings.Trim(value, "<>\"")
+		switch strings.ToLower(key) {
+		case "info":
+			identity.Info = value
+		case "alg":
+			identity.Alg = value
+		case "ppt":
+			identity.PPT = value
+		}
+	}
+
+	token := strings.Split(parts[0], ".")
+	if len(token) != 3 {
+		return identity, errors.New("Invalid Identity header: not a PASSporT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(token[1], "="))
+	if err != nil {
+		return identity, errors.New("Invalid Identity header: " + err.Error())
+	}
+	claims := passport{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return identity, errors.New("Invalid Identity header: " + err.Error())
+	}
+
+	identity.Attestation = Attestation(claims.Attest)
+	identity.OrigTN = claims.Orig.TN
+	identity.DestTNs = claims.Dest.TN
+	identity.OrigID = claims.OrigID
+	if claims.IAT > 0 {
+		identity.IssuedAt = time.Unix(claims.IAT, 0)
+	}
+	return identity, nil
+}
+
+//CallerVerification is the STIR/SHAKEN information of an inbound call.
+//Identity is only set if the call had an Identity header that could be
+//parsed. VerstatDetailed is Freeswitch's fuller description of Verstat, when
+//it sets one.
+type CallerVerification struct {
+	Verstat         Verstat
+	VerstatDetailed string
+	Identity        *Identity
+}
+
+//Verified returns true if the terminating carrier verified the caller ID.
+func (verification CallerVerification) Verified() bool {
+	return verification.Verstat == VerstatPassed
+}
+
+//CallerVerificationFromEvent reads the STIR/SHAKEN information of a channel
+//from an event's channel variables.
+func CallerVerificationFromEvent(event Event) CallerVerification {
+	verification := CallerVerification{
+		Verstat:         Verstat(event["variable_"+VerstatVar]),
+		VerstatDetailed: event["variable_"+VerstatDetailedVar],
+	}
+
+	header := event["variable_"+SIPHeaderPrefix+IdentityHeader]
+	if header == "" {
+		header = event["variable_sip_identity"]
+	}
+	if header != "" {
+		if identity, err := ParseIdentity(header); err == nil {
+			verification.Identity = &identity
+		}
+	}
+	return verification
+}
+
+//CallerVerification returns the STIR/SHAKEN information from the call's most
+//recent event.
+func (call *Call) CallerVerification() CallerVerification {
+	call.mu.RLock()
+	defer call.mu.RUnlock()
+	return CallerVerificationFromEvent(call.event)
+}
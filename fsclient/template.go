@@ -0,0 +1,258 @@
+package fsclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//Patterns for common CommandTemplate parameters.
+const (
+	ParamUUID   = `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`
+	ParamWord   = `[A-Za-z0-9_.@:/+-]+`
+	ParamNumber = `[0-9]+`
+)
+
+//templateParam matches a {name} placeholder in a CommandTemplate's text.
+var templateParam = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+//CommandTemplate is an approved api command pattern, such as
+//"uuid_transfer {uuid} {extension} XML default", that applications run by
+//name with arguments for its {name} placeholders. Params holds a regular
+//expression each argument must match in full, e.g. ParamUUID for a channel,
+//so that arguments can't add to the command. Background templates are sent
+//with bgapi.
+type CommandTemplate struct {
+	Name        string            `json:"name"`
+	Text        string            `json:"text"`
+	Params      map[string]string `json:"params"`
+	Background  bool              `json:"background,omitempty"`
+	Description string            `json:"description,omitempty"`
+}
+
+//compiledTemplate is a defined template with its parameter patterns and a
+//pattern matching the whole command.
+type compiledTemplate struct {
+	template CommandTemplate
+	params   map[string]*regexp.Regexp
+	command  *regexp.Regexp
+}
+
+//CommandTemplates is a set of named command templates, so that the commands
+//an application can send are defined in one place that can be reviewed, and
+//can be enforced with Authorizer.
+type CommandTemplates struct {
+	templates map[string]*compiledTemplate
+	executes  map[string]bool
+	mu        *sync.RWMutex
+}
+
+//NewCommandTemplates returns an empty set of command templates.
+func NewCommandTemplates() *CommandTemplates {
+	return &CommandTemplates{
+		templates: make(map[string]*compiledTemplate),
+		executes:  make(map[string]bool),
+		mu:        &sync.RWMutex{},
+	}
+}
+
+//LoadCommandTemplates reads a JSON array of command templates, such as a file
+//maintained by an operations team, and defines each of them.
+func LoadCommandTemplates(r io.Reader) (*CommandTemplates, error) {
+	var list []CommandTemplate
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	templates := NewCommandTemplates()
+	for _, template := range list {
+		if err := templates.Define(template); err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+//Define adds a template, replacing any with the same name. Every placeholder
+//in the text must have a parameter pattern and every pattern a placeholder.
+//Runs of spaces in the text are collapsed to one, as they are in the commands
+//the client sends.
+func (templates *CommandTemplates) Define(template CommandTemplate) error {
+	if template.Name == "" {
+		return errors.New("Command template has no name")
+	}
+	if strings.ContainsAny(template.Text, "\r\n") {
+		return errors.New("Command template " + template.Name + " spans several lines")
+	}
+	fields := strings.Fields(template.Text)
+	if len(fields) == 0 || strings.Contains(fields[0], "{") {
+		return errors.New("Command template " + template.Name + " has no command")
+	}
+	template.Text = strings.Join(fields, " ")
+
+	compiled := &compiledTemplate{template: template, params: make(map[string]*regexp.Regexp)}
+	for name, pattern := range template.Params {
+		re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+		if err != nil {
+			return errors.New("Command template " + template.Name + " parameter " + name + ": " + err.Error())
+		}
+		compiled.params[name] = re
+	}
+
+	//Build a pattern for the whole command from the literal text and each
+	//parameter's pattern, for checking commands that were sent directly.
+	var command strings.Builder
+	command.WriteString("^")
+	used := make(map[string]bool)
+	last := 0
+	for _, match := range templateParam.FindAllStringSubmatchIndex(template.Text, -1) {
+		name := template.Text[match[2]:match[3]]
+		pattern, ok := template.Params[name]
+		if !ok {
+			return errors.New("Command template " + template.Name + " has no pattern for parameter " + name)
+		}
+		used[name] = true
+		command.WriteString(regexp.QuoteMeta(template.Text[last:match[0]]))
+		command.WriteString("(?:" + pattern + ")")
+		last = match[1]
+	}
+	command.WriteString(regexp.QuoteMeta(template.Text[last:]) + "$")
+
+	for name := range template.Params {
+		if !used[name] {
+			return errors.New("Command template " + template.Name + " doesn't use parameter " + name)
+		}
+	}
+	compiled.command = regexp.MustCompile(command.String())
+
+	templates.mu.Lock()
+	defer templates.mu.Unlock()
+	templates.templates[template.Name] = compiled
+	return nil
+}
+
+//Templates returns the defined templates, sorted by name, e.g. for review.
+func (templates *CommandTemplates) Templates() []CommandTemplate {
+	templates.mu.RLock()
+	defer templates.mu.RUnlock()
+
+	list := make([]CommandTemplate, 0, len(templates.templates))
+	for _, compiled := range templates.templates {
+		list = append(list, compiled.template)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+//Render returns the command for the template name with its placeholders
+//replaced by args, checking each argument against its parameter's pattern.
+func (templates *CommandTemplates) Render(name string, args map[string]string) (Command, error) {
+	templates.mu.RLock()
+	compiled, ok := templates.templates[name]
+	templates.mu.RUnlock()
+	if !ok {
+		return Command{}, errors.New("Unknown command template: " + name)
+	}
+
+	for param, re := range compiled.params {
+		value, ok := args[param]
+		if !ok {
+			return Command{}, errors.New("Command template " + name + " is missing parameter " + param)
+		}
+		if !re.MatchString(value) {
+			return Command{}, errors.New("Command template " + name + " parameter " + param + " is invalid: " + value)
+		}
+	}
+	for param := range args {
+		if _, ok := compiled.params[param]; !ok {
+			return Command{}, errors.New("Command template " + name + " has no parameter " + param)
+		}
+	}
+
+	text := templateParam.ReplaceAllStringFunc(compiled.template.Text, func(placeholder string) string {
+		return args[placeholder[1:len(placeholder)-1]]
+	})
+
+	class := ClassAPI
+	if compiled.template.Background {
+		class = ClassBGAPI
+	}
+	return parseCommand(class, text), nil
+}
+
+//Run renders the template name with args and sends it with client, returning
+//the api result, or the Job-UUID of a background template.
+func (templates *CommandTemplates) Run(ctx context.Context, client Commander, name string, args map[string]string) (string, error) {
+	command, err := templates.Render(name, args)
+	if err != nil {
+		return "", err
+	}
+	if command.Class == ClassBGAPI {
+		return client.BackgroundAPIContext(ctx, command.String())
+	}
+	return client.APIContext(ctx, command.String())
+}
+
+//AllowExecute allows the Authorizer to pass executes of the dialplan
+//applications apps, with any arguments. No executes are allowed by default,
+//as applications such as system can run anything.
+func (templates *CommandTemplates) AllowExecute(apps ...string) {
+	templates.mu.Lock()
+	defer templates.mu.Unlock()
+	for _, app := range apps {
+		templates.executes[app] = true
+	}
+}
+
+//Authorizer returns an Authorizer that only allows api and bgapi commands
+//matching one of the templates, for use with Client.SetAuthorizer, so that
+//only approved commands can be sent however they were built. Raw api and
+//bgapi commands are checked like the others and other raw commands are
+//denied. Executes are denied unless allowed with AllowExecute, and events
+//sent with SendEvent are allowed.
+func (templates *CommandTemplates) Authorizer() Authorizer {
+	return func(ctx context.Context, cmd Command) (Command, error) {
+		switch cmd.Class {
+		case ClassAPI, ClassBGAPI:
+			if templates.approved(cmd.Class == ClassBGAPI, cmd.String()) {
+				return cmd, nil
+			}
+		case ClassRaw:
+			//A raw command over more than one line could have headers that
+			//change its meaning, so only single line ones are checked.
+			background := cmd.Name == "bgapi"
+			if (cmd.Name == "api" || background) && !strings.ContainsAny(cmd.Args, "\r\n") && templates.approved(background, cmd.Args) {
+				return cmd, nil
+			}
+		case ClassExecute:
+			templates.mu.RLock()
+			allowed := templates.executes[cmd.Name]
+			templates.mu.RUnlock()
+			if allowed {
+				return cmd, nil
+			}
+			return Command{}, errors.New("Execute of " + cmd.Name + " isn't allowed")
+		default:
+			return cmd, nil
+		}
+		return Command{}, errors.New("Command doesn't match an approved template: " + cmd.Name)
+	}
+}
+
+//approved returns true if an api command, or a bgapi one if background is
+//true, matches one of the templates.
+func (templates *CommandTemplates) approved(background bool, text string) bool {
+	templates.mu.RLock()
+	defer templates.mu.RUnlock()
+	for _, compiled := range templates.templates {
+		if compiled.template.Background == background && compiled.command.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
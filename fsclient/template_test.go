@@ -0,0 +1,148 @@
+package fsclient_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+)
+
+//testTemplates is a JSON list of templates, with spacing that the client
+//normalises when it sends them.
+const testTemplates = `[
+	{"name": "transfer", "text": "uuid_transfer  {uuid} {extension} XML default ", "params": {"uuid": "` + fsclient.ParamUUID + `", "extension": "` + fsclient.ParamNumber + `"}},
+	{"name": "kill", "text": "uuid_kill {uuid}", "params": {"uuid": "` + fsclient.ParamUUID + `"}},
+	{"name": "reload", "text": "reloadxml", "background": true}
+]`
+
+//TestCommandTemplatesAuthorize renders templates and checks that the results,
+//and commands sent directly, are authorized only if they match a template.
+func TestCommandTemplatesAuthorize(t *testing.T) {
+	templates, err := fsclient.LoadCommandTemplates(strings.NewReader(testTemplates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates.AllowExecute("playback")
+	authorize := templates.Authorizer()
+
+	tests := []struct {
+		name    string
+		cmd     func() (fsclient.Command, error)
+		wantCmd string
+		wantErr bool
+	}{
+		{"rendered", func() (fsclient.Command, error) {
+			return templates.Render("transfer", map[string]string{"uuid": callerUUID, "extension": "1000"})
+		}, "uuid_transfer " + callerUUID + " 1000 XML default", false},
+		{"rendered background", func() (fsclient.Command, error) {
+			return templates.Render("reload", nil)
+		}, "reloadxml", false},
+		{"direct", func() (fsclient.Command, error) {
+			return fsclient.Command{Class: fsclient.ClassAPI, Name: "uuid_kill", Args: callerUUID}, nil
+		}, "uuid_kill " + callerUUID, false},
+		{"extra argument", func() (fsclient.Command, error) {
+			return fsclient.Command{Class: fsclient.ClassAPI, Name: "uuid_kill", Args: callerUUID + " ; system rm"}, nil
+		}, "", true},
+		{"wrong class", func() (fsclient.Command, error) {
+			return fsclient.Command{Class: fsclient.ClassBGAPI, Name: "uuid_kill", Args: callerUUID}, nil
+		}, "", true},
+		{"raw api", func() (fsclient.Command, error) {
+			return fsclient.Command{Class: fsclient.ClassRaw, Name: "api", Args: "uuid_kill " + callerUUID}, nil
+		}, "api uuid_kill " + callerUUID, false},
+		{"raw other", func() (fsclient.Command, error) {
+			return fsclient.Command{Class: fsclient.ClassRaw, Name: "event", Args: "plain ALL"}, nil
+		}, "", true},
+		{"allowed execute", func() (fsclient.Command, error) {
+			return fsclient.Command{Class: fsclient.ClassExecute, Name: "playback", Args: "hello.wav", UUID: callerUUID}, nil
+		}, "playback hello.wav", false},
+		{"execute", func() (fsclient.Command, error) {
+			return fsclient.Command{Class: fsclient.ClassExecute, Name: "system", Args: "rm -rf /", UUID: callerUUID}, nil
+		}, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, err := test.cmd()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmd, err = authorize(context.Background(), cmd)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Got error %v, want error %v", err, test.wantErr)
+			}
+			if cmd.String() != test.wantCmd {
+				t.Errorf("Got command %q, want %q", cmd.String(), test.wantCmd)
+			}
+		})
+	}
+}
+
+//TestCommandTemplatesRender checks that arguments are validated against
+//their parameters' patterns.
+func TestCommandTemplatesRender(t *testing.T) {
+	templates, err := fsclient.LoadCommandTemplates(strings.NewReader(testTemplates))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		args     map[string]string
+		wantErr  bool
+	}{
+		{"valid", "kill", map[string]string{"uuid": callerUUID}, false},
+		{"unknown template", "hupall", nil, true},
+		{"missing argument", "kill", nil, true},
+		{"invalid argument", "kill", map[string]string{"uuid": callerUUID + " " + agentUUID}, true},
+		{"unknown argument", "kill", map[string]string{"uuid": callerUUID, "cause": "NORMAL_CLEARING"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fsclienttest.NewClient()
+			client.Reply(fsclient.ClassAPI, "uuid_kill", "+OK")
+
+			res, err := templates.Run(context.Background(), client, test.template, test.args)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Got error %v, want error %v", err, test.wantErr)
+			}
+			cmds := sentCommands(client)
+			if test.wantErr {
+				if len(cmds) != 0 {
+					t.Errorf("Sent %q for an invalid command", cmds)
+				}
+				return
+			}
+			if res != "+OK" || len(cmds) != 1 || cmds[0] != "uuid_kill "+callerUUID {
+				t.Errorf("Got %q and sent %q", res, cmds)
+			}
+		})
+	}
+}
+
+//TestCommandTemplatesDefine checks that invalid templates are rejected.
+func TestCommandTemplatesDefine(t *testing.T) {
+	tests := []struct {
+		name     string
+		template fsclient.CommandTemplate
+	}{
+		{"no name", fsclient.CommandTemplate{Text: "status"}},
+		{"no command", fsclient.CommandTemplate{Name: "empty", Text: "  "}},
+		{"placeholder command", fsclient.CommandTemplate{Name: "any", Text: "{cmd}", Params: map[string]string{"cmd": fsclient.ParamWord}}},
+		{"several lines", fsclient.CommandTemplate{Name: "lines", Text: "status\napi system"}},
+		{"missing pattern", fsclient.CommandTemplate{Name: "kill", Text: "uuid_kill {uuid}"}},
+		{"unused pattern", fsclient.CommandTemplate{Name: "status", Text: "status", Params: map[string]string{"uuid": fsclient.ParamUUID}}},
+		{"invalid pattern", fsclient.CommandTemplate{Name: "kill", Text: "uuid_kill {uuid}", Params: map[string]string{"uuid": "("}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := fsclient.NewCommandTemplates().Define(test.template); err == nil {
+				t.Error("Invalid template defined")
+			}
+		})
+	}
+}
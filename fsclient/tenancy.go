@@ -0,0 +1,136 @@
+package fsclient
+
+import (
+	"sort"
+	"sync"
+)
+
+//TenantHeader is the event key added by a TenantTagger to each event,
+//containing the tenant the event belongs to.
+const TenantHeader = "fsclient-tenant"
+
+//TenantResolver returns the tenant an event belongs to, or an empty string if
+//it can't be determined.
+type TenantResolver func(event map[string]string) string
+
+//TenantFromHeaders returns a TenantResolver that uses the first of headers
+//that is set on an event, e.g. "variable_fsclient_tenant_id" then
+//"variable_sip_to_host".
+func TenantFromHeaders(headers ...string) TenantResolver {
+	return func(event map[string]string) string {
+		for _, header := range headers {
+			if value := event[header]; value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+}
+
+//TenantTagger reads events from a source, such as a Client's EventCh, tags
+//each with its tenant under TenantHeader and delivers it on EventCh, which is
+//closed when the source is closed. It must be the only reader of the source.
+//
+//Events can then be scoped per tenant with EventHub.SubscribeTenant,
+//TenantHandler or a TenantCallManager.
+type TenantTagger struct {
+	EventCh  chan map[string]string
+	resolver TenantResolver
+}
+
+//NewTenantTagger creates a TenantTagger that buffers up to bufSize events.
+func NewTenantTagger(source <-chan map[string]string, resolver TenantResolver, bufSize int) *TenantTagger {
+	tagger := &TenantTagger{
+		EventCh:  make(chan map[string]string, bufSize),
+		resolver: resolver,
+	}
+
+	go tagger.run(source)
+	return tagger
+}
+
+//run tags events until the source is closed.
+func (tagger *TenantTagger) run(source <-chan map[string]string) {
+	defer close(tagger.EventCh)
+	for event := range source {
+		event[TenantHeader] = tagger.resolver(event)
+		tagger.EventCh <- event
+	}
+}
+
+//Tenant returns the tenant an event was tagged with.
+func (event Event) Tenant() string {
+	return event[TenantHeader]
+}
+
+//TenantHandler returns an EventHandler that passes only events tagged with
+//tenant to handler, e.g. to register a tenant's own CallManager with a
+//Dispatcher.
+func TenantHandler(tenant string, handler EventHandler) EventHandler {
+	return func(event Event) {
+		if event.Tenant() == tenant {
+			handler(event)
+		}
+	}
+}
+
+//TenantCallManager keeps a separate CallManager for each tenant, created
+//when the tenant's first event is seen. Register its HandleEvent method with
+//a Dispatcher reading tagged events.
+type TenantCallManager struct {
+	managers map[string]*CallManager
+	setup    func(tenant string, manager *CallManager)
+	mu       *sync.RWMutex
+}
+
+//NewTenantCallManager creates a TenantCallManager. setup, if not nil, is
+//called with each new tenant's CallManager before it handles any events, for
+//example to register its callbacks.
+func NewTenantCallManager(setup func(tenant string, manager *CallManager)) *TenantCallManager {
+	return &TenantCallManager{
+		managers: make(map[string]*CallManager),
+		setup:    setup,
+		mu:       &sync.RWMutex{},
+	}
+}
+
+//Manager returns the CallManager for tenant, creating it if required.
+func (tenants *TenantCallManager) Manager(tenant string) *CallManager {
+	tenants.mu.RLock()
+	manager, ok := tenants.managers[tenant]
+	tenants.mu.RUnlock()
+	if ok {
+		return manager
+	}
+
+	tenants.mu.Lock()
+	defer tenants.mu.Unlock()
+
+	if manager, ok := tenants.managers[tenant]; ok {
+		return manager
+	}
+	manager = NewCallManager()
+	if tenants.setup != nil {
+		tenants.setup(tenant, manager)
+	}
+	tenants.managers[tenant] = manager
+	return manager
+}
+
+//Tenants returns the tenants that have been seen, sorted by name.
+func (tenants *TenantCallManager) Tenants() []string {
+	tenants.mu.RLock()
+	defer tenants.mu.RUnlock()
+
+	names := make([]string, 0, len(tenants.managers))
+	for name := range tenants.managers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//HandleEvent passes an event to its tenant's CallManager.
+func (tenants *TenantCallManager) HandleEvent(event Event) {
+	tenants.Manager(event.Tenant()).HandleEvent(event)
+}
@@ -0,0 +1,169 @@
+package fsclient
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//timelineNoise are events left out of a timeline as they repeat what other
+//events already say.
+var timelineNoise = map[string]bool{
+	"CHANNEL_STATE":     true,
+	"CHANNEL_CALLSTATE": true,
+	"CHANNEL_DESTROY":   true,
+	"CHANNEL_OUTGOING":  true,
+	"CALL_UPDATE":       true,
+	"PRESENCE_IN":       true,
+	"HEARTBEAT":         true,
+}
+
+//TimelineEntry is a step in a call's Timeline. Offset is the time since the
+//timeline's first entry.
+type TimelineEntry struct {
+	Time        time.Time
+	Offset      time.Duration
+	Name        string
+	Description string
+	Event       Event
+}
+
+//Timeline is what happened to a call, in order.
+type Timeline []TimelineEntry
+
+//CallTimeline returns the timeline of the call uuid from the events in store.
+func CallTimeline(store EventStore, uuid string) (Timeline, error) {
+	events, err := store.QueryByUUID(uuid)
+	if err != nil {
+		return nil, err
+	}
+	return BuildTimeline(events), nil
+}
+
+//BuildTimeline builds a timeline from a call's events, e.g. collected as
+//they were dispatched, ordering them by their Event-Date-Timestamp and
+//Event-Sequence headers.
+func BuildTimeline(events []Event) Timeline {
+	sorted := make([]Event, 0, len(events))
+	for _, event := range events {
+		if !timelineNoise[event.Name()] {
+			sorted = append(sorted, event)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Time(), sorted[j].Time()
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return int64Header(sorted[i], "Event-Sequence") < int64Header(sorted[j], "Event-Sequence")
+	})
+
+	timeline := make(Timeline, 0, len(sorted))
+	for _, event := range sorted {
+		entry := TimelineEntry{
+			Time:        event.Time(),
+			Name:        event.Name(),
+			Description: describeEvent(event),
+			Event:       event,
+		}
+		if len(timeline) > 0 && !entry.Time.IsZero() && !timeline[0].Time.IsZero() {
+			entry.Offset = entry.Time.Sub(timeline[0].Time)
+		}
+		timeline = append(timeline, entry)
+	}
+	return timeline
+}
+
+//String returns the timeline with one entry per line, e.g.
+//"+00:05.120 Answered".
+func (timeline Timeline) String() string {
+	var b strings.Builder
+	for _, entry := range timeline {
+		ms := entry.Offset.Milliseconds()
+		fmt.Fprintf(&b, "+%02d:%02d.%03d %s\n", ms/60000, ms/1000%60, ms%1000, entry.Description)
+	}
+	return b.String()
+}
+
+//describeEvent returns a human readable description of an event in a call.
+func describeEvent(event Event) string {
+	switch event.Name() {
+	case "CHANNEL_CREATE":
+		return fmt.Sprintf("Created %s call from %s to %s on %s", event["Call-Direction"],
+			callerID(event), event["Caller-Destination-Number"], event["Channel-Name"])
+	case "CHANNEL_PROGRESS":
+		return "Ringing"
+	case "CHANNEL_PROGRESS_MEDIA":
+		return "Early media"
+	case "CHANNEL_ANSWER":
+		return "Answered"
+	case "CHANNEL_EXECUTE":
+		return "Started " + application(event)
+	case "CHANNEL_EXECUTE_COMPLETE":
+		description := "Finished " + application(event)
+		if response := event["Application-Response"]; response != "" && response != "_none_" {
+			description += ": " + response
+		}
+		return description
+	case "DTMF":
+		return "DTMF " + event["DTMF-Digit"]
+	case "CHANNEL_BRIDGE":
+		return "Bridged to " + bridgedTo(event)
+	case "CHANNEL_UNBRIDGE":
+		return "Unbridged from " + bridgedTo(event)
+	case "CHANNEL_HOLD":
+		return "Put on hold"
+	case "CHANNEL_UNHOLD":
+		return "Taken off hold"
+	case "CHANNEL_PARK":
+		return "Parked"
+	case "CHANNEL_UNPARK":
+		return "Unparked"
+	case "PLAYBACK_START":
+		return "Started playing " + event["Playback-File-Path"]
+	case "PLAYBACK_STOP":
+		return "Stopped playing " + event["Playback-File-Path"]
+	case "RECORD_START":
+		return "Started recording " + event["Record-File-Path"]
+	case "RECORD_STOP":
+		return "Stopped recording " + event["Record-File-Path"]
+	case "CHANNEL_HANGUP":
+		return "Hanging up: " + event["Hangup-Cause"]
+	case "CHANNEL_HANGUP_COMPLETE":
+		description := "Hung up: " + event["Hangup-Cause"]
+		if billsec := event["variable_billsec"]; billsec != "" {
+			description += " after " + billsec + "s talking"
+		}
+		return description
+	case "BACKGROUND_JOB":
+		if _, reply, ok := originateJob(event); ok {
+			if reply.OK {
+				return "Originated"
+			}
+			return "Originate failed: " + reply.Text
+		}
+		return "Background job " + event["Job-Command"] + " finished"
+	case "CUSTOM":
+		return "Custom event " + event["Event-Subclass"]
+	}
+	return event.Name()
+}
+
+//callerID returns the caller ID name and number of an event.
+func callerID(event Event) string {
+	name, number := event["Caller-Caller-ID-Name"], event["Caller-Caller-ID-Number"]
+	if name == "" || name == number {
+		return number
+	}
+	return strconv.Quote(name) + " <" + number + ">"
+}
+
+//application returns the application and its data of an execute event.
+func application(event Event) string {
+	if data := event["Application-Data"]; data != "" {
+		return event["Application"] + "(" + data + ")"
+	}
+	return event["Application"]
+}
@@ -0,0 +1,105 @@
+package fsclient
+
+import (
+	"strconv"
+	"time"
+)
+
+//localDateLayout is the layout of Event-Date-Local and the *_stamp channel
+//variables, in the server's timezone.
+const localDateLayout = "2006-01-02 15:04:05"
+
+//ParseTimestamp parses a Freeswitch timestamp in microseconds since the Unix
+//epoch, such as Event-Date-Timestamp or Caller-Channel-Answered-Time. It
+//returns the zero time if the value is empty, zero or invalid, as Freeswitch
+//uses zero for times that haven't happened, e.g. a call that wasn't answered.
+func ParseTimestamp(value string) time.Time {
+	usec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || usec == 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(usec)
+}
+
+//Timestamp returns a header holding a timestamp in microseconds since the Unix
+//epoch as a time in the server's timezone, see Location.
+func (event Event) Timestamp(name string) time.Time {
+	return inLocation(ParseTimestamp(event[name]), event.Location())
+}
+
+//Time returns when the event was fired, from its Event-Date-Timestamp header,
+//in the server's timezone.
+func (event Event) Time() time.Time {
+	return event.Timestamp("Event-Date-Timestamp")
+}
+
+//Location returns the server's timezone as a fixed offset from UTC, worked out
+//from the difference between the event's Event-Date-Local and
+//Event-Date-Timestamp headers, as events don't name the timezone. It returns
+//UTC if the event doesn't have both headers.
+func (event Event) Location() *time.Location {
+	local, err := time.Parse(localDateLayout, event["Event-Date-Local"])
+	if err != nil {
+		return time.UTC
+	}
+	fired := ParseTimestamp(event["Event-Date-Timestamp"])
+	if fired.IsZero() {
+		return time.UTC
+	}
+
+	//Round to the nearest quarter hour, as the local date has no fraction
+	//of a second.
+	offset := local.Sub(fired.UTC()).Round(15 * time.Minute)
+	if offset == 0 {
+		return time.UTC
+	}
+	return time.FixedZone("", int(offset.Seconds()))
+}
+
+//inLocation returns t in loc, leaving the zero time as it is.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.In(loc)
+}
+
+//ChannelTimes are the times in a channel's life reported in the
+//Caller-Channel-* headers of its events, in the server's timezone. Times that
+//haven't happened yet are zero. HoldAccum is the total time the channel has
+//spent on hold.
+type ChannelTimes struct {
+	Created        time.Time
+	ProfileCreated time.Time
+	Progress       time.Time
+	ProgressMedia  time.Time
+	Answered       time.Time
+	Bridged        time.Time
+	LastHold       time.Time
+	Hangup         time.Time
+	Resurrect      time.Time
+	Transfer       time.Time
+	HoldAccum      time.Duration
+}
+
+//ParseChannelTimes decodes the channel times of a channel event.
+func ParseChannelTimes(event Event) ChannelTimes {
+	return ChannelTimes{
+		Created:        event.Timestamp("Caller-Channel-Created-Time"),
+		ProfileCreated: event.Timestamp("Caller-Profile-Created-Time"),
+		Progress:       event.Timestamp("Caller-Channel-Progress-Time"),
+		ProgressMedia:  event.Timestamp("Caller-Channel-Progress-Media-Time"),
+		Answered:       event.Timestamp("Caller-Channel-Answered-Time"),
+		Bridged:        event.Timestamp("Caller-Channel-Bridged-Time"),
+		LastHold:       event.Timestamp("Caller-Channel-Last-Hold"),
+		Hangup:         event.Timestamp("Caller-Channel-Hangup-Time"),
+		Resurrect:      event.Timestamp("Caller-Channel-Resurrect-Time"),
+		Transfer:       event.Timestamp("Caller-Channel-Transfer-Time"),
+		HoldAccum:      time.Duration(int64Header(event, "Caller-Channel-Hold-Accum")) * time.Microsecond,
+	}
+}
+
+//Times returns the channel times from the call's most recent event.
+func (call *Call) Times() ChannelTimes {
+	return ParseChannelTimes(call.Event())
+}
@@ -0,0 +1,396 @@
+package fsclient
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+//errTransferFinished is returned when completing or cancelling an attended
+//transfer that has already finished.
+var errTransferFinished = errors.New("Transfer already finished")
+
+//errConsultNotAnswered is returned when completing an attended transfer
+//before the transfer target has answered.
+var errConsultNotAnswered = errors.New("Transfer target hasn't answered")
+
+//TransferOutcome is how an attended transfer finished.
+type TransferOutcome string
+
+//Transfer outcomes.
+const (
+	TransferCompleted TransferOutcome = "completed" //The caller was bridged to the target.
+	TransferCancelled TransferOutcome = "cancelled" //The agent cancelled and was bridged back to the caller.
+	TransferFailed    TransferOutcome = "failed"    //The consult leg failed or hung up and the caller was bridged back.
+	TransferAbandoned TransferOutcome = "abandoned" //The caller hung up during the transfer.
+)
+
+//TransferResult is the result of an attended transfer. Cause is the consult
+//leg's hangup cause, or the originate failure, for a failed transfer. Err is
+//set if a command run to finish the transfer failed, e.g. the caller couldn't
+//be bridged back.
+type TransferResult struct {
+	Outcome     TransferOutcome
+	ConsultUUID string
+	Cause       string
+	Err         error
+}
+
+//TransferRequest is an attended transfer of Caller, bridged to Agent, to
+//Target. The consult leg is originated from Target with its UUID set in
+//origination_uuid, and parked until it answers, so Target's Extension or App
+//is ignored.
+//
+//HoldMusic is played to the caller while it is on hold, "local_stream://moh"
+//if empty. The agent is hung up once the transfer completes unless KeepAgent
+//is true, in which case it is left parked.
+type TransferRequest struct {
+	Caller    string
+	Agent     string
+	Target    OriginateRequest
+	HoldMusic string
+	KeepAgent bool
+}
+
+//AttendedTransfer is an attended transfer in progress. The caller is parked
+//with hold music and a consult leg to the target is originated, which is
+//bridged to the agent when it answers. Complete bridges the caller to the
+//target and Cancel bridges the caller back to the agent. If the consult leg
+//fails, or the target hangs up before the transfer completes, the caller is
+//bridged back to the agent automatically. If the agent hangs up the transfer
+//completes as soon as the target answers.
+//
+//Register the transfer's HandleEvent method with a Dispatcher. The client must
+//be subscribed to CHANNEL_ANSWER, CHANNEL_HANGUP_COMPLETE and BACKGROUND_JOB.
+type AttendedTransfer struct {
+	CallerUUID  string
+	AgentUUID   string
+	ConsultUUID string
+
+	client    Commander
+	holdMusic string
+	originate string
+	keepAgent bool
+	answered  bool
+	agentGone bool
+	finished  bool
+	result    TransferResult
+	done      chan struct{}
+	mu        *sync.Mutex
+}
+
+//NewAttendedTransfer creates an attended transfer, checking the request and
+//choosing the consult leg's UUID. Register its HandleEvent method before
+//calling Start, so that no events for the consult leg are missed.
+func NewAttendedTransfer(client Commander, req TransferRequest) (*AttendedTransfer, error) {
+	if err := ValidateUUID(req.Caller); err != nil {
+		return nil, err
+	}
+	if err := ValidateUUID(req.Agent); err != nil {
+		return nil, err
+	}
+	holdMusic := req.HoldMusic
+	if holdMusic == "" {
+		holdMusic = "local_stream://moh"
+	}
+	if strings.ContainsAny(holdMusic, " \n") {
+		return nil, errors.New("Invalid hold music: " + holdMusic)
+	}
+
+	target := req.Target
+	vars := make(map[string]string, len(target.Variables)+1)
+	for key, value := range target.Variables {
+		vars[key] = value
+	}
+	if vars["origination_uuid"] == "" {
+		vars["origination_uuid"] = NewUUID()
+	}
+	target.Variables = vars
+	target.Extension, target.Dialplan, target.Context = "", "", ""
+	target.App, target.AppArgs = "park", ""
+
+	cmd, err := target.Command()
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &AttendedTransfer{
+		CallerUUID:  req.Caller,
+		AgentUUID:   req.Agent,
+		ConsultUUID: vars["origination_uuid"],
+		client:      client,
+		holdMusic:   holdMusic,
+		originate:   cmd,
+		keepAgent:   req.KeepAgent,
+		done:        make(chan struct{}),
+		mu:          &sync.Mutex{},
+	}
+	return transfer, nil
+}
+
+//Start puts the caller on hold and originates the consult leg. If the caller
+//can't be put on hold, or the originate can't be sent, the caller is bridged
+//back to the agent, the transfer fails and an error is returned.
+func (transfer *AttendedTransfer) Start() error {
+	//Park rather than hang up whichever leg is left unbridged, so either
+	//party can be bridged back.
+	for _, uuid := range []string{transfer.CallerUUID, transfer.AgentUUID} {
+		if err := transfer.command("uuid_setvar " + uuid + " park_after_bridge true"); err != nil {
+			return transfer.startFailed(err)
+		}
+	}
+	if err := transfer.command("uuid_park " + transfer.CallerUUID); err != nil {
+		return transfer.startFailed(err)
+	}
+	if err := transfer.command("uuid_broadcast " + transfer.CallerUUID + " playback::" + transfer.holdMusic + " aleg"); err != nil {
+		return transfer.startFailed(transfer.rollback(err))
+	}
+	if _, err := transfer.client.BackgroundAPI(transfer.originate); err != nil {
+		return transfer.startFailed(transfer.rollback(err))
+	}
+	return nil
+}
+
+//startFailed fails a transfer that couldn't be started with err, unless it
+//has already finished, e.g. because the caller hung up, and returns err.
+func (transfer *AttendedTransfer) startFailed(err error) error {
+	if transfer.claim() {
+		transfer.finish(TransferResult{Outcome: TransferFailed, Err: err})
+	}
+	return err
+}
+
+//Done returns a channel that is closed when the transfer has finished.
+func (transfer *AttendedTransfer) Done() <-chan struct{} {
+	return transfer.done
+}
+
+//Result returns the transfer's result, which is only set once Done is closed.
+func (transfer *AttendedTransfer) Result() TransferResult {
+	transfer.mu.Lock()
+	defer transfer.mu.Unlock()
+	return transfer.result
+}
+
+//Complete bridges the caller to the target, finishing the transfer. It fails
+//if the target hasn't answered yet. If the bridge fails the transfer fails,
+//as events may have been missed while it was being completed, so the consult
+//leg is hung up and the caller bridged back to the agent.
+func (transfer *AttendedTransfer) Complete() error {
+	transfer.mu.Lock()
+	if transfer.finished {
+		transfer.mu.Unlock()
+		return errTransferFinished
+	}
+	if !transfer.answered {
+		transfer.mu.Unlock()
+		return errConsultNotAnswered
+	}
+	transfer.finished = true
+	transfer.mu.Unlock()
+
+	if err := transfer.complete(); err != nil {
+		transfer.abort(err)
+		return err
+	}
+	return nil
+}
+
+//Cancel hangs up the consult leg and bridges the caller back to the agent,
+//finishing the transfer.
+func (transfer *AttendedTransfer) Cancel() error {
+	if !transfer.claim() {
+		return errTransferFinished
+	}
+
+	transfer.command("uuid_kill " + transfer.ConsultUUID + " ORIGINATOR_CANCEL")
+	err := transfer.command("uuid_bridge " + transfer.CallerUUID + " " + transfer.AgentUUID)
+	transfer.finish(TransferResult{Outcome: TransferCancelled, Err: err})
+	return err
+}
+
+//HandleEvent advances the transfer on events for its three legs.
+func (transfer *AttendedTransfer) HandleEvent(event Event) {
+	switch event.Name() {
+	case "BACKGROUND_JOB":
+		uuid, reply, ok := originateJob(event)
+		if ok && uuid == transfer.ConsultUUID && !reply.OK {
+			transfer.consultFailed(reply.Text)
+		}
+	case "CHANNEL_ANSWER":
+		if event.UUID() == transfer.ConsultUUID {
+			transfer.consultAnswered()
+		}
+	case "CHANNEL_HANGUP_COMPLETE":
+		switch event.UUID() {
+		case transfer.ConsultUUID:
+			transfer.consultFailed(event["Hangup-Cause"])
+		case transfer.CallerUUID:
+			transfer.callerHungUp()
+		case transfer.AgentUUID:
+			transfer.agentHungUp()
+		}
+	}
+}
+
+//consultAnswered bridges the agent to the target, or the caller if the agent
+//has already hung up.
+func (transfer *AttendedTransfer) consultAnswered() {
+	transfer.mu.Lock()
+	if transfer.finished || transfer.answered {
+		transfer.mu.Unlock()
+		return
+	}
+	transfer.answered = true
+	agentGone := transfer.agentGone
+	if agentGone {
+		transfer.finished = true
+	}
+	transfer.mu.Unlock()
+
+	if agentGone {
+		transfer.completeWithoutAgent()
+		return
+	}
+	if err := transfer.command("uuid_bridge " + transfer.AgentUUID + " " + transfer.ConsultUUID); err != nil && transfer.claim() {
+		transfer.abort(err)
+	}
+}
+
+//consultFailed bridges the caller back to the agent after the consult leg
+//failed or hung up before the transfer completed.
+func (transfer *AttendedTransfer) consultFailed(cause string) {
+	transfer.mu.Lock()
+	if transfer.finished {
+		transfer.mu.Unlock()
+		return
+	}
+	transfer.finished = true
+	agentGone := transfer.agentGone
+	transfer.mu.Unlock()
+
+	result := TransferResult{Outcome: TransferFailed, Cause: cause}
+	if !agentGone {
+		result.Err = transfer.command("uuid_bridge " + transfer.CallerUUID + " " + transfer.AgentUUID)
+	}
+	transfer.finish(result)
+}
+
+//callerHungUp abandons the transfer, hanging up the consult leg if it hasn't
+//been answered. An answered consult leg is left bridged to the agent.
+func (transfer *AttendedTransfer) callerHungUp() {
+	transfer.mu.Lock()
+	if transfer.finished {
+		transfer.mu.Unlock()
+		return
+	}
+	transfer.finished = true
+	answered := transfer.answered
+	transfer.mu.Unlock()
+
+	if !answered {
+		transfer.command("uuid_kill " + transfer.ConsultUUID + " ORIGINATOR_CANCEL")
+	}
+	transfer.finish(TransferResult{Outcome: TransferAbandoned})
+}
+
+//agentHungUp completes the transfer if the target has answered, otherwise it
+//is completed when the target answers.
+func (transfer *AttendedTransfer) agentHungUp() {
+	transfer.mu.Lock()
+	transfer.agentGone = true
+	complete := transfer.answered && !transfer.finished
+	if complete {
+		transfer.finished = true
+	}
+	transfer.mu.Unlock()
+
+	if complete {
+		transfer.completeWithoutAgent()
+	}
+}
+
+//completeWithoutAgent completes a claimed transfer after the agent hung up.
+//There is no one to return the caller to if the bridge fails, so the transfer
+//fails with the caller left parked.
+func (transfer *AttendedTransfer) completeWithoutAgent() {
+	if err := transfer.complete(); err != nil {
+		transfer.finish(TransferResult{Outcome: TransferFailed, Err: err})
+	}
+}
+
+//complete bridges the caller to the target once the transfer has been
+//claimed, and hangs up the agent unless it is kept.
+func (transfer *AttendedTransfer) complete() error {
+	if err := transfer.command("uuid_bridge " + transfer.CallerUUID + " " + transfer.ConsultUUID); err != nil {
+		return err
+	}
+
+	transfer.mu.Lock()
+	agentGone := transfer.agentGone
+	transfer.mu.Unlock()
+	if !agentGone && !transfer.keepAgent {
+		transfer.command("uuid_kill " + transfer.AgentUUID)
+	}
+
+	transfer.finish(TransferResult{Outcome: TransferCompleted})
+	return nil
+}
+
+//claim marks the transfer as finishing, returning false if it already was.
+func (transfer *AttendedTransfer) claim() bool {
+	transfer.mu.Lock()
+	defer transfer.mu.Unlock()
+	if transfer.finished {
+		return false
+	}
+	transfer.finished = true
+	return true
+}
+
+//finish records the transfer's result and closes Done.
+func (transfer *AttendedTransfer) finish(result TransferResult) {
+	result.ConsultUUID = transfer.ConsultUUID
+
+	transfer.mu.Lock()
+	transfer.result = result
+	transfer.mu.Unlock()
+	close(transfer.done)
+}
+
+//abort fails a claimed transfer after a bridge failed with err, hanging up
+//the consult leg and bridging the caller back to the agent if it is still
+//there.
+func (transfer *AttendedTransfer) abort(err error) {
+	transfer.command("uuid_kill " + transfer.ConsultUUID)
+
+	transfer.mu.Lock()
+	agentGone := transfer.agentGone
+	transfer.mu.Unlock()
+	if !agentGone {
+		err = transfer.rollback(err)
+	}
+	transfer.finish(TransferResult{Outcome: TransferFailed, Err: err})
+}
+
+//rollback bridges the caller back to the agent after the transfer couldn't
+//be started or completed, returning err, or the bridge error if that failed
+//too.
+func (transfer *AttendedTransfer) rollback(err error) error {
+	if bridgeErr := transfer.command("uuid_bridge " + transfer.CallerUUID + " " + transfer.AgentUUID); bridgeErr != nil {
+		return bridgeErr
+	}
+	return err
+}
+
+//command sends an api command, returning the reply as an error if it failed.
+func (transfer *AttendedTransfer) command(cmd string) error {
+	res, err := transfer.client.API(cmd)
+	if err != nil {
+		return err
+	}
+	if !ParseReply(res).OK {
+		return errors.New(strings.TrimSpace(res))
+	}
+	return nil
+}
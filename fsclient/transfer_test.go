@@ -0,0 +1,221 @@
+package fsclient_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+)
+
+//UUIDs of the legs of the test calls.
+const (
+	callerUUID  = "11111111-1111-4111-8111-111111111111"
+	agentUUID   = "22222222-2222-4222-8222-222222222222"
+	consultUUID = "33333333-3333-4333-8333-333333333333"
+)
+
+//scriptCommands scripts the fake client to reply "+OK" to every command,
+//and fail those starting with one of fail as if they couldn't be sent.
+func scriptCommands(client *fsclienttest.Client, fail ...string) {
+	client.Default = func(call fsclienttest.Call) (string, error) {
+		cmd := strings.TrimSpace(call.Name + " " + call.Args)
+		for _, prefix := range fail {
+			if prefix != "" && strings.HasPrefix(cmd, prefix) {
+				return "", errors.New("Not connected")
+			}
+		}
+		if call.Class == fsclient.ClassBGAPI {
+			return "+OK " + consultUUID, nil
+		}
+		return "+OK", nil
+	}
+}
+
+//sentCommands returns the command lines the fake client was sent.
+func sentCommands(client *fsclienttest.Client) []string {
+	var cmds []string
+	for _, call := range client.Calls() {
+		cmds = append(cmds, strings.TrimSpace(call.Name+" "+call.Args))
+	}
+	return cmds
+}
+
+//sent returns true if cmd is one of cmds.
+func sent(cmds []string, cmd string) bool {
+	for _, sent := range cmds {
+		if sent == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+//TestAttendedTransfer runs attended transfers through their outcomes with a
+//fake client, checking the result and the commands sent.
+func TestAttendedTransfer(t *testing.T) {
+	bridgeBack := "uuid_bridge " + callerUUID + " " + agentUUID
+	bridgeTarget := "uuid_bridge " + callerUUID + " " + consultUUID
+
+	tests := []struct {
+		name        string
+		fail        string
+		wantStartOK bool
+		run         func(client *fsclienttest.Client, transfer *fsclient.AttendedTransfer) error
+		wantErr     bool
+		wantOutcome fsclient.TransferOutcome
+		wantResErr  bool
+		wantCmds    []string
+		notCmds     []string
+	}{
+		{
+			name:        "completed",
+			wantStartOK: true,
+			run: func(client *fsclienttest.Client, transfer *fsclient.AttendedTransfer) error {
+				client.Inject(map[string]string{"Event-Name": "CHANNEL_ANSWER", "Unique-ID": consultUUID})
+				return transfer.Complete()
+			},
+			wantOutcome: fsclient.TransferCompleted,
+			wantCmds:    []string{"uuid_bridge " + agentUUID + " " + consultUUID, bridgeTarget, "uuid_kill " + agentUUID},
+			notCmds:     []string{bridgeBack},
+		},
+		{
+			name:        "cancelled",
+			wantStartOK: true,
+			run: func(client *fsclienttest.Client, transfer *fsclient.AttendedTransfer) error {
+				return transfer.Cancel()
+			},
+			wantOutcome: fsclient.TransferCancelled,
+			wantCmds:    []string{"uuid_kill " + consultUUID + " ORIGINATOR_CANCEL", bridgeBack},
+		},
+		{
+			name:        "consult hung up",
+			wantStartOK: true,
+			run: func(client *fsclienttest.Client, transfer *fsclient.AttendedTransfer) error {
+				client.Hangup(consultUUID, "USER_BUSY")
+				return nil
+			},
+			wantOutcome: fsclient.TransferFailed,
+			wantCmds:    []string{bridgeBack},
+		},
+		{
+			name:        "caller hung up",
+			wantStartOK: true,
+			run: func(client *fsclienttest.Client, transfer *fsclient.AttendedTransfer) error {
+				client.Hangup(callerUUID, "NORMAL_CLEARING")
+				return nil
+			},
+			wantOutcome: fsclient.TransferAbandoned,
+			wantCmds:    []string{"uuid_kill " + consultUUID + " ORIGINATOR_CANCEL"},
+			notCmds:     []string{bridgeBack},
+		},
+		{
+			name:        "agent hung up",
+			wantStartOK: true,
+			run: func(client *fsclienttest.Client, transfer *fsclient.AttendedTransfer) error {
+				client.Hangup(agentUUID, "NORMAL_CLEARING")
+				client.Inject(map[string]string{"Event-Name": "CHANNEL_ANSWER", "Unique-ID": consultUUID})
+				return nil
+			},
+			wantOutcome: fsclient.TransferCompleted,
+			wantCmds:    []string{bridgeTarget},
+			notCmds:     []string{"uuid_kill " + agentUUID},
+		},
+		{
+			name:        "park failed",
+			fail:        "uuid_park",
+			wantOutcome: fsclient.TransferFailed,
+			wantResErr:  true,
+			notCmds:     []string{bridgeBack},
+		},
+		{
+			name:        "hold music failed",
+			fail:        "uuid_broadcast",
+			wantOutcome: fsclient.TransferFailed,
+			wantResErr:  true,
+			wantCmds:    []string{bridgeBack},
+		},
+		{
+			name:        "originate failed",
+			fail:        "originate",
+			wantOutcome: fsclient.TransferFailed,
+			wantResErr:  true,
+			wantCmds:    []string{bridgeBack},
+		},
+		{
+			name:        "complete failed",
+			fail:        bridgeTarget,
+			wantStartOK: true,
+			run: func(client *fsclienttest.Client, transfer *fsclient.AttendedTransfer) error {
+				client.Inject(map[string]string{"Event-Name": "CHANNEL_ANSWER", "Unique-ID": consultUUID})
+				return transfer.Complete()
+			},
+			wantErr:     true,
+			wantOutcome: fsclient.TransferFailed,
+			wantResErr:  true,
+			wantCmds:    []string{"uuid_kill " + consultUUID, bridgeBack},
+			notCmds:     []string{"uuid_kill " + agentUUID},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fsclienttest.NewClient()
+			scriptCommands(client, test.fail)
+
+			transfer, err := fsclient.NewAttendedTransfer(client, fsclient.TransferRequest{
+				Caller: callerUUID,
+				Agent:  agentUUID,
+				Target: fsclient.OriginateRequest{
+					Endpoint:  "user/1001",
+					Variables: map[string]string{"origination_uuid": consultUUID},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.OnEvent(transfer.HandleEvent)
+
+			err = transfer.Start()
+			if (err == nil) != test.wantStartOK {
+				t.Fatalf("Start returned %v", err)
+			}
+			if test.run != nil {
+				if err := test.run(client, transfer); (err != nil) != test.wantErr {
+					t.Fatalf("Got error %v, want error %v", err, test.wantErr)
+				}
+			}
+
+			select {
+			case <-transfer.Done():
+			default:
+				t.Fatal("Transfer didn't finish")
+			}
+			result := transfer.Result()
+			if result.Outcome != test.wantOutcome || (result.Err != nil) != test.wantResErr {
+				t.Errorf("Got result %+v, want outcome %s with error %v", result, test.wantOutcome, test.wantResErr)
+			}
+			if result.ConsultUUID != consultUUID {
+				t.Errorf("Got consult UUID %q, want %q", result.ConsultUUID, consultUUID)
+			}
+
+			cmds := sentCommands(client)
+			for _, cmd := range test.wantCmds {
+				if !sent(cmds, cmd) {
+					t.Errorf("Command %q not sent, sent %q", cmd, cmds)
+				}
+			}
+			for _, cmd := range test.notCmds {
+				if sent(cmds, cmd) {
+					t.Errorf("Command %q sent", cmd)
+				}
+			}
+
+			//A finished transfer can't be finished again.
+			if err := transfer.Cancel(); err == nil {
+				t.Error("Cancelled a finished transfer")
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package fsclient
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+//TTSProvider renders text-to-speech as something Freeswitch can play, such
+//as a tts:// source for a Freeswitch TTS module or the URL of speech rendered
+//by an external engine. Set one on a Session with SetTTS to choose how "say:"
+//prompts are spoken without changing the flows that play them.
+type TTSProvider interface {
+	Render(text string) (string, error)
+}
+
+//EngineTTS speaks text with a Freeswitch TTS module, e.g. Engine "flite" and
+//Voice "kal" for mod_flite, or Engine "tts_commandline" for
+//mod_tts_commandline.
+type EngineTTS struct {
+	Engine string
+	Voice  string
+}
+
+//Render returns a tts://engine|voice|text source.
+func (tts EngineTTS) Render(text string) (string, error) {
+	if tts.Engine == "" || strings.ContainsAny(tts.Engine+tts.Voice, "| \r\n") {
+		return "", errors.New("Invalid TTS engine: " + tts.Engine + "|" + tts.Voice)
+	}
+	if err := validateSpeech(text); err != nil {
+		return "", err
+	}
+	return "tts://" + tts.Engine + "|" + tts.Voice + "|" + text, nil
+}
+
+//URLTTS speaks text rendered by an external engine and fetched over HTTP.
+//URL returns the address of the speech for text, e.g. after rendering it
+//with a cloud TTS service or finding it in a cache of rendered prompts. It is
+//played with Module, HTTPCache if empty, with Params added to its query as
+//described for HTTPAudioURL.
+type URLTTS struct {
+	URL    func(text string) (string, error)
+	Module HTTPAudioModule
+	Params url.Values
+}
+
+//Render renders text with URL and returns the file string to play it.
+func (tts URLTTS) Render(text string) (string, error) {
+	if err := validateSpeech(text); err != nil {
+		return "", err
+	}
+	rawURL, err := tts.URL(text)
+	if err != nil {
+		return "", err
+	}
+
+	module := tts.Module
+	if module == "" {
+		module = HTTPCache
+	}
+	return HTTPAudioURL(module, rawURL, tts.Params)
+}
+
+//SetTTS sets the provider "say:" prompts played on the session are rendered
+//with. Without one they are left to Freeswitch, which speaks them with the
+//channel's tts_engine and tts_voice variables.
+func (session *Session) SetTTS(provider TTSProvider) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.tts = provider
+}
+
+//Speak speaks text on the channel, with the session's TTS provider if it has
+//one.
+func (session *Session) Speak(text string) error {
+	return session.Playback("say:" + text)
+}
+
+//renderPrompt renders a "say:" prompt with the session's TTS provider, if it
+//has one, and returns other prompts as they are.
+func (session *Session) renderPrompt(prompt string) (string, error) {
+	text, ok := strings.CutPrefix(prompt, "say:")
+	if !ok {
+		return prompt, nil
+	}
+
+	session.mu.Lock()
+	provider := session.tts
+	session.mu.Unlock()
+
+	if provider == nil {
+		if err := validateSpeech(text); err != nil {
+			return "", err
+		}
+		return prompt, nil
+	}
+	return provider.Render(text)
+}
+
+//validateSpeech checks text can be passed to an application argument.
+func validateSpeech(text string) error {
+	if strings.TrimSpace(text) == "" || strings.ContainsAny(text, "\r\n") {
+		return errors.New("Invalid text to speak: " + strings.TrimSpace(text))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package fsclient
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+//UnicastTransport is the transport a channel's media is sent over by
+//Unicast.
+type UnicastTransport string
+
+//Unicast transports.
+const (
+	UnicastUDP UnicastTransport = "udp"
+	UnicastTCP UnicastTransport = "tcp"
+)
+
+//UnicastNative is the Unicast flag that sends the channel's media in its
+//native codec instead of decoding it to 16 bit linear PCM.
+const UnicastNative = "native"
+
+//Unicast sends a channel's media to remoteAddr, and plays media received on
+//localAddr to the channel, so that an application such as a voicebot can
+//process the audio on its own socket. Both addresses are "host:port". flags
+//is empty or UnicastNative. The media stops when the channel hangs up or
+//leaves the application it is in, e.g. park.
+func (client *Client) Unicast(uuid string, localAddr string, remoteAddr string, transport UnicastTransport, flags string) error {
+	return unicast(client, uuid, localAddr, remoteAddr, transport, flags)
+}
+
+//unicast sends the unicast command to a channel with client.
+func unicast(client Commander, uuid string, localAddr string, remoteAddr string, transport UnicastTransport, flags string) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+
+	localHost, localPort, err := net.SplitHostPort(localAddr)
+	if err != nil || strings.ContainsAny(localAddr, " \r\n") {
+		return errors.New("Invalid unicast local address: " + localAddr)
+	}
+	remoteHost, remotePort, err := net.SplitHostPort(remoteAddr)
+	if err != nil || strings.ContainsAny(remoteAddr, " \r\n") {
+		return errors.New("Invalid unicast remote address: " + remoteAddr)
+	}
+
+	if transport == "" {
+		transport = UnicastUDP
+	}
+	if transport != UnicastUDP && transport != UnicastTCP {
+		return errors.New("Invalid unicast transport: " + string(transport))
+	}
+	if strings.ContainsAny(flags, " \r\n") {
+		return errors.New("Invalid unicast flags: " + flags)
+	}
+
+	lines := []string{
+		"sendmsg " + uuid,
+		"call-command: unicast",
+		"local-ip: " + localHost,
+		"local-port: " + localPort,
+		"remote-ip: " + remoteHost,
+		"remote-port: " + remotePort,
+		"transport: " + string(transport),
+	}
+	if flags != "" {
+		lines = append(lines, "flags: "+flags)
+	}
+
+	reply, err := client.SendRecv(strings.Join(lines, "\n"))
+	if err != nil {
+		return err
+	}
+	if !reply.OK {
+		return errors.New(reply.Text)
+	}
+	return nil
+}
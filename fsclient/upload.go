@@ -0,0 +1,131 @@
+package fsclient
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+//MaxUploadSize is the largest file UploadFile sends, as files are sent
+//through the event socket base64 encoded in api commands.
+const MaxUploadSize = 1 << 20
+
+//uploadChunkSize is the number of base64 characters sent in each api command.
+//It is a multiple of four so each chunk decodes on its own.
+const uploadChunkSize = 32 * 1024
+
+//errUploadUnsupported is returned when uploading a file to a Freeswitch
+//without mod_lua.
+var errUploadUnsupported = errors.New("File upload needs mod_lua")
+
+//uploadScript is the inline Lua run by the lua api to append a base64 chunk
+//to a file, with the path, open mode and chunk substituted for %PATH%, %MODE%
+//and %DATA%. It replies with the file's size.
+const uploadScript = `~local p,m,d=[[%PATH%]],'%MODE%','%DATA%' ` +
+	`local b='ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/' ` +
+	`d=d:gsub('.',function(x) if x=='=' then return '' end local r,f='',(b:find(x,1,true)-1) ` +
+	`for i=6,1,-1 do r=r..(f%2^i-f%2^(i-1)>0 and '1' or '0') end return r end)` +
+	`:gsub('%d%d%d?%d?%d?%d?%d?%d?',function(x) if #x~=8 then return '' end local c=0 ` +
+	`for i=1,8 do c=c+(x:sub(i,i)=='1' and 2^(8-i) or 0) end return string.char(c) end) ` +
+	`local f,e=io.open(p,m) if not f then stream:write('-ERR '..tostring(e)) return end ` +
+	`f:write(d) local n=f:seek('end') f:close() stream:write('+OK '..n)`
+
+//FileExists returns true if a file exists on the Freeswitch host, using the
+//file_exists api.
+func FileExists(client Commander, path string) (bool, error) {
+	if path == "" || strings.ContainsAny(path, "\r\n") {
+		return false, errors.New("Invalid file path: " + path)
+	}
+
+	res, err := client.API("file_exists " + path)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(res) == "true", nil
+}
+
+//ModuleExists returns true if a module, e.g. "mod_lua", is loaded, using the
+//module_exists api.
+func ModuleExists(client Commander, module string) (bool, error) {
+	if module == "" || strings.ContainsAny(module, " \r\n") {
+		return false, errors.New("Invalid module: " + module)
+	}
+
+	res, err := client.API("module_exists " + module)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(res) == "true", nil
+}
+
+//UploadFile writes data to path on the Freeswitch host, replacing any file
+//already there, so that prompts can be managed without storage shared with
+//Freeswitch. Freeswitch has no api to write files, so inline Lua scripts run
+//with the lua api append the data in base64 chunks, which needs mod_lua. The
+//directory must already exist and be writable by Freeswitch. Files are
+//limited to MaxUploadSize.
+//
+//The file is written in place, so Freeswitch may play a partly uploaded file
+//if it is in use. Upload to a new name and switch prompts over to replace a
+//live prompt.
+func UploadFile(client Commander, path string, data []byte) error {
+	//The path is quoted as a Lua [[...]] long string, which a "]]" in it, or
+	//a "]" at its end, would close early.
+	if path == "" || strings.Contains(path, "]]") || strings.HasSuffix(path, "]") || strings.ContainsAny(path, "\r\n") {
+		return errors.New("Invalid file path: " + path)
+	}
+	if len(data) > MaxUploadSize {
+		return errors.New("File too large to upload: " + strconv.Itoa(len(data)) + " bytes")
+	}
+
+	loaded, err := ModuleExists(client, "mod_lua")
+	if err != nil {
+		return err
+	}
+	if !loaded {
+		return errUploadUnsupported
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	mode := "wb"
+	for {
+		chunk := encoded
+		if len(chunk) > uploadChunkSize {
+			chunk = encoded[:uploadChunkSize]
+		}
+		encoded = encoded[len(chunk):]
+
+		size, err := uploadChunk(client, path, mode, chunk)
+		if err != nil {
+			return err
+		}
+		if encoded == "" {
+			if size != len(data) {
+				return errors.New("Uploaded file " + path + " is " + strconv.Itoa(size) + " bytes, expected " + strconv.Itoa(len(data)))
+			}
+			return nil
+		}
+		mode = "ab"
+	}
+}
+
+//uploadChunk writes one base64 chunk to a file and returns the file's size.
+func uploadChunk(client Commander, path string, mode string, chunk string) (int, error) {
+	script := strings.NewReplacer("%PATH%", path, "%MODE%", mode, "%DATA%", chunk).Replace(uploadScript)
+	res, err := client.API("lua " + script)
+	if err != nil {
+		return 0, err
+	}
+
+	res = strings.TrimSpace(res)
+	size, ok := strings.CutPrefix(res, "+OK ")
+	if !ok {
+		return 0, errors.New(res)
+	}
+	n, err := strconv.Atoi(size)
+	if err != nil {
+		return 0, errors.New(res)
+	}
+	return n, nil
+}
@@ -0,0 +1,89 @@
+package fsclient_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+)
+
+//uploadArgs matches the path, mode and data of an upload chunk's Lua script.
+var uploadArgs = regexp.MustCompile(`^~local p,m,d=\[\[(.*?)\]\],'(wb|ab)','([A-Za-z0-9+/=]*)' `)
+
+//fakeFiles scripts the fake client's lua api to write upload chunks to
+//files, and module_exists to report whether mod_lua is loaded.
+func fakeFiles(client *fsclienttest.Client, lua bool) map[string]*bytes.Buffer {
+	files := make(map[string]*bytes.Buffer)
+	client.Reply(fsclient.ClassAPI, "module_exists", strconv.FormatBool(lua))
+	client.Handle(fsclient.ClassAPI, "lua", func(call fsclienttest.Call) (string, error) {
+		match := uploadArgs.FindStringSubmatch(call.Args)
+		if match == nil {
+			return "-ERR syntax error", nil
+		}
+		data, err := base64.StdEncoding.DecodeString(match[3])
+		if err != nil {
+			return "-ERR invalid chunk", nil
+		}
+		if match[2] == "wb" || files[match[1]] == nil {
+			files[match[1]] = &bytes.Buffer{}
+		}
+		files[match[1]].Write(data)
+		return "+OK " + strconv.Itoa(files[match[1]].Len()), nil
+	})
+	return files
+}
+
+//TestUploadFile checks that files are uploaded in chunks, and that paths
+//that would break the Lua script are rejected.
+func TestUploadFile(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789"), 5000)
+
+	tests := []struct {
+		name       string
+		path       string
+		data       []byte
+		lua        bool
+		wantErr    bool
+		wantChunks int
+	}{
+		{"small", "/tmp/prompt.wav", []byte("RIFF"), true, false, 1},
+		{"empty", "/tmp/prompt.wav", nil, true, false, 1},
+		{"chunked", "/tmp/prompt.wav", large, true, false, 3},
+		{"bracket", "/tmp/[1]/prompt.wav", []byte("RIFF"), true, false, 1},
+		{"no mod_lua", "/tmp/prompt.wav", []byte("RIFF"), false, true, 0},
+		{"closing brackets", "/tmp/a]]b.wav", []byte("RIFF"), true, true, 0},
+		{"trailing bracket", "/tmp/a]", []byte("RIFF"), true, true, 0},
+		{"newline", "/tmp/a\nb", []byte("RIFF"), true, true, 0},
+		{"no path", "", []byte("RIFF"), true, true, 0},
+		{"too large", "/tmp/prompt.wav", make([]byte, fsclient.MaxUploadSize+1), true, true, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fsclienttest.NewClient()
+			files := fakeFiles(client, test.lua)
+
+			err := fsclient.UploadFile(client, test.path, test.data)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Got error %v, want error %v", err, test.wantErr)
+			}
+
+			chunks := 0
+			for _, call := range client.Calls() {
+				if call.Name == "lua" {
+					chunks++
+				}
+			}
+			if chunks != test.wantChunks {
+				t.Errorf("Sent %d chunks, want %d", chunks, test.wantChunks)
+			}
+			if !test.wantErr && !bytes.Equal(files[test.path].Bytes(), test.data) {
+				t.Errorf("Uploaded %d bytes, want %d", files[test.path].Len(), len(test.data))
+			}
+		})
+	}
+}
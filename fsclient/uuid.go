@@ -0,0 +1,66 @@
+package fsclient
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//NewUUID returns a random version 4 UUID in the lower case form Freeswitch
+//uses, e.g. for origination_uuid, so a channel's UUID is known before it is
+//originated.
+func NewUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+//ValidUUID returns true if uuid is a UUID in the 8-4-4-4-12 hex digit form,
+//in either case.
+func ValidUUID(uuid string) bool {
+	if len(uuid) != 36 {
+		return false
+	}
+	for i, c := range uuid {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//ValidateUUID returns an error if uuid isn't a valid UUID. The client's
+//channel commands check their UUIDs with it, so that a bad UUID fails with a
+//clear error instead of a -ERR reply or, if it contains a space, being taken
+//as another argument.
+func ValidateUUID(uuid string) error {
+	if !ValidUUID(uuid) {
+		return errors.New("Invalid UUID: " + strings.TrimSpace(uuid))
+	}
+	return nil
+}
+
+//CreateUUID returns a new UUID generated by Freeswitch with the create_uuid
+//api command.
+func (client *Client) CreateUUID() (string, error) {
+	res, err := client.API("create_uuid")
+	if err != nil {
+		return "", err
+	}
+
+	uuid := strings.TrimSpace(res)
+	if !ValidUUID(uuid) {
+		return "", errors.New(uuid)
+	}
+	return uuid, nil
+}
@@ -0,0 +1,155 @@
+package fsclient
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+//Array channel variables start with arrayPrefix and have their items
+//separated by arraySeparator, e.g. "ARRAY::a|:b".
+const (
+	arrayPrefix    = "ARRAY::"
+	arraySeparator = "|:"
+)
+
+//listDelimiters are the delimiters tried, in order, for a list of values.
+//Freeswitch splits lists on commas unless they start with ^^ and another
+//delimiter, e.g. "^^:a,b:c".
+const listDelimiters = ",:;|#~"
+
+//EncodeArray encodes values as an array channel variable, e.g. "ARRAY::a|:b",
+//the form Freeswitch uses for variables set with push or multiple values.
+func EncodeArray(values []string) string {
+	return arrayPrefix + strings.Join(values, arraySeparator)
+}
+
+//DecodeArray decodes an array channel variable. A value that isn't an array
+//is returned as a single item, and an empty value as none.
+func DecodeArray(value string) []string {
+	if value == "" {
+		return nil
+	}
+	if !strings.HasPrefix(value, arrayPrefix) {
+		return []string{value}
+	}
+	return strings.Split(strings.TrimPrefix(value, arrayPrefix), arraySeparator)
+}
+
+//EncodeList encodes values as a delimited list, such as an export_vars or
+//absolute_codec_string value. Values are separated by commas unless a value
+//contains one, in which case the list starts with ^^ and a delimiter that no
+//value contains, e.g. "^^:a,b:c". It returns an error if every delimiter is
+//used.
+func EncodeList(values []string) (string, error) {
+	delimiter := unusedDelimiter(values)
+	switch delimiter {
+	case "":
+		return "", errors.New("No unused delimiter for list")
+	case ",":
+		return strings.Join(values, ","), nil
+	}
+	return "^^" + delimiter + strings.Join(values, delimiter), nil
+}
+
+//DecodeList decodes a delimited list made by EncodeList, including lists
+//that use a ^^ delimiter, and array channel variables.
+func DecodeList(value string) []string {
+	switch {
+	case value == "":
+		return nil
+	case strings.HasPrefix(value, arrayPrefix):
+		return DecodeArray(value)
+	case strings.HasPrefix(value, "^^") && len(value) > 2:
+		return strings.Split(value[3:], value[2:3])
+	}
+	return strings.Split(value, ",")
+}
+
+//QuoteVariable quotes a channel variable value for an originate {...} or
+//[...] block if it contains spaces, quotes, backslashes or braces, which would
+//otherwise split the argument or end the block. The value is single quoted
+//with its quotes and backslashes escaped. Other values are returned as they
+//are.
+func QuoteVariable(value string) string {
+	if strings.ContainsAny(value, " '\"\\{}[]") {
+		return quoteValue(value)
+	}
+	return value
+}
+
+//UnquoteVariable reverses QuoteVariable.
+func UnquoteVariable(value string) string {
+	if len(value) < 2 || value[0] != '\'' || value[len(value)-1] != '\'' {
+		return value
+	}
+
+	var unquoted strings.Builder
+	value = value[1 : len(value)-1]
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+		unquoted.WriteByte(value[i])
+	}
+	return unquoted.String()
+}
+
+//quoteValue single quotes a value, escaping quotes and backslashes within it.
+func quoteValue(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + replacer.Replace(value) + "'"
+}
+
+//unusedDelimiter returns the first of listDelimiters that none of values
+//contain, or an empty string if they all contain one.
+func unusedDelimiter(values []string) string {
+	for _, delimiter := range listDelimiters {
+		used := false
+		for _, value := range values {
+			if strings.ContainsRune(value, delimiter) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			return string(delimiter)
+		}
+	}
+	return ""
+}
+
+//SetVariables sets channel variables on an existing channel. Variables are
+//set together with uuid_setvar_multi, apart from values containing its ;
+//separator, which are set one at a time with uuid_setvar. An empty value
+//unsets the variable.
+func (client *Client) SetVariables(uuid string, vars map[string]string) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(vars))
+	for key, value := range vars {
+		if key == "" || strings.ContainsAny(key, "=; \n") || strings.Contains(value, "\n") {
+			return errors.New("Invalid channel variable: " + key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if strings.Contains(vars[key], ";") {
+			if err := client.apiOK("uuid_setvar " + uuid + " " + key + " " + vars[key]); err != nil {
+				return err
+			}
+			continue
+		}
+		pairs = append(pairs, key+"="+vars[key])
+	}
+
+	if len(pairs) == 0 {
+		return nil
+	}
+	return client.apiOK("uuid_setvar_multi " + uuid + " " + strings.Join(pairs, ";"))
+}
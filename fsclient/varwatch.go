@@ -0,0 +1,224 @@
+package fsclient
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+//VariableChange is a change to a watched channel variable. Set is false if
+//the variable was unset.
+type VariableChange struct {
+	UUID  string
+	Name  string
+	Old   string
+	Value string
+	Set   bool
+}
+
+//variableWatch is the last known value of a watched variable and the
+//handlers to notify when it changes.
+type variableWatch struct {
+	value    string
+	set      bool
+	handlers []func(change VariableChange)
+}
+
+//VariableWatcher notifies handlers when channel variables change, so that an
+//application can react to variables set by the dialplan. Register HandleEvent
+//with a Dispatcher: changes are seen in the variable_ headers of the
+//channel's events, so the client should be subscribed to events that carry
+//them, e.g. CHANNEL_DATA and CHANNEL_EXECUTE_COMPLETE. Variables changed
+//without an event, such as with uuid_setvar, are only seen by Poll.
+type VariableWatcher struct {
+	client  Commander
+	clock   Clock
+	watches map[string]map[string]*variableWatch
+	mu      *sync.Mutex
+}
+
+//NewVariableWatcher creates a VariableWatcher that reads variables with
+//client.
+func NewVariableWatcher(client Commander) *VariableWatcher {
+	return &VariableWatcher{
+		client:  client,
+		clock:   SystemClock,
+		watches: make(map[string]map[string]*variableWatch),
+		mu:      &sync.Mutex{},
+	}
+}
+
+//SetClock sets the clock Poll waits with.
+func (watcher *VariableWatcher) SetClock(clock Clock) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	watcher.clock = clock
+}
+
+//Watch calls handler each time the variable name changes on the channel
+//uuid, until the channel hangs up or Unwatch is called. The variable's
+//current value is read first, so only later changes are notified.
+func (watcher *VariableWatcher) Watch(uuid string, name string, handler func(change VariableChange)) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+	if name == "" || strings.ContainsAny(name, " \r\n") {
+		return errors.New("Invalid variable name: " + name)
+	}
+
+	value, set, err := watcher.getVar(uuid, name)
+	if err != nil {
+		return err
+	}
+
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	vars := watcher.watches[uuid]
+	if vars == nil {
+		vars = make(map[string]*variableWatch)
+		watcher.watches[uuid] = vars
+	}
+	watch := vars[name]
+	if watch == nil {
+		watch = &variableWatch{value: value, set: set}
+		vars[name] = watch
+	}
+	watch.handlers = append(watch.handlers, handler)
+	return nil
+}
+
+//Unwatch stops notifying changes to the variable name on the channel uuid.
+func (watcher *VariableWatcher) Unwatch(uuid string, name string) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	delete(watcher.watches[uuid], name)
+	if len(watcher.watches[uuid]) == 0 {
+		delete(watcher.watches, uuid)
+	}
+}
+
+//HandleEvent notifies changes to watched variables in a channel's event, and
+//stops watching a channel's variables when it hangs up.
+func (watcher *VariableWatcher) HandleEvent(event Event) {
+	uuid := event.UUID()
+
+	if event.Name() == "CHANNEL_HANGUP_COMPLETE" {
+		watcher.mu.Lock()
+		delete(watcher.watches, uuid)
+		watcher.mu.Unlock()
+		return
+	}
+
+	watcher.mu.Lock()
+	var changes []VariableChange
+	var handlers [][]func(change VariableChange)
+	for name, watch := range watcher.watches[uuid] {
+		//Events don't say which variables are unset, so a missing header
+		//isn't a change.
+		value, ok := event["variable_"+name]
+		if !ok {
+			continue
+		}
+		if change, changed := watch.update(uuid, name, value, true); changed {
+			changes = append(changes, change)
+			handlers = append(handlers, watch.handlers)
+		}
+	}
+	watcher.mu.Unlock()
+
+	notifyVariableChanges(changes, handlers)
+}
+
+//Poll reads every watched variable with uuid_getvar each interval and
+//notifies those that have changed, until stop is closed. It catches changes
+//that aren't seen in events, such as variables that are unset.
+func (watcher *VariableWatcher) Poll(interval time.Duration, stop <-chan struct{}) {
+	for {
+		watcher.poll()
+
+		watcher.mu.Lock()
+		clock := watcher.clock
+		watcher.mu.Unlock()
+
+		select {
+		case <-clock.After(interval):
+		case <-stop:
+			return
+		}
+	}
+}
+
+//poll reads each watched variable once and notifies the changes.
+func (watcher *VariableWatcher) poll() {
+	type watched struct{ uuid, name string }
+
+	watcher.mu.Lock()
+	var all []watched
+	for uuid, vars := range watcher.watches {
+		for name := range vars {
+			all = append(all, watched{uuid: uuid, name: name})
+		}
+	}
+	watcher.mu.Unlock()
+
+	for _, variable := range all {
+		value, set, err := watcher.getVar(variable.uuid, variable.name)
+		if err != nil {
+			continue
+		}
+
+		watcher.mu.Lock()
+		watch := watcher.watches[variable.uuid][variable.name]
+		var change VariableChange
+		var changed bool
+		var handlers []func(change VariableChange)
+		if watch != nil {
+			change, changed = watch.update(variable.uuid, variable.name, value, set)
+			handlers = watch.handlers
+		}
+		watcher.mu.Unlock()
+
+		if changed {
+			notifyVariableChanges([]VariableChange{change}, [][]func(change VariableChange){handlers})
+		}
+	}
+}
+
+//getVar reads a channel variable, returning false if it isn't set.
+func (watcher *VariableWatcher) getVar(uuid string, name string) (string, bool, error) {
+	res, err := watcher.client.API("uuid_getvar " + uuid + " " + name)
+	if err != nil {
+		return "", false, err
+	}
+	if strings.HasPrefix(res, "-ERR") {
+		return "", false, errors.New(strings.TrimSpace(res))
+	}
+	if res == "_undef_" {
+		return "", false, nil
+	}
+	return res, true, nil
+}
+
+//update records the variable's latest value, returning the change if it
+//differs from the last known value.
+func (watch *variableWatch) update(uuid string, name string, value string, set bool) (VariableChange, bool) {
+	if value == watch.value && set == watch.set {
+		return VariableChange{}, false
+	}
+	change := VariableChange{UUID: uuid, Name: name, Old: watch.value, Value: value, Set: set}
+	watch.value, watch.set = value, set
+	return change, true
+}
+
+//notifyVariableChanges calls each change's handlers, outside the watcher's
+//lock so handlers can watch and unwatch variables.
+func notifyVariableChanges(changes []VariableChange, handlers [][]func(change VariableChange)) {
+	for i, change := range changes {
+		for _, handler := range handlers[i] {
+			handler(change)
+		}
+	}
+}
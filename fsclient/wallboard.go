@@ -0,0 +1,288 @@
+package fsclient
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+//QueueStats are the statistics of a mod_callcenter queue in a
+//WallboardSnapshot. Waiting is the number of callers waiting now and
+//LongestWait how long the first of them has been waiting. Answered and
+//Abandoned count the callers that were connected to an agent or hung up
+//within the snapshot's window, and AverageWait is how long the answered
+//callers waited.
+type QueueStats struct {
+	Queue       string
+	Waiting     int
+	LongestWait time.Duration
+	Answered    int
+	Abandoned   int
+	AverageWait time.Duration
+}
+
+//WallboardSnapshot is a summary of the calls on a node for a wallboard.
+//CallsInProgress counts calls that haven't hung up, counting a call and the
+//legs it is bridged to once. Answered counts calls answered within Window,
+//and AverageWait is how long they rang first. Queues are only listed when the
+//client receives mod_callcenter events.
+type WallboardSnapshot struct {
+	Time            time.Time
+	Window          time.Duration
+	CallsInProgress int
+	Answered        int
+	AverageWait     time.Duration
+	Queues          []QueueStats
+}
+
+//jsonQueueStats is the JSON form of QueueStats.
+type jsonQueueStats struct {
+	Queue       string  `json:"queue"`
+	Waiting     int     `json:"waiting"`
+	LongestWait float64 `json:"longest_wait_sec"`
+	Answered    int     `json:"answered"`
+	Abandoned   int     `json:"abandoned"`
+	AverageWait float64 `json:"average_wait_sec"`
+}
+
+//jsonWallboardSnapshot is the JSON form of a WallboardSnapshot.
+type jsonWallboardSnapshot struct {
+	Time            time.Time        `json:"time"`
+	Window          float64          `json:"window_sec"`
+	CallsInProgress int              `json:"calls_in_progress"`
+	Answered        int              `json:"answered"`
+	AverageWait     float64          `json:"average_wait_sec"`
+	Queues          []jsonQueueStats `json:"queues,omitempty"`
+}
+
+//MarshalJSON encodes the snapshot with durations in seconds.
+func (snapshot WallboardSnapshot) MarshalJSON() ([]byte, error) {
+	out := jsonWallboardSnapshot{
+		Time:            snapshot.Time,
+		Window:          snapshot.Window.Seconds(),
+		CallsInProgress: snapshot.CallsInProgress,
+		Answered:        snapshot.Answered,
+		AverageWait:     snapshot.AverageWait.Seconds(),
+	}
+	for _, queue := range snapshot.Queues {
+		out.Queues = append(out.Queues, jsonQueueStats{
+			Queue:       queue.Queue,
+			Waiting:     queue.Waiting,
+			LongestWait: queue.LongestWait.Seconds(),
+			Answered:    queue.Answered,
+			Abandoned:   queue.Abandoned,
+			AverageWait: queue.AverageWait.Seconds(),
+		})
+	}
+	return json.Marshal(out)
+}
+
+//waitSample is a call answered after waiting.
+type waitSample struct {
+	at   time.Time
+	wait time.Duration
+}
+
+//wallboardQueue is the state of a mod_callcenter queue.
+type wallboardQueue struct {
+	waiting   map[string]time.Time
+	answered  []waitSample
+	abandoned []time.Time
+}
+
+//Wallboard aggregates channel and mod_callcenter events into snapshots for
+//dashboards, over a sliding window of recent calls.
+//
+//Register the wallboard's HandleEvent method with a Dispatcher. The client
+//must be subscribed to CHANNEL_CREATE, CHANNEL_ANSWER and
+//CHANNEL_HANGUP_COMPLETE, and for queue statistics to
+//"CUSTOM callcenter::info".
+type Wallboard struct {
+	window   time.Duration
+	clock    Clock
+	calls    map[string]time.Time
+	answered []waitSample
+	queues   map[string]*wallboardQueue
+	mu       *sync.Mutex
+}
+
+//NewWallboard creates a Wallboard counting answered and abandoned calls
+//within window, e.g. the last 15 minutes.
+func NewWallboard(window time.Duration) *Wallboard {
+	return &Wallboard{
+		window: window,
+		clock:  SystemClock,
+		calls:  make(map[string]time.Time),
+		queues: make(map[string]*wallboardQueue),
+		mu:     &sync.Mutex{},
+	}
+}
+
+//SetClock sets the clock used to time calls and snapshots.
+func (wallboard *Wallboard) SetClock(clock Clock) {
+	wallboard.mu.Lock()
+	defer wallboard.mu.Unlock()
+	wallboard.clock = clock
+}
+
+//HandleEvent updates the wallboard from channel and mod_callcenter events.
+func (wallboard *Wallboard) HandleEvent(event Event) {
+	if event.Name() == "CUSTOM" {
+		if event["Event-Subclass"] == "callcenter::info" {
+			wallboard.queueEvent(event)
+		}
+		return
+	}
+
+	uuid := event.UUID()
+	if uuid == "" || event["variable_originator"] != "" || event["variable_originating_leg_uuid"] != "" {
+		return
+	}
+
+	wallboard.mu.Lock()
+	defer wallboard.mu.Unlock()
+	now := wallboard.clock.Now()
+
+	switch event.Name() {
+	case "CHANNEL_CREATE":
+		wallboard.calls[uuid] = now
+	case "CHANNEL_ANSWER":
+		created, ok := wallboard.calls[uuid]
+		if !ok {
+			created = now
+			wallboard.calls[uuid] = now
+		}
+		wallboard.answered = append(pruneWaits(wallboard.answered, now.Add(-wallboard.window)), waitSample{at: now, wait: now.Sub(created)})
+	case "CHANNEL_HANGUP_COMPLETE":
+		delete(wallboard.calls, uuid)
+	}
+}
+
+//queueEvent updates a queue from a mod_callcenter event.
+func (wallboard *Wallboard) queueEvent(event Event) {
+	name, member := event["CC-Queue"], event["CC-Member-UUID"]
+	if name == "" || member == "" {
+		return
+	}
+
+	wallboard.mu.Lock()
+	defer wallboard.mu.Unlock()
+	now := wallboard.clock.Now()
+	since := now.Add(-wallboard.window)
+
+	queue, ok := wallboard.queues[name]
+	if !ok {
+		queue = &wallboardQueue{waiting: make(map[string]time.Time)}
+		wallboard.queues[name] = queue
+	}
+
+	switch event["CC-Action"] {
+	case "member-queue-start":
+		queue.waiting[member] = now
+	case "bridge-agent-start":
+		joined, waiting := queue.waiting[member]
+		if !waiting {
+			return
+		}
+		delete(queue.waiting, member)
+		queue.answered = append(pruneWaits(queue.answered, since), waitSample{at: now, wait: now.Sub(joined)})
+	case "member-queue-end":
+		if _, waiting := queue.waiting[member]; !waiting {
+			return
+		}
+		delete(queue.waiting, member)
+		if event["CC-Cause"] == "Cancel" {
+			queue.abandoned = append(pruneTimes(queue.abandoned, since), now)
+		}
+	}
+}
+
+//Snapshot returns the current state of the wallboard.
+func (wallboard *Wallboard) Snapshot() WallboardSnapshot {
+	wallboard.mu.Lock()
+	defer wallboard.mu.Unlock()
+
+	now := wallboard.clock.Now()
+	since := now.Add(-wallboard.window)
+	wallboard.answered = pruneWaits(wallboard.answered, since)
+
+	snapshot := WallboardSnapshot{
+		Time:            now,
+		Window:          wallboard.window,
+		CallsInProgress: len(wallboard.calls),
+		Answered:        len(wallboard.answered),
+		AverageWait:     averageWait(wallboard.answered),
+	}
+
+	for name, queue := range wallboard.queues {
+		queue.answered = pruneWaits(queue.answered, since)
+		queue.abandoned = pruneTimes(queue.abandoned, since)
+		if len(queue.waiting) == 0 && len(queue.answered) == 0 && len(queue.abandoned) == 0 {
+			delete(wallboard.queues, name)
+			continue
+		}
+
+		stats := QueueStats{
+			Queue:       name,
+			Waiting:     len(queue.waiting),
+			Answered:    len(queue.answered),
+			Abandoned:   len(queue.abandoned),
+			AverageWait: averageWait(queue.answered),
+		}
+		for _, joined := range queue.waiting {
+			if wait := now.Sub(joined); wait > stats.LongestWait {
+				stats.LongestWait = wait
+			}
+		}
+		snapshot.Queues = append(snapshot.Queues, stats)
+	}
+	sort.Slice(snapshot.Queues, func(i, j int) bool { return snapshot.Queues[i].Queue < snapshot.Queues[j].Queue })
+	return snapshot
+}
+
+//Run calls publish with a snapshot each interval, until stop is closed.
+func (wallboard *Wallboard) Run(interval time.Duration, stop <-chan struct{}, publish func(snapshot WallboardSnapshot)) {
+	for {
+		wallboard.mu.Lock()
+		clock := wallboard.clock
+		wallboard.mu.Unlock()
+
+		select {
+		case <-clock.After(interval):
+			publish(wallboard.Snapshot())
+		case <-stop:
+			return
+		}
+	}
+}
+
+//pruneWaits removes the samples from before since, which are in time order.
+func pruneWaits(samples []waitSample, since time.Time) []waitSample {
+	i := 0
+	for i < len(samples) && !samples[i].at.After(since) {
+		i++
+	}
+	return samples[i:]
+}
+
+//pruneTimes removes the times from before since, which are in order.
+func pruneTimes(times []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(since) {
+		i++
+	}
+	return times[i:]
+}
+
+//averageWait returns the mean wait of samples, or zero if there are none.
+func averageWait(samples []waitSample) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, sample := range samples {
+		total += sample.wait
+	}
+	return total / time.Duration(len(samples))
+}
@@ -0,0 +1,162 @@
+package fsclient_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tomponline/fsclient/fsclient"
+	"github.com/tomponline/fsclient/fsclient/fsclienttest"
+)
+
+//wallboardStep advances the clock and then injects an event.
+type wallboardStep struct {
+	advance time.Duration
+	event   map[string]string
+}
+
+//legEvent is a channel event for the channel uuid, as a B leg of the call
+//originator if it is set.
+func legEvent(name string, uuid string, originator string) map[string]string {
+	event := map[string]string{"Event-Name": name, "Unique-ID": uuid}
+	if originator != "" {
+		event["variable_originator"] = originator
+	}
+	return event
+}
+
+//queueEvent is a mod_callcenter event for member in the support queue.
+func queueEvent(action string, member string, cause string) map[string]string {
+	return map[string]string{
+		"Event-Name":     "CUSTOM",
+		"Event-Subclass": "callcenter::info",
+		"CC-Queue":       "support@default",
+		"CC-Action":      action,
+		"CC-Member-UUID": member,
+		"CC-Cause":       cause,
+	}
+}
+
+//TestWallboard checks the snapshots aggregated from channel and queue events.
+func TestWallboard(t *testing.T) {
+	tests := []struct {
+		name            string
+		steps           []wallboardStep
+		advance         time.Duration
+		wantInProgress  int
+		wantAnswered    int
+		wantAverageWait time.Duration
+		wantQueues      []fsclient.QueueStats
+	}{
+		{"in progress", []wallboardStep{
+			{0, legEvent("CHANNEL_CREATE", callerUUID, "")},
+			{0, legEvent("CHANNEL_CREATE", agentUUID, callerUUID)},
+			{time.Second, legEvent("CHANNEL_CREATE", consultUUID, "")},
+		}, 0, 2, 0, 0, nil},
+		{"answered", []wallboardStep{
+			{0, legEvent("CHANNEL_CREATE", callerUUID, "")},
+			{2 * time.Second, legEvent("CHANNEL_CREATE", agentUUID, "")},
+			{2 * time.Second, legEvent("CHANNEL_ANSWER", callerUUID, "")},
+			{4 * time.Second, legEvent("CHANNEL_ANSWER", agentUUID, "")},
+			{0, legEvent("CHANNEL_ANSWER", consultUUID, callerUUID)},
+		}, 0, 2, 2, 5 * time.Second, nil},
+		{"hung up", []wallboardStep{
+			{0, legEvent("CHANNEL_CREATE", callerUUID, "")},
+			{4 * time.Second, legEvent("CHANNEL_ANSWER", callerUUID, "")},
+			{time.Minute, legEvent("CHANNEL_HANGUP_COMPLETE", callerUUID, "")},
+		}, 0, 0, 1, 4 * time.Second, nil},
+		{"outside window", []wallboardStep{
+			{0, legEvent("CHANNEL_CREATE", callerUUID, "")},
+			{4 * time.Second, legEvent("CHANNEL_ANSWER", callerUUID, "")},
+		}, 15 * time.Minute, 1, 0, 0, nil},
+		{"queue", []wallboardStep{
+			{0, queueEvent("member-queue-start", callerUUID, "")},
+			{5 * time.Second, queueEvent("member-queue-start", agentUUID, "")},
+			{5 * time.Second, queueEvent("bridge-agent-start", callerUUID, "")},
+		}, 0, 0, 0, 0, []fsclient.QueueStats{
+			{Queue: "support@default", Waiting: 1, LongestWait: 5 * time.Second, Answered: 1, AverageWait: 10 * time.Second},
+		}},
+		{"abandoned", []wallboardStep{
+			{0, queueEvent("member-queue-start", callerUUID, "")},
+			{30 * time.Second, queueEvent("member-queue-end", callerUUID, "Cancel")},
+		}, time.Second, 0, 0, 0, []fsclient.QueueStats{
+			{Queue: "support@default", Abandoned: 1},
+		}},
+		{"ended after answer", []wallboardStep{
+			{0, queueEvent("member-queue-start", callerUUID, "")},
+			{3 * time.Second, queueEvent("bridge-agent-start", callerUUID, "")},
+			{time.Minute, queueEvent("member-queue-end", callerUUID, "Terminated")},
+		}, 0, 0, 0, 0, []fsclient.QueueStats{
+			{Queue: "support@default", Answered: 1, AverageWait: 3 * time.Second},
+		}},
+		{"queue outside window", []wallboardStep{
+			{0, queueEvent("member-queue-start", callerUUID, "")},
+			{30 * time.Second, queueEvent("member-queue-end", callerUUID, "Cancel")},
+		}, 15 * time.Minute, 0, 0, 0, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := fsclienttest.NewClock(time.Unix(0, 0))
+			wallboard := fsclient.NewWallboard(15 * time.Minute)
+			wallboard.SetClock(clock)
+			client := fsclienttest.NewClient()
+			client.OnEvent(wallboard.HandleEvent)
+
+			for _, step := range test.steps {
+				clock.Advance(step.advance)
+				client.Inject(step.event)
+			}
+			clock.Advance(test.advance)
+
+			snapshot := wallboard.Snapshot()
+			if !snapshot.Time.Equal(clock.Now()) || snapshot.Window != 15*time.Minute {
+				t.Errorf("Got snapshot at %v of %v", snapshot.Time, snapshot.Window)
+			}
+			if snapshot.CallsInProgress != test.wantInProgress || snapshot.Answered != test.wantAnswered || snapshot.AverageWait != test.wantAverageWait {
+				t.Errorf("Got %d in progress and %d answered after %v, want %d and %d after %v",
+					snapshot.CallsInProgress, snapshot.Answered, snapshot.AverageWait, test.wantInProgress, test.wantAnswered, test.wantAverageWait)
+			}
+			if !reflect.DeepEqual(snapshot.Queues, test.wantQueues) {
+				t.Errorf("Got queues %+v, want %+v", snapshot.Queues, test.wantQueues)
+			}
+		})
+	}
+}
+
+//TestWallboardRun checks that snapshots are published each interval, in
+//their JSON form with durations in seconds.
+func TestWallboardRun(t *testing.T) {
+	clock := fsclienttest.NewClock(time.Unix(0, 0).UTC())
+	wallboard := fsclient.NewWallboard(time.Minute)
+	wallboard.SetClock(clock)
+	wallboard.HandleEvent(fsclient.Event(queueEvent("member-queue-start", callerUUID, "")))
+
+	snapshots := make(chan fsclient.WallboardSnapshot)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		wallboard.Run(10*time.Second, stop, func(snapshot fsclient.WallboardSnapshot) {
+			snapshots <- snapshot
+		})
+		close(done)
+	}()
+
+	clock.WaitForTimers(1)
+	clock.Advance(10 * time.Second)
+	snapshot := <-snapshots
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"time":"1970-01-01T00:00:10Z","window_sec":60,"calls_in_progress":0,"answered":0,"average_wait_sec":0,` +
+		`"queues":[{"queue":"support@default","waiting":1,"longest_wait_sec":10,"answered":0,"abandoned":0,"average_wait_sec":0}]}`
+	if string(data) != want {
+		t.Errorf("Got %s, want %s", data, want)
+	}
+
+	close(stop)
+	<-done
+}
@@ -0,0 +1,265 @@
+//Package xmlcurl builds Freeswitch dialplan and directory XML documents from
+//Go structs, for mod_xml_curl HTTP handlers, so a service that also controls
+//calls over the event socket can use one typed model of its extensions and
+//users:
+//
+//	http.Handle("/freeswitch", xmlcurl.Handler(func(req xmlcurl.Request) ([]byte, error) {
+//		if req.Section != "dialplan" {
+//			return xmlcurl.NotFound(), nil
+//		}
+//		return xmlcurl.DialplanDocument(xmlcurl.Context{
+//			Name: req.Params.Get("Caller-Context"),
+//			Extensions: []xmlcurl.Extension{{
+//				Name: "park",
+//				Conditions: []xmlcurl.Condition{{
+//					Field:      "destination_number",
+//					Expression: "^1000$",
+//					Actions:    []xmlcurl.Action{{Application: "park"}},
+//				}},
+//			}},
+//		})
+//	}))
+//
+//Freeswitch treats a reply it can't parse as not found, so handlers
+//returning an error reply with a not found document too.
+package xmlcurl
+
+import (
+	"encoding/xml"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+//logPrefix is the prefix of messages logged by the package.
+var logPrefix = "fsclient/xmlcurl: "
+
+//Context is a dialplan context.
+type Context struct {
+	Name       string      `xml:"name,attr"`
+	Extensions []Extension `xml:"extension"`
+}
+
+//Extension is a dialplan extension. Continue carries on to the next
+//extension after this one matches.
+type Extension struct {
+	Name       string      `xml:"name,attr"`
+	Continue   bool        `xml:"continue,attr,omitempty"`
+	Conditions []Condition `xml:"condition"`
+}
+
+//Condition is an extension's condition. An empty Field and Expression always
+//matches. Break is "on-false" (the default), "on-true", "always" or "never".
+//AntiActions run when the condition doesn't match.
+type Condition struct {
+	Field       string   `xml:"field,attr,omitempty"`
+	Expression  string   `xml:"expression,attr,omitempty"`
+	Break       string   `xml:"break,attr,omitempty"`
+	Actions     []Action `xml:"action"`
+	AntiActions []Action `xml:"anti-action"`
+}
+
+//Action is an application run by a condition. Inline actions run while the
+//dialplan is being parsed, e.g. to set variables used by later conditions.
+type Action struct {
+	Application string `xml:"application,attr"`
+	Data        string `xml:"data,attr,omitempty"`
+	Inline      bool   `xml:"inline,attr,omitempty"`
+}
+
+//Domain is a directory domain. Users can be listed directly or in groups.
+type Domain struct {
+	Name      string     `xml:"name,attr"`
+	Params    []Param    `xml:"params>param,omitempty"`
+	Variables []Variable `xml:"variables>variable,omitempty"`
+	Users     []User     `xml:"users>user,omitempty"`
+	Groups    []Group    `xml:"groups>group,omitempty"`
+}
+
+//Group is a group of users in a Domain.
+type Group struct {
+	Name  string `xml:"name,attr"`
+	Users []User `xml:"users>user"`
+}
+
+//User is a directory user. Params hold settings such as "password" or
+//"a1-hash", and Variables channel variables set on the user's calls, such as
+//"user_context".
+type User struct {
+	ID          string     `xml:"id,attr"`
+	NumberAlias string     `xml:"number-alias,attr,omitempty"`
+	Params      []Param    `xml:"params>param,omitempty"`
+	Variables   []Variable `xml:"variables>variable,omitempty"`
+}
+
+//Param is a directory parameter.
+type Param struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+//Variable is a directory channel variable.
+type Variable struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+//paramList, variableList, userList and groupList are the lists nested in a
+//Domain or User, which are left out when empty.
+type paramList struct {
+	Params []Param `xml:"param"`
+}
+
+type variableList struct {
+	Variables []Variable `xml:"variable"`
+}
+
+type userList struct {
+	Users []User `xml:"user"`
+}
+
+type groupList struct {
+	Groups []Group `xml:"group"`
+}
+
+//MarshalXML encodes the domain, leaving out empty lists.
+func (domain Domain) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	shadow := struct {
+		Name      string        `xml:"name,attr"`
+		Params    *paramList    `xml:"params"`
+		Variables *variableList `xml:"variables"`
+		Users     *userList     `xml:"users"`
+		Groups    *groupList    `xml:"groups"`
+	}{Name: domain.Name}
+	if len(domain.Params) > 0 {
+		shadow.Params = &paramList{Params: domain.Params}
+	}
+	if len(domain.Variables) > 0 {
+		shadow.Variables = &variableList{Variables: domain.Variables}
+	}
+	if len(domain.Users) > 0 {
+		shadow.Users = &userList{Users: domain.Users}
+	}
+	if len(domain.Groups) > 0 {
+		shadow.Groups = &groupList{Groups: domain.Groups}
+	}
+	return e.EncodeElement(shadow, start)
+}
+
+//MarshalXML encodes the user, leaving out empty lists.
+func (user User) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	shadow := struct {
+		ID          string        `xml:"id,attr"`
+		NumberAlias string        `xml:"number-alias,attr,omitempty"`
+		Params      *paramList    `xml:"params"`
+		Variables   *variableList `xml:"variables"`
+	}{ID: user.ID, NumberAlias: user.NumberAlias}
+	if len(user.Params) > 0 {
+		shadow.Params = &paramList{Params: user.Params}
+	}
+	if len(user.Variables) > 0 {
+		shadow.Variables = &variableList{Variables: user.Variables}
+	}
+	return e.EncodeElement(shadow, start)
+}
+
+//document is the root of a mod_xml_curl reply.
+type document struct {
+	XMLName xml.Name `xml:"document"`
+	Type    string   `xml:"type,attr"`
+	Section section  `xml:"section"`
+}
+
+//section is the section of a document, holding one kind of content.
+type section struct {
+	Name     string    `xml:"name,attr"`
+	Contexts []Context `xml:"context,omitempty"`
+	Domains  []Domain  `xml:"domain,omitempty"`
+	Result   *result   `xml:"result,omitempty"`
+}
+
+//result is the content of a not found document.
+type result struct {
+	Status string `xml:"status,attr"`
+}
+
+//DialplanDocument returns a dialplan document with the contexts.
+func DialplanDocument(contexts ...Context) ([]byte, error) {
+	if len(contexts) == 0 {
+		return nil, errors.New("Dialplan document has no contexts")
+	}
+	return marshal(section{Name: "dialplan", Contexts: contexts})
+}
+
+//DirectoryDocument returns a directory document with the domains.
+func DirectoryDocument(domains ...Domain) ([]byte, error) {
+	if len(domains) == 0 {
+		return nil, errors.New("Directory document has no domains")
+	}
+	return marshal(section{Name: "directory", Domains: domains})
+}
+
+//NotFound returns the document that tells Freeswitch there is no result, so
+//it carries on to its next source of configuration.
+func NotFound() []byte {
+	doc, _ := marshal(section{Name: "result", Result: &result{Status: "not found"}})
+	return doc
+}
+
+//marshal encodes a document with a single section.
+func marshal(content section) ([]byte, error) {
+	doc, err := xml.MarshalIndent(document{Type: "freeswitch/xml", Section: content}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), doc...), nil
+}
+
+//Request is a mod_xml_curl request. Section is e.g. "dialplan" or
+//"directory", and Params holds all the posted fields, such as
+//"Caller-Context" and "destination_number" for a dialplan lookup, or "user"
+//and "domain" for a directory lookup.
+type Request struct {
+	Section  string
+	TagName  string
+	KeyName  string
+	KeyValue string
+	Params   url.Values
+}
+
+//ParseRequest reads the fields mod_xml_curl posts with each lookup.
+func ParseRequest(r *http.Request) (Request, error) {
+	if err := r.ParseForm(); err != nil {
+		return Request{}, err
+	}
+	return Request{
+		Section:  r.Form.Get("section"),
+		TagName:  r.Form.Get("tag_name"),
+		KeyName:  r.Form.Get("key_name"),
+		KeyValue: r.Form.Get("key_value"),
+		Params:   r.Form,
+	}, nil
+}
+
+//Handler returns an http.Handler that parses each request and replies with
+//the document returned by lookup. An error is logged and replied to with a
+//not found document.
+func Handler(lookup func(req Request) ([]byte, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc := NotFound()
+		req, err := ParseRequest(r)
+		if err == nil {
+			var found []byte
+			if found, err = lookup(req); err == nil && found != nil {
+				doc = found
+			}
+		}
+		if err != nil {
+			log.Print(logPrefix, "Lookup failed: ", err)
+		}
+
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write(doc)
+	})
+}
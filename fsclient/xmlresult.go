@@ -0,0 +1,145 @@
+package fsclient
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+)
+
+//ConferenceList is the result of "conference xml_list".
+type ConferenceList struct {
+	Conferences []Conference `xml:"conference"`
+}
+
+//Conference is a conference in a ConferenceList. The flags are only true if
+//Freeswitch sets them, and RunTime is in seconds.
+type Conference struct {
+	Name        string             `xml:"name,attr"`
+	UUID        string             `xml:"uuid,attr"`
+	MemberCount int                `xml:"member-count,attr"`
+	GhostCount  int                `xml:"ghost-count,attr"`
+	Rate        int                `xml:"rate,attr"`
+	RunTime     int                `xml:"run_time,attr"`
+	Running     bool               `xml:"running,attr"`
+	Answered    bool               `xml:"answered,attr"`
+	Locked      bool               `xml:"locked,attr"`
+	Dynamic     bool               `xml:"dynamic,attr"`
+	Recording   bool               `xml:"recording,attr"`
+	Members     []ConferenceMember `xml:"members>member"`
+}
+
+//ConferenceMember is a member of a Conference. Type is "caller", or
+//"recording_node" for a recording. JoinTime and LastTalking are in seconds.
+type ConferenceMember struct {
+	Type           string `xml:"type,attr"`
+	ID             int    `xml:"id"`
+	UUID           string `xml:"uuid"`
+	CallerIDName   string `xml:"caller_id_name"`
+	CallerIDNumber string `xml:"caller_id_number"`
+	JoinTime       int    `xml:"join_time"`
+	LastTalking    int    `xml:"last_talking"`
+	Energy         int    `xml:"energy"`
+	VolumeIn       int    `xml:"volume_in"`
+	VolumeOut      int    `xml:"volume_out"`
+	CanHear        bool   `xml:"flags>can_hear"`
+	CanSpeak       bool   `xml:"flags>can_speak"`
+	Talking        bool   `xml:"flags>talking"`
+	HasFloor       bool   `xml:"flags>has_floor"`
+	IsModerator    bool   `xml:"flags>is_moderator"`
+	EndConference  bool   `xml:"flags>end_conference"`
+}
+
+//SofiaStatus is the result of "sofia xmlstatus": the SIP profiles, gateways
+//and aliases.
+type SofiaStatus struct {
+	Profiles []SofiaEntry `xml:"profile"`
+	Gateways []SofiaEntry `xml:"gateway"`
+	Aliases  []SofiaEntry `xml:"alias"`
+}
+
+//SofiaEntry is a profile, gateway or alias in a SofiaStatus. Data is the
+//profile's SIP URI or the gateway's, and State e.g. "RUNNING (0)" for a
+//profile or "REGED" for a gateway.
+type SofiaEntry struct {
+	Name  string `xml:"name"`
+	Type  string `xml:"type"`
+	Data  string `xml:"data"`
+	State string `xml:"state"`
+}
+
+//SofiaGateway is the result of "sofia xmlstatus gateway <name>". State is the
+//registration state, e.g. "REGED", and Status "UP" or "DOWN" from options
+//pings.
+type SofiaGateway struct {
+	Name           string `xml:"name"`
+	Profile        string `xml:"profile"`
+	Scheme         string `xml:"scheme"`
+	Realm          string `xml:"realm"`
+	Username       string `xml:"username"`
+	From           string `xml:"from"`
+	Contact        string `xml:"contact"`
+	Proxy          string `xml:"proxy"`
+	Context        string `xml:"context"`
+	Expires        int    `xml:"expires"`
+	Freq           int    `xml:"freq"`
+	State          string `xml:"state"`
+	Status         string `xml:"status"`
+	CallsIn        int    `xml:"calls-in"`
+	CallsOut       int    `xml:"calls-out"`
+	FailedCallsIn  int    `xml:"failed-calls-in"`
+	FailedCallsOut int    `xml:"failed-calls-out"`
+}
+
+//ParseXMLResult decodes the XML result of an api command, such as
+//"conference xml_list", into T. A -ERR reply, or any other reply that isn't
+//XML such as the usage text of a command given bad arguments, is returned as
+//an error.
+func ParseXMLResult[T any](res string) (T, error) {
+	var result T
+
+	trimmed := strings.TrimSpace(res)
+	if !strings.HasPrefix(trimmed, "<") {
+		line, _, _ := strings.Cut(trimmed, "\n")
+		if line == "" {
+			line = "Empty reply"
+		}
+		return result, errors.New(line)
+	}
+
+	if err := xml.Unmarshal([]byte(trimmed), &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+//APIXML sends an api command and decodes its XML result with ParseXMLResult.
+func APIXML[T any](client Commander, cmd string) (T, error) {
+	res, err := client.API(cmd)
+	if err != nil {
+		var result T
+		return result, err
+	}
+	return ParseXMLResult[T](res)
+}
+
+//Conferences returns the running conferences and their members.
+func Conferences(client Commander) ([]Conference, error) {
+	list, err := APIXML[ConferenceList](client, "conference xml_list")
+	if err != nil {
+		return nil, err
+	}
+	return list.Conferences, nil
+}
+
+//SofiaXMLStatus returns the status of the SIP profiles and gateways.
+func SofiaXMLStatus(client Commander) (SofiaStatus, error) {
+	return APIXML[SofiaStatus](client, "sofia xmlstatus")
+}
+
+//SofiaGatewayStatus returns the status of a SIP gateway.
+func SofiaGatewayStatus(client Commander, name string) (SofiaGateway, error) {
+	if name == "" || strings.ContainsAny(name, " \r\n") {
+		return SofiaGateway{}, errors.New("Invalid gateway name: " + name)
+	}
+	return APIXML[SofiaGateway](client, "sofia xmlstatus gateway "+name)
+}
@@ -0,0 +1,15 @@
+module github.com/tomponline/fsclient
+
+go 1.26.0
+
+require (
+	golang.org/x/net v0.59.0
+	google.golang.org/grpc v1.84.0
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	golang.org/x/sys v0.48.0 // indirect
+	golang.org/x/text v0.42.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260706201446-f0a921348800 // indirect
+)